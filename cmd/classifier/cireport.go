@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+// ciReport collects what a pass reports for CI systems: -junit writes it
+// as a JUnit XML file and -github-annotations prints it as GitHub Actions
+// workflow commands, so scheduled jobs show failures in the pipeline UI.
+type ciReport struct {
+	junit       string
+	annotations bool
+
+	mu       sync.Mutex
+	warnings []string
+}
+
+// enabled reports whether any CI output was asked for; a nil report
+// asks for none.
+func (c *ciReport) enabled() bool {
+	return c != nil && (c.junit != "" || c.annotations)
+}
+
+// collect returns a Warnf for the engine that records every warning and
+// passes it on to next.
+func (c *ciReport) collect(next func(string, ...any)) func(string, ...any) {
+	return func(format string, args ...any) {
+		c.mu.Lock()
+		c.warnings = append(c.warnings, fmt.Sprintf(format, args...))
+		c.mu.Unlock()
+		if next != nil {
+			next(format, args...)
+		}
+	}
+}
+
+// junitSuites is the root of a JUnit XML report.
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitCase     `xml:"testcase"`
+	SystemErr  string          `xml:"system-err,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// write puts out the report of a pass that started at started and ended
+// with res and runErr.
+func (c *ciReport) write(opts engine.Options, started time.Time, res engine.Result, runErr error) error {
+	c.mu.Lock()
+	warnings := c.warnings
+	c.warnings = nil
+	c.mu.Unlock()
+	if c.annotations {
+		printAnnotations(os.Stdout, res, runErr, warnings)
+	}
+	if c.junit == "" {
+		return nil
+	}
+
+	// The run itself is one test case, every file that failed another.
+	run := junitCase{Name: "run " + res.RunID, ClassName: "classifier"}
+	if runErr != nil {
+		run.Error = &junitProblem{Message: runErr.Error()}
+	}
+	suite := junitSuite{
+		Name:      "classifier " + opts.Source + " -> " + opts.Dest,
+		Tests:     1 + len(res.Failures),
+		Failures:  len(res.Failures),
+		Time:      fmt.Sprintf("%.3f", time.Since(started).Seconds()),
+		Timestamp: started.UTC().Format(time.RFC3339),
+		Properties: []junitProperty{
+			{Name: "run_id", Value: res.RunID},
+			{Name: "status", Value: res.Status},
+			{Name: "copied", Value: fmt.Sprint(res.Copied)},
+			{Name: "duplicates", Value: fmt.Sprint(res.Duplicates)},
+			{Name: "warnings", Value: fmt.Sprint(len(warnings))},
+		},
+		Cases:     []junitCase{run},
+		SystemErr: strings.Join(warnings, "\n"),
+	}
+	if runErr != nil {
+		suite.Errors = 1
+	}
+	for _, f := range res.Failures {
+		suite.Cases = append(suite.Cases, junitCase{
+			Name:      f.Src,
+			ClassName: "classifier.file",
+			Failure:   &junitProblem{Message: f.Err.Error(), Text: f.Src + " -> " + f.Dest},
+		})
+	}
+
+	out, err := os.Create(c.junit)
+	if err != nil {
+		return fmt.Errorf("write JUnit report: %w", err)
+	}
+	defer out.Close()
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return fmt.Errorf("write JUnit report: %w", err)
+	}
+	enc := xml.NewEncoder(out)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitSuites{Suites: []junitSuite{suite}}); err != nil {
+		return fmt.Errorf("write JUnit report: %w", err)
+	}
+	if _, err := io.WriteString(out, "\n"); err != nil {
+		return fmt.Errorf("write JUnit report: %w", err)
+	}
+	return out.Close()
+}
+
+// printAnnotations prints the failures and warnings of a pass as GitHub
+// Actions workflow commands.
+func printAnnotations(w io.Writer, res engine.Result, runErr error, warnings []string) {
+	for _, f := range res.Failures {
+		fmt.Fprintf(w, "::error file=%s,title=classifier::%s\n", escapeProperty(f.Src), escapeData(f.Src+" -> "+f.Dest+": "+f.Err.Error()))
+	}
+	for _, msg := range warnings {
+		fmt.Fprintf(w, "::warning title=classifier::%s\n", escapeData(msg))
+	}
+	if runErr != nil {
+		fmt.Fprintf(w, "::error title=classifier::%s\n", escapeData("run failed: "+runErr.Error()))
+	}
+}
+
+// escapeData escapes the message of a workflow command.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a property value of a workflow command.
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/xml"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLI_JUnitAndGitHubAnnotationsReportFailures(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("needs sh")
+	}
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "notes.txt", "notes")
+	writeFile(t, src, "broken.log", "log")
+	writeFile(t, workspace, "config.yaml", `categories:
+  - name: documents
+    extensions: [txt]
+  - name: logs
+    extensions: [log]
+    post_command: [sh, -c, 'echo "scrub failed, again" >&2; exit 3']
+    on_post_failure: fail
+`)
+	junit := filepath.Join(workspace, "report.xml")
+
+	res := runCLI(t, workspace, "-config", filepath.Join(workspace, "config.yaml"), "-junit", junit, "-github-annotations", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	var report junitSuites
+	if err := xml.Unmarshal([]byte(readFile(t, junit)), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Suites) != 1 {
+		t.Fatalf("expected 1 test suite, got %+v", report)
+	}
+	suite := report.Suites[0]
+	if suite.Tests != 2 || suite.Failures != 1 || suite.Errors != 0 || len(suite.Cases) != 2 {
+		t.Fatalf("expected the run and one failed file, got %+v", suite)
+	}
+	failed := suite.Cases[1]
+	if failed.Name != absPath(t, filepath.Join(src, "broken.log")) || failed.Failure == nil || !strings.Contains(failed.Failure.Message, "scrub failed") {
+		t.Fatalf("unexpected failed test case: %+v", failed)
+	}
+	if suite.Cases[0].Failure != nil || suite.Cases[0].Error != nil {
+		t.Fatalf("expected the run itself to pass, got %+v", suite.Cases[0])
+	}
+
+	want := "::error file=" + escapeProperty(absPath(t, filepath.Join(src, "broken.log"))) + ",title=classifier::"
+	if !strings.Contains(res.stdout, want) || !strings.Contains(res.stdout, "scrub failed, again") {
+		t.Fatalf("expected an error annotation for broken.log, got stdout: %s", res.stdout)
+	}
+
+	res = runCLI(t, workspace, "-junit", junit, "-watch", "1m", absPath(t, src), absPath(t, dest))
+	if res.exitCode == 0 || !strings.Contains(res.stderr, "cannot be combined with -watch") {
+		t.Fatalf("expected a usage error, got exit %d, stderr: %s", res.exitCode, res.stderr)
+	}
+}
+
+func TestEscapeProperty(t *testing.T) {
+	if got, want := escapeProperty("C:\\a,b%\n"), "C%3A\\a%2Cb%25%0A"; got != want {
+		t.Fatalf("escapeProperty = %q, want %q", got, want)
+	}
+	if got, want := escapeData("a: b,\r\n"), "a: b,%0D%0A"; got != want {
+		t.Fatalf("escapeData = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+// configCommands are the subcommands of `classifier config`.
+var configCommands = map[string]func(context.Context, []string) error{
+	"doctor": runConfigDoctor,
+	"schema": runConfigSchema,
+}
+
+var configUsage = strings.Join([]string{configDoctorUsage, configSchemaUsage}, "\n       ")
+
+const configSchemaUsage = "usage: classifier config schema"
+
+// runConfigSchema prints the JSON Schema of the config file, e.g. for the
+// yaml-language-server: # yaml-language-server: $schema=config.schema.json
+func runConfigSchema(ctx context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("config schema", flag.ContinueOnError)
+	if err := parseFlags(flagSet, args, configSchemaUsage); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 0 {
+		return errors.New("unexpected arguments; " + configSchemaUsage)
+	}
+	_, err := os.Stdout.Write(engine.ConfigSchema)
+	return err
+}
+
+func runConfig(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := configCommands[args[0]]; ok {
+			return cmd(ctx, args[1:])
+		}
+	}
+	return errors.New("expected a config subcommand; " + configUsage)
+}
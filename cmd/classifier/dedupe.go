@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+const dedupeUsage = "usage: classifier dedupe [-mode report|hardlink|delete|similar|perceptual] [-threshold score] [-keep first|shortest|oldest|newest] [-trash] <dest-abs-dir>"
+
+// keepStrategies order the copies of one content so that the copy to keep
+// comes first. Ties fall back to the path.
+var keepStrategies = map[string]func(a, b fileEntry) bool{
+	"first": func(a, b fileEntry) bool { return a.path < b.path },
+	"shortest": func(a, b fileEntry) bool {
+		if len(a.path) != len(b.path) {
+			return len(a.path) < len(b.path)
+		}
+		return a.path < b.path
+	},
+	"oldest": func(a, b fileEntry) bool {
+		if !a.info.ModTime().Equal(b.info.ModTime()) {
+			return a.info.ModTime().Before(b.info.ModTime())
+		}
+		return a.path < b.path
+	},
+	"newest": func(a, b fileEntry) bool {
+		if !a.info.ModTime().Equal(b.info.ModTime()) {
+			return a.info.ModTime().After(b.info.ModTime())
+		}
+		return a.path < b.path
+	},
+}
+
+// runDedupe finds byte-identical files anywhere under an existing
+// destination and reports them, replaces the extra copies with hard links to
+// the kept one, or deletes them. Each duplicate is printed to stdout as a
+// "duplicate,kept" CSV row. Mode similar instead reports photos that are
+// probably the same shot saved twice, e.g. a re-encoded export, and mode
+// perceptual reports pairs of images that look alike with a similarity
+// score; neither changes anything.
+func runDedupe(ctx context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("dedupe", flag.ContinueOnError)
+	var mode, keep string
+	flagSet.StringVar(&mode, "mode", "report", "what to do with duplicates: report, hardlink or delete; similar reports JPEGs with the same EXIF capture time, camera model and pixel size but different content; perceptual reports pairs of GIF, JPEG and PNG images that look alike")
+	var threshold float64
+	flagSet.Float64Var(&threshold, "threshold", engine.DefaultSimilarity, "with -mode perceptual, the similarity score from 0 to 1 a pair needs to be reported")
+	flagSet.StringVar(&keep, "keep", "first", "which copy to keep: first (by path), shortest (path), oldest or newest (by mtime)")
+	var useTrash bool
+	flagSet.BoolVar(&useTrash, "trash", false, "with -mode delete, move duplicates to the OS trash")
+	if err := parseFlags(flagSet, args, dedupeUsage); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return errors.New("expected 1 argument; " + dedupeUsage)
+	}
+	dest := flagSet.Arg(0)
+	if !filepath.IsAbs(dest) {
+		return errors.New("destination must be an absolute path; " + dedupeUsage)
+	}
+	if mode != "report" && mode != "hardlink" && mode != "delete" && mode != "similar" && mode != "perceptual" {
+		return fmt.Errorf("unknown -mode %q; %s", mode, dedupeUsage)
+	}
+	less, ok := keepStrategies[keep]
+	if !ok {
+		return fmt.Errorf("unknown -keep %q; %s", keep, dedupeUsage)
+	}
+
+	if mode == "similar" {
+		return reportSimilar(ctx, dest, less)
+	}
+	if mode == "perceptual" {
+		if threshold < 0 || threshold > 1 {
+			return fmt.Errorf("-threshold must be between 0 and 1; %s", dedupeUsage)
+		}
+		return reportPerceptual(ctx, dest, threshold, less)
+	}
+
+	groups, err := duplicateGroups(ctx, dest)
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(os.Stdout)
+	var count int
+	var reclaimed int64
+	manifest := filepath.Join(dest, engine.StateDir, "deleted.csv")
+	for _, g := range groups {
+		sort.Slice(g.files, func(i, j int) bool { return less(g.files[i], g.files[j]) })
+		kept := g.files[0]
+		for _, dup := range g.files[1:] {
+			if os.SameFile(kept.info, dup.info) {
+				// Already a hard link to the kept copy.
+				continue
+			}
+			switch mode {
+			case "hardlink":
+				if err := replaceWithLink(kept.path, dup.path); err != nil {
+					return err
+				}
+			case "delete":
+				trashed, err := engine.DisposeFile(dup.path, useTrash)
+				if err != nil {
+					return fmt.Errorf("delete duplicate %s: %w", dup.path, err)
+				}
+				if err := engine.LogDeletion(manifest, dup.path, kept.path, g.hash, trashed); err != nil {
+					return err
+				}
+			}
+			if err := w.Write([]string{dup.path, kept.path}); err != nil {
+				return err
+			}
+			count++
+			reclaimed += dup.size
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	verb := "reclaimable"
+	if mode != "report" {
+		verb = "reclaimed"
+	}
+	fmt.Fprintf(os.Stderr, "%d duplicates, %s %s\n", count, engine.FormatBytes(uint64(reclaimed)), verb)
+	return nil
+}
+
+type duplicateGroup struct {
+	hash  string
+	files []fileEntry
+}
+
+// duplicateGroups hashes files under root that share their size with another
+// file and returns the sets of byte-identical files, ordered by first path.
+func duplicateGroups(ctx context.Context, root string) ([]duplicateGroup, error) {
+	files, err := listFiles(root)
+	if err != nil {
+		return nil, fmt.Errorf("read destination: %w", err)
+	}
+
+	bySize := make(map[int64][]fileEntry)
+	for _, f := range files {
+		bySize[f.size] = append(bySize[f.size], f)
+	}
+
+	byHash := make(map[string][]fileEntry)
+	var order []string
+	for _, f := range files {
+		if len(bySize[f.size]) < 2 {
+			continue
+		}
+		hash, err := engine.FileHash(ctx, f.path)
+		if err != nil {
+			return nil, err
+		}
+		if _, seen := byHash[hash]; !seen {
+			order = append(order, hash)
+		}
+		byHash[hash] = append(byHash[hash], f)
+	}
+
+	var groups []duplicateGroup
+	for _, hash := range order {
+		if len(byHash[hash]) > 1 {
+			groups = append(groups, duplicateGroup{hash: hash, files: byHash[hash]})
+		}
+	}
+	return groups, nil
+}
+
+// reportSimilar prints a "probable,kept" CSV row for every photo of a
+// similarGroups group but the one less puts first.
+func reportSimilar(ctx context.Context, dest string, less func(a, b fileEntry) bool) error {
+	groups, err := similarGroups(ctx, dest)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(os.Stdout)
+	var count int
+	for _, g := range groups {
+		sort.Slice(g, func(i, j int) bool { return less(g[i], g[j]) })
+		for _, f := range g[1:] {
+			if err := w.Write([]string{f.path, g[0].path}); err != nil {
+				return err
+			}
+			count++
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d probable duplicates in %d groups; review them by hand\n", count, len(groups))
+	return nil
+}
+
+// similarGroups returns the sets of JPEGs under root that share their EXIF
+// capture time and camera model and their pixel size but differ in content,
+// ordered by first path. Of byte-identical files only the first is kept, as
+// those are exact duplicates.
+func similarGroups(ctx context.Context, root string) ([][]fileEntry, error) {
+	files, err := listFiles(root)
+	if err != nil {
+		return nil, fmt.Errorf("read destination: %w", err)
+	}
+
+	byKey := make(map[string][]fileEntry)
+	var order []string
+	for _, f := range files {
+		x, err := engine.ReadExif(f.path)
+		if err != nil || x.DateTimeOriginal == "" || x.Model == "" {
+			continue
+		}
+		w, h, err := engine.ImageDimensions(f.path)
+		if err != nil {
+			continue
+		}
+		key := fmt.Sprintf("%s\x00%s\x00%dx%d", x.DateTimeOriginal, x.Model, w, h)
+		if _, seen := byKey[key]; !seen {
+			order = append(order, key)
+		}
+		byKey[key] = append(byKey[key], f)
+	}
+
+	var groups [][]fileEntry
+	for _, key := range order {
+		if len(byKey[key]) < 2 {
+			continue
+		}
+		seen := make(map[string]bool)
+		var distinct []fileEntry
+		for _, f := range byKey[key] {
+			hash, err := engine.FileHash(ctx, f.path)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[hash] {
+				seen[hash] = true
+				distinct = append(distinct, f)
+			}
+		}
+		if len(distinct) > 1 {
+			groups = append(groups, distinct)
+		}
+	}
+	return groups, nil
+}
+
+// lookalike is an image with its perceptual hash.
+type lookalike struct {
+	fileEntry
+	hash          uint64
+	width, height int
+}
+
+// reportPerceptual prints a "image,kept,score,resolution,size,kept
+// resolution,kept size" CSV row for every pair of images under dest whose
+// similarity is at least threshold, most alike first. Pairs of
+// byte-identical files are left out, as those are exact duplicates.
+func reportPerceptual(ctx context.Context, dest string, threshold float64, less func(a, b fileEntry) bool) error {
+	files, err := listFiles(dest)
+	if err != nil {
+		return fmt.Errorf("read destination: %w", err)
+	}
+	var images []lookalike
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, _, ok := engine.ArchivedPath(dest, f.path); !ok {
+			continue
+		}
+		hash, err := engine.PerceptualHash(f.path)
+		if err != nil {
+			continue
+		}
+		w, h, err := engine.ImageDimensions(f.path)
+		if err != nil {
+			continue
+		}
+		images = append(images, lookalike{fileEntry: f, hash: hash, width: w, height: h})
+	}
+
+	type pair struct {
+		a, b  lookalike
+		score float64
+	}
+	var pairs []pair
+	for i := range images {
+		for j := i + 1; j < len(images); j++ {
+			a, b := images[i], images[j]
+			score := engine.Similarity(a.hash, b.hash)
+			if score < threshold {
+				continue
+			}
+			same, err := sameContent(ctx, a.fileEntry, b.fileEntry)
+			if err != nil {
+				return err
+			}
+			if same {
+				continue
+			}
+			if less(b.fileEntry, a.fileEntry) {
+				a, b = b, a
+			}
+			pairs = append(pairs, pair{a: a, b: b, score: score})
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].score > pairs[j].score })
+
+	w := csv.NewWriter(os.Stdout)
+	for _, p := range pairs {
+		if err := w.Write([]string{
+			p.b.path, p.a.path, strconv.FormatFloat(p.score, 'f', 2, 64),
+			fmt.Sprintf("%dx%d", p.b.width, p.b.height), strconv.FormatInt(p.b.size, 10),
+			fmt.Sprintf("%dx%d", p.a.width, p.a.height), strconv.FormatInt(p.a.size, 10),
+		}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d similar pairs at a score of %.2f or more; review them by hand\n", len(pairs), threshold)
+	return nil
+}
+
+// sameContent reports whether a and b are the same file or byte-identical.
+func sameContent(ctx context.Context, a, b fileEntry) (bool, error) {
+	if os.SameFile(a.info, b.info) {
+		return true, nil
+	}
+	if a.size != b.size {
+		return false, nil
+	}
+	ha, err := engine.FileHash(ctx, a.path)
+	if err != nil {
+		return false, err
+	}
+	hb, err := engine.FileHash(ctx, b.path)
+	if err != nil {
+		return false, err
+	}
+	return ha == hb, nil
+}
+
+// replaceWithLink atomically replaces dup with a hard link to kept.
+func replaceWithLink(kept, dup string) error {
+	tmp := filepath.Join(filepath.Dir(dup), "."+filepath.Base(dup)+".classifier-link")
+	if err := os.Link(kept, tmp); err != nil {
+		return fmt.Errorf("hard link %s: %w", dup, err)
+	}
+	if err := os.Rename(tmp, dup); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("hard link %s: %w", dup, err)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLI_DedupeModes(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		// check inspects the destination after the run.
+		check func(t *testing.T, kept, dup string)
+	}{
+		{
+			name: "report leaves files alone",
+			args: nil,
+			check: func(t *testing.T, kept, dup string) {
+				assertFileContent(t, dup, "same")
+			},
+		},
+		{
+			name: "hardlink replaces duplicates with links",
+			args: []string{"-mode", "hardlink"},
+			check: func(t *testing.T, kept, dup string) {
+				keptInfo, err := os.Stat(kept)
+				if err != nil {
+					t.Fatalf("stat kept: %v", err)
+				}
+				dupInfo, err := os.Stat(dup)
+				if err != nil {
+					t.Fatalf("stat duplicate: %v", err)
+				}
+				if !os.SameFile(keptInfo, dupInfo) {
+					t.Fatalf("expected %s to be a hard link to %s", dup, kept)
+				}
+			},
+		},
+		{
+			name: "delete removes duplicates",
+			args: []string{"-mode", "delete"},
+			check: func(t *testing.T, kept, dup string) {
+				if _, err := os.Stat(dup); err == nil {
+					t.Fatalf("expected %s to be deleted", dup)
+				}
+				assertFileContent(t, kept, "same")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workspace := t.TempDir()
+			dest := filepath.Join(workspace, "dest")
+			mustMkdir(t, filepath.Join(dest, "documents"))
+			mustMkdir(t, filepath.Join(dest, "others", "nested"))
+			writeFile(t, filepath.Join(dest, "documents"), "a.txt", "same")
+			writeFile(t, filepath.Join(dest, "others", "nested"), "b.bin", "same")
+			writeFile(t, filepath.Join(dest, "documents"), "c.txt", "diff")
+
+			kept := filepath.Join(dest, "documents", "a.txt")
+			dup := filepath.Join(dest, "others", "nested", "b.bin")
+
+			args := append([]string{"dedupe"}, tt.args...)
+			res := runCLI(t, workspace, append(args, absPath(t, dest))...)
+			if res.err != nil {
+				t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+			}
+			if got := strings.TrimSpace(res.stdout); got != dup+","+kept {
+				t.Fatalf("unexpected duplicates output: %q", got)
+			}
+			assertFileContent(t, filepath.Join(dest, "documents", "c.txt"), "diff")
+			tt.check(t, kept, dup)
+		})
+	}
+}
+
+func TestCLI_DedupeKeepShortest(t *testing.T) {
+	workspace := t.TempDir()
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, filepath.Join(dest, "aaa", "deep"))
+	mustMkdir(t, filepath.Join(dest, "z"))
+	writeFile(t, filepath.Join(dest, "aaa", "deep"), "long.txt", "same")
+	writeFile(t, filepath.Join(dest, "z"), "s.txt", "same")
+
+	res := runCLI(t, workspace, "dedupe", "-keep", "shortest", absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	want := filepath.Join(dest, "aaa", "deep", "long.txt") + "," + filepath.Join(dest, "z", "s.txt")
+	if got := strings.TrimSpace(res.stdout); got != want {
+		t.Fatalf("unexpected duplicates output:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+// jpegWithExif encodes a gray JPEG at quality q with an EXIF block giving
+// the camera model and capture time.
+func jpegWithExif(t *testing.T, model, taken string, q int) string {
+	t.Helper()
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 32, 24)), &jpeg.Options{Quality: q}); err != nil {
+		t.Fatal(err)
+	}
+	le := binary.LittleEndian
+	entry := func(b []byte, tag, typ uint16, count, value uint32) []byte {
+		b = le.AppendUint16(b, tag)
+		b = le.AppendUint16(b, typ)
+		b = le.AppendUint32(b, count)
+		return le.AppendUint32(b, value)
+	}
+	model += "\x00"
+	taken += "\x00"
+	// Header, IFD0 at 8 with two entries, the Exif IFD with one, then the
+	// two strings.
+	ifd0, exifIFD := uint32(8), uint32(8+2+24+4)
+	modelOff := exifIFD + 2 + 12 + 4
+	takenOff := modelOff + uint32(len(model))
+	tiff := []byte("II*\x00")
+	tiff = le.AppendUint32(tiff, ifd0)
+	tiff = le.AppendUint16(tiff, 2)
+	tiff = entry(tiff, 0x0110, 2, uint32(len(model)), modelOff)
+	tiff = entry(tiff, 0x8769, 4, 1, exifIFD)
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = le.AppendUint16(tiff, 1)
+	tiff = entry(tiff, 0x9003, 2, uint32(len(taken)), takenOff)
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = append(append(tiff, model...), taken...)
+
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	seg := binary.BigEndian.AppendUint16([]byte{0xFF, 0xE1}, uint16(len(app1)+2))
+	data := img.Bytes()
+	return string(append(append(append([]byte{}, data[:2]...), append(seg, app1...)...), data[2:]...))
+}
+
+func TestCLI_DedupeSimilar(t *testing.T) {
+	workspace := t.TempDir()
+	dest := filepath.Join(workspace, "dest")
+	dir := filepath.Join(dest, "images")
+	mustMkdir(t, dir)
+	original := jpegWithExif(t, "Pixel 8", "2024:05:01 10:00:00", 95)
+	writeFile(t, dir, "a.jpg", original)
+	writeFile(t, dir, "b.jpg", original)
+	writeFile(t, dir, "c.jpg", jpegWithExif(t, "Pixel 8", "2024:05:01 10:00:00", 60))
+	writeFile(t, dir, "d.jpg", jpegWithExif(t, "Pixel 8", "2024:05:01 10:00:01", 60))
+
+	res := runCLI(t, workspace, "dedupe", "-mode", "similar", absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	// b.jpg is an exact copy of a.jpg and d.jpg was taken a second later.
+	want := filepath.Join(dir, "c.jpg") + "," + filepath.Join(dir, "a.jpg")
+	if got := strings.TrimSpace(res.stdout); got != want {
+		t.Fatalf("unexpected probable duplicates:\ngot  %q\nwant %q", got, want)
+	}
+	assertFileContent(t, filepath.Join(dir, "c.jpg"), jpegWithExif(t, "Pixel 8", "2024:05:01 10:00:00", 60))
+}
+
+func TestCLI_DedupePerceptual(t *testing.T) {
+	workspace := t.TempDir()
+	dest := filepath.Join(workspace, "dest")
+	dir := filepath.Join(dest, "images")
+	mustMkdir(t, dir)
+	gradient := image.NewGray(image.Rect(0, 0, 64, 48))
+	stripes := image.NewGray(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			gradient.Pix[y*64+x] = uint8(x*4 + y)
+			stripes.Pix[y*64+x] = uint8(255 * (x / 8 % 2))
+		}
+	}
+	encode := func(img image.Image, q int) string {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: q}); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	writeFile(t, dir, "a.jpg", encode(gradient, 95))
+	writeFile(t, dir, "b.jpg", encode(gradient, 40))
+	writeFile(t, dir, "c.jpg", encode(stripes, 95))
+
+	res := runCLI(t, workspace, "dedupe", "-mode", "perceptual", absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	rows := strings.Split(strings.TrimSpace(res.stdout), "\n")
+	if len(rows) != 1 {
+		t.Fatalf("expected one similar pair, got:\n%s", res.stdout)
+	}
+	fields := strings.Split(rows[0], ",")
+	if len(fields) != 7 || fields[0] != filepath.Join(dir, "b.jpg") || fields[1] != filepath.Join(dir, "a.jpg") || fields[3] != "64x48" || fields[5] != "64x48" {
+		t.Fatalf("unexpected pair: %s", rows[0])
+	}
+
+	res = runCLI(t, workspace, "dedupe", "-mode", "perceptual", "-threshold", "2", absPath(t, dest))
+	if res.err == nil || !strings.Contains(res.stderr, "-threshold") {
+		t.Fatalf("expected a -threshold error, got: %v, stderr: %s", res.err, res.stderr)
+	}
+}
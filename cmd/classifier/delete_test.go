@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLI_DeleteSourceAfterVerifiedCopy(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "alpha")
+	writeFile(t, src, "bravo.txt", "alpha")
+	writeFile(t, src, "tiny.jpg", "tiny")
+
+	res := runCLI(t, workspace, "-delete-source", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+
+	assertFileContent(t, filepath.Join(dest, "documents", "alpha.txt"), "alpha")
+	for _, name := range []string{"alpha.txt", "bravo.txt"} {
+		if _, err := os.Stat(filepath.Join(src, name)); err == nil {
+			t.Fatalf("expected %s to be deleted from the source", name)
+		}
+	}
+	// Filtered files were never copied and must survive.
+	assertFileContent(t, filepath.Join(src, "tiny.jpg"), "tiny")
+
+	lines := strings.Split(strings.TrimSpace(readFile(t, filepath.Join(dest, ".classifier", "deleted.csv"))), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 deletions in manifest, got %d", len(lines))
+	}
+	for i, name := range []string{"alpha.txt", "bravo.txt"} {
+		parts := strings.Split(lines[i], ",")
+		if len(parts) != 5 || parts[1] != filepath.Join(src, name) || parts[2] != filepath.Join(dest, "documents", "alpha.txt") {
+			t.Fatalf("unexpected manifest line: %s", lines[i])
+		}
+	}
+}
+
+func TestCLI_DeleteSourceMovesToTrash(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	dataHome := filepath.Join(workspace, "xdg")
+
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "alpha")
+
+	res := runCLIEnv(t, []string{"XDG_DATA_HOME=" + dataHome}, workspace,
+		"-delete-source", "-trash", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+
+	assertFileContent(t, filepath.Join(dest, "documents", "alpha.txt"), "alpha")
+	if _, err := os.Stat(filepath.Join(src, "alpha.txt")); err == nil {
+		t.Fatalf("expected source to be moved away")
+	}
+	trashed := filepath.Join(dataHome, "Trash", "files", "alpha.txt")
+	assertFileContent(t, trashed, "alpha")
+	info := readFile(t, filepath.Join(dataHome, "Trash", "info", "alpha.txt.trashinfo"))
+	if !strings.Contains(info, "Path="+filepath.Join(src, "alpha.txt")) {
+		t.Fatalf("unexpected trash info: %s", info)
+	}
+
+	manifest := strings.TrimSpace(readFile(t, filepath.Join(dest, ".classifier", "deleted.csv")))
+	if !strings.HasSuffix(manifest, ","+trashed) {
+		t.Fatalf("expected manifest to record trash location, got: %s", manifest)
+	}
+}
+
+func TestCLI_DryRunWritesNothing(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "alpha")
+	writeFile(t, src, "bravo.txt", "alpha")
+
+	nested := filepath.Join(src, "nested")
+	mustMkdir(t, nested)
+	writeFile(t, nested, "alpha.txt", "other")
+
+	res := runCLI(t, workspace, "-dry-run", "-delete-source", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+
+	docs := filepath.Join(dest, "documents")
+	want := []string{
+		"copy " + filepath.Join(src, "alpha.txt") + " -> " + filepath.Join(docs, "alpha.txt"),
+		"delete " + filepath.Join(src, "alpha.txt"),
+		"duplicate " + filepath.Join(src, "bravo.txt") + " = " + filepath.Join(docs, "alpha.txt"),
+		"delete " + filepath.Join(src, "bravo.txt"),
+		"copy " + filepath.Join(nested, "alpha.txt") + " -> " + filepath.Join(docs, "alpha_1.txt"),
+		"delete " + filepath.Join(nested, "alpha.txt"),
+	}
+	if got := strings.Split(strings.TrimSpace(res.stdout), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected preview:\ngot  %q\nwant %q", got, want)
+	}
+	if _, err := os.Stat(dest); err == nil {
+		t.Fatalf("dry run must not create the destination")
+	}
+	assertFileContent(t, filepath.Join(src, "alpha.txt"), "alpha")
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+const diffUsage = "usage: classifier diff <src-abs-dir> <dest-abs-dir>"
+
+// runDiff lists source files whose content is not present anywhere in the
+// destination, so the source can be wiped safely once the list is empty.
+// Only destination files whose size matches a source file are hashed.
+func runDiff(ctx context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("diff", flag.ContinueOnError)
+	if err := parseFlags(flagSet, args, diffUsage); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 2 {
+		return errors.New("expected 2 arguments; " + diffUsage)
+	}
+	src, dest := flagSet.Arg(0), flagSet.Arg(1)
+	if !filepath.IsAbs(src) || !filepath.IsAbs(dest) {
+		return errors.New("source and destination must be absolute paths; " + diffUsage)
+	}
+
+	srcFiles, err := listFiles(src)
+	if err != nil {
+		return fmt.Errorf("read source: %w", err)
+	}
+	destFiles, err := listFiles(dest)
+	if err != nil {
+		return fmt.Errorf("read destination: %w", err)
+	}
+
+	srcSizes := make(map[int64]bool)
+	for _, f := range srcFiles {
+		srcSizes[f.size] = true
+	}
+	destHashes := make(map[string]bool)
+	destSizes := make(map[int64]bool)
+	for _, f := range destFiles {
+		if !srcSizes[f.size] {
+			continue
+		}
+		hash, err := engine.FileHash(ctx, f.path)
+		if err != nil {
+			return err
+		}
+		destHashes[hash] = true
+		destSizes[f.size] = true
+	}
+
+	missing := 0
+	for _, f := range srcFiles {
+		if destSizes[f.size] {
+			hash, err := engine.FileHash(ctx, f.path)
+			if err != nil {
+				return err
+			}
+			if destHashes[hash] {
+				continue
+			}
+		}
+		fmt.Fprintln(os.Stdout, f.path)
+		missing++
+	}
+
+	if missing > 0 {
+		return fmt.Errorf("%d of %d source files are missing from %s", missing, len(srcFiles), dest)
+	}
+	return nil
+}
+
+type fileEntry struct {
+	path string
+	size int64
+	info fs.FileInfo
+}
+
+// listFiles returns the regular files under root, skipping the tool's own
+// state directory.
+func listFiles(root string) ([]fileEntry, error) {
+	var files []fileEntry
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if engine.IsToolDir(d.Name()) && path != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, fileEntry{path: path, size: info.Size(), info: info})
+		}
+		return nil
+	})
+	return files, err
+}
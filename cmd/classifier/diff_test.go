@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLI_DiffReportsSourceFilesMissingFromDestination(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "alpha")
+	writeFile(t, src, "bravo.txt", "alpha")
+
+	res := runCLI(t, workspace, absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+
+	res = runCLI(t, workspace, "diff", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected no differences after a full run, got: %v, stderr: %s", res.err, res.stderr)
+	}
+	if res.stdout != "" {
+		t.Fatalf("expected no output, got: %s", res.stdout)
+	}
+
+	nested := filepath.Join(src, "nested")
+	mustMkdir(t, nested)
+	writeFile(t, nested, "charlie.txt", "charlie")
+	// Same size as an archived file but different content.
+	writeFile(t, src, "delta.txt", "delta")
+
+	res = runCLI(t, workspace, "diff", absPath(t, src), absPath(t, dest))
+	if res.exitCode != 1 {
+		t.Fatalf("expected exit code 1 when files are missing, got %d, stderr: %s", res.exitCode, res.stderr)
+	}
+	got := strings.Split(strings.TrimSpace(res.stdout), "\n")
+	want := []string{filepath.Join(src, "delta.txt"), filepath.Join(nested, "charlie.txt")}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected missing files:\ngot  %q\nwant %q", got, want)
+	}
+	if !strings.Contains(res.stderr, "2 of 4 source files") {
+		t.Fatalf("expected summary on stderr, got: %s", res.stderr)
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+const configDoctorUsage = "usage: classifier config doctor [-config path]"
+
+// configFinding is a problem found in a config; errors make part of the
+// config ineffective, warnings are likely mistakes.
+type configFinding struct {
+	severity string
+	message  string
+}
+
+func runConfigDoctor(ctx context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("config doctor", flag.ContinueOnError)
+	var configPath string
+	flagSet.StringVar(&configPath, "config", "", "path or http(s) URL of the YAML config (default: the embedded config)")
+	flagSet.StringVar(&configPath, "c", "", "path or http(s) URL of the YAML config (default: the embedded config)")
+	if err := parseFlags(flagSet, args, configDoctorUsage); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 0 {
+		return errors.New("unexpected arguments; " + configDoctorUsage)
+	}
+
+	cfg, err := engine.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	findings := diagnoseConfig(cfg)
+	for _, f := range findings {
+		fmt.Fprintf(os.Stdout, "%s: %s\n", f.severity, f.message)
+	}
+	if len(findings) > 0 {
+		return fmt.Errorf("%d problems found", len(findings))
+	}
+	fmt.Fprintln(os.Stdout, "no problems found")
+	return nil
+}
+
+// diagnoseConfig checks a config for rules that conflict, can never match,
+// or depend on guesswork.
+func diagnoseConfig(cfg engine.Config) []configFinding {
+	var findings []configFinding
+	report := func(severity, format string, args ...any) {
+		findings = append(findings, configFinding{severity: severity, message: fmt.Sprintf(format, args...)})
+	}
+
+	owner := make(map[string]string)
+	seenCategory := make(map[string]bool)
+	for i, cat := range cfg.Categories {
+		name := cat.Name
+		switch {
+		case name == "":
+			report("error", "category #%d has no name", i+1)
+			name = fmt.Sprintf("#%d", i+1)
+		case strings.ContainsAny(name, `/\`) || name == "." || name == "..":
+			report("error", "category %q is not a plain directory name", name)
+		case engine.IsToolDir(name):
+			report("error", "category %q clashes with a directory the tool keeps in the destination", name)
+		}
+		if seenCategory[cat.Name] && cat.Name != "" {
+			report("warning", "category %q is defined more than once; merge the extension lists", cat.Name)
+		}
+		seenCategory[cat.Name] = true
+		if cat.Root != "" && !filepath.IsAbs(cat.Root) {
+			report("error", "category %q has root %q, which is not an absolute path", name, cat.Root)
+		}
+		if cat.Layout == engine.LayoutAlphabetical && (name == "images" || name == "movies") {
+			report("warning", "category %q is dated, so layout %q only applies to its files without a date", name, cat.Layout)
+		}
+		for j, b := range cat.VideoBuckets {
+			switch {
+			case b.Category == name:
+				report("warning", "video bucket #%d of category %q sends videos to the category itself", j+1, name)
+			case strings.ContainsAny(b.Category, `/\`) || b.Category == "." || b.Category == "..":
+				report("error", "video bucket #%d of category %q is not a plain directory name", j+1, name)
+			}
+			if b.MinDuration > 0 && b.MaxDuration > 0 && b.MinDuration >= b.MaxDuration {
+				report("warning", "video bucket #%d of category %q can never match: min_duration is not below max_duration", j+1, name)
+			}
+		}
+		if cat.MinDuration < 0 {
+			report("error", "min_duration of category %q must not be negative", name)
+		}
+		for j, b := range cat.VideoBuckets {
+			if cat.MinDuration > 0 && b.MaxDuration > 0 && b.MaxDuration <= cat.MinDuration {
+				report("warning", "video bucket #%d of category %q can never match: its max_duration is not above the category's min_duration", j+1, name)
+			}
+		}
+		if cat.MinBytes < 0 {
+			report("error", "min_bytes of category %q must not be negative", name)
+		}
+		listed := make(map[string]bool)
+		for _, ext := range cat.Extensions {
+			listed[strings.TrimPrefix(strings.ToLower(ext), ".")] = true
+		}
+		exts := make([]string, 0, len(cat.MinBytesByExtension))
+		for ext := range cat.MinBytesByExtension {
+			exts = append(exts, ext)
+		}
+		sort.Strings(exts)
+		for _, ext := range exts {
+			switch {
+			case cat.MinBytesByExtension[ext] < 0:
+				report("error", "min_bytes_by_extension of %q in category %q must not be negative", ext, name)
+			case !listed[strings.TrimPrefix(strings.ToLower(ext), ".")] && cat.Name != cfg.DefaultCategory:
+				report("warning", "min_bytes_by_extension of category %q names %q, which is not one of its extensions", name, ext)
+			}
+		}
+		if len(cat.PostCommand) > 0 {
+			if _, err := exec.LookPath(cat.PostCommand[0]); err != nil {
+				report("warning", "post_command of category %q: %v", name, err)
+			}
+		}
+
+		reachable := 0
+		for _, ext := range cat.Extensions {
+			clean := strings.TrimPrefix(strings.ToLower(ext), ".")
+			switch {
+			case clean == "":
+				report("warning", "category %q lists an empty extension, which is ignored", name)
+				continue
+			case strings.HasSuffix(clean, ".") || strings.Contains(clean, ".."):
+				report("error", "extension %q in category %q has an empty part and can never match", ext, name)
+				continue
+			case strings.ContainsAny(clean, `/\ `):
+				report("error", "extension %q in category %q can never match a file name", ext, name)
+				continue
+			}
+			reachable++
+			prev, ok := owner[clean]
+			switch {
+			case !ok:
+			case prev == name:
+				report("warning", "extension %q is listed twice in category %q", clean, name)
+			default:
+				report("error", "extension %q is listed in %q and %q; files go to %q because it comes last — remove it from one of them",
+					clean, prev, name, name)
+			}
+			owner[clean] = name
+		}
+		if reachable == 0 && cat.Name != cfg.DefaultCategory {
+			report("warning", "category %q has no usable extensions, so no file is ever classified into it", name)
+		}
+	}
+
+	defaultCategory := cfg.DefaultCategory
+	if defaultCategory == "" {
+		defaultCategory = "others"
+	}
+	froms := make([]string, 0, len(cfg.Aliases))
+	for from := range cfg.Aliases {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+	for _, from := range froms {
+		to := cfg.Aliases[from]
+		switch {
+		case from == to:
+			report("warning", "alias %q points to itself and does nothing", from)
+			continue
+		case seenCategory[from]:
+			report("error", "alias %q renames a category that is still defined; files for it go to %q — remove one of them", from, to)
+		case strings.ContainsAny(from, `/\`) || from == "." || from == "..":
+			report("error", "alias %q is not a plain directory name", from)
+		}
+		seen := map[string]bool{from: true}
+		for next, ok := to, true; ok; next, ok = cfg.Aliases[next] {
+			if seen[next] {
+				report("error", "alias %q is part of a cycle", from)
+				break
+			}
+			seen[next] = true
+			if _, more := cfg.Aliases[next]; !more && !seenCategory[next] && next != defaultCategory {
+				report("warning", "alias %q leads to %q, which is not a category", from, next)
+			}
+		}
+	}
+
+	for _, pattern := range cfg.Exclude {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			report("error", "exclude pattern %q is malformed", pattern)
+		}
+	}
+	seenSource := make(map[string]bool)
+	for i, s := range cfg.Sources {
+		if !filepath.IsAbs(s.Path) || !filepath.IsAbs(s.Dest) {
+			report("error", "source #%d: path and dest must be absolute paths", i+1)
+		}
+		if s.Interval < 0 {
+			report("error", "interval of source %q must not be negative", s.Path)
+		}
+		if seenSource[filepath.Clean(s.Path)] {
+			report("error", "source %q is listed more than once", s.Path)
+		}
+		seenSource[filepath.Clean(s.Path)] = true
+		for _, pattern := range s.Exclude {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				report("error", "exclude pattern %q of source %q is malformed", pattern, s.Path)
+			}
+		}
+		for _, p := range s.DatePatterns {
+			if _, err := regexp.Compile(p); err != nil {
+				report("error", "date pattern %q of source %q does not compile: %v", p, s.Path, err)
+			}
+		}
+		names := make([]string, 0, len(s.MinBytes))
+		for name := range s.MinBytes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if s.MinBytes[name] < 0 {
+				report("error", "min_bytes of category %q in source %q must not be negative", name, s.Path)
+			}
+		}
+	}
+
+	profiles := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+	for _, name := range profiles {
+		p := cfg.Profiles[name]
+		if p.Dest != "" && !filepath.IsAbs(p.Dest) {
+			report("error", "profile %q has dest %q, which is not an absolute path", name, p.Dest)
+		}
+		if p.DateLayout != "" {
+			if err := engine.CheckDateLayout(p.DateLayout); err != nil {
+				report("error", "profile %q: %v", name, err)
+			}
+		}
+	}
+
+	if cfg.DateLayout != "" {
+		if err := engine.CheckDateLayout(cfg.DateLayout); err != nil {
+			report("error", "%v", err)
+		}
+	}
+
+	var compiled []*regexp.Regexp
+	seenPattern := make(map[string]int)
+	for i, p := range cfg.DatePatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			report("error", "date pattern %q does not compile: %v", p, err)
+			continue
+		}
+		if j, ok := seenPattern[p]; ok {
+			report("warning", "date pattern %q repeats pattern #%d and is never used", p, j+1)
+			continue
+		}
+		seenPattern[p] = i
+		for _, earlier := range compiled {
+			if earlier.MatchString("") {
+				report("warning", "date pattern %q is unreachable: %q before it matches every file name", p, earlier.String())
+				break
+			}
+		}
+		compiled = append(compiled, re)
+
+		names := re.SubexpNames()
+		hasYear, hasMonth := false, false
+		for _, n := range names {
+			hasYear = hasYear || n == "year"
+			hasMonth = hasMonth || n == "month"
+		}
+		switch {
+		case hasYear && hasMonth:
+		case re.NumSubexp() >= 2:
+			report("warning", "date pattern %q has no (?P<year>...) and (?P<month>...) groups; its first two groups are taken as year and month", p)
+		default:
+			report("warning", "date pattern %q has no (?P<year>...) and (?P<month>...) groups; year and month are guessed from 4- and 2-digit groups", p)
+		}
+	}
+	return findings
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+func TestDiagnoseConfig_Aliases(t *testing.T) {
+	cfg := engine.Config{
+		Categories: []engine.Category{
+			{Name: "images", Extensions: []string{"jpg"}},
+			{Name: "photos", Extensions: []string{"png"}},
+		},
+		Aliases: map[string]string{
+			"photos": "images",
+			"pics":   "pics",
+			"old":    "photos",
+			"snaps":  "stills",
+			"junk":   "others",
+			"a":      "b",
+			"b":      "a",
+		},
+	}
+
+	var got []string
+	for _, f := range diagnoseConfig(cfg) {
+		got = append(got, f.severity+": "+f.message)
+	}
+	want := []string{
+		`error: alias "a" is part of a cycle`,
+		`error: alias "b" is part of a cycle`,
+		`error: alias "photos" renames a category that is still defined; files for it go to "images" — remove one of them`,
+		`warning: alias "pics" points to itself and does nothing`,
+		`warning: alias "snaps" leads to "stills", which is not a category`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected findings:\ngot:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDiagnoseConfig_MinBytes(t *testing.T) {
+	cfg := engine.Config{
+		Categories: []engine.Category{
+			{Name: "images", Extensions: []string{"jpg", ".PNG"}, MinBytes: -1, MinBytesByExtension: map[string]int64{".png": 100, "gif": 10, "jpg": -5}},
+		},
+	}
+
+	var got []string
+	for _, f := range diagnoseConfig(cfg) {
+		got = append(got, f.severity+": "+f.message)
+	}
+	want := []string{
+		`error: min_bytes of category "images" must not be negative`,
+		`warning: min_bytes_by_extension of category "images" names "gif", which is not one of its extensions`,
+		`error: min_bytes_by_extension of "jpg" in category "images" must not be negative`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected findings:\ngot:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDiagnoseConfig_SourcesAndProfiles(t *testing.T) {
+	cfg := engine.Config{
+		Sources: []engine.Source{
+			{Path: "/mnt/phone", Dest: "/archive"},
+			{Path: "camera", Dest: "/archive"},
+			{Path: "/mnt/phone/", Dest: "/other", Interval: -time.Minute},
+			{Path: "/mnt/dslr", Dest: "/archive", Exclude: []string{"["}, DatePatterns: []string{"("}, MinBytes: map[string]int64{"images": -1}},
+		},
+		Exclude: []string{"*.tmp", "[a-"},
+		Profiles: map[string]engine.Profile{
+			"photos":    {Dest: "/archive/photos"},
+			"paperwork": {Dest: "paperwork"},
+		},
+	}
+
+	var got []string
+	for _, f := range diagnoseConfig(cfg) {
+		got = append(got, f.severity+": "+f.message)
+	}
+	want := []string{
+		`error: exclude pattern "[a-" is malformed`,
+		`error: source #2: path and dest must be absolute paths`,
+		`error: interval of source "/mnt/phone/" must not be negative`,
+		`error: source "/mnt/phone/" is listed more than once`,
+		`error: exclude pattern "[" of source "/mnt/dslr" is malformed`,
+		"error: date pattern \"(\" of source \"/mnt/dslr\" does not compile: error parsing regexp: missing closing ): `(`",
+		`error: min_bytes of category "images" in source "/mnt/dslr" must not be negative`,
+		`error: profile "paperwork" has dest "paperwork", which is not an absolute path`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected findings:\ngot:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDiagnoseConfig(t *testing.T) {
+	cfg := engine.Config{
+		Categories: []engine.Category{
+			{Name: "images", Extensions: []string{"jpg", ".PNG", "png"}},
+			{Name: "photos", Extensions: []string{"JPG"}},
+			{Name: "archives", Extensions: []string{"tar..gz", ""}},
+		},
+		DefaultCategory: "others",
+		DatePatterns: []string{
+			`^(?P<year>\d{4})-(?P<month>\d{2})`,
+			`^(\d{4})(\d{2})`,
+			`(\d{4})?`,
+			`^IMG_`,
+			`^(?P<year>\d{4})-(?P<month>\d{2})`,
+			`(`,
+		},
+	}
+
+	var got []string
+	for _, f := range diagnoseConfig(cfg) {
+		got = append(got, f.severity+": "+f.message)
+	}
+	want := []string{
+		`warning: extension "png" is listed twice in category "images"`,
+		`error: extension "jpg" is listed in "images" and "photos"; files go to "photos" because it comes last — remove it from one of them`,
+		`error: extension "tar..gz" in category "archives" has an empty part and can never match`,
+		`warning: category "archives" lists an empty extension, which is ignored`,
+		`warning: category "archives" has no usable extensions, so no file is ever classified into it`,
+		`warning: date pattern "^(\\d{4})(\\d{2})" has no (?P<year>...) and (?P<month>...) groups; its first two groups are taken as year and month`,
+		`warning: date pattern "(\\d{4})?" has no (?P<year>...) and (?P<month>...) groups; year and month are guessed from 4- and 2-digit groups`,
+		`warning: date pattern "^IMG_" is unreachable: "(\\d{4})?" before it matches every file name`,
+		`warning: date pattern "^IMG_" has no (?P<year>...) and (?P<month>...) groups; year and month are guessed from 4- and 2-digit groups`,
+		`warning: date pattern "^(?P<year>\\d{4})-(?P<month>\\d{2})" repeats pattern #1 and is never used`,
+		"error: date pattern \"(\" does not compile: error parsing regexp: missing closing ): `(`",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected findings:\ngot:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCLI_ConfigDoctor(t *testing.T) {
+	workspace := t.TempDir()
+
+	res := runCLI(t, workspace, "config", "doctor")
+	if res.err != nil {
+		t.Fatalf("expected the embedded config to be clean, got error: %v, stdout: %s", res.err, res.stdout)
+	}
+
+	writeFile(t, workspace, "config.yaml", `categories:
+  - name: images
+    extensions: [jpg]
+  - name: photos
+    extensions: [jpg]
+`)
+	res = runCLI(t, workspace, "config", "doctor", "-config", filepath.Join(workspace, "config.yaml"))
+	if res.err == nil {
+		t.Fatalf("expected conflicting extensions to fail, stdout: %s", res.stdout)
+	}
+	if !strings.Contains(res.stdout, `extension "jpg" is listed in "images" and "photos"`) {
+		t.Fatalf("expected conflict in output, got %q", res.stdout)
+	}
+	if !strings.Contains(res.stderr, "1 problems found") {
+		t.Fatalf("expected summary on stderr, got %q", res.stderr)
+	}
+}
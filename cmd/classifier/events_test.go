@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+func TestCLI_OutputNDJSONStreamsEvents(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "doc")
+	writeFile(t, src, "bravo.txt", "doc")
+	writeFile(t, src, "tiny.jpg", "img")
+
+	res := runCLI(t, workspace, "-output", "ndjson", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(res.stdout), "\n") {
+		var ev engine.Event
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("invalid event line %q: %v", line, err)
+		}
+		if ev.Time.IsZero() {
+			t.Fatalf("event without time: %q", line)
+		}
+		got = append(got, ev.Type+" "+filepath.Base(ev.Src)+" "+ev.Dest)
+	}
+	want := []string{
+		"discovered alpha.txt ",
+		"classified alpha.txt ",
+		"discovered bravo.txt ",
+		"classified bravo.txt ",
+		"discovered tiny.jpg ",
+		"skipped-small tiny.jpg ",
+		"copied alpha.txt " + filepath.Join(dest, "documents", "alpha.txt"),
+		"skipped-duplicate bravo.txt " + filepath.Join(dest, "documents", "alpha.txt"),
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected events:\ngot  %q\nwant %q", got, want)
+	}
+}
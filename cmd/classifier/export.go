@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+const exportUsage = "usage: classifier export [-format csv|sql] [-o file] <dest-abs-dir>"
+
+// catalogEntry is one row of the exported catalog.
+type catalogEntry struct {
+	path     string
+	category string
+	date     string
+	size     int64
+	hash     string
+}
+
+// runExport writes a catalog of every archived file in a destination. The
+// sql format is a SQL script, not a database; sqlite3 loads it into one:
+// `sqlite3 archive.db < catalog.sql`.
+func runExport(ctx context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flagSet.String("format", "csv", "catalog format: csv, or sql for a SQL script that sqlite3 loads into a database")
+	var outPath string
+	flagSet.StringVar(&outPath, "o", "", "write the catalog to this file instead of stdout")
+	if err := parseFlags(flagSet, args, exportUsage); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return errors.New("expected 1 argument; " + exportUsage)
+	}
+	dest := flagSet.Arg(0)
+	if !filepath.IsAbs(dest) {
+		return errors.New("destination must be an absolute path; " + exportUsage)
+	}
+	var write func(io.Writer, []catalogEntry) error
+	switch *format {
+	case "csv":
+		write = writeCatalogCSV
+	case "sql":
+		write = writeCatalogSQL
+	default:
+		return fmt.Errorf("unknown -format %q; %s", *format, exportUsage)
+	}
+
+	files, err := listFiles(dest)
+	if err != nil {
+		return fmt.Errorf("read destination: %w", err)
+	}
+	entries := make([]catalogEntry, 0, len(files))
+	for _, f := range files {
+		category, date, ok := engine.ArchivedPath(dest, f.path)
+		if !ok {
+			continue
+		}
+		hash, err := engine.FileHash(ctx, f.path)
+		if err != nil {
+			return fmt.Errorf("hash %s: %w", f.path, err)
+		}
+		rel, _ := filepath.Rel(dest, f.path)
+		entries = append(entries, catalogEntry{
+			path:     filepath.ToSlash(rel),
+			category: category,
+			date:     date,
+			size:     f.size,
+			hash:     hash,
+		})
+	}
+
+	if outPath == "" {
+		return writeCatalog(os.Stdout, write, entries)
+	}
+	file, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("write catalog: %w", err)
+	}
+	if err := writeCatalog(file, write, entries); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("write catalog: %w", err)
+	}
+	return nil
+}
+
+func writeCatalog(w io.Writer, write func(io.Writer, []catalogEntry) error, entries []catalogEntry) error {
+	buf := bufio.NewWriter(w)
+	if err := write(buf, entries); err != nil {
+		return fmt.Errorf("write catalog: %w", err)
+	}
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("write catalog: %w", err)
+	}
+	return nil
+}
+
+func writeCatalogCSV(w io.Writer, entries []catalogEntry) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"path", "category", "date", "size", "hash"}); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := cw.Write([]string{e.path, e.category, e.date, strconv.FormatInt(e.size, 10), e.hash}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeCatalogSQL(w io.Writer, entries []catalogEntry) error {
+	fmt.Fprintln(w, "BEGIN TRANSACTION;")
+	fmt.Fprintln(w, "CREATE TABLE IF NOT EXISTS files (path TEXT PRIMARY KEY, category TEXT NOT NULL, date TEXT, size INTEGER NOT NULL, hash TEXT NOT NULL);")
+	fmt.Fprintln(w, "CREATE INDEX IF NOT EXISTS files_category_date ON files (category, date);")
+	fmt.Fprintln(w, "CREATE INDEX IF NOT EXISTS files_hash ON files (hash);")
+	for _, e := range entries {
+		date := "NULL"
+		if e.date != "" {
+			date = sqlQuote(e.date)
+		}
+		_, err := fmt.Fprintf(w, "INSERT OR REPLACE INTO files VALUES (%s, %s, %s, %d, %s);\n",
+			sqlQuote(e.path), sqlQuote(e.category), date, e.size, sqlQuote(e.hash))
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "COMMIT;")
+	return err
+}
+
+func sqlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLI_ExportWritesCatalog(t *testing.T) {
+	workspace := t.TempDir()
+	dest := filepath.Join(workspace, "dest")
+	out := filepath.Join(workspace, "catalog.csv")
+
+	mustMkdir(t, filepath.Join(dest, "movies", "2019", "201903"))
+	mustMkdir(t, filepath.Join(dest, "documents"))
+	mustMkdir(t, filepath.Join(dest, ".classifier"))
+	writeFile(t, filepath.Join(dest, "movies", "2019", "201903"), "clip.mp4", "clip")
+	writeFile(t, filepath.Join(dest, "documents"), "it's.txt", "doc")
+	writeFile(t, filepath.Join(dest, ".classifier"), "checkpoint.csv", "ignored")
+	writeFile(t, dest, "warn.csv", "ignored")
+
+	res := runCLI(t, workspace, "export", "-o", out, absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatalf("open catalog: %v", err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("parse catalog: %v", err)
+	}
+	want := [][]string{
+		{"path", "category", "date", "size", "hash"},
+		{"documents/it's.txt", "documents", "", "3", ""},
+		{"movies/2019/201903/clip.mp4", "movies", "2019-03", "4", ""},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("unexpected catalog rows: %q", rows)
+	}
+	for i := range want {
+		if strings.Join(rows[i][:4], ",") != strings.Join(want[i][:4], ",") {
+			t.Fatalf("row %d: got %q, want %q", i, rows[i], want[i])
+		}
+		if i > 0 && len(rows[i][4]) != 64 {
+			t.Fatalf("row %d: expected sha256 hash, got %q", i, rows[i][4])
+		}
+	}
+
+	res = runCLI(t, workspace, "export", "-format", "sql", absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	for _, want := range []string{
+		"CREATE TABLE IF NOT EXISTS files",
+		"('documents/it''s.txt', 'documents', NULL, 3, '",
+		"('movies/2019/201903/clip.mp4', 'movies', '2019-03', 4, '",
+		"COMMIT;",
+	} {
+		if !strings.Contains(res.stdout, want) {
+			t.Fatalf("expected %q in SQL output:\n%s", want, res.stdout)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+func TestCLI_FilesFromClassifiesOnlyListedFiles(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, filepath.Join(src, "nested"))
+	writeFile(t, src, "alpha.txt", "alpha")
+	writeFile(t, src, "ignored.txt", "ignored")
+	writeFile(t, filepath.Join(src, "nested"), "clip.mp4", "clip")
+
+	for _, sep := range []string{"\n", "\x00"} {
+		list := filepath.Join(workspace, "list")
+		entries := []string{
+			filepath.Join(src, "alpha.txt"),
+			filepath.Join(src, "nested", "clip.mp4"),
+			filepath.Join(src, "alpha.txt"),
+			filepath.Join(src, "missing.txt"),
+		}
+		writeFile(t, workspace, "list", strings.Join(entries, sep)+sep)
+		os.RemoveAll(dest)
+
+		res := runCLI(t, workspace, "-files-from", list, absPath(t, dest))
+		if res.err != nil {
+			t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+		}
+		assertFileContent(t, filepath.Join(dest, "documents", "alpha.txt"), "alpha")
+		assertFileContent(t, filepath.Join(dest, "movies", "clip.mp4"), "clip")
+		if _, err := os.Stat(filepath.Join(dest, "documents", "ignored.txt")); !os.IsNotExist(err) {
+			t.Fatalf("unlisted file must not be copied, got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dest, engine.StateDir, "warn.csv")); !os.IsNotExist(err) {
+			t.Fatalf("a path listed twice must not be reported as its own duplicate, got %v", err)
+		}
+		errs := readFile(t, filepath.Join(dest, engine.StateDir, "errors.csv"))
+		if !strings.Contains(errs, filepath.Join(src, "missing.txt")) {
+			t.Fatalf("expected missing file in errors.csv, got %q", errs)
+		}
+	}
+}
+
+func TestCLI_FilesFromRejectsRelativePaths(t *testing.T) {
+	workspace := t.TempDir()
+	writeFile(t, workspace, "list", "relative.txt\n")
+
+	res := runCLI(t, workspace, "-files-from", filepath.Join(workspace, "list"), absPath(t, filepath.Join(workspace, "dest")))
+	if res.err == nil {
+		t.Fatal("expected relative list entries to fail")
+	}
+	if !strings.Contains(res.stderr, "absolute") {
+		t.Fatalf("unexpected error: %s", res.stderr)
+	}
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+func TestCLI_ModificationTimeWindow(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	mustMkdir(t, src)
+	now := time.Now()
+	for name, age := range map[string]time.Duration{
+		"fresh.txt":  time.Hour,
+		"recent.txt": 10 * 24 * time.Hour,
+		"old.txt":    60 * 24 * time.Hour,
+	} {
+		writeFile(t, src, name, name)
+		mod := now.Add(-age)
+		if err := os.Chtimes(filepath.Join(src, name), mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		args []string
+		want map[string]bool
+	}{
+		{args: []string{"-newer-than", "30d"}, want: map[string]bool{"fresh.txt": true, "recent.txt": true}},
+		{args: []string{"-older-than", "2d"}, want: map[string]bool{"recent.txt": true, "old.txt": true}},
+		{args: []string{"-newer-than", "30d", "-older-than", "1d"}, want: map[string]bool{"recent.txt": true}},
+		{args: []string{"-newer-than", now.AddDate(0, 0, -90).Format(time.DateOnly)}, want: map[string]bool{"fresh.txt": true, "recent.txt": true, "old.txt": true}},
+	}
+	for i, tt := range tests {
+		dest := filepath.Join(workspace, "dest", string(rune('a'+i)))
+		args := append(append([]string{}, tt.args...), absPath(t, src), absPath(t, dest))
+		res := runCLI(t, workspace, args...)
+		if res.err != nil {
+			t.Fatalf("%v: expected success, got error: %v, stderr: %s", tt.args, res.err, res.stderr)
+		}
+		for _, name := range []string{"fresh.txt", "recent.txt", "old.txt"} {
+			_, err := os.Stat(filepath.Join(dest, "documents", name))
+			if copied := err == nil; copied != tt.want[name] {
+				t.Fatalf("%v: %s copied = %v, want %v", tt.args, name, copied, tt.want[name])
+			}
+		}
+	}
+
+	res := runCLI(t, workspace, "-newer-than", "1d", "-older-than", "30d", absPath(t, src), absPath(t, filepath.Join(workspace, "dest", "x")))
+	if res.err == nil {
+		t.Fatal("expected an empty time window to be rejected")
+	}
+}
+
+func TestCLI_MinAgeLeavesRecentFilesForALaterRun(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "settled.txt", "settled")
+	writeFile(t, src, "downloading.txt", "partial")
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(src, "settled.txt"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	res := runCLI(t, workspace, "-min-age", "10m", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "settled.txt"), "settled")
+	if _, err := os.Stat(filepath.Join(dest, "documents", "downloading.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected the recent file to be left alone, got %v", err)
+	}
+	if got, want := strings.TrimSpace(readFile(t, filepath.Join(dest, engine.StateDir, "skipped.csv"))), filepath.Join(src, "downloading.txt")+",modified within -min-age"; got != want {
+		t.Fatalf("unexpected skipped.csv:\ngot  %q\nwant %q", got, want)
+	}
+
+	// Once it has settled, the next run takes it.
+	if err := os.Chtimes(filepath.Join(src, "downloading.txt"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	res = runCLI(t, workspace, "-min-age", "10m", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "downloading.txt"), "partial")
+
+	res = runCLI(t, workspace, "-min-age", "soon", absPath(t, src), absPath(t, dest))
+	if res.exitCode == 0 || !strings.Contains(res.stderr, "invalid age") {
+		t.Fatalf("expected an invalid -min-age to be rejected, got exit %d, stderr: %s", res.exitCode, res.stderr)
+	}
+}
+
+func TestCLI_SizeRangeFilterIsReported(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "small.txt", "tiny")
+	writeFile(t, src, "medium.mp4", strings.Repeat("m", 2048))
+	writeFile(t, src, "large.mp4", strings.Repeat("l", 8192))
+
+	res := runCLI(t, workspace, "-min-size", "1K", "-max-size", "4K", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "movies", "medium.mp4"), strings.Repeat("m", 2048))
+	for _, rel := range []string{filepath.Join("documents", "small.txt"), filepath.Join("movies", "large.mp4")} {
+		if _, err := os.Stat(filepath.Join(dest, rel)); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be filtered out, got %v", rel, err)
+		}
+	}
+
+	got := strings.Split(strings.TrimSpace(readFile(t, filepath.Join(dest, engine.StateDir, "skipped.csv"))), "\n")
+	want := []string{
+		filepath.Join(src, "large.mp4") + ",larger than -max-size",
+		filepath.Join(src, "small.txt") + ",smaller than -min-size",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected skipped.csv:\ngot  %q\nwant %q", got, want)
+	}
+
+	res = runCLI(t, workspace, "-min-size", "4K", "-max-size", "1K", absPath(t, src), absPath(t, dest))
+	if res.err == nil {
+		t.Fatal("expected an empty size range to be rejected")
+	}
+}
+
+func TestCLI_ConfigMinimumSizesAreReported(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "stub.txt", "tiny")
+	writeFile(t, src, "notes.txt", "long enough")
+	writeFile(t, src, "todo.md", "x")
+	writeFile(t, src, "icon.png", "png")
+	writeFile(t, workspace, "config.yaml", `categories:
+  - name: images
+    extensions: [png]
+  - name: documents
+    extensions: [txt, md]
+    min_bytes: 8
+    min_bytes_by_extension:
+      md: 1
+`)
+
+	res := runCLI(t, workspace, "-config", filepath.Join(workspace, "config.yaml"), absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "notes.txt"), "long enough")
+	assertFileContent(t, filepath.Join(dest, "documents", "todo.md"), "x")
+
+	got := strings.Split(strings.TrimSpace(readFile(t, filepath.Join(dest, engine.StateDir, "skipped.csv"))), "\n")
+	want := []string{
+		filepath.Join(src, "icon.png") + ",smaller than -min-image-bytes",
+		filepath.Join(src, "stub.txt") + ",smaller than the 8 bytes of min_bytes",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected skipped.csv:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestCLI_MIMEFilters(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	mustMkdir(t, src)
+	writeFile(t, src, "tool.bin", "\x7fELF\x02\x01\x01"+strings.Repeat("\x00", 64))
+	writeFile(t, src, "notes.txt", "plain text")
+	writeFile(t, src, "photo.png", "\x89PNG\r\n\x1a\n"+strings.Repeat("\x00", 64))
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{
+			args: []string{"-deny-mime", "application/x-executable"},
+			want: []string{filepath.Join(src, "tool.bin") + ",MIME type application/x-executable denied by -deny-mime"},
+		},
+		{
+			args: []string{"-allow-mime", "image/*,video/*"},
+			want: []string{
+				filepath.Join(src, "notes.txt") + ",MIME type text/plain not in -allow-mime",
+				filepath.Join(src, "tool.bin") + ",MIME type application/x-executable not in -allow-mime",
+			},
+		},
+	}
+	for i, tt := range tests {
+		dest := filepath.Join(workspace, "dest"+strconv.Itoa(i))
+		args := append(append([]string{"-min-image-bytes", "0"}, tt.args...), absPath(t, src), absPath(t, dest))
+		res := runCLI(t, workspace, args...)
+		if res.err != nil {
+			t.Fatalf("%v: expected success, got error: %v, stderr: %s", tt.args, res.err, res.stderr)
+		}
+		got := strings.Split(strings.TrimSpace(readFile(t, filepath.Join(dest, engine.StateDir, "skipped.csv"))), "\n")
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Fatalf("%v: unexpected skipped.csv:\ngot  %q\nwant %q", tt.args, got, tt.want)
+		}
+	}
+
+	res := runCLI(t, workspace, "-deny-mime", "executable", absPath(t, src), absPath(t, filepath.Join(workspace, "dest")))
+	if res.err == nil || !strings.Contains(res.stderr, "not a MIME type") {
+		t.Fatalf("expected an invalid pattern to be rejected, got: %v, stderr: %s", res.err, res.stderr)
+	}
+}
+
+func TestCLI_CategoryFilters(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	mustMkdir(t, src)
+	writeFile(t, src, "clip.mp4", "movie")
+	writeFile(t, src, "notes.txt", "notes")
+	writeFile(t, src, "data.bin", "data")
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{
+			args: []string{"-only", "movies", "-only", "documents"},
+			want: []string{filepath.Join(src, "data.bin") + ",category others not in -only"},
+		},
+		{
+			args: []string{"-skip-category", "others,movies"},
+			want: []string{
+				filepath.Join(src, "clip.mp4") + ",category movies skipped by -skip-category",
+				filepath.Join(src, "data.bin") + ",category others skipped by -skip-category",
+			},
+		},
+	}
+	for i, tt := range tests {
+		dest := filepath.Join(workspace, "dest"+strconv.Itoa(i))
+		res := runCLI(t, workspace, append(tt.args, absPath(t, src), absPath(t, dest))...)
+		if res.err != nil {
+			t.Fatalf("%v: expected success, got error: %v, stderr: %s", tt.args, res.err, res.stderr)
+		}
+		assertFileContent(t, filepath.Join(dest, "documents", "notes.txt"), "notes")
+		got := strings.Split(strings.TrimSpace(readFile(t, filepath.Join(dest, engine.StateDir, "skipped.csv"))), "\n")
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Fatalf("%v: unexpected skipped.csv:\ngot  %q\nwant %q", tt.args, got, tt.want)
+		}
+	}
+
+	res := runCLI(t, workspace, "-only", "photos", absPath(t, src), absPath(t, filepath.Join(workspace, "dest")))
+	if res.err != nil || !strings.Contains(res.stderr, `category "photos" is not in the config`) {
+		t.Fatalf("expected a warning about an unknown category, got: %v, stderr: %s", res.err, res.stderr)
+	}
+}
+
+func TestCLI_SkipsICloudPlaceholders(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, ".report.txt.icloud", "stub")
+	writeFile(t, src, "notes.txt", "notes")
+
+	for _, args := range [][]string{nil, {"-hydrate-placeholders"}} {
+		res := runCLI(t, workspace, append(args, absPath(t, src), absPath(t, dest))...)
+		if res.err != nil {
+			t.Fatalf("%v: expected success, got error: %v, stderr: %s", args, res.err, res.stderr)
+		}
+		want := filepath.Join(src, ".report.txt.icloud") + ",iCloud placeholder (not downloaded)"
+		if got := strings.TrimSpace(readFile(t, filepath.Join(dest, engine.StateDir, "skipped.csv"))); got != want {
+			t.Fatalf("%v: unexpected skipped.csv: %q", args, got)
+		}
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "notes.txt"), "notes")
+}
+
+func TestCLI_SkipHidden(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	mustMkdir(t, filepath.Join(src, ".cache", "nested"))
+	mustMkdir(t, filepath.Join(src, "visible"))
+	writeFile(t, filepath.Join(src, ".cache", "nested"), "blob.bin", "cache")
+	writeFile(t, src, ".DS_Store", "meta")
+	writeFile(t, filepath.Join(src, "visible"), "notes.txt", "notes")
+
+	dest := filepath.Join(workspace, "dest")
+	res := runCLI(t, workspace, "-skip-hidden", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "notes.txt"), "notes")
+	if _, err := os.Stat(filepath.Join(dest, "others")); !os.IsNotExist(err) {
+		t.Fatalf("hidden files must not be classified, got %v", err)
+	}
+
+	dest = filepath.Join(workspace, "dest-all")
+	res = runCLI(t, workspace, absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "others", "blob.bin"), "cache")
+	assertFileContent(t, filepath.Join(dest, "others", ".DS_Store"), "meta")
+}
+
+func TestCLI_MaxDepth(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	mustMkdir(t, filepath.Join(src, "one", "two"))
+	writeFile(t, src, "top.txt", "top")
+	writeFile(t, filepath.Join(src, "one"), "first.txt", "first")
+	writeFile(t, filepath.Join(src, "one", "two"), "second.txt", "second")
+
+	for depth, want := range map[string][]string{
+		"1": {"top.txt"},
+		"2": {"top.txt", "first.txt"},
+		"0": {"top.txt", "first.txt", "second.txt"},
+	} {
+		dest := filepath.Join(workspace, "dest"+depth)
+		res := runCLI(t, workspace, "-max-depth", depth, absPath(t, src), absPath(t, dest))
+		if res.err != nil {
+			t.Fatalf("-max-depth %s: expected success, got error: %v, stderr: %s", depth, res.err, res.stderr)
+		}
+		entries, err := os.ReadDir(filepath.Join(dest, "documents"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != len(want) {
+			t.Fatalf("-max-depth %s: copied %d files, want %v", depth, len(entries), want)
+		}
+		for _, name := range want {
+			if _, err := os.Stat(filepath.Join(dest, "documents", name)); err != nil {
+				t.Fatalf("-max-depth %s: expected %s to be copied: %v", depth, name, err)
+			}
+		}
+	}
+}
+
+func TestCLI_LimitAndSample(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	mustMkdir(t, src)
+	for i := 0; i < 20; i++ {
+		name := string(rune('a'+i)) + ".txt"
+		writeFile(t, src, name, name)
+	}
+
+	dest := filepath.Join(workspace, "first")
+	res := runCLI(t, workspace, "-limit", "3", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	entries, err := os.ReadDir(filepath.Join(dest, "documents"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if strings.Join(names, ",") != "a.txt,b.txt,c.txt" {
+		t.Fatalf("expected the first 3 files, got %v", names)
+	}
+
+	dest = filepath.Join(workspace, "sample")
+	res = runCLI(t, workspace, "-limit", "5", "-sample", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	entries, err = os.ReadDir(filepath.Join(dest, "documents"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 5 {
+		t.Fatalf("expected a sample of 5 files, got %d", len(entries))
+	}
+
+	res = runCLI(t, workspace, "-sample", absPath(t, src), absPath(t, dest))
+	if res.err == nil {
+		t.Fatal("expected -sample without -limit to be rejected")
+	}
+}
+
+func TestCLI_OrderDecidesDuplicateWinnerAndBudget(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	mustMkdir(t, src)
+	now := time.Now()
+	for name, age := range map[string]time.Duration{
+		"a-old.txt": 48 * time.Hour,
+		"b-new.txt": time.Hour,
+	} {
+		writeFile(t, src, name, "same")
+		mod := now.Add(-age)
+		if err := os.Chtimes(filepath.Join(src, name), mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, src, "c-big.txt", strings.Repeat("x", 4096))
+	old := now.Add(-72 * time.Hour)
+	if err := os.Chtimes(filepath.Join(src, "c-big.txt"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	copied := func(dest string) []string {
+		entries, err := os.ReadDir(filepath.Join(dest, "documents"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		return names
+	}
+	tests := []struct {
+		order string
+		want  string
+	}{
+		{order: "lexical", want: "a-old.txt"},
+		{order: "newest", want: "b-new.txt"},
+		{order: "largest", want: "c-big.txt"},
+	}
+	for _, tt := range tests {
+		dest := filepath.Join(workspace, tt.order)
+		res := runCLI(t, workspace, "-order", tt.order, "-max-bytes", "4096", absPath(t, src), absPath(t, dest))
+		if res.err != nil {
+			t.Fatalf("-order %s: expected success, got error: %v, stderr: %s", tt.order, res.err, res.stderr)
+		}
+		if got := strings.Join(copied(dest), ","); got != tt.want {
+			t.Fatalf("-order %s: copied %s, want %s", tt.order, got, tt.want)
+		}
+	}
+
+	res := runCLI(t, workspace, "-order", "random", absPath(t, src), absPath(t, filepath.Join(workspace, "x")))
+	if res.err == nil {
+		t.Fatal("expected an unknown order to be rejected")
+	}
+}
+
+func TestCLI_ConfigExcludePatterns(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, filepath.Join(src, ".thumbnails"))
+	writeFile(t, src, "keep.txt", "keep")
+	writeFile(t, src, "draft.tmp", "tmp")
+	writeFile(t, filepath.Join(src, ".thumbnails"), "thumb.txt", "thumb")
+	writeFile(t, workspace, "config.yaml", `categories:
+  - name: documents
+    extensions: [txt]
+exclude: ["*.tmp", .thumbnails]
+`)
+
+	res := runCLI(t, workspace, "-config", filepath.Join(workspace, "config.yaml"), absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "keep.txt"), "keep")
+	for _, name := range []string{filepath.Join("documents", "thumb.txt"), filepath.Join("others", "draft.tmp")} {
+		if _, err := os.Stat(filepath.Join(dest, name)); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be excluded, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+// healthStatus follows the passes of -watch for -health-addr. It is the
+// Sink of every pass and serves
+//
+//	/healthz  200 while the process runs
+//	/readyz   200 unless the last pass failed, e.g. on an unmounted source
+//	/status   the current pass as JSON: file, progress, files per second
+//
+// for container orchestrators and monitoring.
+type healthStatus struct {
+	mu sync.Mutex
+	// running is set during a pass over source; started is when it began.
+	running bool
+	source  string
+	started time.Time
+	current string
+	planned int
+	done    int
+	// settled holds the sources of the pass already counted; with mirrors
+	// every file produces one event per destination.
+	settled  map[string]bool
+	finished time.Time
+	next     time.Time
+	lastErr  error
+}
+
+// passStatus is the body of /status.
+type passStatus struct {
+	State          string    `json:"state"`
+	Source         string    `json:"source,omitempty"`
+	PassStarted    time.Time `json:"pass_started,omitzero"`
+	CurrentFile    string    `json:"current_file,omitempty"`
+	Planned        int       `json:"planned"`
+	Done           int       `json:"done"`
+	QueueDepth     int       `json:"queue_depth"`
+	FilesPerSecond float64   `json:"files_per_second"`
+	LastFinished   time.Time `json:"last_pass_finished,omitzero"`
+	LastError      string    `json:"last_error,omitempty"`
+	// NextPass is when the source of the last pass is due again.
+	NextPass time.Time `json:"next_pass,omitzero"`
+}
+
+// pass wraps the pass over source of watchSources, repeated interval after
+// it finishes, to keep the status up to date.
+func (h *healthStatus) pass(source string, interval time.Duration, pass func(context.Context) error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		h.mu.Lock()
+		h.running, h.source, h.started = true, source, time.Now()
+		h.current, h.planned, h.done = "", 0, 0
+		h.settled = make(map[string]bool)
+		h.mu.Unlock()
+
+		err := pass(ctx)
+
+		h.mu.Lock()
+		h.running, h.current = false, ""
+		h.finished, h.lastErr = time.Now(), err
+		h.next = h.finished.Add(interval)
+		h.mu.Unlock()
+		return err
+	}
+}
+
+func (h *healthStatus) Emit(ev engine.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch ev.Type {
+	case engine.EventClassified:
+		h.planned++
+		return
+	case engine.EventCopied, engine.EventSkippedDuplicate, engine.EventSkippedPresent, engine.EventError:
+	default:
+		return
+	}
+	h.current = ev.Src
+	if h.settled != nil && !h.settled[ev.Src] {
+		h.settled[ev.Src] = true
+		h.done++
+	}
+}
+
+func (h *healthStatus) Finish() {}
+
+func (h *healthStatus) status() passStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := passStatus{
+		State:        "waiting",
+		Source:       h.source,
+		CurrentFile:  h.current,
+		Planned:      h.planned,
+		Done:         h.done,
+		QueueDepth:   max(h.planned-h.done, 0),
+		LastFinished: h.finished,
+	}
+	if h.lastErr != nil {
+		s.LastError = h.lastErr.Error()
+	}
+	if h.running {
+		s.State, s.PassStarted = "running", h.started
+		if elapsed := time.Since(h.started).Seconds(); elapsed > 0 {
+			s.FilesPerSecond = float64(h.done) / elapsed
+		}
+	} else {
+		s.NextPass = h.next
+	}
+	return s
+}
+
+func (h *healthStatus) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		h.mu.Lock()
+		err := h.lastErr
+		h.mu.Unlock()
+		if err != nil {
+			http.Error(w, "last pass failed: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// A client that went away is not worth a log line.
+		_ = json.NewEncoder(w).Encode(h.status())
+	})
+	return mux
+}
+
+// serveHealth serves the endpoints of h on addr until the returned stop is
+// called.
+func serveHealth(addr string, h *healthStatus) (stop func(), err error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("health endpoints: %w", err)
+	}
+	srv := &http.Server{Handler: h.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logf(prioErr, "health endpoints: %v", err)
+		}
+	}()
+	logf(prioInfo, "health endpoints on http://%s", ln.Addr())
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+func TestHealthStatus_FollowsPasses(t *testing.T) {
+	h := &healthStatus{}
+	srv := httptest.NewServer(h.handler())
+	defer srv.Close()
+
+	get := func(path string) (int, passStatus) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var s passStatus
+		if path == "/status" {
+			if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode, s
+	}
+
+	var during passStatus
+	pass := h.pass("/src", time.Minute, func(ctx context.Context) error {
+		for _, src := range []string{"/src/a.txt", "/src/b.txt", "/src/c.txt"} {
+			h.Emit(engine.Event{Type: engine.EventClassified, Src: src})
+		}
+		h.Emit(engine.Event{Type: engine.EventCopied, Src: "/src/a.txt"})
+		h.Emit(engine.Event{Type: engine.EventCopied, Src: "/src/a.txt"})
+		_, during = get("/status")
+		return errors.New("source not mounted")
+	})
+	if code, _ := get("/readyz"); code != http.StatusOK {
+		t.Fatalf("expected ready before the first pass, got %d", code)
+	}
+	if err := pass(t.Context()); err == nil {
+		t.Fatal("expected the error of the pass")
+	}
+	if during.State != "running" || during.Source != "/src" || during.CurrentFile != "/src/a.txt" || during.Done != 1 || during.QueueDepth != 2 {
+		t.Fatalf("unexpected status during the pass: %+v", during)
+	}
+
+	if code, _ := get("/healthz"); code != http.StatusOK {
+		t.Fatalf("expected healthy, got %d", code)
+	}
+	if code, _ := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected not ready after a failed pass, got %d", code)
+	}
+	_, after := get("/status")
+	if after.State != "waiting" || after.LastError != "source not mounted" || after.NextPass.Sub(after.LastFinished) != time.Minute {
+		t.Fatalf("unexpected status after the pass: %+v", after)
+	}
+
+	if err := h.pass("/src", time.Minute, func(context.Context) error { return nil })(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := get("/readyz"); code != http.StatusOK {
+		t.Fatalf("expected ready again after a good pass, got %d", code)
+	}
+}
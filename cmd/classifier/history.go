@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+const historyUsage = "usage: classifier history [-since duration] [-status s] [-last n] [-json] <dest-abs-dir>"
+
+// historyNote says, below the usage line, where the runs come from. They
+// are kept in the JSON Lines audit log rather than a SQLite database: a
+// SQLite driver needs cgo or a large pure Go port that does not build for
+// every platform the classifier does, and a second store could drift from
+// the log.
+const historyNote = `Runs are read from the audit log, one JSON Lines file per month in
+<dest>/.classifier/history. -json prints its entries for jq or for
+sqlite3's json functions.`
+
+// runHistory lists the runs recorded in the audit log of a destination,
+// oldest first. It fails when no run matches, so that a monitoring job can
+// check with -since 24h that the nightly run happened.
+func runHistory(ctx context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("history", flag.ContinueOnError)
+	var since time.Duration
+	flagSet.DurationVar(&since, "since", 0, "only list runs started within this long (e.g. 24h)")
+	var status string
+	flagSet.StringVar(&status, "status", "", "only list runs that ended so: completed, stopped, interrupted or failed")
+	var last int
+	flagSet.IntVar(&last, "last", 20, "list at most this many of the latest runs; 0 lists all")
+	var asJSON bool
+	flagSet.BoolVar(&asJSON, "json", false, "print the full audit log entries, one JSON object per line")
+	if err := parseFlags(flagSet, args, historyUsage+"\n\n"+tr(historyNote)); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return errors.New("expected 1 argument; " + historyUsage)
+	}
+	dest := flagSet.Arg(0)
+	if !filepath.IsAbs(dest) {
+		return errors.New("destination must be an absolute path; " + historyUsage)
+	}
+	if since < 0 || last < 0 {
+		return errors.New("-since and -last must not be negative; " + historyUsage)
+	}
+
+	records, err := engine.ReadHistory(dest)
+	if err != nil {
+		return err
+	}
+	var runs []engine.RunRecord
+	for _, r := range records {
+		if since > 0 && time.Since(r.Started) > since {
+			continue
+		}
+		if status != "" && r.Status != status {
+			continue
+		}
+		runs = append(runs, r)
+	}
+	if len(runs) == 0 {
+		return fmt.Errorf("no matching runs recorded in %s", dest)
+	}
+	if last > 0 && len(runs) > last {
+		runs = runs[len(runs)-last:]
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, r := range runs {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return ctx.Err()
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "started\tduration\tstatus\tcopied\tbytes\tduplicates\tpresent\terrors\tconfig\tid")
+	for _, r := range runs {
+		config := r.ConfigHash
+		if len(config) > 12 {
+			config = config[:12]
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%d\t%d\t%d\t%s\t%s\n",
+			r.Started.Local().Format("2006-01-02 15:04:05"), r.Finished.Sub(r.Started).Round(time.Second), r.Status,
+			r.Counts[engine.EventCopied], engine.FormatBytes(uint64(r.BytesCopied)), r.Counts[engine.EventSkippedDuplicate],
+			r.Counts[engine.EventSkippedPresent], r.Counts[engine.EventError], config, r.ID)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for _, r := range runs {
+		if r.Error != "" {
+			fmt.Fprintf(os.Stdout, "%s: %s\n", r.ID, r.Error)
+		}
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+func TestCLI_RunsAreRecordedInTheAuditLog(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "doc")
+	writeFile(t, src, "bravo.txt", "doc")
+
+	for i := 0; i < 2; i++ {
+		res := runCLI(t, workspace, absPath(t, src), absPath(t, dest))
+		if res.err != nil {
+			t.Fatalf("run %d: expected success, got error: %v, stderr: %s", i, res.err, res.stderr)
+		}
+	}
+
+	logs, err := filepath.Glob(filepath.Join(dest, engine.StateDir, "history", "*.jsonl"))
+	if err != nil || len(logs) != 1 {
+		t.Fatalf("expected one audit log file, got %v (%v)", logs, err)
+	}
+	if want := time.Now().UTC().Format("2006-01") + ".jsonl"; filepath.Base(logs[0]) != want {
+		t.Fatalf("expected audit log %s, got %s", want, logs[0])
+	}
+	lines := strings.Split(strings.TrimSpace(readFile(t, logs[0])), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 audit entries, got %d", len(lines))
+	}
+
+	wantHash, err := engine.ConfigHash("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type runRecord struct {
+		ID          string         `json:"id"`
+		Status      string         `json:"status"`
+		Version     string         `json:"version"`
+		ConfigHash  string         `json:"config_hash"`
+		Source      string         `json:"source"`
+		Manifest    string         `json:"manifest"`
+		HashAlgo    string         `json:"hash_algorithm"`
+		Counts      map[string]int `json:"counts"`
+		BytesCopied int64          `json:"bytes_copied"`
+	}
+	var first, second runRecord
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("parse audit entry: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatalf("parse audit entry: %v", err)
+	}
+	if first.Status != "completed" || first.ConfigHash != wantHash || first.Version == "" || first.Source != src || first.HashAlgo != "sha256" {
+		t.Fatalf("unexpected audit entry: %+v", first)
+	}
+	if first.Counts[engine.EventCopied] != 1 || first.Counts[engine.EventSkippedDuplicate] != 1 || first.BytesCopied != 3 {
+		t.Fatalf("unexpected counts in first run: %+v, %d bytes", first.Counts, first.BytesCopied)
+	}
+	if second.Counts[engine.EventCopied] != 0 || second.Counts[engine.EventSkippedPresent] != 1 {
+		t.Fatalf("unexpected counts in second run: %+v", second.Counts)
+	}
+	if first.ID == second.ID {
+		t.Fatalf("runs must have distinct ids, both are %s", first.ID)
+	}
+
+	f, err := os.Open(filepath.Join(dest, first.Manifest))
+	if err != nil {
+		t.Fatalf("open manifest: %v", err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("parse manifest: %v", err)
+	}
+	got := make([]string, 0, len(rows))
+	for _, r := range rows {
+		got = append(got, r[0]+" "+r[1]+" "+r[2])
+	}
+	want := []string{
+		"action src dest",
+		engine.EventCopied + " " + filepath.Join(src, "alpha.txt") + " " + filepath.Join(dest, "documents", "alpha.txt"),
+		engine.EventSkippedDuplicate + " " + filepath.Join(src, "bravo.txt") + " " + filepath.Join(dest, "documents", "alpha.txt"),
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected manifest:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestCLI_DryRunLeavesNoAuditTrail(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "doc")
+
+	res := runCLI(t, workspace, "-dry-run", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dest, engine.StateDir)); !os.IsNotExist(err) {
+		t.Fatalf("dry run must not write state, got %v", err)
+	}
+}
+
+func TestCLI_EarlierManifestsSeedTheDedupIndex(t *testing.T) {
+	workspace := t.TempDir()
+	first := filepath.Join(workspace, "first")
+	second := filepath.Join(workspace, "second")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, first)
+	mustMkdir(t, second)
+	writeFile(t, first, "alpha.txt", "doc")
+	writeFile(t, second, "renamed.txt", "doc")
+	writeFile(t, second, "bravo.txt", "new")
+
+	for _, src := range []string{first, second} {
+		res := runCLI(t, workspace, absPath(t, src), absPath(t, dest))
+		if res.err != nil {
+			t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dest, "documents", "renamed.txt")); !os.IsNotExist(err) {
+		t.Fatalf("content copied by the first run must not be copied again, got %v", err)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "bravo.txt"), "new")
+
+	// A copy that changed since its run was recorded is not trusted.
+	writeFile(t, filepath.Join(dest, "documents"), "alpha.txt", "edited")
+	res := runCLI(t, workspace, absPath(t, second), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "renamed.txt"), "doc")
+}
+
+func TestCLI_HistoryListsRuns(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "doc")
+
+	res := runCLI(t, workspace, "history", absPath(t, dest))
+	if res.exitCode == 0 || !strings.Contains(res.stderr, "no matching runs") {
+		t.Fatalf("expected an error before any run, got exit %d, stderr: %s", res.exitCode, res.stderr)
+	}
+
+	for i := 0; i < 2; i++ {
+		res := runCLI(t, workspace, absPath(t, src), absPath(t, dest))
+		if res.err != nil {
+			t.Fatalf("run %d: expected success, got error: %v, stderr: %s", i, res.err, res.stderr)
+		}
+	}
+
+	res = runCLI(t, workspace, "history", "-since", "1h", absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(res.stdout), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "started") || !strings.Contains(lines[1], "completed") {
+		t.Fatalf("expected a header and 2 runs, got:\n%s", res.stdout)
+	}
+
+	res = runCLI(t, workspace, "history", "-json", "-last", "1", absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	var rec engine.RunRecord
+	if err := json.Unmarshal([]byte(res.stdout), &rec); err != nil {
+		t.Fatalf("parse history: %v\n%s", err, res.stdout)
+	}
+	if rec.Counts[engine.EventSkippedPresent] != 1 || rec.Status != "completed" {
+		t.Fatalf("expected the second run, got %+v", rec)
+	}
+
+	res = runCLI(t, workspace, "history", "-status", "failed", absPath(t, dest))
+	if res.exitCode == 0 {
+		t.Fatalf("expected no failed runs, got:\n%s", res.stdout)
+	}
+
+	res = runCLI(t, workspace, "history", "-h")
+	if res.err != nil || !strings.Contains(res.stdout, "<dest>/.classifier/history") {
+		t.Fatalf("expected the help to name the audit log, got error %v:\n%s", res.err, res.stdout)
+	}
+	res = runCLI(t, workspace, "-lang", "ja", "history", "-h")
+	if res.err != nil || !strings.Contains(res.stdout, "監査ログ") {
+		t.Fatalf("expected the help in Japanese, got error %v:\n%s", res.err, res.stdout)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+func TestCLI_HTMLReportShowsDuplicatesWithThumbnails(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+
+	content := noisyPNG(t, 800, 600)
+	writeFile(t, src, "a.png", content)
+	writeFile(t, src, "b.png", content)
+
+	res := runCLI(t, workspace, "-html-report", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	report := readFile(t, filepath.Join(dest, engine.StateDir, "duplicates.html"))
+	for _, want := range []string{
+		"1 duplicate groups",
+		filepath.Join(dest, "images", "a.png"),
+		filepath.Join(src, "b.png"),
+		`<figure class="kept">`,
+		`src="data:image/jpeg;base64,`,
+	} {
+		if !strings.Contains(report, want) {
+			t.Fatalf("expected %q in report:\n%.2000s", want, report)
+		}
+	}
+}
+
+// noisyPNG returns a PNG whose random pixels keep it above the tiny-image
+// threshold.
+func noisyPNG(t *testing.T, w, h int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	seed := uint32(1)
+	for i := range img.Pix {
+		seed = seed*1664525 + 1013904223
+		img.Pix[i] = uint8(seed >> 24)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	if buf.Len() < 1<<20 { // smaller images are skipped
+		t.Fatalf("test image too small: %d bytes", buf.Len())
+	}
+	return buf.String()
+}
+
+func TestCLI_HTMLReportIsOptional(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "a.txt", "same")
+	writeFile(t, src, "b.txt", "same")
+
+	res := runCLI(t, workspace, absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dest, engine.StateDir, "duplicates.html")); !os.IsNotExist(err) {
+		t.Fatalf("expected no HTML report without -html-report, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// languages are the languages messages can be shown in, English first.
+var languages = []string{"en", "ja"}
+
+// catalogs holds the translations of each language other than English,
+// keyed by the English message or format. Messages without a translation
+// are shown in English, as are those of the engine and the reports, which
+// scripts read.
+var catalogs = map[string]map[string]string{
+	"ja": messagesJA,
+}
+
+// messageLang is the language messages are shown in.
+var messageLang = "en"
+
+// tr returns msg in the language of the run.
+func tr(msg string) string {
+	if t, ok := catalogs[messageLang][msg]; ok {
+		return t
+	}
+	return msg
+}
+
+// trf formats args with the translation of format; translations may
+// reorder their arguments with explicit indexes such as %[2]d.
+func trf(format string, args ...any) string {
+	return fmt.Sprintf(tr(format), args...)
+}
+
+// localeLanguage picks the language of messages from the locale
+// environment, looking at LC_ALL, LC_MESSAGES and LANG in that order as
+// setlocale(3) does. Unsupported locales, C and POSIX give English.
+func localeLanguage(getenv func(string) string) string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := getenv(name); v != "" {
+			if lang, ok := parseLanguage(v); ok {
+				return lang
+			}
+			return "en"
+		}
+	}
+	return "en"
+}
+
+// parseLanguage returns the supported language of a locale or language
+// name such as ja, ja_JP.UTF-8 or ja-JP.
+func parseLanguage(v string) (string, bool) {
+	lang := strings.ToLower(v)
+	if i := strings.IndexAny(lang, "_-.@"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang, slices.Contains(languages, lang)
+}
+
+// takeLangFlag takes -lang out of args, wherever it is before "--", so it
+// applies to every subcommand, and returns the language it names, or ""
+// without it.
+func takeLangFlag(args []string) ([]string, string, error) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "lang" {
+			continue
+		}
+		rest := slices.Clone(args[:i])
+		if hasValue {
+			rest = append(rest, args[i+1:]...)
+		} else if i+1 < len(args) {
+			value = args[i+1]
+			rest = append(rest, args[i+2:]...)
+		} else {
+			return nil, "", fmt.Errorf("flag needs an argument: -lang")
+		}
+		lang, ok := parseLanguage(value)
+		if !ok {
+			return nil, "", fmt.Errorf("invalid value %q for flag -lang: want one of %s", value, strings.Join(languages, ", "))
+		}
+		return rest, lang, nil
+	}
+	return args, "", nil
+}
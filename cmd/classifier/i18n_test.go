@@ -0,0 +1,117 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestLocaleLanguage(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want string
+	}{
+		{env: nil, want: "en"},
+		{env: map[string]string{"LANG": "ja_JP.UTF-8"}, want: "ja"},
+		{env: map[string]string{"LANG": "ja"}, want: "ja"},
+		{env: map[string]string{"LANG": "fr_FR.UTF-8"}, want: "en"},
+		{env: map[string]string{"LC_MESSAGES": "ja_JP.UTF-8", "LANG": "en_US.UTF-8"}, want: "ja"},
+		{env: map[string]string{"LC_ALL": "C", "LANG": "ja_JP.UTF-8"}, want: "en"},
+		{env: map[string]string{"LC_ALL": "ja_JP.eucJP@euro"}, want: "ja"},
+	}
+	for _, tt := range tests {
+		if got := localeLanguage(func(name string) string { return tt.env[name] }); got != tt.want {
+			t.Fatalf("localeLanguage(%v) = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestTakeLangFlag(t *testing.T) {
+	tests := []struct {
+		args     []string
+		wantArgs []string
+		wantLang string
+		wantErr  bool
+	}{
+		{args: []string{"-dry-run", "/src", "/dest"}, wantArgs: []string{"-dry-run", "/src", "/dest"}},
+		{args: []string{"-lang", "ja", "-dry-run", "/src", "/dest"}, wantArgs: []string{"-dry-run", "/src", "/dest"}, wantLang: "ja"},
+		{args: []string{"stats", "--lang=en", "/dest"}, wantArgs: []string{"stats", "/dest"}, wantLang: "en"},
+		{args: []string{"-lang=ja-JP", "verify", "/dest"}, wantArgs: []string{"verify", "/dest"}, wantLang: "ja"},
+		{args: []string{"service", "install", "--", "-lang", "ja"}, wantArgs: []string{"service", "install", "--", "-lang", "ja"}},
+		{args: []string{"-lang", "fr", "/src", "/dest"}, wantErr: true},
+		{args: []string{"/src", "/dest", "-lang"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		args, lang, err := takeLangFlag(tt.args)
+		if tt.wantErr {
+			if err == nil {
+				t.Fatalf("takeLangFlag(%q): expected error, got %q, %q", tt.args, args, lang)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(args, tt.wantArgs) || lang != tt.wantLang {
+			t.Fatalf("takeLangFlag(%q) = %q, %q, %v; want %q, %q", tt.args, args, lang, err, tt.wantArgs, tt.wantLang)
+		}
+	}
+}
+
+// TestCatalogsKeepTheVerbs checks that every translation formats the same
+// arguments as its English message.
+func TestCatalogsKeepTheVerbs(t *testing.T) {
+	verb := regexp.MustCompile(`%(\[\d+\])?[-+# 0]*[\d.]*[a-zA-Z%]`)
+	verbs := func(s string) []string {
+		var out []string
+		for _, m := range verb.FindAllString(s, -1) {
+			out = append(out, regexp.MustCompile(`\[\d+\]`).ReplaceAllString(m, ""))
+		}
+		slices.Sort(out)
+		return out
+	}
+	for lang, catalog := range catalogs {
+		for msg, translated := range catalog {
+			if !slices.Equal(verbs(msg), verbs(translated)) {
+				t.Errorf("%s: %q formats %q, but %q formats %q", lang, translated, verbs(translated), msg, verbs(msg))
+			}
+		}
+	}
+}
+
+func TestCLI_JapaneseMessages(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	mustMkdir(t, src)
+
+	res := runCLIEnv(t, []string{"LC_ALL=ja_JP.UTF-8"}, workspace, "-sample", absPath(t, src), absPath(t, filepath.Join(workspace, "dest")))
+	if res.exitCode == 0 || !strings.Contains(res.stderr, "-sample には -limit が必要です; 使い方: classifier") {
+		t.Fatalf("expected a Japanese usage error, got exit %d, stderr: %s", res.exitCode, res.stderr)
+	}
+	res = runCLIEnv(t, []string{"LC_ALL=ja_JP.UTF-8"}, workspace, "-sample", "-lang", "en", absPath(t, src), absPath(t, filepath.Join(workspace, "dest")))
+	if res.exitCode == 0 || !strings.Contains(res.stderr, "-sample requires -limit; usage: classifier") {
+		t.Fatalf("expected -lang en to win over the locale, got exit %d, stderr: %s", res.exitCode, res.stderr)
+	}
+
+	// Every flag of a run is described in Japanese.
+	res = runCLI(t, workspace, "-lang", "ja", "-h")
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	if !strings.HasPrefix(res.stdout, "使い方: classifier [フラグ]") {
+		t.Fatalf("expected Japanese usage, got: %s", res.stdout)
+	}
+	lines := strings.Split(res.stdout, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "  -") {
+			continue
+		}
+		usage := strings.TrimSpace(strings.TrimPrefix(line, "  "))
+		if i+1 < len(lines) && strings.HasPrefix(lines[i+1], "    \t") {
+			usage = lines[i+1]
+		}
+		if utf8.RuneCountInString(usage) == len(usage) {
+			t.Errorf("flag without a Japanese description: %s", line)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+// duplicatePrompt returns the engine.Options.ResolveDuplicate of
+// -interactive: it shows both files and reads keep, skip or rename from in.
+// An answer in capitals applies to every further duplicate of the same
+// kind, exact or look-alike, for the rest of the run. Without an answer
+// the run goes on as it would without -interactive.
+func duplicatePrompt(in io.Reader, out io.Writer) func(engine.Duplicate) engine.DuplicateResolution {
+	r := bufio.NewReader(in)
+	// always holds the "apply to all" answers by whether the duplicate is
+	// exact.
+	always := make(map[bool]string)
+	return func(d engine.Duplicate) engine.DuplicateResolution {
+		if action, ok := always[d.Exact]; ok {
+			return engine.DuplicateResolution{Action: action}
+		}
+		fallback := engine.DuplicateKeep
+		if d.Exact {
+			fallback = engine.DuplicateSkip
+			fmt.Fprintln(out, tr("duplicate: same content as a file already placed"))
+		} else {
+			fmt.Fprintf(out, tr("near-duplicate: looks like a file already placed (similarity %.2f)\n"), d.Score)
+		}
+		fmt.Fprintf(out, tr("  new:      %s\n"), describeFile(d.Src))
+		fmt.Fprintf(out, tr("  existing: %s\n"), describeFile(d.Existing))
+		for {
+			fmt.Fprint(out, tr("[k]eep, [s]kip or [r]ename it? K or S for all such files: "))
+			answer, err := r.ReadString('\n')
+			if err != nil && answer == "" {
+				fmt.Fprintln(out)
+				return engine.DuplicateResolution{Action: fallback}
+			}
+			switch strings.TrimSpace(answer) {
+			case "k", "keep":
+				return engine.DuplicateResolution{Action: engine.DuplicateKeep}
+			case "s", "skip":
+				return engine.DuplicateResolution{Action: engine.DuplicateSkip}
+			case "K":
+				always[d.Exact] = engine.DuplicateKeep
+				return engine.DuplicateResolution{Action: engine.DuplicateKeep}
+			case "S":
+				always[d.Exact] = engine.DuplicateSkip
+				return engine.DuplicateResolution{Action: engine.DuplicateSkip}
+			case "r", "rename":
+				fmt.Fprint(out, tr("new name: "))
+				name, _ := r.ReadString('\n')
+				name = strings.TrimSpace(name)
+				if name != "" && !strings.ContainsAny(name, `/\`) && name != "." && name != ".." {
+					return engine.DuplicateResolution{Action: engine.DuplicateRename, Name: name}
+				}
+				fmt.Fprintf(out, tr("%q is not a file name\n"), name)
+			}
+		}
+	}
+}
+
+// describeFile prints path with its size, modification time and, for
+// images, pixel size.
+func describeFile(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Sprintf("%s (%v)", path, err)
+	}
+	desc := fmt.Sprintf("%s  %s  %s", path, engine.FormatBytes(uint64(info.Size())), info.ModTime().Format("2006-01-02 15:04"))
+	if w, h, err := engine.ImageDimensions(path); err == nil {
+		desc += fmt.Sprintf("  %dx%d", w, h)
+	}
+	return desc
+}
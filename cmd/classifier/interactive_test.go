@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+func TestDuplicatePrompt(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "same")
+	writeFile(t, dir, "b.txt", "same")
+	exact := engine.Duplicate{Src: filepath.Join(dir, "b.txt"), Existing: filepath.Join(dir, "a.txt"), Exact: true, Score: 1}
+	near := engine.Duplicate{Src: filepath.Join(dir, "b.txt"), Existing: filepath.Join(dir, "a.txt"), Score: 0.95}
+
+	var out bytes.Buffer
+	resolve := duplicatePrompt(strings.NewReader("what\nK\nr\nsub/dir\nr\nb (2).txt\n"), &out)
+	want := []engine.DuplicateResolution{
+		{Action: engine.DuplicateKeep},
+		// Kept without asking again.
+		{Action: engine.DuplicateKeep},
+		{Action: engine.DuplicateRename, Name: "b (2).txt"},
+		// No answer left: look-alikes are copied as without -interactive.
+		{Action: engine.DuplicateKeep},
+	}
+	for i, d := range []engine.Duplicate{exact, exact, near, near} {
+		if got := resolve(d); got != want[i] {
+			t.Fatalf("answer %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+	if !strings.Contains(out.String(), `"sub/dir" is not a file name`) || !strings.Contains(out.String(), "similarity 0.95") {
+		t.Fatalf("unexpected prompts:\n%s", out.String())
+	}
+}
+
+func TestRunResolvesDuplicates(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "a.txt", "same")
+	writeFile(t, src, "b.txt", "same")
+	gradient := image.NewGray(image.Rect(0, 0, 64, 48))
+	for i := range gradient.Pix {
+		gradient.Pix[i] = uint8(i % 64 * 4)
+	}
+	for name, q := range map[string]int{"a.jpg": 95, "b.jpg": 40} {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, gradient, &jpeg.Options{Quality: q}); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, src, name, buf.String())
+	}
+	cfg, _, err := loadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var asked []engine.Duplicate
+	_, err = engine.Run(context.Background(), engine.Options{
+		Config: cfg,
+		Source: src,
+		Dest:   dest,
+		// The test images are tiny.
+		MinImageBytes: -1,
+		ResolveDuplicate: func(d engine.Duplicate) engine.DuplicateResolution {
+			asked = append(asked, d)
+			if d.Exact {
+				return engine.DuplicateResolution{Action: engine.DuplicateRename, Name: "b-copy.txt"}
+			}
+			return engine.DuplicateResolution{Action: engine.DuplicateSkip}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(asked) != 2 {
+		t.Fatalf("expected to be asked about b.txt and b.jpg, got %+v", asked)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "a.txt"), "same")
+	assertFileContent(t, filepath.Join(dest, "documents", "b-copy.txt"), "same")
+	images, err := filepath.Glob(filepath.Join(dest, "images", "*.jpg"))
+	if err != nil || len(images) != 1 {
+		t.Fatalf("expected b.jpg to be left out, got %v, %v", images, err)
+	}
+}
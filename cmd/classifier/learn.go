@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+const learnUsage = "usage: classifier learn <organized-abs-dir>"
+
+// learnedFragment is the part of the config that learn prints.
+type learnedFragment struct {
+	Categories   []engine.Category `yaml:"categories"`
+	DatePatterns []string          `yaml:"date_patterns,omitempty"`
+	DateLayout   string            `yaml:"date_layout,omitempty"`
+}
+
+// runLearn prints a config fragment with the rules a tree organized by hand
+// follows, one top-level folder per category, to start a config from.
+// Extensions found in several folders are listed in comments, to be settled
+// by hand.
+func runLearn(ctx context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("learn", flag.ContinueOnError)
+	if err := parseFlags(flagSet, args, learnUsage); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return errors.New("expected 1 argument; " + learnUsage)
+	}
+	dir := flagSet.Arg(0)
+	if !filepath.IsAbs(dir) {
+		return errors.New("directory must be an absolute path; " + learnUsage)
+	}
+
+	learned, err := engine.Learn(ctx, dir)
+	if err != nil {
+		return fmt.Errorf("learn from %s: %w", dir, err)
+	}
+	if len(learned.Config.Categories) == 0 {
+		return fmt.Errorf("no files in category folders of %s", dir)
+	}
+
+	fmt.Fprintf(os.Stdout, "# learned from %s: %d files in %d categories\n", dir, learned.Files, len(learned.Config.Categories))
+	for _, ext := range slices.Sorted(maps.Keys(learned.Ambiguous)) {
+		var elsewhere []string
+		for _, folder := range sortedKeys(learned.Ambiguous[ext]) {
+			elsewhere = append(elsewhere, fmt.Sprintf("%d in %s", learned.Ambiguous[ext][folder], folder))
+		}
+		fmt.Fprintf(os.Stdout, "# %s is also found elsewhere: %s\n", ext, strings.Join(elsewhere, ", "))
+	}
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(learnedFragment{
+		Categories:   learned.Config.Categories,
+		DatePatterns: learned.Config.DatePatterns,
+		DateLayout:   learned.Config.DateLayout,
+	}); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
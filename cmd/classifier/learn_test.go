@@ -0,0 +1,64 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+func TestCLI_LearnInfersRulesFromOrganizedTree(t *testing.T) {
+	workspace := t.TempDir()
+	tree := filepath.Join(workspace, "sorted")
+	for _, dir := range []string{"photos/2024/01", "photos/2024/02", "papers", "misc", ".cache"} {
+		mustMkdir(t, filepath.Join(tree, dir))
+	}
+	writeFile(t, filepath.Join(tree, "photos/2024/01"), "IMG_20240105_101010.jpg", "a")
+	writeFile(t, filepath.Join(tree, "photos/2024/02"), "IMG_20240210_101010.JPG", "b")
+	writeFile(t, filepath.Join(tree, "photos/2024/02"), "shot.png", "c")
+	writeFile(t, filepath.Join(tree, "papers"), "tax.pdf", "d")
+	writeFile(t, filepath.Join(tree, "papers"), "notes.txt", "e")
+	writeFile(t, filepath.Join(tree, "misc"), "odd.jpg", "f")
+	writeFile(t, filepath.Join(tree, ".cache"), "thumb.webp", "g")
+	writeFile(t, tree, "loose.zip", "h")
+
+	res := runCLI(t, workspace, "learn", absPath(t, tree))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	if !strings.Contains(res.stdout, "# jpg is also found elsewhere: 1 in misc") {
+		t.Fatalf("expected the stray jpg to be noted, got:\n%s", res.stdout)
+	}
+
+	cfg, err := engine.ParseConfig("learned.yaml", []byte(res.stdout))
+	if err != nil {
+		t.Fatalf("learned fragment is not a valid config: %v\n%s", err, res.stdout)
+	}
+	var got []string
+	for _, c := range cfg.Categories {
+		got = append(got, c.Name+":"+strings.Join(c.Extensions, ","))
+	}
+	if want := []string{"papers:pdf,txt", "photos:jpg,png"}; !slices.Equal(got, want) {
+		t.Fatalf("expected categories %v, got %v", want, got)
+	}
+	if len(cfg.DatePatterns) != 1 || !strings.HasPrefix(cfg.DatePatterns[0], "^IMG_") {
+		t.Fatalf("expected the IMG_ date pattern, got %v", cfg.DatePatterns)
+	}
+	if cfg.DateLayout != "2006/01" {
+		t.Fatalf("expected date layout 2006/01, got %q", cfg.DateLayout)
+	}
+}
+
+func TestCLI_LearnWithoutCategoryFoldersFails(t *testing.T) {
+	workspace := t.TempDir()
+	tree := filepath.Join(workspace, "flat")
+	mustMkdir(t, tree)
+	writeFile(t, tree, "a.jpg", "a")
+
+	res := runCLI(t, workspace, "learn", absPath(t, tree))
+	if res.err == nil || !strings.Contains(res.stderr, "no files in category folders") {
+		t.Fatalf("expected an error about missing category folders, got %v, stderr: %s", res.err, res.stderr)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Log priorities, as in syslog(3).
+const (
+	prioErr     = 3
+	prioWarning = 4
+	prioNotice  = 5
+	prioInfo    = 6
+)
+
+// logBackend receives the run's diagnostics. stderr shows notices and
+// above; syslog and journald also get informational run summaries.
+type logBackend interface {
+	write(priority int, msg string) error
+}
+
+type stderrLog struct{}
+
+func (stderrLog) write(priority int, msg string) error {
+	switch {
+	case priority <= prioErr:
+		msg = stderrColors.paint(colorRed, msg)
+	case priority == prioWarning:
+		msg = stderrColors.paint(colorYellow, "warning: "+msg)
+	case priority >= prioInfo:
+		return nil
+	}
+	_, err := fmt.Fprintln(os.Stderr, msg)
+	return err
+}
+
+var (
+	logMu       sync.Mutex
+	diagnostics logBackend = stderrLog{}
+)
+
+// openLog selects the backend named by -log.
+func openLog(name string) (logBackend, error) {
+	switch name {
+	case "stderr":
+		return stderrLog{}, nil
+	case "syslog":
+		return openSyslog()
+	case "journald":
+		return openJournald()
+	}
+	return nil, fmt.Errorf("unknown -log %q", name)
+}
+
+func setLogBackend(b logBackend) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	diagnostics = b
+}
+
+// logf sends a message to the selected backend, falling back to stderr when
+// the backend cannot take it.
+func logf(priority int, format string, args ...any) {
+	logMu.Lock()
+	b := diagnostics
+	logMu.Unlock()
+	msg := fmt.Sprintf(format, args...)
+	if err := b.write(priority, msg); err != nil {
+		if _, ok := b.(stderrLog); !ok {
+			stderrLog{}.write(priority, msg)
+		}
+	}
+}
+
+func warnf(format string, args ...any) {
+	logf(prioWarning, format, args...)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// journalSocket is where systemd-journald accepts native protocol datagrams.
+var journalSocket = "/run/systemd/journal/socket"
+
+type journaldBackend struct {
+	conn *net.UnixConn
+}
+
+func openJournald() (logBackend, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("connect to journald: %w", err)
+	}
+	return journaldBackend{conn: conn}, nil
+}
+
+func (j journaldBackend) write(priority int, msg string) error {
+	var b bytes.Buffer
+	journalField(&b, "PRIORITY", strconv.Itoa(priority))
+	journalField(&b, "SYSLOG_IDENTIFIER", "classifier")
+	journalField(&b, "MESSAGE", msg)
+	_, err := j.conn.Write(b.Bytes())
+	return err
+}
+
+// journalField appends one field in the journal's native format; values
+// with newlines use the length-prefixed binary form.
+func journalField(b *bytes.Buffer, key, value string) {
+	b.WriteString(key)
+	if !strings.Contains(value, "\n") {
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+		return
+	}
+	b.WriteByte('\n')
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestJournaldBackendWritesNativeProtocol(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "journal.sock")
+	l, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sock, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+
+	old := journalSocket
+	journalSocket = sock
+	defer func() { journalSocket = old }()
+
+	b, err := openJournald()
+	if err != nil {
+		t.Fatalf("open journald: %v", err)
+	}
+	if err := b.write(prioWarning, "one\ntwo"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	buf := make([]byte, 1024)
+	n, err := l.Read(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var want bytes.Buffer
+	want.WriteString("PRIORITY=4\nSYSLOG_IDENTIFIER=classifier\nMESSAGE\n")
+	binary.Write(&want, binary.LittleEndian, uint64(len("one\ntwo")))
+	want.WriteString("one\ntwo\n")
+	if !bytes.Equal(buf[:n], want.Bytes()) {
+		t.Fatalf("unexpected datagram:\ngot  %q\nwant %q", buf[:n], want.Bytes())
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func openJournald() (logBackend, error) {
+	return nil, errors.New("-log journald is only supported on Linux")
+}
@@ -0,0 +1,9 @@
+//go:build windows || plan9 || js || wasip1
+
+package main
+
+import "errors"
+
+func openSyslog() (logBackend, error) {
+	return nil, errors.New("-log syslog is not supported on this platform")
+}
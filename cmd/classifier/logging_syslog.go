@@ -0,0 +1,32 @@
+//go:build !windows && !plan9 && !js && !wasip1
+
+package main
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+type syslogBackend struct {
+	w *syslog.Writer
+}
+
+func openSyslog() (logBackend, error) {
+	w, err := syslog.New(syslog.LOG_USER|syslog.LOG_INFO, "classifier")
+	if err != nil {
+		return nil, fmt.Errorf("connect to syslog: %w", err)
+	}
+	return syslogBackend{w: w}, nil
+}
+
+func (s syslogBackend) write(priority int, msg string) error {
+	switch {
+	case priority <= prioErr:
+		return s.w.Err(msg)
+	case priority == prioWarning:
+		return s.w.Warning(msg)
+	case priority == prioNotice:
+		return s.w.Notice(msg)
+	}
+	return s.w.Info(msg)
+}
@@ -1,392 +1,765 @@
 package main
 
 import (
-	"crypto/sha256"
-	"embed"
-	"encoding/csv"
+	"bufio"
+	"cmp"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"io/fs"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"regexp"
+	"runtime"
+	"slices"
 	"strings"
+	"syscall"
+	"time"
 
-	"gopkg.in/yaml.v3"
+	"github.com/sky0621/classifier/internal/engine"
+	"github.com/sky0621/classifier/internal/trace"
 )
 
-type config struct {
-	Categories      []category `yaml:"categories"`
-	DefaultCategory string     `yaml:"default_category"`
-	DatePatterns    []string   `yaml:"date_patterns"`
-}
-
-type category struct {
-	Name       string   `yaml:"name"`
-	Extensions []string `yaml:"extensions"`
-}
-
-const minImageSize int64 = 1 << 20 // 1 MiB
-
-//go:embed config.yaml
-var embeddedFS embed.FS
-
-type skippedEntry struct {
-	srcPath  string
-	destPath string
-}
-
-type dateResolver struct {
-	patterns []*regexp.Regexp
-}
+// exitInterrupted is the exit code of a run stopped by SIGINT or SIGTERM.
+const exitInterrupted = 130
 
 func main() {
-	if err := run(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
+	messageLang = localeLanguage(os.Getenv)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	var err error
+	if isWindowsService() {
+		err = runWindowsService(ctx, func(ctx context.Context) error { return run(ctx, os.Args[1:]) })
+	} else {
+		err = run(ctx, os.Args[1:])
+	}
+	stop()
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		logf(prioErr, "%v", err)
+		if errors.Is(err, context.Canceled) {
+			os.Exit(exitInterrupted)
+		}
 		os.Exit(1)
 	}
 }
 
-func run() error {
-	flagSet := flag.NewFlagSet("classifier", flag.ContinueOnError)
-	flagSet.SetOutput(io.Discard)
-	var configPath string
-	flagSet.StringVar(&configPath, "config", "", "path to YAML config file")
-	flagSet.StringVar(&configPath, "c", "", "path to YAML config file")
-
-	if err := flagSet.Parse(os.Args[1:]); err != nil {
-		return err
-	}
-
-	if flagSet.NArg() != 2 {
-		return usageError("expected 2 arguments: <src-abs-dir> <dest-abs-dir>")
-	}
-
-	src := flagSet.Arg(0)
-	dest := flagSet.Arg(1)
-
-	if !filepath.IsAbs(src) || !filepath.IsAbs(dest) {
-		return usageError("source and destination must be absolute paths")
-	}
+// subcommands maps a first argument to its handler; anything else starts a
+// classification run.
+var subcommands = map[string]func(context.Context, []string) error{
+	"config":     runConfig,
+	"dedupe":     runDedupe,
+	"diff":       runDiff,
+	"export":     runExport,
+	"history":    runHistory,
+	"learn":      runLearn,
+	"merge":      runMerge,
+	"migrate":    runMigrate,
+	"prune":      runPrune,
+	"reclassify": runReclassify,
+	"report":     runReport,
+	"scan":       runScan,
+	"service":    runService,
+	"undo":       runUndo,
+	"stats":      runStats,
+	"verify":     runVerify,
+}
 
-	cfg, err := loadConfig(configPath)
-	if err != nil {
-		return err
-	}
-	resolver := newCategoryResolver(cfg)
-	dateResolver, err := newDateResolver(cfg.DatePatterns)
+func run(ctx context.Context, args []string) error {
+	args, lang, err := takeLangFlag(args)
 	if err != nil {
 		return err
 	}
-
-	srcInfo, err := os.Stat(src)
-	if err != nil {
-		return fmt.Errorf("read source: %w", err)
+	if lang != "" {
+		messageLang = lang
 	}
-	if !srcInfo.IsDir() {
-		return fmt.Errorf("source is not a directory: %s", src)
-	}
-
-	if err := os.MkdirAll(dest, 0o755); err != nil {
-		return fmt.Errorf("create destination: %w", err)
-	}
-
-	hashIndex := make(map[string]string)
-	var skipped []skippedEntry
-
-	walkErr := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
+	if len(args) > 0 {
+		if cmd, ok := subcommands[args[0]]; ok {
+			return cmd(ctx, args[1:])
 		}
+	}
+	return runClassify(ctx, args)
+}
 
-		info, err := d.Info()
-		if err != nil {
-			return fmt.Errorf("stat source entry %s: %w", path, err)
+func runClassify(ctx context.Context, args []string) (err error) {
+	flagSet := flag.NewFlagSet("classifier", flag.ContinueOnError)
+	var configPath string
+	flagSet.StringVar(&configPath, "config", "", "path or http(s) URL of the YAML config; pin a URL's content with #sha256=<hex>")
+	flagSet.StringVar(&configPath, "c", "", "path or http(s) URL of the YAML config")
+	var profile string
+	flagSet.StringVar(&profile, "profile", "", "use this profile of the config; with a dest in the profile, <dest-abs-dir> may be left out")
+	var noSpaceCheck bool
+	flagSet.BoolVar(&noSpaceCheck, "no-space-check", false, "skip the pre-flight free-space check")
+	hashAlgorithm := "sha256"
+	flagSet.Func("hash", "content hash duplicates are detected by: sha256 (default), sha512, or sha256-tree, which hashes files of 64 MiB and more on every core", func(v string) error {
+		if !slices.Contains(engine.HashAlgorithms, v) {
+			return fmt.Errorf("want one of %s", strings.Join(engine.HashAlgorithms, ", "))
 		}
-		if !info.Mode().IsRegular() {
-			// Skip non-regular files (symlinks, devices, etc.).
-			return nil
+		hashAlgorithm = v
+		return nil
+	})
+	var noHashCache bool
+	flagSet.BoolVar(&noHashCache, "no-hash-cache", false, "hash every source file instead of reusing the hashes of files unchanged since an earlier run (same device, inode, size and modification time)")
+	var fileMode modeFlag
+	flagSet.Var(&fileMode, "file-mode", "create every copy with this octal mode (e.g. 0644) instead of the mode of its source; filesystems without permissions get 0644 unless set")
+	var noXattrs bool
+	flagSet.BoolVar(&noXattrs, "no-xattrs", false, "do not record the source path and run id of copies in the extended attributes "+engine.XattrSource+" and "+engine.XattrRunID)
+	var maxBytes sizeFlag
+	flagSet.Var(&maxBytes, "max-bytes", "stop cleanly after copying this many bytes (e.g. 32G); re-run to resume")
+	var maxDuration time.Duration
+	flagSet.DurationVar(&maxDuration, "max-duration", 0, "stop cleanly, between two files, after running this long (e.g. 2h); re-run to resume")
+	var fileTimeout time.Duration
+	flagSet.DurationVar(&fileTimeout, "file-timeout", 0, "give up hashing or copying a single file after this long (e.g. 2m); 0 disables")
+	var retries int
+	flagSet.IntVar(&retries, "retries", 0, "retry transient I/O errors on a file, such as a file another program has open on Windows, this many times")
+	var retryBackoff time.Duration
+	flagSet.DurationVar(&retryBackoff, "retry-backoff", time.Second, "initial delay between retries, doubled after each attempt")
+	var verify bool
+	flagSet.BoolVar(&verify, "verify", false, "re-hash every copy once it is in place and copy it again if it differs from the source")
+	var verifyRetries int
+	flagSet.IntVar(&verifyRetries, "verify-retries", 2, "with -verify, copy a file this many more times before reporting it as failed")
+	var dryRun bool
+	flagSet.BoolVar(&dryRun, "dry-run", false, "print what would be copied or deleted without writing anything")
+	var syncDeletions, force bool
+	flagSet.BoolVar(&syncDeletions, "sync-deletions", false, "take destination files out whose source files were deleted since they were copied; asks first unless -force")
+	flagSet.BoolVar(&force, "force", false, "with -sync-deletions, do not ask before taking files out")
+	var deleteSource bool
+	flagSet.BoolVar(&deleteSource, "delete-source", false, "delete each source file after its copies are fsynced and hash-verified")
+	var assertReadOnly bool
+	flagSet.BoolVar(&assertReadOnly, "assert-readonly", false, "guarantee the source is left untouched, e.g. a mounted evidence or backup image: refuse -delete-source and -hydrate-placeholders and fail any write below the source")
+	var sandboxed bool
+	flagSet.BoolVar(&sandboxed, "sandbox", false, "on Linux, have the kernel (Landlock) refuse every write outside the destinations and category roots, post commands included; a -dry-run may write nowhere")
+	var useTrash bool
+	flagSet.BoolVar(&useTrash, "trash", false, "with -delete-source, move sources to the OS trash instead of deleting them")
+	var mirrors []string
+	var relativePaths bool
+	flagSet.BoolVar(&relativePaths, "relative-paths", false, "record paths in manifests, warn.csv, errors.csv and skipped.csv relative to the source and destination, with src_root and dest_root columns, so they stay valid when drives are mounted elsewhere")
+	var reportsDir string
+	flagSet.StringVar(&reportsDir, "reports-dir", "", "absolute path of the directory warn.csv, errors.csv, skipped.csv, unknown.csv and duplicates.html are written to (default <dest>/"+engine.StateDir+"); later runs leave it out")
+	var appendReports bool
+	flagSet.BoolVar(&appendReports, "append-reports", false, "append to warn.csv, errors.csv and skipped.csv instead of rewriting them every run, with the run id and start time of every row in two more columns")
+	var htmlReport bool
+	flagSet.BoolVar(&htmlReport, "html-report", false, "also write duplicates.html showing skipped duplicates next to the kept files")
+	var thumbnails bool
+	flagSet.BoolVar(&thumbnails, "thumbnails", false, "write a thumbnail of every copied image and movie to <dest>/"+engine.ThumbnailDir+" (movies need ffmpeg)")
+	var sidecars bool
+	flagSet.BoolVar(&sidecars, "sidecars", false, "write <name>"+engine.SidecarExt+" next to every copied file with its source path, modification time, hash and run id")
+	forks := engine.ForksCopy
+	flagSet.Func("forks", "what happens to AppleDouble ._ files and NTFS alternate data streams: copy (default) puts them next to the copies of their files, strip leaves them out; skipped.csv lists those left behind", func(v string) error {
+		if !slices.Contains(engine.ForkModes, v) {
+			return fmt.Errorf("want one of %s", strings.Join(engine.ForkModes, ", "))
 		}
-
-		name := d.Name()
-		category := resolver.categoryFor(name)
-
-		if category == "images" && info.Size() < minImageSize {
-			// Skip tiny images to avoid noise.
-			return nil
+		forks = v
+		return nil
+	})
+	nameConflicts := engine.NameConflictsSuffix
+	flagSet.Func("name-conflicts", "how a copy is named when another file of the same name is in its folder: suffix (default) numbers it (IMG_1.jpg), folder adds the name of its source folder first (IMG__holiday.jpg)", func(v string) error {
+		if !slices.Contains(engine.NameConflictModes, v) {
+			return fmt.Errorf("want one of %s", strings.Join(engine.NameConflictModes, ", "))
 		}
-
-		hash, err := fileHash(path)
-		if err != nil {
-			return err
+		nameConflicts = v
+		return nil
+	})
+	var thumbnailSize int
+	flagSet.IntVar(&thumbnailSize, "thumbnail-size", engine.DefaultThumbnailSize, "longest edge of a thumbnail in pixels")
+	var postJobs int
+	flagSet.IntVar(&postJobs, "post-jobs", runtime.NumCPU(), "run at most this many post_command processes of the config at once")
+	var logTo string
+	flagSet.StringVar(&logTo, "log", "stderr", "where to send warnings and errors: stderr, syslog or journald")
+	var filesFrom string
+	flagSet.StringVar(&filesFrom, "files-from", "", "classify only the absolute paths listed in this file (- for stdin), one per line or NUL-separated, instead of walking <src-abs-dir>")
+	var newerThan, olderThan timeFlag
+	flagSet.Var(&newerThan, "newer-than", "only classify files modified at or after this date or within this age (e.g. 2024-01-31, 30d, 2w)")
+	flagSet.Var(&olderThan, "older-than", "only classify files modified before this date or more than this age ago")
+	var minAge ageFlag
+	flagSet.Var(&minAge, "min-age", "leave files modified less than this long ago for a later run, e.g. downloads still being written (e.g. 10m, 7d); with -watch, checked again on every pass")
+	var minSize, maxSize sizeFlag
+	flagSet.Var(&minSize, "min-size", "only classify files of at least this size (e.g. 100K)")
+	flagSet.Var(&maxSize, "max-size", "only classify files of at most this size (e.g. 4G)")
+	minImageBytes := sizeFlag(engine.DefaultMinImageBytes)
+	flagSet.Var(&minImageBytes, "min-image-bytes", "skip images smaller than this as too small to keep; 0 turns the check off")
+	var minImageDimensions dimensionsFlag
+	flagSet.Var(&minImageDimensions, "min-image-dimensions", "also skip images with fewer pixels than WIDTHxHEIGHT in either orientation (e.g. 640x480), read from the image header; use with -min-image-bytes 0 to go by pixels only")
+	var normalizeNames bool
+	flagSet.BoolVar(&normalizeNames, "normalize-names", false, "name destination files in Unicode NFC and treat NFC and NFD spellings of a name as the same name")
+	var sanitizeNames bool
+	flagSet.BoolVar(&sanitizeNames, "sanitize-names", false, "make destination file names valid on NTFS and exFAT drives: replace : ? * < > | \" \\, drop trailing dots and spaces and shorten long names")
+	var shardSize int
+	flagSet.IntVar(&shardSize, "shard-size", 0, "once a destination folder holds this many files, put further ones in numbered sub-folders 0001, 0002, ...; 0 disables")
+	var interactive bool
+	flagSet.BoolVar(&interactive, "interactive", false, "ask whether to keep, skip or rename each duplicate and each image that looks like one copied earlier in the run")
+	var contentAddressed bool
+	flagSet.BoolVar(&contentAddressed, "content-addressed", false, "store every distinct content once below <dest>/"+engine.CASDir+", named after its hash, and make the category and date folders of hard links to it")
+	var hydratePlaceholders bool
+	flagSet.BoolVar(&hydratePlaceholders, "hydrate-placeholders", false, "download cloud placeholders (OneDrive online-only, evicted iCloud files) by reading them instead of skipping them")
+	var skipHidden bool
+	flagSet.BoolVar(&skipHidden, "skip-hidden", false, "ignore dotfiles and dot-directories, and files with the Windows hidden attribute")
+	var maxDepth int
+	flagSet.IntVar(&maxDepth, "max-depth", 0, "only take files this many levels below <src-abs-dir>; 1 is the top level only, 0 means no limit")
+	var limit int
+	flagSet.IntVar(&limit, "limit", 0, "stop after planning this many files, e.g. to try a new config; 0 means no limit")
+	var sample bool
+	flagSet.BoolVar(&sample, "sample", false, "with -limit, pick the files at random from the whole source instead of taking the first ones")
+	order := engine.OrderLexical
+	flagSet.Func("order", "processing order: lexical (default; deterministic duplicate winners), newest or largest first", func(v string) error {
+		if !slices.Contains(engine.ProcessingOrders, v) {
+			return fmt.Errorf("want one of %s", strings.Join(engine.ProcessingOrders, ", "))
 		}
-		if existingPath, exists := hashIndex[hash]; exists {
-			skipped = append(skipped, skippedEntry{srcPath: path, destPath: existingPath})
+		order = v
+		return nil
+	})
+	var otlp string
+	flagSet.StringVar(&otlp, "otlp-endpoint", "", "export OpenTelemetry traces to this OTLP/HTTP URL (default: $OTEL_EXPORTER_OTLP_ENDPOINT/v1/traces)")
+	var noColor bool
+	flagSet.BoolVar(&noColor, "no-color", false, "disable colored terminal output (also NO_COLOR)")
+	var watch time.Duration
+	flagSet.DurationVar(&watch, "watch", 0, "keep running and classify the source again this long after each pass (e.g. 10m); SIGHUP reloads the config, SIGTERM stops. Without <src-abs-dir> <dest-abs-dir>, classify the sources of the config")
+	var healthAddr string
+	flagSet.StringVar(&healthAddr, "health-addr", "", "with -watch, serve /healthz, /readyz and a JSON /status of the current pass on this address (e.g. :8080)")
+	var ci ciReport
+	flagSet.StringVar(&ci.junit, "junit", "", "write the errors and warnings of the run to this file as JUnit XML, for CI systems")
+	flagSet.BoolVar(&ci.annotations, "github-annotations", false, "print the errors and warnings of the run as GitHub Actions annotations")
+	var output string
+	flagSet.StringVar(&output, "output", "text", "output format: text, or ndjson to stream one JSON event per decision to stdout")
+	var allowMIME, denyMIME []string
+	mimeList := func(list *[]string) func(string) error {
+		return func(v string) error {
+			for _, pattern := range strings.Split(v, ",") {
+				if err := engine.CheckMIMEPattern(pattern); err != nil {
+					return err
+				}
+				*list = append(*list, pattern)
+			}
 			return nil
 		}
-
-		targetDir := filepath.Join(dest, category)
-		if category == "images" || category == "movies" {
-			if year, ym, ok := dateResolver.resolve(name); ok {
-				targetDir = filepath.Join(targetDir, year, ym)
+	}
+	flagSet.Func("allow-mime", "only classify files whose MIME type, sniffed from their content, matches one of these comma-separated types or patterns (e.g. image/*,video/*; repeatable)", mimeList(&allowMIME))
+	flagSet.Func("deny-mime", "leave out files whose sniffed MIME type matches one of these comma-separated types or patterns (e.g. application/x-executable; repeatable)", mimeList(&denyMIME))
+	var only, skipCategories []string
+	categoryList := func(list *[]string) func(string) error {
+		return func(v string) error {
+			for _, name := range strings.Split(v, ",") {
+				if name == "" {
+					return errors.New("empty category name")
+				}
+				*list = append(*list, name)
 			}
+			return nil
 		}
-		if err := os.MkdirAll(targetDir, 0o755); err != nil {
-			return fmt.Errorf("create category directory %s: %w", targetDir, err)
-		}
-
-		finalPath, err := uniqueDestPath(targetDir, name)
-		if err != nil {
-			return err
-		}
-
-		if err := copyFile(path, finalPath, info.Mode()); err != nil {
-			return err
-		}
-
-		hashIndex[hash] = finalPath
-
+	}
+	flagSet.Func("only", "only classify files of these comma-separated categories (e.g. images,movies; repeatable)", categoryList(&only))
+	flagSet.Func("skip-category", "leave out files of these comma-separated categories (e.g. others; repeatable)", categoryList(&skipCategories))
+	flagSet.Func("mirror", "additional absolute destination to mirror output to (repeatable)", func(v string) error {
+		mirrors = append(mirrors, v)
 		return nil
 	})
 
-	if walkErr != nil {
-		return walkErr
+	if err := parseFlags(flagSet, args, helpText()); err != nil {
+		return err
 	}
 
-	if len(skipped) > 0 {
-		if err := writeWarnings(filepath.Join(dest, "warn.csv"), skipped); err != nil {
-			return err
+	var src, dest string
+	// configSources is set when -watch classifies the sources of the config.
+	configSources := filesFrom == "" && flagSet.NArg() == 0 && watch > 0
+	switch {
+	case configSources:
+		// The sources are checked once the config is loaded.
+	case filesFrom != "":
+		if flagSet.NArg() != 1 {
+			return usageError("with -files-from, expected 1 argument: <dest-abs-dir>")
+		}
+		dest = flagSet.Arg(0)
+		if !filepath.IsAbs(dest) {
+			return usageError("destination must be an absolute path")
+		}
+	case flagSet.NArg() == 1 && profile != "":
+		// The destination comes from the profile.
+		src = flagSet.Arg(0)
+		if !filepath.IsAbs(src) {
+			return usageError("source must be an absolute path")
+		}
+	default:
+		if flagSet.NArg() != 2 {
+			return usageError("expected 2 arguments: <src-abs-dir> <dest-abs-dir>")
+		}
+		src = flagSet.Arg(0)
+		dest = flagSet.Arg(1)
+		if !filepath.IsAbs(src) || !filepath.IsAbs(dest) {
+			return usageError("source and destination must be absolute paths")
 		}
 	}
-
-	return nil
-}
-
-func usageError(msg string) error {
-	return errors.New(msg + "; usage: classifier [-config path|-c path] <src-abs-dir> <dest-abs-dir>")
-}
-
-func loadConfig(path string) (config, error) {
-	if path == "" {
-		return loadEmbeddedConfig()
+	if force && !syncDeletions {
+		return usageError("-force requires -sync-deletions")
 	}
-
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return config{}, fmt.Errorf("read config: %w", err)
+	if syncDeletions && filesFrom != "" {
+		return usageError("-sync-deletions cannot be combined with -files-from")
 	}
-
-	var cfg config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return config{}, fmt.Errorf("parse config: %w", err)
+	if syncDeletions && watch > 0 && !force {
+		return usageError("-sync-deletions with -watch requires -force")
 	}
-
-	return cfg, nil
-}
-
-func loadEmbeddedConfig() (config, error) {
-	var cfg config
-	data, err := embeddedFS.ReadFile("config.yaml")
-	if err != nil {
-		return config{}, fmt.Errorf("read embedded config: %w", err)
+	if interactive && (dryRun || watch > 0 || filesFrom == "-") {
+		return usageError("-interactive cannot be combined with -dry-run, -watch or -files-from -")
 	}
-
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return config{}, fmt.Errorf("parse embedded config: %w", err)
+	if interactive && !isTerminal(os.Stdin) {
+		return usageError("-interactive needs a terminal")
 	}
-
-	return cfg, nil
-}
-
-type categoryResolver struct {
-	defaultCategory string
-	extToCategory   map[string]string
-}
-
-func newCategoryResolver(cfg config) categoryResolver {
-	resolver := categoryResolver{
-		defaultCategory: cfg.DefaultCategory,
-		extToCategory:   map[string]string{},
+	if useTrash && !deleteSource {
+		return usageError("-trash requires -delete-source")
 	}
-	if resolver.defaultCategory == "" {
-		resolver.defaultCategory = "others"
+	if assertReadOnly && deleteSource {
+		return usageError("-assert-readonly cannot be combined with -delete-source")
 	}
-
-	for _, cat := range cfg.Categories {
-		for _, ext := range cat.Extensions {
-			clean := strings.TrimPrefix(strings.ToLower(ext), ".")
-			if clean == "" {
-				continue
+	if assertReadOnly && hydratePlaceholders {
+		return usageError("-assert-readonly cannot be combined with -hydrate-placeholders")
+	}
+	if sandboxed && (deleteSource || hydratePlaceholders) {
+		// The sandbox only lets the run write below the destination.
+		return usageError("-sandbox cannot be combined with -delete-source, -trash or -hydrate-placeholders")
+	}
+	if retries < 0 {
+		return usageError("-retries must not be negative")
+	}
+	if verifyRetries < 0 {
+		return usageError("-verify-retries must not be negative")
+	}
+	if limit < 0 {
+		return usageError("-limit must not be negative")
+	}
+	if sample && limit == 0 {
+		return usageError("-sample requires -limit")
+	}
+	if maxDepth < 0 {
+		return usageError("-max-depth must not be negative")
+	}
+	if maxDepth > 0 && filesFrom != "" {
+		return usageError("-max-depth cannot be combined with -files-from")
+	}
+	if maxSize > 0 && minSize > maxSize {
+		return usageError("-min-size must not exceed -max-size")
+	}
+	if now := time.Now(); newerThan.v != "" && olderThan.v != "" && !newerThan.at(now).Before(olderThan.at(now)) {
+		return usageError("-newer-than must be earlier than -older-than")
+	}
+	if maxDuration < 0 {
+		return usageError("-max-duration must not be negative")
+	}
+	if watch < 0 {
+		return usageError("-watch must not be negative")
+	}
+	if watch > 0 && (filesFrom != "" || dryRun || limit > 0) {
+		return usageError("-watch cannot be combined with -files-from, -dry-run or -limit")
+	}
+	if watch > 0 && ci.enabled() {
+		return usageError("-junit and -github-annotations report a single run and cannot be combined with -watch")
+	}
+	if ci.annotations && output == "ndjson" {
+		return usageError("-github-annotations cannot be combined with -output ndjson")
+	}
+	if healthAddr != "" && watch == 0 {
+		return usageError("-health-addr requires -watch")
+	}
+	if thumbnailSize <= 0 {
+		return usageError("-thumbnail-size must be positive")
+	}
+	if noColor {
+		stderrColors = palette{}
+	}
+	backend, err := openLog(logTo)
+	if err != nil {
+		return err
+	}
+	setLogBackend(backend)
+	if endpoint := trace.Endpoint(otlp); endpoint != "" {
+		tr := trace.New(endpoint, versionString())
+		ctx = trace.WithTracer(ctx, tr)
+		var runSpan *trace.Span
+		ctx, runSpan = trace.Start(ctx, "run", map[string]any{"classifier.source": src, "classifier.destination": dest, "classifier.dry_run": dryRun})
+		defer func() {
+			runSpan.Finish(err)
+			if err := tr.Export(ctx); err != nil {
+				warnf("%v", err)
 			}
-			resolver.extToCategory[clean] = cat.Name
+		}()
+	}
+	var events engine.Sink
+	switch output {
+	case "text":
+		if !dryRun && isTerminal(os.Stdout) {
+			colors := newPalette(os.Stdout)
+			colors.enabled = colors.enabled && !noColor
+			events = newTTYEvents(os.Stdout, colors)
+		}
+	case "ndjson":
+		if dryRun {
+			return usageError("-output ndjson cannot be combined with -dry-run")
+		}
+		events = engine.NewNDJSONSink(os.Stdout)
+	default:
+		return usageError("unknown -output %s", output)
+	}
+	switch {
+	case reportsDir != "" && !filepath.IsAbs(reportsDir):
+		return usageError("-reports-dir must be an absolute path")
+	case reportsDir != "" && configSources:
+		return usageError("-reports-dir cannot be combined with the sources of the config, whose destinations keep their own reports")
+	}
+	for _, m := range mirrors {
+		if !filepath.IsAbs(m) {
+			return usageError("mirror destinations must be absolute paths")
+		}
+		if filepath.Clean(m) == filepath.Clean(dest) {
+			return usageError("mirror destination must differ from destination: %s", m)
 		}
 	}
 
-	return resolver
-}
-
-func (r categoryResolver) categoryFor(name string) string {
-	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
-	if ext == "" {
-		return r.defaultCategory
+	cfg, hash, err := loadProfile(configPath, profile)
+	if err != nil {
+		return err
 	}
-	if cat, ok := r.extToCategory[ext]; ok {
-		return cat
+	if src != "" && dest == "" {
+		dest = cfg.Profiles[profile].Dest
+		if dest == "" {
+			return usageError("profile %q has no dest; expected 2 arguments: <src-abs-dir> <dest-abs-dir>", profile)
+		}
+		if !filepath.IsAbs(dest) {
+			return usageError("dest of profile %q must be an absolute path", profile)
+		}
 	}
-	return r.defaultCategory
-}
-
-func newDateResolver(patterns []string) (dateResolver, error) {
-	if len(patterns) == 0 {
-		return dateResolver{}, nil
+	if configSources {
+		if len(cfg.Sources) == 0 {
+			return usageError("expected 2 arguments: <src-abs-dir> <dest-abs-dir>, or -watch with sources in the config")
+		}
+		if err := checkSources(cfg.Sources); err != nil {
+			return err
+		}
 	}
-
-	res := dateResolver{patterns: make([]*regexp.Regexp, 0, len(patterns))}
-	for _, p := range patterns {
-		re, err := regexp.Compile(p)
-		if err != nil {
-			return dateResolver{}, fmt.Errorf("compile date pattern %q: %w", p, err)
+	if sandboxed {
+		if err := sandboxRun(cfg, dest, mirrors, reportsDir, configSources, dryRun); err != nil {
+			return err
 		}
-		res.patterns = append(res.patterns, re)
 	}
-	return res, nil
-}
+	for _, name := range unknownCategories(cfg, append(only, skipCategories...)) {
+		warnf("category %q is not in the config", name)
+	}
 
-func (r dateResolver) resolve(name string) (string, string, bool) {
-	for _, re := range r.patterns {
-		matches := re.FindStringSubmatch(name)
-		if matches == nil {
-			continue
+	var fileList []string
+	if filesFrom != "" {
+		if fileList, err = engine.ReadFileList(filesFrom); err != nil {
+			return err
 		}
+	}
 
-		subexpNames := re.SubexpNames()
-		var year, month string
-		for i, v := range subexpNames {
-			if v == "year" {
-				year = matches[i]
+	imageBytes := int64(minImageBytes)
+	if imageBytes == 0 {
+		// The engine takes zero as its default.
+		imageBytes = -1
+	}
+
+	pause := engine.NewPauser()
+	stopPauseSignals := watchPauseSignals(pause)
+	defer stopPauseSignals()
+
+	opts := engine.Options{
+		Config:           cfg,
+		Source:           src,
+		Files:            fileList,
+		Dest:             dest,
+		Mirrors:          mirrors,
+		NormalizeNames:   normalizeNames,
+		SanitizeNames:    sanitizeNames,
+		ShardSize:        shardSize,
+		ContentAddressed: contentAddressed,
+		DryRun:           dryRun,
+		Preview:          os.Stdout,
+		NoSpaceCheck:     noSpaceCheck,
+		NoXattrs:         noXattrs,
+		FileMode:         os.FileMode(fileMode),
+		Hash:             hashAlgorithm,
+		NoHashCache:      noHashCache,
+		MaxBytes:         int64(maxBytes),
+		MaxDuration:      maxDuration,
+		FileTimeout:      fileTimeout,
+		Retries:          retries,
+		Verify:           verify,
+		VerifyRetries:    verifyRetries,
+		RetryBackoff:     retryBackoff,
+		SyncDeletions:    syncDeletions,
+		ConfirmRemoval:   confirmRemoval(force),
+		DeleteSource:     deleteSource,
+		ReadOnlySource:   assertReadOnly,
+		Trash:            useTrash,
+		HTMLReport:       htmlReport,
+		RelativePaths:    relativePaths,
+		ReportsDir:       reportsDir,
+		AppendReports:    appendReports,
+		Sidecars:         sidecars,
+		Forks:            forks,
+		NameConflicts:    nameConflicts,
+		Thumbnails:       thumbnails,
+		ThumbnailSize:    thumbnailSize,
+		PostJobs:         postJobs,
+		MinImageBytes:    imageBytes,
+		MinImageWidth:    minImageDimensions.width,
+		MinImageHeight:   minImageDimensions.height,
+		Filter: engine.Filter{
+			NewerThan:           newerThan.at(time.Now()),
+			OlderThan:           olderThan.at(time.Now()),
+			MinAge:              time.Duration(minAge),
+			MinSize:             int64(minSize),
+			MaxSize:             int64(maxSize),
+			SkipHidden:          skipHidden,
+			HydratePlaceholders: hydratePlaceholders,
+			MaxDepth:            maxDepth,
+			AllowMIME:           allowMIME,
+			DenyMIME:            denyMIME,
+			Only:                only,
+			SkipCategories:      skipCategories,
+		},
+		Limit:      limit,
+		Sample:     sample,
+		Order:      order,
+		Events:     events,
+		Pause:      pause,
+		Warnf:      warnf,
+		ConfigPath: configPath,
+		ConfigHash: hash,
+		Args:       args,
+		Version:    versionString(),
+	}
+	if interactive {
+		opts.ResolveDuplicate = duplicatePrompt(os.Stdin, os.Stderr)
+	}
+	if watch > 0 {
+		reload := func() error {
+			cfg, hash, err := loadProfile(configPath, profile)
+			if err != nil {
+				return err
 			}
-			if v == "month" {
-				month = matches[i]
+			if configSources {
+				if err := checkSources(cfg.Sources); err != nil {
+					return err
+				}
 			}
+			opts.Config, opts.ConfigHash = cfg, hash
+			return nil
 		}
-		if year == "" && len(matches) >= 3 {
-			year = matches[1]
-			month = matches[2]
-		}
-		if year == "" || month == "" {
-			year, month = fallbackYearMonth(matches)
+		var health *healthStatus
+		if healthAddr != "" {
+			health = &healthStatus{}
+			stop, err := serveHealth(healthAddr, health)
+			if err != nil {
+				return err
+			}
+			defer stop()
+			opts.Events = engine.MultiSink(opts.Events, health)
 		}
-		if len(year) == 4 && len(month) == 2 {
-			return year, year + month, true
+		jobs := func() []watchJob {
+			sources := []engine.Source{{Path: src, Dest: dest}}
+			if configSources {
+				sources = opts.Config.Sources
+			}
+			var list []watchJob
+			for _, s := range sources {
+				o := opts
+				if configSources {
+					o.Config, o.Source, o.Dest = opts.Config.ForSource(s), s.Path, s.Dest
+					for _, other := range sources {
+						if filepath.Clean(other.Dest) != filepath.Clean(s.Dest) && !slices.Contains(o.SharedDedupe, other.Dest) {
+							o.SharedDedupe = append(o.SharedDedupe, other.Dest)
+						}
+					}
+				}
+				interval := cmp.Or(s.Interval, watch)
+				pass := func(ctx context.Context) error {
+					o := o
+					now := time.Now()
+					o.Filter.NewerThan, o.Filter.OlderThan = newerThan.at(now), olderThan.at(now)
+					return classifyPass(ctx, o, maxBytes, nil)
+				}
+				if health != nil {
+					pass = health.pass(s.Path, interval, pass)
+				}
+				list = append(list, watchJob{name: s.Path, interval: interval, pass: pass})
+			}
+			return list
 		}
+		return watchSources(ctx, reload, jobs)
 	}
-	return "", "", false
+	return classifyPass(ctx, opts, maxBytes, &ci)
 }
 
-func fallbackYearMonth(matches []string) (string, string) {
-	var year, month string
-	for _, m := range matches {
-		if len(m) == 4 && allDigits(m) && year == "" {
-			year = m
-			continue
-		}
-		if len(m) == 2 && allDigits(m) && month == "" {
-			month = m
-		}
-		if year != "" && month != "" {
-			break
-		}
+// loadProfile is loadConfig with the settings of profile, unless it is
+// empty.
+func loadProfile(path, profile string) (engine.Config, string, error) {
+	cfg, hash, err := loadConfig(path)
+	if err != nil || profile == "" {
+		return cfg, hash, err
 	}
-	return year, month
+	cfg, err = cfg.WithProfile(profile)
+	return cfg, hash, err
 }
 
-func allDigits(s string) bool {
-	for _, r := range s {
-		if r < '0' || r > '9' {
-			return false
+// checkSources rejects the sources of a config that -watch cannot
+// classify.
+func checkSources(sources []engine.Source) error {
+	seen := make(map[string]bool)
+	for i, s := range sources {
+		switch {
+		case !filepath.IsAbs(s.Path) || !filepath.IsAbs(s.Dest):
+			return fmt.Errorf("source #%d: path and dest must be absolute paths", i+1)
+		case s.Interval < 0:
+			return fmt.Errorf("source %s: interval must not be negative", s.Path)
+		case seen[filepath.Clean(s.Path)]:
+			return fmt.Errorf("source %s is listed more than once", s.Path)
 		}
+		seen[filepath.Clean(s.Path)] = true
 	}
-	return s != ""
+	return nil
 }
 
-func copyFile(src, dest string, perm os.FileMode) error {
-	in, err := os.Open(src)
+// loadConfig reads the config at path, a file or URL, once and returns it
+// with the hash the audit log records for it.
+func loadConfig(path string) (engine.Config, string, error) {
+	data, err := engine.ReadConfig(path)
 	if err != nil {
-		return fmt.Errorf("open source file %s: %w", src, err)
+		return engine.Config{}, "", err
 	}
-	defer in.Close()
-
-	out, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	cfg, err := engine.ParseConfig(path, data)
 	if err != nil {
-		return fmt.Errorf("create destination file %s: %w", dest, err)
+		return engine.Config{}, "", err
 	}
-	defer out.Close()
+	return cfg, engine.HashConfig(data), nil
+}
 
-	if _, err := io.Copy(out, in); err != nil {
-		return fmt.Errorf("copy %s -> %s: %w", src, dest, err)
+// classifyPass runs the classification once and logs its outcome, and
+// reports it to CI systems as ci asks.
+func classifyPass(ctx context.Context, opts engine.Options, maxBytes sizeFlag, ci *ciReport) error {
+	started := time.Now()
+	if ci.enabled() {
+		opts.Warnf = ci.collect(opts.Warnf)
+	}
+	res, err := engine.Run(ctx, opts)
+	for _, f := range res.Failures {
+		warnf("%s -> %s: %v", f.Src, f.Dest, f.Err)
+	}
+	if res.OutOfTime {
+		logf(prioNotice, tr("stopped after running for %s (-max-duration); %d files left, re-run to resume"),
+			opts.MaxDuration, res.Remaining)
+	} else if res.Stopped {
+		logf(prioNotice, tr("stopped after copying %s (-max-bytes %s); %d files left, re-run to resume"),
+			engine.FormatBytes(uint64(res.BudgetUsed)), maxBytes.String(), res.Remaining)
+	}
+	if res.Removed > 0 {
+		fmt.Fprintf(os.Stdout, tr("took out %d files whose sources were deleted; undo with: classifier undo %s %s\n"), res.Removed, res.RemovedLog, opts.Dest)
+	}
+	if res.RunID != "" {
+		logf(prioInfo, tr("run %s %s: %d copied (%s), %d duplicates, %d already present, %d errors"),
+			res.RunID, res.Status, res.Copied, engine.FormatBytes(uint64(res.BytesCopied)), res.Duplicates, res.Present, res.Failed)
+	}
+	if res.VerifyRetries > 0 {
+		logf(prioNotice, tr("%d copies differed from their source and were made again (-verify)"), res.VerifyRetries)
+	}
+	if ci.enabled() {
+		if cerr := ci.write(opts, started, res, err); cerr != nil && err == nil {
+			err = cerr
+		}
 	}
-
-	return nil
+	return err
 }
 
-func uniqueDestPath(dir, name string) (string, error) {
-	target := filepath.Join(dir, name)
-	if _, err := os.Stat(target); err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return target, nil
+// unknownCategories returns the names that no file can be classified into
+// under cfg, going by its categories, video buckets, aliases and default
+// category.
+func unknownCategories(cfg engine.Config, names []string) []string {
+	known := map[string]bool{"others": cfg.DefaultCategory == ""}
+	known[cfg.DefaultCategory] = true
+	for _, c := range cfg.Categories {
+		known[c.Name] = true
+		for _, b := range c.VideoBuckets {
+			known[b.Category] = true
 		}
-		return "", fmt.Errorf("stat destination %s: %w", target, err)
 	}
-
-	ext := filepath.Ext(name)
-	base := strings.TrimSuffix(name, ext)
-
-	for i := 1; ; i++ {
-		candidate := filepath.Join(dir, fmt.Sprintf("%s_%d%s", base, i, ext))
-		if _, err := os.Stat(candidate); err != nil {
-			if errors.Is(err, os.ErrNotExist) {
-				return candidate, nil
-			}
-			return "", fmt.Errorf("stat destination %s: %w", candidate, err)
+	for _, to := range cfg.Aliases {
+		known[to] = true
+	}
+	var out []string
+	for _, name := range names {
+		if !known[name] {
+			out = append(out, name)
 		}
 	}
+	return out
 }
 
-func fileHash(path string) (string, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return "", fmt.Errorf("open for hash %s: %w", path, err)
+// confirmRemoval asks on the terminal before -sync-deletions takes files
+// out; without a terminal only -force lets it.
+func confirmRemoval(force bool) func([]string) bool {
+	return func(paths []string) bool {
+		if force {
+			return true
+		}
+		if !isTerminal(os.Stdin) {
+			warnf(tr("not taking out %d files whose sources were deleted: no terminal to confirm on, use -force"), len(paths))
+			return false
+		}
+		for _, p := range paths {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		fmt.Fprintf(os.Stderr, tr("take out these %d files whose sources were deleted? [y/N] "), len(paths))
+		answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && answer == "" {
+			fmt.Fprintln(os.Stderr)
+			warnf(tr("no answer, not taking out %d files; use -force"), len(paths))
+			return false
+		}
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes"
 	}
-	defer f.Close()
+}
+
+const usageLine = "usage: classifier [flags] <src-abs-dir> <dest-abs-dir>"
+
+const filesFromUsage = "usage: classifier -files-from <list|-> [flags] <dest-abs-dir>"
 
-	h := sha256.New()
-	if _, err := io.Copy(h, f); err != nil {
-		return "", fmt.Errorf("hash %s: %w", path, err)
+// subcommandUsages are listed under the main usage line by -h.
+var subcommandUsages = []string{filesFromUsage, configDoctorUsage, configSchemaUsage, dedupeUsage, diffUsage, exportUsage, historyUsage, learnUsage, mergeUsage, migrateUsage, pruneUsage, reclassifyUsage, reportDiffUsage, scanUsage, serviceUsage, statsUsage, undoUsage, verifyUsage}
+
+func helpText() string {
+	text := tr(usageLine)
+	for _, u := range subcommandUsages {
+		text += "\n       " + strings.TrimPrefix(u, "usage: ")
 	}
-	return fmt.Sprintf("%x", h.Sum(nil)), nil
+	return text + "\n\n" + tr("-lang en|ja, anywhere before --, picks the language of messages; by default it follows LC_ALL, LC_MESSAGES and LANG")
 }
 
-func writeWarnings(path string, entries []skippedEntry) error {
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
-	if err != nil {
-		return fmt.Errorf("write warnings: %w", err)
-	}
-	defer f.Close()
+// usageError formats a usage error in the language of the run.
+func usageError(format string, args ...any) error {
+	return errors.New(trf(format, args...) + "; " + tr(usageLine))
+}
 
-	w := csv.NewWriter(f)
-	for _, e := range entries {
-		if err := w.Write([]string{e.srcPath, e.destPath}); err != nil {
-			return fmt.Errorf("write warnings: %w", err)
-		}
-	}
-	w.Flush()
-	if err := w.Error(); err != nil {
-		return fmt.Errorf("write warnings: %w", err)
+// parseFlags parses args into fs. For -h it prints usage and the flag
+// defaults to stdout and returns flag.ErrHelp, which main treats as success.
+func parseFlags(fs *flag.FlagSet, args []string, usage string) error {
+	fs.SetOutput(io.Discard)
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		fs.SetOutput(os.Stdout)
+		fmt.Fprintln(os.Stdout, usage)
+		fs.VisitAll(func(f *flag.Flag) { f.Usage = tr(f.Usage) })
+		fs.PrintDefaults()
+	}
+	return err
+}
+
+// version is set at build time with -ldflags "-X main.version=v1.2.3";
+// otherwise the module version or VCS revision from the build info is used.
+var version string
+
+func versionString() string {
+	if version != "" {
+		return version
 	}
-	return nil
+	return engine.BuildVersion()
 }
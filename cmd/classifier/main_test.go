@@ -2,11 +2,19 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/png"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"testing"
+
+	"github.com/sky0621/classifier/internal/engine"
 )
 
 func TestCLI_ClassifiesFilesByConfig(t *testing.T) {
@@ -93,6 +101,272 @@ func TestCLI_HandlesDuplicateFilenames(t *testing.T) {
 	}
 }
 
+func TestCLI_NormalizeNamesTreatsNFDAndNFCAsOneName(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	mustMkdir(t, filepath.Join(dest, "documents"))
+	// "café.txt" and "résumé.txt" spelled in NFD, as macOS writes them.
+	writeFile(t, src, "cafe\u0301.txt", "menu")
+	writeFile(t, src, "re\u0301sume\u0301.txt", "cv")
+	// The same name in NFC, already in the destination with other content.
+	writeFile(t, filepath.Join(dest, "documents"), "caf\u00e9.txt", "old menu")
+
+	res := runCLI(t, workspace, "-normalize-names", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dest, "documents"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	want := []string{"caf\u00e9.txt", "caf\u00e9_1.txt", "r\u00e9sum\u00e9.txt"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("unexpected destination names: got %q, want %q", got, want)
+	}
+}
+
+func TestCLI_SanitizeNamesForFATDrives(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "meeting 10:30?.txt", "notes")
+
+	res := runCLI(t, workspace, "-sanitize-names", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "meeting 10_30_.txt"), "notes")
+}
+
+func TestCLI_AliasesRecogniseFilesUnderFormerCategoryNames(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	mustMkdir(t, filepath.Join(dest, "docs"))
+	writeFile(t, src, "kept.txt", "old")
+	writeFile(t, src, "new.txt", "new")
+	// Filed before "docs" was renamed to "documents".
+	writeFile(t, filepath.Join(dest, "docs"), "kept.txt", "old")
+	writeFile(t, workspace, "config.yaml", `categories:
+  - name: documents
+    extensions: [txt]
+aliases:
+  docs: documents
+`)
+
+	res := runCLI(t, workspace, "-config", filepath.Join(workspace, "config.yaml"), absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "new.txt"), "new")
+	if _, err := os.Stat(filepath.Join(dest, "documents", "kept.txt")); !os.IsNotExist(err) {
+		t.Fatalf("a file present under the former category name must not be copied again, got %v", err)
+	}
+}
+
+func TestCLI_ProfileSelectsSettingsAndDestination(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "paperwork")
+	mustMkdir(t, src)
+	writeFile(t, src, "invoice.pdf", "pdf")
+	writeFile(t, src, "notes.txt", "txt")
+	writeFile(t, workspace, "config.yaml", `categories:
+  - name: documents
+    extensions: [txt, pdf]
+profiles:
+  paperwork:
+    categories:
+      - name: invoices
+        extensions: [pdf]
+    default_category: unsorted
+    dest: `+dest+`
+  photos: {}
+`)
+	config := filepath.Join(workspace, "config.yaml")
+
+	res := runCLI(t, workspace, "-config", config, "-profile", "paperwork", absPath(t, src))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "invoices", "invoice.pdf"), "pdf")
+	assertFileContent(t, filepath.Join(dest, "unsorted", "notes.txt"), "txt")
+
+	res = runCLI(t, workspace, "-config", config, "-profile", "photos", absPath(t, src))
+	if res.exitCode == 0 || !strings.Contains(res.stderr, `profile "photos" has no dest`) {
+		t.Fatalf("expected a profile without dest to need a destination, got exit %d, stderr: %s", res.exitCode, res.stderr)
+	}
+	res = runCLI(t, workspace, "-config", config, "-profile", "music", absPath(t, src), absPath(t, dest))
+	if res.exitCode == 0 || !strings.Contains(res.stderr, `profile "music" is not in the config`) {
+		t.Fatalf("expected an unknown profile to be rejected, got exit %d, stderr: %s", res.exitCode, res.stderr)
+	}
+}
+
+func TestCLI_CategoryRootOverridesDestination(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	big := filepath.Join(workspace, "big")
+	mustMkdir(t, src)
+	writeFile(t, src, "notes.txt", "notes")
+	writeFile(t, src, "clip.mp4", "clip")
+	writeFile(t, workspace, "config.yaml", `categories:
+  - name: documents
+    extensions: [txt]
+  - name: movies
+    extensions: [mp4]
+    root: `+strconv.Quote(absPath(t, big))+`
+`)
+
+	res := runCLI(t, workspace, "-config", filepath.Join(workspace, "config.yaml"), absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "notes.txt"), "notes")
+	assertFileContent(t, filepath.Join(big, "movies", "clip.mp4"), "clip")
+	if _, err := os.Stat(filepath.Join(dest, "movies")); !os.IsNotExist(err) {
+		t.Fatalf("movies must not be created below the destination argument, got %v", err)
+	}
+}
+
+func TestCLI_RunsPostCommandOnCopiedFiles(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("needs sh")
+	}
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "notes.txt", "notes")
+	writeFile(t, src, "broken.log", "log")
+	writeFile(t, workspace, "config.yaml", `categories:
+  - name: documents
+    extensions: [txt]
+    post_command: [sh, -c, 'echo "$2" > "$1.post"', sh, "{dest}", "{category}"]
+  - name: logs
+    extensions: [log]
+    post_command: [sh, -c, 'echo scrub failed >&2; exit 3']
+    on_post_failure: fail
+`)
+
+	res := runCLI(t, workspace, "-config", filepath.Join(workspace, "config.yaml"), absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "notes.txt.post"), "documents\n")
+	errs := readFile(t, filepath.Join(dest, engine.StateDir, "errors.csv"))
+	if !strings.Contains(errs, "broken.log") || !strings.Contains(errs, "scrub failed") {
+		t.Fatalf("expected the post command failure in errors.csv, got %q", errs)
+	}
+}
+
+func TestCLI_ShardsOverfullFolders(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		writeFile(t, src, name+".txt", name)
+	}
+
+	countFiles := func(dir string) int {
+		t.Helper()
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("read %s: %v", dir, err)
+		}
+		n := 0
+		for _, e := range entries {
+			if !e.IsDir() {
+				n++
+			}
+		}
+		return n
+	}
+	docs := filepath.Join(dest, "documents")
+	for run := 1; run <= 2; run++ {
+		res := runCLI(t, workspace, "-shard-size", "2", absPath(t, src), absPath(t, dest))
+		if res.err != nil {
+			t.Fatalf("run %d: expected success, got error: %v, stderr: %s", run, res.err, res.stderr)
+		}
+		// The second run must find every file in its shard.
+		got := []int{countFiles(docs), countFiles(filepath.Join(docs, "0001")), countFiles(filepath.Join(docs, "0002"))}
+		if got[0] != 2 || got[1] != 2 || got[2] != 1 {
+			t.Fatalf("run %d: expected 2, 2 and 1 files in documents and its shards, got %v", run, got)
+		}
+	}
+}
+
+func TestCLI_ContentAddressedLayout(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "notes.txt", "notes")
+
+	sum := sha256.Sum256([]byte("notes"))
+	hash := hex.EncodeToString(sum[:])
+	blob := filepath.Join(dest, ".cas", hash[:2], hash[2:])
+	tree := filepath.Join(dest, "documents", "notes.txt")
+	for run := 1; run <= 2; run++ {
+		res := runCLI(t, workspace, "-content-addressed", absPath(t, src), absPath(t, dest))
+		if res.err != nil {
+			t.Fatalf("run %d: expected success, got error: %v, stderr: %s", run, res.err, res.stderr)
+		}
+		assertFileContent(t, blob, "notes")
+		assertFileContent(t, tree, "notes")
+		blobInfo, err := os.Stat(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		treeInfo, err := os.Stat(tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !os.SameFile(blobInfo, treeInfo) {
+			t.Fatalf("run %d: expected %s to be a link to %s", run, tree, blob)
+		}
+		// The second run has to link the stored blob again.
+		if err := os.Remove(tree); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCLI_AlphabeticalLayout(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	for _, name := range []string{"Apple.txt", "banana.txt", "2024 taxes.txt", "(draft).txt"} {
+		writeFile(t, src, name, name)
+	}
+	writeFile(t, workspace, "config.yaml", `categories:
+  - name: documents
+    extensions: [txt]
+    layout: alphabetical
+`)
+
+	res := runCLI(t, workspace, "-config", filepath.Join(workspace, "config.yaml"), absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	docs := filepath.Join(dest, "documents")
+	assertFileContent(t, filepath.Join(docs, "a", "Apple.txt"), "Apple.txt")
+	assertFileContent(t, filepath.Join(docs, "b", "banana.txt"), "banana.txt")
+	assertFileContent(t, filepath.Join(docs, "0-9", "2024 taxes.txt"), "2024 taxes.txt")
+	assertFileContent(t, filepath.Join(docs, "_", "(draft).txt"), "(draft).txt")
+}
+
 func TestCLI_SkipsDuplicateImagesByContent(t *testing.T) {
 	workspace := t.TempDir()
 	src := filepath.Join(workspace, "src")
@@ -122,7 +396,7 @@ func TestCLI_SkipsDuplicateImagesByContent(t *testing.T) {
 
 	assertFileContent(t, filepath.Join(imagesDir, "alpha.jpg"), duplicate)
 
-	warnPath := filepath.Join(dest, "warn.csv")
+	warnPath := filepath.Join(dest, engine.StateDir, "warn.csv")
 	content := readFile(t, warnPath)
 	lines := strings.Split(strings.TrimSpace(content), "\n")
 	if len(lines) != 1 {
@@ -160,7 +434,7 @@ func TestCLI_DefaultConfigUsedWhenNoFlag(t *testing.T) {
 	assertFileContent(t, filepath.Join(dest, "images", "alpha.jpg"), imgContent)
 	assertFileContent(t, filepath.Join(dest, "documents", "bravo.txt"), "doc")
 
-	if _, err := os.Stat(filepath.Join(dest, "warn.csv")); err == nil {
+	if _, err := os.Stat(filepath.Join(dest, engine.StateDir, "warn.csv")); err == nil {
 		t.Fatalf("warn.csv should not exist when nothing skipped")
 	}
 }
@@ -184,7 +458,7 @@ func TestCLI_MultipleDuplicateImagesProduceMultipleWarnings(t *testing.T) {
 		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
 	}
 
-	warnPath := filepath.Join(dest, "warn.csv")
+	warnPath := filepath.Join(dest, engine.StateDir, "warn.csv")
 	content := readFile(t, warnPath)
 	lines := strings.Split(strings.TrimSpace(content), "\n")
 	if len(lines) != 2 {
@@ -231,7 +505,7 @@ func TestCLI_SkipsDuplicateMoviesByContent(t *testing.T) {
 
 	assertFileContent(t, filepath.Join(moviesDir, "alpha.mp4"), content)
 
-	warnPath := filepath.Join(dest, "warn.csv")
+	warnPath := filepath.Join(dest, engine.StateDir, "warn.csv")
 	warnContent := readFile(t, warnPath)
 	lines := strings.Split(strings.TrimSpace(warnContent), "\n")
 	if len(lines) != 1 {
@@ -279,7 +553,7 @@ func TestCLI_SkipsDuplicateDocumentsByContent(t *testing.T) {
 	keptPath := filepath.Join(docsDir, keptName)
 	assertFileContent(t, keptPath, "doc")
 
-	warnPath := filepath.Join(dest, "warn.csv")
+	warnPath := filepath.Join(dest, engine.StateDir, "warn.csv")
 	content := readFile(t, warnPath)
 	lines := strings.Split(strings.TrimSpace(content), "\n")
 	if len(lines) != 1 {
@@ -328,11 +602,45 @@ func TestCLI_SkipsSmallImagesBySize(t *testing.T) {
 	}
 	assertFileContent(t, filepath.Join(imagesDir, "large.jpg"), large)
 
-	if _, err := os.Stat(filepath.Join(dest, "warn.csv")); err == nil {
+	if _, err := os.Stat(filepath.Join(dest, engine.StateDir, "warn.csv")); err == nil {
 		t.Fatalf("warn.csv should not exist when only small images are skipped")
 	}
 }
 
+func TestCLI_SkipsSmallImagesByDimensions(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writePNG := func(name string, w, h int) {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, src, name, buf.String())
+	}
+	writePNG("icon.png", 64, 64)
+	writePNG("wide.png", 800, 600)
+	writePNG("portrait.png", 600, 800)
+	writePNG("strip.png", 2000, 100)
+
+	res := runCLI(t, workspace, "-min-image-bytes", "0", "-min-image-dimensions", "640x480", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	imagesDir := filepath.Join(dest, "images")
+	for name, kept := range map[string]bool{"icon.png": false, "wide.png": true, "portrait.png": true, "strip.png": false} {
+		_, err := os.Stat(filepath.Join(imagesDir, name))
+		if kept && err != nil {
+			t.Errorf("expected %s to be copied, got %v", name, err)
+		}
+		if !kept && err == nil {
+			t.Errorf("expected %s to be skipped as too small", name)
+		}
+	}
+}
+
 func TestCLI_PlacesImagesAndMoviesInDateFolders(t *testing.T) {
 	workspace := t.TempDir()
 	src := filepath.Join(workspace, "src")
@@ -373,6 +681,245 @@ func TestCLI_CopiesFilesWhenNoDateFound(t *testing.T) {
 	assertFileContent(t, filepath.Join(dest, "images", "picture.jpg"), imgContent)
 }
 
+func TestCLI_RerunSkipsFilesAlreadyAtDestination(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+
+	mustMkdir(t, src)
+	rootContent := strings.Repeat("r", 2*1024*1024)
+	nestedContent := strings.Repeat("n", 2*1024*1024)
+	writeFile(t, src, "alpha.jpg", rootContent)
+	writeFile(t, src, "bravo.txt", "doc")
+
+	nested := filepath.Join(src, "nested")
+	mustMkdir(t, nested)
+	writeFile(t, nested, "alpha.jpg", nestedContent)
+
+	for i := 0; i < 2; i++ {
+		res := runCLI(t, workspace, absPath(t, src), absPath(t, dest))
+		if res.err != nil {
+			t.Fatalf("run %d: expected success, got error: %v, stderr: %s", i+1, res.err, res.stderr)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dest, "images"))
+	if err != nil {
+		t.Fatalf("expected images directory, got: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 files in images after re-run, got %d", len(entries))
+	}
+	assertFileContent(t, filepath.Join(dest, "images", "alpha.jpg"), rootContent)
+	assertFileContent(t, filepath.Join(dest, "images", "alpha_1.jpg"), nestedContent)
+	if _, err := os.Stat(filepath.Join(dest, "documents", "bravo_1.txt")); err == nil {
+		t.Fatalf("did not expect re-run to create bravo_1.txt")
+	}
+	if _, err := os.Stat(filepath.Join(dest, engine.StateDir, "warn.csv")); err == nil {
+		t.Fatalf("warn.csv should not exist when re-run only finds previous copies")
+	}
+}
+
+func TestCLI_MirrorsOutputToEveryDestination(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mirror := filepath.Join(workspace, "mirror")
+
+	mustMkdir(t, src)
+	imgContent := strings.Repeat("m", 2*1024*1024)
+	writeFile(t, src, "2024-01-31_photo.jpg", imgContent)
+	writeFile(t, src, "alpha.txt", "doc")
+	writeFile(t, src, "bravo.txt", "doc")
+
+	res := runCLI(t, workspace, "-mirror", absPath(t, mirror), absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+
+	for _, root := range []string{dest, mirror} {
+		assertFileContent(t, filepath.Join(root, "images", "2024", "202401", "2024-01-31_photo.jpg"), imgContent)
+		assertFileContent(t, filepath.Join(root, "documents", "alpha.txt"), "doc")
+
+		lines := strings.Split(strings.TrimSpace(readFile(t, filepath.Join(root, engine.StateDir, "warn.csv"))), "\n")
+		if len(lines) != 1 {
+			t.Fatalf("expected 1 warning line in %s, got %d", root, len(lines))
+		}
+		want := filepath.Join(src, "bravo.txt") + "," + filepath.Join(root, "documents", "alpha.txt")
+		if lines[0] != want {
+			t.Fatalf("unexpected warning in %s: got %q want %q", root, lines[0], want)
+		}
+	}
+}
+
+func TestCLI_MirrorFailureDoesNotAffectPrimary(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mirror := filepath.Join(workspace, "mirror")
+
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "doc")
+	writeFile(t, src, "bravo.mp4", "movie")
+
+	// A regular file where the category directory should go breaks the mirror.
+	mustMkdir(t, mirror)
+	writeFile(t, mirror, "documents", "blocker")
+
+	res := runCLI(t, workspace, "-mirror", absPath(t, mirror), absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+
+	assertFileContent(t, filepath.Join(dest, "documents", "alpha.txt"), "doc")
+	assertFileContent(t, filepath.Join(dest, "movies", "bravo.mp4"), "movie")
+	assertFileContent(t, filepath.Join(mirror, "movies", "bravo.mp4"), "movie")
+
+	if _, err := os.Stat(filepath.Join(dest, engine.StateDir, "errors.csv")); err == nil {
+		t.Fatalf("errors.csv should not exist in the healthy destination")
+	}
+	lines := strings.Split(strings.TrimSpace(readFile(t, filepath.Join(mirror, engine.StateDir, "errors.csv"))), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 error line in mirror errors.csv, got %d", len(lines))
+	}
+	if !strings.HasPrefix(lines[0], filepath.Join(src, "alpha.txt")+",") {
+		t.Fatalf("unexpected mirror error entry: %s", lines[0])
+	}
+	if !strings.Contains(res.stderr, "warning:") {
+		t.Fatalf("expected mirror failure on stderr, got: %s", res.stderr)
+	}
+}
+
+func TestCLI_MaxBytesStopsAndResumes(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", strings.Repeat("a", 100))
+	writeFile(t, src, "bravo.txt", strings.Repeat("b", 100))
+	writeFile(t, src, "charlie.txt", strings.Repeat("c", 100))
+
+	res := runCLI(t, workspace, "-max-bytes", "250", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	if !strings.Contains(res.stderr, "re-run to resume") {
+		t.Fatalf("expected resume hint on stderr, got: %s", res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "alpha.txt"), strings.Repeat("a", 100))
+	assertFileContent(t, filepath.Join(dest, "documents", "bravo.txt"), strings.Repeat("b", 100))
+	if _, err := os.Stat(filepath.Join(dest, "documents", "charlie.txt")); err == nil {
+		t.Fatalf("expected charlie.txt to wait for the next run")
+	}
+	checkpointPath := filepath.Join(dest, ".classifier", "checkpoint.csv")
+	if _, err := os.Stat(checkpointPath); err != nil {
+		t.Fatalf("expected checkpoint after stopping early: %v", err)
+	}
+
+	res = runCLI(t, workspace, "-max-bytes", "250", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success on resume, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "charlie.txt"), strings.Repeat("c", 100))
+	if _, err := os.Stat(checkpointPath); err == nil {
+		t.Fatalf("expected checkpoint to be removed after a complete run")
+	}
+}
+
+func TestCLI_MaxDurationStopsAndResumes(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "alpha")
+	writeFile(t, src, "bravo.txt", "bravo")
+
+	// The time box is over before the first file.
+	res := runCLI(t, workspace, "-max-duration", "1ns", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	if !strings.Contains(res.stderr, "-max-duration") || !strings.Contains(res.stderr, "2 files left") {
+		t.Fatalf("expected resume hint on stderr, got: %s", res.stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "documents", "alpha.txt")); err == nil {
+		t.Fatalf("expected alpha.txt to wait for the next run")
+	}
+	if _, err := os.Stat(filepath.Join(dest, ".classifier", "checkpoint.csv")); err != nil {
+		t.Fatalf("expected checkpoint after stopping early: %v", err)
+	}
+
+	res = runCLI(t, workspace, "-max-duration", "1h", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success on resume, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "alpha.txt"), "alpha")
+	assertFileContent(t, filepath.Join(dest, "documents", "bravo.txt"), "bravo")
+
+	res = runCLI(t, workspace, "-max-duration", "-1s", absPath(t, src), absPath(t, dest))
+	if res.exitCode == 0 || !strings.Contains(res.stderr, "-max-duration must not be negative") {
+		t.Fatalf("expected a usage error, got exit %d, stderr: %s", res.exitCode, res.stderr)
+	}
+}
+
+func TestCLI_VerifyKeepsMatchingCopies(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "alpha")
+
+	res := runCLI(t, workspace, "-verify", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "alpha.txt"), "alpha")
+	if strings.Contains(res.stderr, "made again") {
+		t.Fatalf("expected no copy to be made again, stderr: %s", res.stderr)
+	}
+
+	res = runCLI(t, workspace, "-verify-retries", "-1", absPath(t, src), absPath(t, dest))
+	if res.exitCode == 0 || !strings.Contains(res.stderr, "-verify-retries must not be negative") {
+		t.Fatalf("expected a usage error, got exit %d, stderr: %s", res.exitCode, res.stderr)
+	}
+}
+
+func TestCLI_FileModeSetsTheModeOfCopies(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no POSIX permissions")
+	}
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "alpha")
+	if err := os.Chmod(filepath.Join(src, "alpha.txt"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		want os.FileMode
+	}{
+		{want: 0o600},
+		{args: []string{"-file-mode", "0640"}, want: 0o640},
+	} {
+		dest := filepath.Join(t.TempDir(), "dest")
+		res := runCLI(t, workspace, append(tt.args, absPath(t, src), dest)...)
+		if res.err != nil {
+			t.Fatalf("%v: expected success, got error: %v, stderr: %s", tt.args, res.err, res.stderr)
+		}
+		info, err := os.Stat(filepath.Join(dest, "documents", "alpha.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != tt.want {
+			t.Fatalf("%v: copy has mode %s, want %s", tt.args, got, tt.want)
+		}
+	}
+}
+
 func TestCLI_RejectsRelativePaths(t *testing.T) {
 	workspace := t.TempDir()
 	src := filepath.Join(workspace, "src")
@@ -402,10 +949,18 @@ type cliResult struct {
 
 func runCLI(t *testing.T, workdir string, args ...string) cliResult {
 	t.Helper()
+	return runCLIEnv(t, nil, workdir, args...)
+}
+
+// runCLIEnv runs the CLI with extra KEY=VALUE environment entries.
+func runCLIEnv(t *testing.T, env []string, workdir string, args ...string) cliResult {
+	t.Helper()
 
 	cmd := exec.Command("go", "run", filepath.Join(repoRoot(t), "cmd", "classifier"))
 	cmd.Args = append(cmd.Args, args...)
 	cmd.Dir = repoRoot(t)
+	// Messages are checked in English whatever the locale of the machine.
+	cmd.Env = append(append(os.Environ(), "LC_ALL=C"), env...)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -489,3 +1044,30 @@ func assertFileContent(t *testing.T, path string, want string) {
 		t.Fatalf("unexpected content for %s: got %q want %q", path, got, want)
 	}
 }
+
+func TestCLI_AssertReadOnlyRefusesWritesToTheSource(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "alpha")
+
+	res := runCLI(t, workspace, "-assert-readonly", "-delete-source", absPath(t, src), absPath(t, dest))
+	if res.exitCode == 0 || !strings.Contains(res.stderr, "-assert-readonly cannot be combined with -delete-source") {
+		t.Fatalf("expected a usage error, got exit %d, stderr: %s", res.exitCode, res.stderr)
+	}
+	res = runCLI(t, workspace, "-assert-readonly", absPath(t, src), absPath(t, filepath.Join(src, "sorted")))
+	if res.err == nil || !strings.Contains(res.stderr, "source is read-only") {
+		t.Fatalf("expected a destination inside the source to be refused, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	if _, err := os.Stat(filepath.Join(src, "sorted")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing written to the source, got %v", err)
+	}
+
+	res = runCLI(t, workspace, "-assert-readonly", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "alpha.txt"), "alpha")
+	assertFileContent(t, filepath.Join(src, "alpha.txt"), "alpha")
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+const mergeUsage = "usage: classifier merge [-dry-run] <destA-abs-dir> <destB-abs-dir> <out-abs-dir>"
+
+// runMerge combines two destinations written by classifier into a third,
+// deduplicating by content and keeping both files when names collide.
+func runMerge(ctx context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("merge", flag.ContinueOnError)
+	var dryRun bool
+	flagSet.BoolVar(&dryRun, "dry-run", false, "only print what would be merged")
+	if err := parseFlags(flagSet, args, mergeUsage); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 3 {
+		return errors.New("expected 3 arguments; " + mergeUsage)
+	}
+	a, b, out := flagSet.Arg(0), flagSet.Arg(1), flagSet.Arg(2)
+	if !filepath.IsAbs(a) || !filepath.IsAbs(b) || !filepath.IsAbs(out) {
+		return errors.New("destinations must be absolute paths; " + mergeUsage)
+	}
+	for _, archive := range []string{a, b} {
+		if filepath.Clean(archive) == filepath.Clean(out) {
+			return errors.New("output must differ from the merged destinations; " + mergeUsage)
+		}
+	}
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return errors.New("cannot merge a destination with itself; " + mergeUsage)
+	}
+
+	res, err := engine.Merge(ctx, engine.MergeOptions{
+		Archives: []string{a, b},
+		Dest:     out,
+		DryRun:   dryRun,
+		Preview:  os.Stdout,
+		Warnf:    warnf,
+		Args:     append([]string{"merge"}, args...),
+		Version:  versionString(),
+	})
+	if err != nil {
+		return err
+	}
+	if res.RunID != "" {
+		logf(prioInfo, "merge %s %s: %d copied (%s), %d duplicates, %d already present, %d errors",
+			res.RunID, res.Status, res.Copied, engine.FormatBytes(uint64(res.BytesCopied)), res.Duplicates, res.Present, res.Failed)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+func TestCLI_MergeCombinesTwoDestinations(t *testing.T) {
+	workspace := t.TempDir()
+	laptopA := filepath.Join(workspace, "a")
+	laptopB := filepath.Join(workspace, "b")
+	destA := filepath.Join(workspace, "destA")
+	destB := filepath.Join(workspace, "destB")
+	out := filepath.Join(workspace, "out")
+	mustMkdir(t, laptopA)
+	mustMkdir(t, laptopB)
+	writeFile(t, laptopA, "notes.txt", "from a")
+	writeFile(t, laptopA, "shared.txt", "shared")
+	writeFile(t, laptopB, "notes.txt", "from b")
+	writeFile(t, laptopB, "copy of shared.txt", "shared")
+
+	for _, run := range [][2]string{{laptopA, destA}, {laptopB, destB}} {
+		res := runCLI(t, workspace, absPath(t, run[0]), absPath(t, run[1]))
+		if res.err != nil {
+			t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+		}
+	}
+
+	res := runCLI(t, workspace, "merge", absPath(t, destA), absPath(t, destB), absPath(t, out))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	docs := filepath.Join(out, "documents")
+	assertFileContent(t, filepath.Join(docs, "notes.txt"), "from a")
+	assertFileContent(t, filepath.Join(docs, "notes_1.txt"), "from b")
+	assertFileContent(t, filepath.Join(docs, "shared.txt"), "shared")
+	if _, err := os.Stat(filepath.Join(docs, "copy of shared.txt")); !os.IsNotExist(err) {
+		t.Fatalf("duplicate content must not be merged twice, got %v", err)
+	}
+
+	// Both runs and the merge are in the history, pointing into out.
+	manifests, err := engine.Manifests(out)
+	if err != nil || len(manifests) != 3 {
+		t.Fatalf("expected 3 manifests, got %v (%v)", manifests, err)
+	}
+	for _, m := range manifests {
+		entries, err := engine.ReadManifest(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if !strings.HasPrefix(e.Dest, docs+string(filepath.Separator)) {
+				t.Fatalf("%s: manifest row points outside the merged destination: %+v", filepath.Base(m), e)
+			}
+		}
+	}
+	logs, _ := filepath.Glob(filepath.Join(out, engine.StateDir, "history", "*.jsonl"))
+	var runs int
+	for _, l := range logs {
+		runs += len(strings.Split(strings.TrimSpace(readFile(t, l)), "\n"))
+	}
+	if runs != 3 {
+		t.Fatalf("expected 3 runs in the audit log, got %d", runs)
+	}
+	res = runCLI(t, workspace, "verify", absPath(t, out))
+	if res.err != nil {
+		t.Fatalf("expected the merged destination to verify, got: %v, stdout: %s", res.err, res.stdout)
+	}
+
+	// Merging again changes nothing.
+	res = runCLI(t, workspace, "merge", absPath(t, destA), absPath(t, destB), absPath(t, out))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	if _, err := os.Stat(filepath.Join(docs, "notes_2.txt")); !os.IsNotExist(err) {
+		t.Fatalf("a repeated merge must not copy again, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import "github.com/sky0621/classifier/internal/engine"
+
+// messagesJA is the Japanese catalog.
+var messagesJA = map[string]string{
+	// Usage.
+	usageLine: "使い方: classifier [フラグ] <src-abs-dir> <dest-abs-dir>",
+	"-lang en|ja, anywhere before --, picks the language of messages; by default it follows LC_ALL, LC_MESSAGES and LANG": "-lang en|ja (-- より前のどこでも) でメッセージの言語を選びます。指定がなければ LC_ALL、LC_MESSAGES、LANG に従います",
+	historyNote: "実行の記録は監査ログから読みます。ログは月ごとに 1 つの JSON Lines ファイルで\n<dest>/.classifier/history にあります。-json はそのエントリーを jq や\nsqlite3 の JSON 関数向けに出力します。",
+
+	// Flags of a classification run.
+	"path or http(s) URL of the YAML config; pin a URL's content with #sha256=<hex>":                                                                                                                    "YAML 設定ファイルのパスまたは http(s) の URL。URL の内容は #sha256=<hex> で固定できます",
+	"path or http(s) URL of the YAML config":                                                                                                                                                            "YAML 設定ファイルのパスまたは http(s) の URL",
+	"use this profile of the config; with a dest in the profile, <dest-abs-dir> may be left out":                                                                                                        "設定のこのプロファイルを使います。プロファイルに dest があれば <dest-abs-dir> は省略できます",
+	"skip the pre-flight free-space check":                                                                                                                                                              "開始前の空き容量チェックを省きます",
+	"content hash duplicates are detected by: sha256 (default), sha512, or sha256-tree, which hashes files of 64 MiB and more on every core":                                                            "重複の検出に使うハッシュ: sha256 (既定)、sha512、または 64 MiB 以上のファイルをすべてのコアでハッシュする sha256-tree",
+	"hash every source file instead of reusing the hashes of files unchanged since an earlier run (same device, inode, size and modification time)":                                                     "前回の実行から変わっていないファイル (デバイス、inode、サイズ、更新日時が同じ) のハッシュを使い回さず、すべての元ファイルをハッシュします",
+	"create every copy with this octal mode (e.g. 0644) instead of the mode of its source; filesystems without permissions get 0644 unless set":                                                         "すべてのコピーを元ファイルのモードではなくこの 8 進数のモード (例: 0644) で作ります。権限のないファイルシステムでは指定がなければ 0644 になります",
+	"do not record the source path and run id of copies in the extended attributes " + engine.XattrSource + " and " + engine.XattrRunID:                                                                 "コピーの元パスと実行 ID を拡張属性 " + engine.XattrSource + " と " + engine.XattrRunID + " に記録しません",
+	"stop cleanly after copying this many bytes (e.g. 32G); re-run to resume":                                                                                                                           "この量 (例: 32G) をコピーしたら区切りよく停止します。再実行すると続きから処理します",
+	"stop cleanly, between two files, after running this long (e.g. 2h); re-run to resume":                                                                                                              "この時間 (例: 2h) 実行したらファイルの区切りで停止します。再実行すると続きから処理します",
+	"give up hashing or copying a single file after this long (e.g. 2m); 0 disables":                                                                                                                    "1 つのファイルのハッシュやコピーにこの時間 (例: 2m) かかったら諦めます。0 で無効",
+	"retry transient I/O errors on a file, such as a file another program has open on Windows, this many times":                                                                                         "Windows で他のプログラムが開いているファイルなど、一時的な入出力エラーをこの回数まで再試行します",
+	"initial delay between retries, doubled after each attempt":                                                                                                                                         "再試行の最初の待ち時間。試行ごとに 2 倍になります",
+	"re-hash every copy once it is in place and copy it again if it differs from the source":                                                                                                            "配置したコピーをもう一度ハッシュし、元ファイルと異なればコピーし直します",
+	"with -verify, copy a file this many more times before reporting it as failed":                                                                                                                      "-verify のとき、失敗として報告するまでにコピーし直す回数",
+	"print what would be copied or deleted without writing anything":                                                                                                                                    "何も書き込まずに、コピーや削除の予定を表示します",
+	"take destination files out whose source files were deleted since they were copied; asks first unless -force":                                                                                       "コピー後に元ファイルが削除された保存先のファイルを取り除きます。-force がなければ先に確認します",
+	"with -sync-deletions, do not ask before taking files out":                                                                                                                                          "-sync-deletions のとき、ファイルを取り除く前に確認しません",
+	"delete each source file after its copies are fsynced and hash-verified":                                                                                                                            "コピーの fsync とハッシュ検証が済んだ元ファイルを削除します",
+	"guarantee the source is left untouched, e.g. a mounted evidence or backup image: refuse -delete-source and -hydrate-placeholders and fail any write below the source":                              "マウントした証拠やバックアップのイメージなど、元フォルダーに手を付けないことを保証します。-delete-source と -hydrate-placeholders を拒否し、元フォルダー内への書き込みはすべて失敗させます",
+	"on Linux, have the kernel (Landlock) refuse every write outside the destinations and category roots, post commands included; a -dry-run may write nowhere":                                         "Linux で、保存先とカテゴリーのルート以外への書き込みを post command も含めてカーネル (Landlock) に拒否させます。-dry-run ではどこにも書き込めません",
+	"with -delete-source, move sources to the OS trash instead of deleting them":                                                                                                                        "-delete-source のとき、元ファイルを削除せずに OS のゴミ箱へ移します",
+	"record paths in manifests, warn.csv, errors.csv and skipped.csv relative to the source and destination, with src_root and dest_root columns, so they stay valid when drives are mounted elsewhere": "マニフェスト、warn.csv、errors.csv、skipped.csv のパスを元フォルダーと保存先からの相対パスで src_root と dest_root の列とともに記録し、ドライブを別の場所にマウントしても使えるようにします",
+	"absolute path of the directory warn.csv, errors.csv, skipped.csv, unknown.csv and duplicates.html are written to (default <dest>/" + engine.StateDir + "); later runs leave it out":                "warn.csv、errors.csv、skipped.csv、unknown.csv、duplicates.html を書き出すフォルダーの絶対パス (既定は <dest>/" + engine.StateDir + ")。以後の実行ではこのフォルダーを対象外にします",
+	"append to warn.csv, errors.csv and skipped.csv instead of rewriting them every run, with the run id and start time of every row in two more columns":                                               "warn.csv、errors.csv、skipped.csv を毎回書き直さずに追記し、各行の実行 ID と開始時刻を 2 つの列に加えます",
+	"also write duplicates.html showing skipped duplicates next to the kept files":                                                                                                                      "スキップした重複を残したファイルと並べて見せる duplicates.html も書き出します",
+	"write a thumbnail of every copied image and movie to <dest>/" + engine.ThumbnailDir + " (movies need ffmpeg)":                                                                                      "コピーしたすべての画像と動画のサムネイルを <dest>/" + engine.ThumbnailDir + " に書き出します (動画には ffmpeg が必要です)",
+	"write <name>" + engine.SidecarExt + " next to every copied file with its source path, modification time, hash and run id":                                                                          "コピーしたすべてのファイルの隣に、元パス、更新日時、ハッシュ、実行 ID を記した <name>" + engine.SidecarExt + " を書き出します",
+	"what happens to AppleDouble ._ files and NTFS alternate data streams: copy (default) puts them next to the copies of their files, strip leaves them out; skipped.csv lists those left behind":      "AppleDouble の ._ ファイルと NTFS の代替データストリームの扱い: copy (既定) はファイルのコピーの隣に置き、strip は除きます。残したものは skipped.csv に記録します",
+	"how a copy is named when another file of the same name is in its folder: suffix (default) numbers it (IMG_1.jpg), folder adds the name of its source folder first (IMG__holiday.jpg)":              "保存先のフォルダーに同じ名前のファイルがあるときのコピーの名前: suffix (既定) は番号を付け (IMG_1.jpg)、folder はまず元フォルダーの名前を付けます (IMG__holiday.jpg)",
+	"longest edge of a thumbnail in pixels":                                                                                                                                                               "サムネイルの長辺のピクセル数",
+	"run at most this many post_command processes of the config at once":                                                                                                                                  "設定の post_command を同時にこの数まで実行します",
+	"where to send warnings and errors: stderr, syslog or journald":                                                                                                                                       "警告とエラーの送り先: stderr、syslog、journald",
+	"classify only the absolute paths listed in this file (- for stdin), one per line or NUL-separated, instead of walking <src-abs-dir>":                                                                 "<src-abs-dir> をたどる代わりに、このファイル (- で標準入力) に 1 行に 1 つずつ、または NUL 区切りで並べた絶対パスだけを分類します",
+	"only classify files modified at or after this date or within this age (e.g. 2024-01-31, 30d, 2w)":                                                                                                    "この日付以降、またはこの期間内 (例: 2024-01-31、30d、2w) に更新されたファイルだけを分類します",
+	"only classify files modified before this date or more than this age ago":                                                                                                                             "この日付より前、またはこの期間より前に更新されたファイルだけを分類します",
+	"leave files modified less than this long ago for a later run, e.g. downloads still being written (e.g. 10m, 7d); with -watch, checked again on every pass":                                           "書き込み中のダウンロードなど、更新からこの時間 (例: 10m、7d) が経っていないファイルは後の実行に回します。-watch では毎回確かめ直します",
+	"only classify files of at least this size (e.g. 100K)":                                                                                                                                               "このサイズ (例: 100K) 以上のファイルだけを分類します",
+	"only classify files of at most this size (e.g. 4G)":                                                                                                                                                  "このサイズ (例: 4G) 以下のファイルだけを分類します",
+	"skip images smaller than this as too small to keep; 0 turns the check off":                                                                                                                           "これより小さい画像は残すには小さすぎるとしてスキップします。0 でチェックしません",
+	"also skip images with fewer pixels than WIDTHxHEIGHT in either orientation (e.g. 640x480), read from the image header; use with -min-image-bytes 0 to go by pixels only":                             "画像ヘッダーから読んだピクセル数が縦横どちら向きでも WIDTHxHEIGHT (例: 640x480) に満たない画像もスキップします。ピクセル数だけで判断するには -min-image-bytes 0 と併用します",
+	"name destination files in Unicode NFC and treat NFC and NFD spellings of a name as the same name":                                                                                                    "保存先のファイル名を Unicode NFC にし、NFC と NFD で綴りが違うだけの名前を同じ名前として扱います",
+	"make destination file names valid on NTFS and exFAT drives: replace : ? * < > | \" \\, drop trailing dots and spaces and shorten long names":                                                         "保存先のファイル名を NTFS と exFAT のドライブで使えるようにします: : ? * < > | \" \\ を置き換え、末尾のピリオドと空白を取り、長い名前を短くします",
+	"once a destination folder holds this many files, put further ones in numbered sub-folders 0001, 0002, ...; 0 disables":                                                                               "保存先のフォルダーのファイルがこの数に達したら、以降は番号付きのサブフォルダー 0001、0002、... に入れます。0 で無効",
+	"ask whether to keep, skip or rename each duplicate and each image that looks like one copied earlier in the run":                                                                                     "重複と、この実行で先にコピーした画像に似た画像のそれぞれについて、残すか、スキップするか、名前を変えるかを尋ねます",
+	"store every distinct content once below <dest>/" + engine.CASDir + ", named after its hash, and make the category and date folders of hard links to it":                                              "異なる内容をそれぞれ 1 度だけハッシュの名前で <dest>/" + engine.CASDir + " の下に保存し、カテゴリーと日付のフォルダーはそれへのハードリンクで作ります",
+	"download cloud placeholders (OneDrive online-only, evicted iCloud files) by reading them instead of skipping them":                                                                                   "クラウドのプレースホルダー (OneDrive のオンライン専用ファイル、削除された iCloud のファイル) をスキップせず、読み込んでダウンロードします",
+	"ignore dotfiles and dot-directories, and files with the Windows hidden attribute":                                                                                                                    "ドットで始まるファイルとフォルダー、Windows の隠し属性を持つファイルを無視します",
+	"only take files this many levels below <src-abs-dir>; 1 is the top level only, 0 means no limit":                                                                                                     "<src-abs-dir> からこの階層までのファイルだけを対象にします。1 は最上位だけ、0 は無制限",
+	"stop after planning this many files, e.g. to try a new config; 0 means no limit":                                                                                                                     "新しい設定を試すときなどに、この数のファイルを計画したら停止します。0 は無制限",
+	"with -limit, pick the files at random from the whole source instead of taking the first ones":                                                                                                        "-limit のとき、先頭から取らずに元フォルダー全体から無作為に選びます",
+	"processing order: lexical (default; deterministic duplicate winners), newest or largest first":                                                                                                       "処理の順序: lexical (既定。重複でどれが残るかが決まります)、newest (新しい順)、largest (大きい順)",
+	"export OpenTelemetry traces to this OTLP/HTTP URL (default: $OTEL_EXPORTER_OTLP_ENDPOINT/v1/traces)":                                                                                                 "OpenTelemetry のトレースをこの OTLP/HTTP の URL に送ります (既定: $OTEL_EXPORTER_OTLP_ENDPOINT/v1/traces)",
+	"disable colored terminal output (also NO_COLOR)":                                                                                                                                                     "端末出力の色付けをしません (NO_COLOR でも同じ)",
+	"keep running and classify the source again this long after each pass (e.g. 10m); SIGHUP reloads the config, SIGTERM stops. Without <src-abs-dir> <dest-abs-dir>, classify the sources of the config": "実行し続け、毎回の処理からこの時間 (例: 10m) 後に元フォルダーを分類し直します。SIGHUP で設定を読み直し、SIGTERM で停止します。<src-abs-dir> <dest-abs-dir> がなければ設定の sources を分類します",
+	"with -watch, serve /healthz, /readyz and a JSON /status of the current pass on this address (e.g. :8080)":                                                                                            "-watch のとき、このアドレス (例: :8080) で /healthz、/readyz と現在の処理の JSON の /status を提供します",
+	"write the errors and warnings of the run to this file as JUnit XML, for CI systems":                                                                                                                  "CI システム向けに、実行のエラーと警告を JUnit XML でこのファイルに書き出します",
+	"print the errors and warnings of the run as GitHub Actions annotations":                                                                                                                              "実行のエラーと警告を GitHub Actions のアノテーションとして表示します",
+	"output format: text, or ndjson to stream one JSON event per decision to stdout":                                                                                                                      "出力形式: text、または判断ごとに 1 つの JSON イベントを標準出力に流す ndjson",
+	"only classify files whose MIME type, sniffed from their content, matches one of these comma-separated types or patterns (e.g. image/*,video/*; repeatable)":                                          "内容から判定した MIME タイプが、カンマ区切りのこれらのタイプやパターン (例: image/*,video/*。繰り返し指定可) のどれかに合うファイルだけを分類します",
+	"leave out files whose sniffed MIME type matches one of these comma-separated types or patterns (e.g. application/x-executable; repeatable)":                                                          "判定した MIME タイプが、カンマ区切りのこれらのタイプやパターン (例: application/x-executable。繰り返し指定可) のどれかに合うファイルを除きます",
+	"only classify files of these comma-separated categories (e.g. images,movies; repeatable)":                                                                                                            "カンマ区切りのこれらのカテゴリー (例: images,movies。繰り返し指定可) のファイルだけを分類します",
+	"leave out files of these comma-separated categories (e.g. others; repeatable)":                                                                                                                       "カンマ区切りのこれらのカテゴリー (例: others。繰り返し指定可) のファイルを除きます",
+	"additional absolute destination to mirror output to (repeatable)":                                                                                                                                    "出力を複製する追加の保存先の絶対パス (繰り返し指定可)",
+
+	// Usage errors of a classification run.
+	"with -files-from, expected 1 argument: <dest-abs-dir>":                                                     "-files-from のときは引数を 1 つ指定してください: <dest-abs-dir>",
+	"destination must be an absolute path":                                                                      "保存先は絶対パスで指定してください",
+	"source must be an absolute path":                                                                           "元フォルダーは絶対パスで指定してください",
+	"expected 2 arguments: <src-abs-dir> <dest-abs-dir>":                                                        "引数を 2 つ指定してください: <src-abs-dir> <dest-abs-dir>",
+	"source and destination must be absolute paths":                                                             "元フォルダーと保存先は絶対パスで指定してください",
+	"-force requires -sync-deletions":                                                                           "-force には -sync-deletions が必要です",
+	"-sync-deletions cannot be combined with -files-from":                                                       "-sync-deletions は -files-from と併用できません",
+	"-sync-deletions with -watch requires -force":                                                               "-watch で -sync-deletions を使うには -force が必要です",
+	"-interactive cannot be combined with -dry-run, -watch or -files-from -":                                    "-interactive は -dry-run、-watch、-files-from - と併用できません",
+	"-interactive needs a terminal":                                                                             "-interactive には端末が必要です",
+	"-trash requires -delete-source":                                                                            "-trash には -delete-source が必要です",
+	"-assert-readonly cannot be combined with -delete-source":                                                   "-assert-readonly は -delete-source と併用できません",
+	"-assert-readonly cannot be combined with -hydrate-placeholders":                                            "-assert-readonly は -hydrate-placeholders と併用できません",
+	"-sandbox cannot be combined with -delete-source, -trash or -hydrate-placeholders":                          "-sandbox は -delete-source、-trash、-hydrate-placeholders と併用できません",
+	"-retries must not be negative":                                                                             "-retries に負の値は指定できません",
+	"-verify-retries must not be negative":                                                                      "-verify-retries に負の値は指定できません",
+	"-limit must not be negative":                                                                               "-limit に負の値は指定できません",
+	"-sample requires -limit":                                                                                   "-sample には -limit が必要です",
+	"-max-depth must not be negative":                                                                           "-max-depth に負の値は指定できません",
+	"-max-depth cannot be combined with -files-from":                                                            "-max-depth は -files-from と併用できません",
+	"-min-size must not exceed -max-size":                                                                       "-min-size は -max-size 以下にしてください",
+	"-newer-than must be earlier than -older-than":                                                              "-newer-than は -older-than より前にしてください",
+	"-max-duration must not be negative":                                                                        "-max-duration に負の値は指定できません",
+	"-watch must not be negative":                                                                               "-watch に負の値は指定できません",
+	"-watch cannot be combined with -files-from, -dry-run or -limit":                                            "-watch は -files-from、-dry-run、-limit と併用できません",
+	"-junit and -github-annotations report a single run and cannot be combined with -watch":                     "-junit と -github-annotations は 1 回の実行を報告するもので、-watch と併用できません",
+	"-github-annotations cannot be combined with -output ndjson":                                                "-github-annotations は -output ndjson と併用できません",
+	"-health-addr requires -watch":                                                                              "-health-addr には -watch が必要です",
+	"-thumbnail-size must be positive":                                                                          "-thumbnail-size には正の値を指定してください",
+	"-output ndjson cannot be combined with -dry-run":                                                           "-output ndjson は -dry-run と併用できません",
+	"unknown -output %s":                                                                                        "-output %s は使えません",
+	"-reports-dir must be an absolute path":                                                                     "-reports-dir は絶対パスで指定してください",
+	"-reports-dir cannot be combined with the sources of the config, whose destinations keep their own reports": "-reports-dir は設定の sources と併用できません。各保存先がそれぞれのレポートを持ちます",
+	"mirror destinations must be absolute paths":                                                                "複製先は絶対パスで指定してください",
+	"mirror destination must differ from destination: %s":                                                       "複製先は保存先と別にしてください: %s",
+	"profile %q has no dest; expected 2 arguments: <src-abs-dir> <dest-abs-dir>":                                "プロファイル %q に dest がありません。引数を 2 つ指定してください: <src-abs-dir> <dest-abs-dir>",
+	"dest of profile %q must be an absolute path":                                                               "プロファイル %q の dest は絶対パスで指定してください",
+	"expected 2 arguments: <src-abs-dir> <dest-abs-dir>, or -watch with sources in the config":                  "引数を 2 つ指定するか、設定に sources を書いて -watch を指定してください: <src-abs-dir> <dest-abs-dir>",
+
+	// Run summary.
+	"stopped after running for %s (-max-duration); %d files left, re-run to resume":    "%s 実行したため停止しました (-max-duration)。残りは %d 件です。再実行すると続きから処理します",
+	"stopped after copying %s (-max-bytes %s); %d files left, re-run to resume":        "%s コピーしたため停止しました (-max-bytes %s)。残りは %d 件です。再実行すると続きから処理します",
+	"took out %d files whose sources were deleted; undo with: classifier undo %s %s\n": "元ファイルが削除された %d 件を取り除きました。元に戻すには: classifier undo %s %s\n",
+	"run %s %s: %d copied (%s), %d duplicates, %d already present, %d errors":          "実行 %s %s: コピー %d 件 (%s)、重複 %d 件、既存 %d 件、エラー %d 件",
+	"%d copies differed from their source and were made again (-verify)":               "%d 件のコピーが元ファイルと異なったため作り直しました (-verify)",
+	"scanning: %d files": "走査中: %d 件",
+	"%d copied":          "コピー %d 件",
+	"%d skipped":         "スキップ %d 件",
+	"%d failed":          "失敗 %d 件",
+
+	// Pausing.
+	"paused after the current file; send SIGUSR2 to pid %d to resume": "処理中のファイルの後で一時停止しました。再開するには pid %d に SIGUSR2 を送ってください",
+	"resumed": "再開しました",
+
+	// Questions.
+	"not taking out %d files whose sources were deleted: no terminal to confirm on, use -force": "元ファイルが削除された %d 件は取り除きません: 確認する端末がありません。-force を指定してください",
+	"take out these %d files whose sources were deleted? [y/N] ":                                "元ファイルが削除されたこれらの %d 件を取り除きますか? [y/N] ",
+	"no answer, not taking out %d files; use -force":                                            "応答がないため %d 件は取り除きません。-force を指定してください",
+	"duplicate: same content as a file already placed":                                          "重複: 配置済みのファイルと同じ内容です",
+	"near-duplicate: looks like a file already placed (similarity %.2f)\n":                      "類似: 配置済みのファイルに似ています (類似度 %.2f)\n",
+	"  new:      %s\n": "  新規: %s\n",
+	"  existing: %s\n": "  既存: %s\n",
+	"[k]eep, [s]kip or [r]ename it? K or S for all such files: ": "[k] 残す、[s] スキップ、[r] 名前を変える? 同じ種類のすべてに適用するなら K か S: ",
+	"new name: ":              "新しい名前: ",
+	"%q is not a file name\n": "%q はファイル名として使えません\n",
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+const migrateUsage = "usage: classifier migrate [-from layout] [-config path] [-dry-run] [-undo move-log] <dest-abs-dir>"
+
+// runMigrate moves the date folders of a destination from an earlier
+// date_layout to the one of the config, or with -undo moves the files of
+// an earlier migration back.
+func runMigrate(ctx context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	var from string
+	flagSet.StringVar(&from, "from", engine.DefaultDateLayout, "date_layout the destination was filed with")
+	var configPath string
+	flagSet.StringVar(&configPath, "config", "", "path or http(s) URL of the YAML config whose date_layout to migrate to (default: the embedded config)")
+	flagSet.StringVar(&configPath, "c", "", "path or http(s) URL of the YAML config (default: the embedded config)")
+	var dryRun bool
+	flagSet.BoolVar(&dryRun, "dry-run", false, "only print the moves")
+	var undo string
+	flagSet.StringVar(&undo, "undo", "", "move the files of this move log back")
+	if err := parseFlags(flagSet, args, migrateUsage); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return errors.New("expected 1 argument; " + migrateUsage)
+	}
+	dest := flagSet.Arg(0)
+	if !filepath.IsAbs(dest) {
+		return errors.New("destination must be an absolute path; " + migrateUsage)
+	}
+
+	if undo != "" {
+		return undoMoves(dest, undo, dryRun)
+	}
+
+	cfg, _, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	to := cfg.DateLayout
+	if to == "" {
+		to = engine.DefaultDateLayout
+	}
+	if to == from {
+		return fmt.Errorf("the config's date_layout is already %q; set it to the new layout first", from)
+	}
+	res, err := engine.MigrateDates(ctx, engine.MigrateOptions{Dest: dest, From: from, To: to, DryRun: dryRun, Preview: os.Stdout})
+	if err != nil {
+		if res.Moves != "" {
+			return fmt.Errorf("%w; the moves so far can be undone with -undo %s", err, res.Moves)
+		}
+		return err
+	}
+	logf(prioInfo, "migrate: %d moved, %d duplicates left in place, %d unchanged", res.Moved, res.Duplicates, res.Unchanged)
+	if res.Moves != "" {
+		fmt.Fprintf(os.Stdout, "undo with: classifier migrate -undo %s %s\n", res.Moves, dest)
+	}
+	return nil
+}
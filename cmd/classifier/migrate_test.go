@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLI_MigrateMovesDateFoldersToTheNewLayout(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	imgContent := strings.Repeat("p", 2*1024*1024)
+	writeFile(t, src, "2024-01-31_photo.jpg", imgContent)
+	writeFile(t, src, "IMG_20230715_video.mp4", "video")
+
+	res := runCLI(t, workspace, absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+
+	configPath := filepath.Join(workspace, "config.yaml")
+	writeFile(t, workspace, "config.yaml", readFile(t, filepath.Join(repoRoot(t), "internal", "engine", "config.yaml"))+"\ndate_layout: \"2006-01\"\n")
+	oldPhoto := filepath.Join(dest, "images", "2024", "202401", "2024-01-31_photo.jpg")
+	newPhoto := filepath.Join(dest, "images", "2024-01", "2024-01-31_photo.jpg")
+
+	res = runCLI(t, workspace, "migrate", "-config", configPath, "-dry-run", absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	if !strings.Contains(res.stdout, "move "+oldPhoto+" -> "+newPhoto) {
+		t.Fatalf("expected the move in the plan, got: %s", res.stdout)
+	}
+	assertFileContent(t, oldPhoto, imgContent)
+
+	res = runCLI(t, workspace, "migrate", "-config", configPath, absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, newPhoto, imgContent)
+	assertFileContent(t, filepath.Join(dest, "movies", "2023-07", "IMG_20230715_video.mp4"), "video")
+	if _, err := os.Stat(filepath.Join(dest, "images", "2024")); !os.IsNotExist(err) {
+		t.Fatalf("expected the emptied date folder to be removed, got %v", err)
+	}
+
+	// Runs under the new layout find the migrated files.
+	res = runCLI(t, workspace, "-config", configPath, absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	if _, err := os.Stat(oldPhoto); !os.IsNotExist(err) {
+		t.Fatalf("expected no copy under the old layout, got %v", err)
+	}
+
+	logs, err := filepath.Glob(filepath.Join(dest, ".classifier", "moves", "*.csv"))
+	if err != nil || len(logs) != 1 {
+		t.Fatalf("expected one move log, got %v (%v)", logs, err)
+	}
+	res = runCLI(t, workspace, "migrate", "-undo", logs[0], absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, oldPhoto, imgContent)
+}
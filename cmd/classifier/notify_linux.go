@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net"
+	"os"
+)
+
+// notifyService sends state to systemd over $NOTIFY_SOCKET, as sd_notify(3)
+// does, so a Type=notify unit knows when -watch is ready, reloading or
+// stopping. Without the variable it does nothing.
+func notifyService(state string) {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return
+	}
+	// A leading @ names an abstract socket, which net handles itself.
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		warnf("notify systemd: %v", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		warnf("notify systemd: %v", err)
+	}
+}
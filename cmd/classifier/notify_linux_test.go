@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWatchSource_ReloadsOnSIGHUPAndNotifiesSystemd(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", addr)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	reloads := 0
+	passes := 0
+	pass := func(ctx context.Context) error {
+		passes++
+		if passes == 1 {
+			if err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+				t.Errorf("send SIGHUP: %v", err)
+			}
+		} else {
+			cancel()
+		}
+		return nil
+	}
+	err = watchSources(ctx, func() error {
+		reloads++
+		return nil
+	}, func() []watchJob { return []watchJob{{interval: time.Hour, pass: pass}} })
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	if reloads != 1 || passes != 2 {
+		t.Fatalf("expected the reload to start a second pass, got %d reloads and %d passes", reloads, passes)
+	}
+
+	var states []string
+	buf := make([]byte, 256)
+	for {
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, err := conn.Read(buf)
+		if err != nil {
+			break
+		}
+		if state := string(buf[:n]); !strings.HasPrefix(state, "STATUS=") {
+			states = append(states, state)
+		}
+	}
+	want := []string{"READY=1", "RELOADING=1", "READY=1", "STOPPING=1"}
+	if strings.Join(states, " ") != strings.Join(want, " ") {
+		t.Fatalf("unexpected notifications: got %q, want %q", states, want)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !linux
+
+package main
+
+// notifyService is a no-op without systemd.
+func notifyService(string) {}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "github.com/sky0621/classifier/internal/engine"
+
+// watchPauseSignals is a no-op where SIGUSR1/SIGUSR2 do not exist.
+func watchPauseSignals(*engine.Pauser) (stop func()) {
+	return func() {}
+}
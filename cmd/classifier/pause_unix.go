@@ -0,0 +1,38 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+// watchPauseSignals pauses p on SIGUSR1 and resumes it on SIGUSR2 until the
+// returned stop function is called.
+func watchPauseSignals(p *engine.Pauser) (stop func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1, syscall.SIGUSR2)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-ch:
+				if sig == syscall.SIGUSR1 && p.Pause() {
+					logf(prioNotice, tr("paused after the current file; send SIGUSR2 to pid %d to resume"), os.Getpid())
+				}
+				if sig == syscall.SIGUSR2 && p.Resume() {
+					logf(prioNotice, "%s", tr("resumed"))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
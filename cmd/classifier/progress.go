@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+// ttyEvents turns the event stream into a single, continuously rewritten
+// status line and a per-category summary when the run ends.
+type ttyEvents struct {
+	w          io.Writer
+	colors     palette
+	scanned    int
+	categories map[string]*categoryProgress
+	// settled holds sources already counted; with mirrors every file
+	// produces one event per destination.
+	settled map[string]bool
+}
+
+type categoryProgress struct {
+	planned int
+	copied  int
+	skipped int
+	failed  int
+}
+
+func newTTYEvents(w io.Writer, colors palette) *ttyEvents {
+	return &ttyEvents{w: w, colors: colors, categories: make(map[string]*categoryProgress), settled: make(map[string]bool)}
+}
+
+func (t *ttyEvents) category(name string) *categoryProgress {
+	c, ok := t.categories[name]
+	if !ok {
+		c = &categoryProgress{}
+		t.categories[name] = c
+	}
+	return c
+}
+
+func (t *ttyEvents) Emit(ev engine.Event) {
+	switch ev.Type {
+	case engine.EventDiscovered:
+		t.scanned++
+		t.status(trf("scanning: %d files", t.scanned))
+		return
+	case engine.EventClassified:
+		t.category(ev.Category).planned++
+		return
+	}
+	if t.settled[ev.Src] {
+		return
+	}
+	c := t.category(ev.Category)
+	switch ev.Type {
+	case engine.EventCopied:
+		c.copied++
+	case engine.EventSkippedDuplicate, engine.EventSkippedPresent:
+		c.skipped++
+	case engine.EventError:
+		c.failed++
+	default:
+		return
+	}
+	t.settled[ev.Src] = true
+	if c.planned > 0 {
+		t.status(fmt.Sprintf("%s: %d/%d", ev.Category, c.copied+c.skipped+c.failed, c.planned))
+	}
+}
+
+func (t *ttyEvents) status(line string) {
+	fmt.Fprint(t.w, "\r\x1b[K"+line)
+}
+
+func (t *ttyEvents) Finish() {
+	fmt.Fprint(t.w, "\r\x1b[K")
+	names := make([]string, 0, len(t.categories))
+	width := 0
+	for name, c := range t.categories {
+		if c.copied+c.skipped+c.failed == 0 {
+			continue
+		}
+		names = append(names, name)
+		width = max(width, len(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := t.categories[name]
+		parts := []string{t.colors.paint(colorGreen, trf("%d copied", c.copied))}
+		if c.skipped > 0 {
+			parts = append(parts, t.colors.paint(colorDim, trf("%d skipped", c.skipped)))
+		}
+		if c.failed > 0 {
+			parts = append(parts, t.colors.paint(colorRed, trf("%d failed", c.failed)))
+		}
+		fmt.Fprintf(t.w, "%-*s  %s\n", width, name, strings.Join(parts, ", "))
+	}
+	// Start over for the next pass of -watch.
+	*t = *newTTYEvents(t.w, t.colors)
+}
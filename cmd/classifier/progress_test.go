@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+func TestTTYEventsSummarizesPerCategory(t *testing.T) {
+	var buf strings.Builder
+	ev := newTTYEvents(&buf, palette{})
+
+	for _, e := range []engine.Event{
+		{Type: engine.EventDiscovered, Src: "/s/a.txt"},
+		{Type: engine.EventClassified, Src: "/s/a.txt", Category: "documents"},
+		{Type: engine.EventDiscovered, Src: "/s/b.txt"},
+		{Type: engine.EventClassified, Src: "/s/b.txt", Category: "documents"},
+		{Type: engine.EventDiscovered, Src: "/s/c.mp4"},
+		{Type: engine.EventClassified, Src: "/s/c.mp4", Category: "movies"},
+		{Type: engine.EventCopied, Src: "/s/a.txt", Dest: "/d/documents/a.txt", Category: "documents"},
+		// A mirror copy of the same source must not be counted twice.
+		{Type: engine.EventCopied, Src: "/s/a.txt", Dest: "/m/documents/a.txt", Category: "documents"},
+		{Type: engine.EventSkippedDuplicate, Src: "/s/b.txt", Dest: "/d/documents/a.txt", Category: "documents"},
+		{Type: engine.EventError, Src: "/s/c.mp4", Dest: "/d/movies/c.mp4", Category: "movies", Error: "boom"},
+	} {
+		ev.Emit(e)
+	}
+	ev.Finish()
+
+	out := buf.String()
+	if !strings.Contains(out, "scanning: 3 files") || !strings.Contains(out, "documents: 2/2") {
+		t.Fatalf("expected progress status lines, got %q", out)
+	}
+	summary := out[strings.LastIndex(out, "\x1b[K")+len("\x1b[K"):]
+	want := "documents  1 copied, 1 skipped\nmovies     0 copied, 1 failed\n"
+	if summary != want {
+		t.Fatalf("unexpected summary:\ngot  %q\nwant %q", summary, want)
+	}
+}
+
+func TestPalette(t *testing.T) {
+	if got := (palette{}).paint(colorRed, "x"); got != "x" {
+		t.Fatalf("disabled palette must not color, got %q", got)
+	}
+	if got := (palette{enabled: true}).paint(colorRed, "x"); got != "\x1b[31mx\x1b[0m" {
+		t.Fatalf("unexpected colored text %q", got)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+const pruneUsage = "usage: classifier prune [-dry-run] <dest-abs-dir>"
+
+// runPrune removes empty directories left under a destination, e.g. after
+// files were moved or deleted. The destination root and the tool's state
+// directory are kept.
+func runPrune(ctx context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("prune", flag.ContinueOnError)
+	var dryRun bool
+	flagSet.BoolVar(&dryRun, "dry-run", false, "only print the directories that would be removed")
+	if err := parseFlags(flagSet, args, pruneUsage); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return errors.New("expected 1 argument; " + pruneUsage)
+	}
+	dest := flagSet.Arg(0)
+	if !filepath.IsAbs(dest) {
+		return errors.New("destination must be an absolute path; " + pruneUsage)
+	}
+
+	var dirs []string
+	err := filepath.WalkDir(dest, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || path == dest {
+			return nil
+		}
+		if engine.IsToolDir(d.Name()) {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("read destination: %w", err)
+	}
+
+	// WalkDir visits parents first, so walking backwards handles children
+	// before the directories that contain them.
+	removed := make(map[string]bool)
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		dir := dirs[i]
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("read directory %s: %w", dir, err)
+		}
+		empty := true
+		for _, e := range entries {
+			if !removed[filepath.Join(dir, e.Name())] {
+				empty = false
+				break
+			}
+		}
+		if !empty {
+			continue
+		}
+		if !dryRun {
+			if err := os.Remove(dir); err != nil {
+				return fmt.Errorf("remove directory %s: %w", dir, err)
+			}
+		}
+		removed[dir] = true
+		fmt.Fprintln(os.Stdout, dir)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLI_PruneRemovesEmptyDirectories(t *testing.T) {
+	workspace := t.TempDir()
+	dest := filepath.Join(workspace, "dest")
+
+	mustMkdir(t, filepath.Join(dest, "images", "2024", "202401"))
+	mustMkdir(t, filepath.Join(dest, "images", "2023", "202307"))
+	writeFile(t, filepath.Join(dest, "images", "2023", "202307"), "kept.jpg", "kept")
+	mustMkdir(t, filepath.Join(dest, "others"))
+	mustMkdir(t, filepath.Join(dest, ".classifier"))
+
+	res := runCLI(t, workspace, "prune", "-dry-run", absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "others")); err != nil {
+		t.Fatalf("dry run must not remove directories: %v", err)
+	}
+
+	res = runCLI(t, workspace, "prune", absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+
+	want := []string{
+		filepath.Join(dest, "others"),
+		filepath.Join(dest, "images", "2024", "202401"),
+		filepath.Join(dest, "images", "2024"),
+	}
+	if got := strings.Split(strings.TrimSpace(res.stdout), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected removed directories:\ngot  %q\nwant %q", got, want)
+	}
+	for _, dir := range want {
+		if _, err := os.Stat(dir); err == nil {
+			t.Fatalf("expected %s to be removed", dir)
+		}
+	}
+	assertFileContent(t, filepath.Join(dest, "images", "2023", "202307", "kept.jpg"), "kept")
+	if _, err := os.Stat(filepath.Join(dest, ".classifier")); err != nil {
+		t.Fatalf("expected state directory to be kept: %v", err)
+	}
+}
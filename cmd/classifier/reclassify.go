@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+const reclassifyUsage = "usage: classifier reclassify [-config path] [-dry-run] [-undo move-log] <dest-abs-dir>"
+
+// runReclassify moves the files of a destination to where the current
+// config puts them, or with -undo moves the files of an earlier
+// reclassify back.
+func runReclassify(ctx context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("reclassify", flag.ContinueOnError)
+	var configPath string
+	flagSet.StringVar(&configPath, "config", "", "path or http(s) URL of the YAML config (default: the embedded config)")
+	flagSet.StringVar(&configPath, "c", "", "path or http(s) URL of the YAML config (default: the embedded config)")
+	var dryRun bool
+	flagSet.BoolVar(&dryRun, "dry-run", false, "only print the moves")
+	var undo string
+	flagSet.StringVar(&undo, "undo", "", "move the files of this move log back")
+	if err := parseFlags(flagSet, args, reclassifyUsage); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return errors.New("expected 1 argument; " + reclassifyUsage)
+	}
+	dest := flagSet.Arg(0)
+	if !filepath.IsAbs(dest) {
+		return errors.New("destination must be an absolute path; " + reclassifyUsage)
+	}
+
+	if undo != "" {
+		return undoMoves(dest, undo, dryRun)
+	}
+
+	cfg, _, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	res, err := engine.Reclassify(ctx, engine.ReclassifyOptions{Config: cfg, Dest: dest, DryRun: dryRun, Preview: os.Stdout})
+	if err != nil {
+		if res.Moves != "" {
+			return fmt.Errorf("%w; the moves so far can be undone with -undo %s", err, res.Moves)
+		}
+		return err
+	}
+	logf(prioInfo, "reclassify: %d moved, %d duplicates left in place, %d unchanged", res.Moved, res.Duplicates, res.Unchanged)
+	if res.Moves != "" {
+		fmt.Fprintf(os.Stdout, "undo with: classifier reclassify -undo %s %s\n", res.Moves, dest)
+	}
+	return nil
+}
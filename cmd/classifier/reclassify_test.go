@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLI_ReclassifyMovesFilesAfterConfigChange(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "notes.txt", "doc")
+	writeFile(t, src, "song.mp3", "audio")
+
+	res := runCLI(t, workspace, absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	before := filepath.Join(dest, "documents", "notes.txt")
+	assertFileContent(t, before, "doc")
+
+	configPath := filepath.Join(workspace, "config.yaml")
+	writeFile(t, workspace, "config.yaml", `categories:
+  - name: texts
+    extensions: [txt]
+  - name: music
+    extensions: [mp3]
+`)
+	res = runCLI(t, workspace, "reclassify", "-config", configPath, "-dry-run", absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	after := filepath.Join(dest, "texts", "notes.txt")
+	if !strings.Contains(res.stdout, "move "+before+" -> "+after) {
+		t.Fatalf("expected the move in the plan, got: %s", res.stdout)
+	}
+	assertFileContent(t, before, "doc")
+
+	res = runCLI(t, workspace, "reclassify", "-config", configPath, absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, after, "doc")
+	if _, err := os.Stat(before); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be moved, got %v", before, err)
+	}
+	logs, err := filepath.Glob(filepath.Join(dest, ".classifier", "moves", "*.csv"))
+	if err != nil || len(logs) != 1 {
+		t.Fatalf("expected one move log, got %v (%v)", logs, err)
+	}
+	if !strings.Contains(res.stdout, "-undo "+logs[0]) {
+		t.Fatalf("expected the undo command on stdout, got: %s", res.stdout)
+	}
+	res = runCLI(t, workspace, "verify", absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected moved files to verify, got: %v, stdout: %s", res.err, res.stdout)
+	}
+
+	res = runCLI(t, workspace, "reclassify", "-undo", logs[0], absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, before, "doc")
+	if _, err := os.Stat(after); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be moved back, got %v", after, err)
+	}
+}
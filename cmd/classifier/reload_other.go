@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// watchReloadSignals returns a channel that never fires where SIGHUP does
+// not exist.
+func watchReloadSignals() (reloads <-chan os.Signal, stop func()) {
+	return nil, func() {}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+// reportCommands are the subcommands of `classifier report`.
+var reportCommands = map[string]func(context.Context, []string) error{
+	"diff": runReportDiff,
+}
+
+const reportDiffUsage = "usage: classifier report diff <dest-abs-dir> <run-a> <run-b>"
+
+var reportUsage = strings.Join([]string{reportDiffUsage}, "\n       ")
+
+func runReport(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := reportCommands[args[0]]; ok {
+			return cmd(ctx, args[1:])
+		}
+	}
+	return errors.New("expected a report subcommand; " + reportUsage)
+}
+
+// runReportDiff compares two runs from the audit log of a destination, as
+// listed by classifier history: the categories that appeared or went
+// unused, the change in duplicates and the unknown extensions new in the
+// second run. It shows what a config edit did between them.
+func runReportDiff(ctx context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("report diff", flag.ContinueOnError)
+	if err := parseFlags(flagSet, args, reportDiffUsage); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 3 {
+		return errors.New("expected 3 arguments; " + reportDiffUsage)
+	}
+	dest := flagSet.Arg(0)
+	if !filepath.IsAbs(dest) {
+		return errors.New("destination must be an absolute path; " + reportDiffUsage)
+	}
+	records, err := engine.ReadHistory(dest)
+	if err != nil {
+		return err
+	}
+	a, err := findRun(records, flagSet.Arg(1))
+	if err != nil {
+		return err
+	}
+	b, err := findRun(records, flagSet.Arg(2))
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	for _, name := range sortedKeys(b.Categories) {
+		if a.Categories[name] == 0 {
+			lines = append(lines, fmt.Sprintf("new category %s: %d files", name, b.Categories[name]))
+		}
+	}
+	for _, name := range sortedKeys(a.Categories) {
+		if b.Categories[name] == 0 {
+			lines = append(lines, fmt.Sprintf("category %s no longer used: had %d files", name, a.Categories[name]))
+		}
+	}
+	if da, db := a.Counts[engine.EventSkippedDuplicate], b.Counts[engine.EventSkippedDuplicate]; da != db {
+		lines = append(lines, fmt.Sprintf("duplicates: %d -> %d (%+d)", da, db, db-da))
+	}
+	for _, ext := range sortedKeys(b.UnknownExtensions) {
+		if a.UnknownExtensions[ext] == 0 {
+			lines = append(lines, fmt.Sprintf("new unknown extension %s: %d files", ext, b.UnknownExtensions[ext]))
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "no differences")
+	}
+	fmt.Fprintf(os.Stdout, "%s -> %s\n", a.ID, b.ID)
+	for _, line := range lines {
+		fmt.Fprintln(os.Stdout, line)
+	}
+	return ctx.Err()
+}
+
+// findRun returns the run with the given id.
+func findRun(records []engine.RunRecord, id string) (engine.RunRecord, error) {
+	for _, r := range records {
+		if r.ID == id {
+			return r, nil
+		}
+	}
+	return engine.RunRecord{}, fmt.Errorf("run %s is not in the audit log; see classifier history", id)
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+func TestCLI_ReportDiffComparesRuns(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "doc")
+	writeFile(t, src, "shot.heic", "raw")
+
+	res := runCLI(t, workspace, absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+
+	// The second run sorts heic files into a category of their own and
+	// meets a duplicate and a new unknown extension.
+	writeFile(t, workspace, "config.yaml", `categories:
+  - name: documents
+    extensions: [txt]
+  - name: raw
+    extensions: [heic]
+default_category: others
+`)
+	writeFile(t, src, "bravo.txt", "doc")
+	writeFile(t, src, "data.zzz", "data")
+	res = runCLI(t, workspace, "-config", filepath.Join(workspace, "config.yaml"), absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+
+	runs, err := engine.ReadHistory(dest)
+	if err != nil || len(runs) != 2 {
+		t.Fatalf("expected 2 runs, got %d (%v)", len(runs), err)
+	}
+	res = runCLI(t, workspace, "report", "diff", absPath(t, dest), runs[0].ID, runs[1].ID)
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	for _, want := range []string{
+		"new category raw: 1 files",
+		"duplicates: 0 -> 1 (+1)",
+		"new unknown extension zzz: 1 files",
+	} {
+		if !strings.Contains(res.stdout, want) {
+			t.Fatalf("expected %q in the report, got:\n%s", want, res.stdout)
+		}
+	}
+	if strings.Contains(res.stdout, "no longer used") {
+		t.Fatalf("expected every category of the first run to be used again, got:\n%s", res.stdout)
+	}
+
+	res = runCLI(t, workspace, "report", "diff", absPath(t, dest), runs[0].ID, "nope")
+	if res.exitCode == 0 || !strings.Contains(res.stderr, "not in the audit log") {
+		t.Fatalf("expected an unknown run to be rejected, got exit %d, stderr: %s", res.exitCode, res.stderr)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+// sandboxRun limits the writes of the process to the destinations of a run,
+// its reports directory and the roots of its categories, and with -watch over the sources of the
+// config to theirs; a dry run may write nowhere. Destinations a reload of
+// the config adds stay out of reach until a restart.
+func sandboxRun(cfg engine.Config, dest string, mirrors []string, reportsDir string, configSources, dryRun bool) error {
+	var dirs []string
+	if !dryRun {
+		dirs = append(dirs, mirrors...)
+		if reportsDir != "" {
+			dirs = append(dirs, reportsDir)
+		}
+		configs := []engine.Config{cfg}
+		if configSources {
+			for _, s := range cfg.Sources {
+				dirs = append(dirs, s.Dest)
+				configs = append(configs, cfg.ForSource(s))
+			}
+		} else {
+			dirs = append(dirs, dest)
+		}
+		for _, c := range configs {
+			for _, category := range c.Categories {
+				if category.Root != "" {
+					dirs = append(dirs, category.Root)
+				}
+			}
+		}
+	}
+	for _, dir := range dirs {
+		// The kernel allows writes below folders that exist.
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("sandbox: %w", err)
+		}
+	}
+	return sandbox(dirs)
+}
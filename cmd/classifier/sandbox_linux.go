@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// landlockWrites are the accesses of Landlock ABI 1 that change the
+// filesystem; sandbox restricts them and leaves reading alone.
+const landlockWrites = unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+	unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+	unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+	unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+	unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+	unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+	unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+	unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+	unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+	unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+
+// sandboxedEnv marks the process sandbox started again inside the sandbox.
+// It names a pipe the process inherits as fd:inode, so a variable left
+// over in the environment does not turn the sandbox off.
+const sandboxedEnv = "CLASSIFIER_SANDBOXED"
+
+// sandbox has the kernel refuse, with the Landlock LSM, every write of the
+// process and the commands it starts outside the writable folders, so a
+// config or pattern bug can never change anything else. Reading is left
+// alone, as post commands, ffmpeg and time zone data live outside the
+// source. The folders must exist. It fails on kernels without Landlock.
+//
+// Builds with cgo cannot restrict the threads of the runtime they do not
+// run on; there sandbox restricts its own thread and starts the program
+// again from it, with the same arguments, and does not return.
+func sandbox(writable []string) error {
+	if restarted() {
+		return nil
+	}
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return fmt.Errorf("sandbox: Landlock is not available: %w", errno)
+	}
+	handled := uint64(landlockWrites)
+	if abi >= 2 {
+		// Moves and links between folders.
+		handled |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		handled |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	ruleset, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("sandbox: create ruleset: %w", errno)
+	}
+	defer unix.Close(int(ruleset))
+
+	allow := func(path string, access uint64) error {
+		fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return fmt.Errorf("sandbox: open %s: %w", path, err)
+		}
+		defer unix.Close(fd)
+		rule := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(fd)}
+		if _, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, ruleset, unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&rule)), 0, 0, 0); errno != 0 {
+			return fmt.Errorf("sandbox: allow writes to %s: %w", path, errno)
+		}
+		return nil
+	}
+	for _, dir := range writable {
+		if err := allow(dir, handled); err != nil {
+			return err
+		}
+	}
+	// Commands started without their output redirected write to it.
+	if err := allow(os.DevNull, handled&(unix.LANDLOCK_ACCESS_FS_WRITE_FILE|unix.LANDLOCK_ACCESS_FS_TRUNCATE)); err != nil {
+		return err
+	}
+
+	// Every thread of the runtime has to be restricted, not just this one.
+	_, _, errno = syscall.AllThreadsSyscall(unix.SYS_PRCTL, unix.PR_SET_NO_NEW_PRIVS, 1, 0)
+	if errors.Is(errno, syscall.ENOTSUP) {
+		return sandboxExec(ruleset)
+	}
+	if errno != 0 {
+		return fmt.Errorf("sandbox: %w", errno)
+	}
+	if _, _, errno := syscall.AllThreadsSyscall(unix.SYS_LANDLOCK_RESTRICT_SELF, ruleset, 0, 0); errno != 0 {
+		return fmt.Errorf("sandbox: restrict: %w", errno)
+	}
+	return nil
+}
+
+// restarted reports whether sandboxExec started the process, that is
+// whether it holds the pipe sandboxedEnv names, and closes the pipe. Post
+// commands need not know either way.
+func restarted() bool {
+	v, ok := os.LookupEnv(sandboxedEnv)
+	if !ok {
+		return false
+	}
+	os.Unsetenv(sandboxedEnv)
+	fdText, inoText, _ := strings.Cut(v, ":")
+	fd, err := strconv.Atoi(fdText)
+	if err != nil || fd < 0 {
+		return false
+	}
+	ino, err := strconv.ParseUint(inoText, 10, 64)
+	if err != nil {
+		return false
+	}
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil || st.Mode&unix.S_IFMT != unix.S_IFIFO || st.Ino != ino {
+		return false
+	}
+	unix.Close(fd)
+	return true
+}
+
+// sandboxExec restricts the calling thread to ruleset and replaces the
+// process from it, so the program starts again in the sandbox.
+func sandboxExec(ruleset uintptr) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("sandbox: %w", err)
+	}
+	// The marker: a pipe without close-on-exec, which only the new
+	// program holds.
+	var pipe [2]int
+	if err := unix.Pipe(pipe[:]); err != nil {
+		return fmt.Errorf("sandbox: %w", err)
+	}
+	defer unix.Close(pipe[0])
+	unix.Close(pipe[1])
+	var st unix.Stat_t
+	if err := unix.Fstat(pipe[0], &st); err != nil {
+		return fmt.Errorf("sandbox: %w", err)
+	}
+	marker := fmt.Sprintf("%s=%d:%d", sandboxedEnv, pipe[0], st.Ino)
+	// The thread is not unlocked: it either becomes the new program or
+	// is left restricted.
+	runtime.LockOSThread()
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("sandbox: %w", err)
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, ruleset, 0, 0); errno != 0 {
+		return fmt.Errorf("sandbox: restrict: %w", errno)
+	}
+	err = syscall.Exec(exe, os.Args, append(os.Environ(), marker))
+	return fmt.Errorf("sandbox: start again: %w", err)
+}
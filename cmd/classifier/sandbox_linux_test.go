@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLI_SandboxRefusesWritesToTheSource(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "alpha")
+
+	for _, args := range [][]string{
+		{"-delete-source"},
+		{"-delete-source", "-trash"},
+		{"-hydrate-placeholders"},
+	} {
+		res := runCLI(t, workspace, append(append([]string{"-sandbox"}, args...), absPath(t, src), absPath(t, dest))...)
+		if res.exitCode == 0 || !strings.Contains(res.stderr, "-sandbox cannot be combined with") {
+			t.Fatalf("%v: expected a usage error, got exit %d, stderr: %s", args, res.exitCode, res.stderr)
+		}
+	}
+	assertFileContent(t, filepath.Join(src, "alpha.txt"), "alpha")
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing copied, got %v", err)
+	}
+}
+
+func TestCLI_SandboxRefusesWritesOutsideTheDestination(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("needs sh")
+	}
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	outside := filepath.Join(workspace, "outside")
+	mustMkdir(t, src)
+	writeFile(t, src, "notes.txt", "notes")
+	writeFile(t, src, "app.log", "log")
+	writeFile(t, workspace, "config.yaml", `categories:
+  - name: documents
+    extensions: [txt]
+    post_command: [sh, -c, 'echo done > "$1.post"', sh, "{dest}"]
+  - name: logs
+    extensions: [log]
+    post_command: [sh, -c, 'echo escaped > "$1"', sh, "`+outside+`"]
+`)
+
+	// A marker left over in the environment does not turn the sandbox off.
+	res := runCLIEnv(t, []string{"CLASSIFIER_SANDBOXED=1"}, workspace, "-sandbox", "-config", filepath.Join(workspace, "config.yaml"), absPath(t, src), absPath(t, dest))
+	if strings.Contains(res.stderr, "Landlock is not available") {
+		t.Skip("no Landlock in this kernel")
+	}
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "notes.txt"), "notes")
+	assertFileContent(t, filepath.Join(dest, "documents", "notes.txt.post"), "done\n")
+	assertFileContent(t, filepath.Join(dest, "logs", "app.log"), "log")
+	if _, err := os.Stat(outside); !os.IsNotExist(err) {
+		t.Fatalf("expected the write outside the destination to be refused, got %v", err)
+	}
+
+	res = runCLI(t, workspace, "-sandbox", "-dry-run", absPath(t, src), absPath(t, filepath.Join(workspace, "preview")))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "preview")); !os.IsNotExist(err) {
+		t.Fatalf("expected a dry run to create nothing, got %v", err)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// sandbox needs the Landlock LSM of Linux.
+func sandbox([]string) error {
+	return errors.New("sandbox: only supported on Linux")
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+const scanUsage = "usage: classifier scan [-config path] [-profile name] [-skip-hidden] [-top n] <src-abs-dir>"
+
+// runScan takes the inventory of a source without copying anything: its
+// extensions, sizes and file name dates, and how many files and bytes each
+// category would get under the config, to tune the rules before a run.
+func runScan(ctx context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("scan", flag.ContinueOnError)
+	var configPath string
+	flagSet.StringVar(&configPath, "config", "", "path or http(s) URL of the YAML config")
+	flagSet.StringVar(&configPath, "c", "", "path or http(s) URL of the YAML config")
+	var profile string
+	flagSet.StringVar(&profile, "profile", "", "use this profile of the config")
+	var skipHidden bool
+	flagSet.BoolVar(&skipHidden, "skip-hidden", false, "ignore dotfiles and dot-directories, and files with the Windows hidden attribute")
+	var top int
+	flagSet.IntVar(&top, "top", 20, "list at most this many of the most frequent extensions; 0 lists all")
+	if err := parseFlags(flagSet, args, scanUsage); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return errors.New("expected 1 argument; " + scanUsage)
+	}
+	src := flagSet.Arg(0)
+	if !filepath.IsAbs(src) {
+		return errors.New("source must be an absolute path; " + scanUsage)
+	}
+	if top < 0 {
+		return errors.New("-top must not be negative; " + scanUsage)
+	}
+	cfg, _, err := loadProfile(configPath, profile)
+	if err != nil {
+		return err
+	}
+
+	report, err := engine.Scan(ctx, engine.Options{
+		Config: cfg,
+		Source: src,
+		Filter: engine.Filter{SkipHidden: skipHidden},
+	})
+	if err != nil {
+		return err
+	}
+	if report.Total.Files == 0 {
+		return fmt.Errorf("no files in %s", src)
+	}
+
+	total := report.Total
+	fmt.Fprintf(os.Stdout, "%d files, %s; %d named with a date (%s)", total.Files, engine.FormatBytes(uint64(total.Bytes)), total.Dated, percent(total.Dated, total.Files))
+	if total.Dated > 0 {
+		fmt.Fprintf(os.Stdout, " from %s to %s", report.Oldest.Format("2006-01"), report.Newest.Format("2006-01"))
+	}
+	fmt.Fprintln(os.Stdout)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	row := func(name string, t engine.ScanTotals) {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t\n", name, t.Files, engine.FormatBytes(uint64(t.Bytes)), percent(t.Dated, t.Files))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "extension\tfiles\tbytes\tdated\t")
+	exts := slices.SortedFunc(maps.Keys(report.Extensions), func(a, b string) int {
+		return cmp.Or(cmp.Compare(report.Extensions[b].Files, report.Extensions[a].Files), cmp.Compare(a, b))
+	})
+	if top > 0 && len(exts) > top {
+		exts = exts[:top]
+	}
+	for _, ext := range exts {
+		row(ext, report.Extensions[ext])
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "size\tfiles\tbytes\tdated\t")
+	for i, t := range report.Sizes {
+		name := ">= " + engine.FormatBytes(uint64(engine.ScanSizeClasses[len(engine.ScanSizeClasses)-1]))
+		if i < len(engine.ScanSizeClasses) {
+			name = "< " + engine.FormatBytes(uint64(engine.ScanSizeClasses[i]))
+		}
+		row(name, t)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "category\tfiles\tbytes\tdated\t")
+	for _, name := range slices.Sorted(maps.Keys(report.Categories)) {
+		row(name, report.Categories[name])
+	}
+	if len(report.Skipped) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "left out\tfiles\tbytes\tdated\t")
+		for _, reason := range slices.Sorted(maps.Keys(report.Skipped)) {
+			row(reason, report.Skipped[reason])
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(report.Unknown) > 0 {
+		var unknown []string
+		for _, ext := range sortedKeys(report.Unknown) {
+			unknown = append(unknown, fmt.Sprintf("%s (%d)", ext, report.Unknown[ext]))
+		}
+		fmt.Fprintf(os.Stdout, "\nno rule for: %s\n", strings.Join(unknown, ", "))
+	}
+	return ctx.Err()
+}
+
+// percent formats n of total as a whole percentage.
+func percent(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d%%", n*100/total)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCLI_ScanReportsInventoryWithoutCopying(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	mustMkdir(t, src)
+	writeFile(t, src, "2024-01-05_notes.txt", "one")
+	writeFile(t, src, "2023-07-01_report.txt", "two")
+	writeFile(t, src, "todo.txt", "three")
+	writeFile(t, src, "IMG_20240301_101010.jpg", "tiny")
+	writeFile(t, src, "archive.xyz", "four")
+
+	res := runCLI(t, workspace, "scan", absPath(t, src))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	for _, want := range []string{
+		`(?m)^5 files, 19 B; 3 named with a date \(60%\) from 2023-07 to 2024-03$`,
+		`(?m)^\s*txt\s+3\s+11 B\s+66%\s*$`,
+		`(?m)^\s*< 1\.0 KiB\s+5\s+19 B\s+60%\s*$`,
+		`(?m)^\s*documents\s+3\s+11 B\s+66%\s*$`,
+		`(?m)^\s*others\s+1\s+4 B\s+0%\s*$`,
+		`(?m)^\s*smaller than -min-image-bytes\s+1\s+4 B\s+100%\s*$`,
+		`(?m)^no rule for: xyz \(1\)$`,
+	} {
+		if !regexp.MustCompile(want).MatchString(res.stdout) {
+			t.Errorf("expected output to match %s, got:\n%s", want, res.stdout)
+		}
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 5 {
+		t.Fatalf("expected scan to leave the source alone, found %d entries", len(entries))
+	}
+}
+
+func TestCLI_ScanUsesTheConfig(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	mustMkdir(t, src)
+	writeFile(t, src, "a.xyz", "aa")
+	writeFile(t, src, "b.xyz", "bb")
+	writeFile(t, workspace, "config.yaml", "categories:\n  - name: odd\n    extensions: [xyz]\n")
+
+	res := runCLI(t, workspace, "scan", "-config", filepath.Join(workspace, "config.yaml"), absPath(t, src))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	if !regexp.MustCompile(`(?m)^\s*odd\s+2\s+4 B\s+0%\s*$`).MatchString(res.stdout) || strings.Contains(res.stdout, "no rule for") {
+		t.Fatalf("expected both files in category odd, got:\n%s", res.stdout)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLI_ConfigSchemaAndValidation(t *testing.T) {
+	workspace := t.TempDir()
+
+	res := runCLI(t, workspace, "config", "schema")
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal([]byte(res.stdout), &schema); err != nil {
+		t.Fatalf("schema output is not JSON: %v", err)
+	}
+	if schema["$schema"] == nil || schema["properties"] == nil {
+		t.Fatalf("unexpected schema: %v", schema)
+	}
+
+	src := filepath.Join(workspace, "src")
+	mustMkdir(t, src)
+	writeFile(t, workspace, "config.yaml", "categories:\n  - name: docs\n    extensions: [txt]\ndefault_category: [others]\n")
+	res = runCLI(t, workspace, "-config", filepath.Join(workspace, "config.yaml"), absPath(t, src), absPath(t, filepath.Join(workspace, "dest")))
+	if res.err == nil {
+		t.Fatal("expected an invalid config to fail the run")
+	}
+	if want := filepath.Join(workspace, "config.yaml") + ":4:19: default_category: expected a string, got a list"; !strings.Contains(res.stderr, want) {
+		t.Fatalf("expected %q in stderr, got %q", want, res.stderr)
+	}
+}
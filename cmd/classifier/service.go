@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"slices"
+	"strings"
+)
+
+const serviceUsage = "usage: classifier service install|uninstall [-name name] [-- -watch <interval> [flags] <src-abs-dir> <dest-abs-dir>]"
+
+// runService registers the classifier with the Windows service manager,
+// passing it the arguments after --, or removes it again. On Linux, -watch
+// runs under a systemd unit instead.
+func runService(ctx context.Context, args []string) error {
+	if len(args) == 0 || (args[0] != "install" && args[0] != "uninstall") {
+		return errors.New("expected install or uninstall; " + serviceUsage)
+	}
+	flagSet := flag.NewFlagSet("service "+args[0], flag.ContinueOnError)
+	var name string
+	flagSet.StringVar(&name, "name", "classifier", "name of the service")
+	if err := parseFlags(flagSet, args[1:], serviceUsage); err != nil {
+		return err
+	}
+
+	if args[0] == "uninstall" {
+		if flagSet.NArg() != 0 {
+			return errors.New("unexpected arguments; " + serviceUsage)
+		}
+		return removeService(name)
+	}
+	runArgs := flagSet.Args()
+	// Without -watch the service would stop again after one pass.
+	if !slices.ContainsFunc(runArgs, func(a string) bool {
+		name, _, _ := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		return strings.HasPrefix(a, "-") && name == "watch"
+	}) {
+		return errors.New("the service needs -watch after --; " + serviceUsage)
+	}
+	return installService(name, runArgs)
+}
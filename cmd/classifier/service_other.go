@@ -0,0 +1,21 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+var errNoServiceManager = errors.New("classifier service is only available on Windows; elsewhere run -watch under systemd or another supervisor")
+
+func installService(string, []string) error { return errNoServiceManager }
+
+func removeService(string) error { return errNoServiceManager }
+
+// isWindowsService is false outside Windows.
+func isWindowsService() bool { return false }
+
+func runWindowsService(ctx context.Context, fn func(context.Context) error) error {
+	return fn(ctx)
+}
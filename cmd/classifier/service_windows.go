@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// installService creates an automatically started service that runs this
+// executable with args.
+func installService(name string, args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate executable: %w", err)
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+	s, err := m.CreateService(name, exe, mgr.Config{
+		DisplayName: name,
+		Description: "Classifies new files from a source folder into a destination.",
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if errors.Is(err, windows.ERROR_SERVICE_EXISTS) {
+		return fmt.Errorf("service %s already exists; uninstall it first", name)
+	}
+	if err != nil {
+		return fmt.Errorf("create service: %w", err)
+	}
+	defer s.Close()
+	logf(prioNotice, "installed service %s; start it with: sc start %s", name, name)
+	return nil
+}
+
+// removeService stops the service if it runs and deletes it.
+func removeService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("open service %s: %w", name, err)
+	}
+	defer s.Close()
+	// A stopped service refuses the request, which is fine.
+	_, _ = s.Control(svc.Stop)
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("delete service: %w", err)
+	}
+	logf(prioNotice, "removed service %s", name)
+	return nil
+}
+
+// isWindowsService reports whether the service manager started the
+// process.
+func isWindowsService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+// runWindowsService runs fn until it returns or the service manager asks
+// the service to stop, which cancels its context.
+func runWindowsService(ctx context.Context, fn func(context.Context) error) error {
+	h := &serviceHandler{ctx: ctx, fn: fn}
+	if err := svc.Run("", h); err != nil {
+		return fmt.Errorf("run service: %w", err)
+	}
+	return h.err
+}
+
+type serviceHandler struct {
+	ctx context.Context
+	fn  func(context.Context) error
+	err error
+}
+
+func (h *serviceHandler) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	ctx, cancel := context.WithCancel(h.ctx)
+	defer cancel()
+	status <- svc.Status{State: svc.StartPending}
+	done := make(chan error, 1)
+	go func() { done <- h.fn(ctx) }()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case h.err = <-done:
+			status <- svc.Status{State: svc.StopPending}
+			if h.err != nil {
+				return false, 1
+			}
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+			}
+		}
+	}
+}
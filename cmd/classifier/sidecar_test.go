@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCLI_SidecarsRecordProvenance(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "alpha")
+	writeFile(t, src, "bravo.txt", "bravo")
+	mtime := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(src, "alpha.txt"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sidecars do not count towards the shard size.
+	res := runCLI(t, workspace, "-sidecars", "-shard-size", "2", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	alpha := filepath.Join(dest, "documents", "alpha.txt")
+	assertFileContent(t, filepath.Join(dest, "documents", "bravo.txt"), "bravo")
+
+	var meta struct {
+		Source        string    `json:"source"`
+		ModTime       time.Time `json:"mtime"`
+		Hash          string    `json:"hash"`
+		HashAlgorithm string    `json:"hash_algorithm"`
+		RunID         string    `json:"run_id"`
+	}
+	if err := json.Unmarshal([]byte(readFile(t, alpha+".meta.json")), &meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta.Source != absPath(t, filepath.Join(src, "alpha.txt")) || !meta.ModTime.Equal(mtime) || meta.HashAlgorithm != "sha256" || len(meta.Hash) != 64 || meta.RunID == "" {
+		t.Fatalf("unexpected sidecar: %+v", meta)
+	}
+
+	// Sidecars move along with their files.
+	if err := os.Remove(filepath.Join(src, "alpha.txt")); err != nil {
+		t.Fatal(err)
+	}
+	res = runCLI(t, workspace, "-sync-deletions", "-force", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	if _, err := os.Stat(alpha + ".meta.json"); !os.IsNotExist(err) {
+		t.Fatalf("expected the sidecar of %s to be taken out with it, got %v", alpha, err)
+	}
+	removed, err := filepath.Glob(filepath.Join(dest, ".classifier", "removed", "*", "documents", "alpha.txt.meta.json"))
+	if err != nil || len(removed) != 1 {
+		t.Fatalf("expected the sidecar among the removed files, got %v, %v", removed, err)
+	}
+}
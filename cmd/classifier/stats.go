@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+const statsUsage = "usage: classifier stats [-top n] [-compare snapshot.json] [-save snapshot.json] <dest-abs-dir>"
+
+// undatedYear groups files that are not inside a year folder.
+const undatedYear = "undated"
+
+type statsTotals struct {
+	Files int64 `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// statsSnapshot is what -save writes and -compare reads back.
+type statsSnapshot struct {
+	Taken      time.Time              `json:"taken"`
+	Total      statsTotals            `json:"total"`
+	Categories map[string]statsTotals `json:"categories"`
+	Years      map[string]statsTotals `json:"years"`
+}
+
+// runStats reports per-category and per-year file counts and sizes of a
+// destination, its largest files, and the growth since an earlier snapshot.
+func runStats(ctx context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("stats", flag.ContinueOnError)
+	var top int
+	flagSet.IntVar(&top, "top", 10, "number of largest files to list")
+	var comparePath, savePath string
+	flagSet.StringVar(&comparePath, "compare", "", "show growth against a snapshot written by -save")
+	flagSet.StringVar(&savePath, "save", "", "write a JSON snapshot of these stats")
+	if err := parseFlags(flagSet, args, statsUsage); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return errors.New("expected 1 argument; " + statsUsage)
+	}
+	dest := flagSet.Arg(0)
+	if !filepath.IsAbs(dest) {
+		return errors.New("destination must be an absolute path; " + statsUsage)
+	}
+
+	var prev *statsSnapshot
+	if comparePath != "" {
+		data, err := os.ReadFile(comparePath)
+		if err != nil {
+			return fmt.Errorf("read snapshot: %w", err)
+		}
+		prev = &statsSnapshot{}
+		if err := json.Unmarshal(data, prev); err != nil {
+			return fmt.Errorf("parse snapshot %s: %w", comparePath, err)
+		}
+	}
+
+	files, err := listFiles(dest)
+	if err != nil {
+		return fmt.Errorf("read destination: %w", err)
+	}
+
+	snap := statsSnapshot{
+		Taken:      time.Now().UTC(),
+		Categories: make(map[string]statsTotals),
+		Years:      make(map[string]statsTotals),
+	}
+	var archived []fileEntry
+	for _, f := range files {
+		category, date, ok := engine.ArchivedPath(dest, f.path)
+		if !ok {
+			continue
+		}
+		year := undatedYear
+		if date != "" {
+			year = date[:4]
+		}
+		addTotals(snap.Categories, category, f.size)
+		addTotals(snap.Years, year, f.size)
+		snap.Total.Files++
+		snap.Total.Bytes += f.size
+		archived = append(archived, f)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	writeStatsTable(w, "category", snap.Categories, snap.Total, prev, func(s *statsSnapshot) map[string]statsTotals { return s.Categories })
+	fmt.Fprintln(w)
+	writeStatsTable(w, "year", snap.Years, snap.Total, prev, func(s *statsSnapshot) map[string]statsTotals { return s.Years })
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	sort.SliceStable(archived, func(i, j int) bool { return archived[i].size > archived[j].size })
+	if top > 0 && len(archived) > 0 {
+		fmt.Fprintln(os.Stdout)
+		fmt.Fprintln(os.Stdout, "largest files")
+		for _, f := range archived[:min(top, len(archived))] {
+			fmt.Fprintf(os.Stdout, "%12s  %s\n", engine.FormatBytes(uint64(f.size)), f.path)
+		}
+	}
+
+	if savePath != "" {
+		data, err := json.MarshalIndent(snap, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(savePath, append(data, '\n'), 0o644); err != nil {
+			return fmt.Errorf("write snapshot: %w", err)
+		}
+	}
+	return ctx.Err()
+}
+
+func addTotals(m map[string]statsTotals, key string, size int64) {
+	t := m[key]
+	t.Files++
+	t.Bytes += size
+	m[key] = t
+}
+
+func writeStatsTable(w io.Writer, label string, rows map[string]statsTotals, total statsTotals, prev *statsSnapshot, prevRows func(*statsSnapshot) map[string]statsTotals) {
+	header := label + "\tfiles\tbytes\t"
+	if prev != nil {
+		header += "+files\t+bytes\t"
+	}
+	fmt.Fprintln(w, header)
+
+	keys := make([]string, 0, len(rows))
+	for k := range rows {
+		keys = append(keys, k)
+	}
+	if prev != nil {
+		for k := range prevRows(prev) {
+			if _, ok := rows[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	row := func(name string, cur, old statsTotals) {
+		line := fmt.Sprintf("%s\t%d\t%s\t", name, cur.Files, engine.FormatBytes(uint64(cur.Bytes)))
+		if prev != nil {
+			line += fmt.Sprintf("%+d\t%s\t", cur.Files-old.Files, formatDelta(cur.Bytes-old.Bytes))
+		}
+		fmt.Fprintln(w, line)
+	}
+	for _, k := range keys {
+		var old statsTotals
+		if prev != nil {
+			old = prevRows(prev)[k]
+		}
+		row(k, rows[k], old)
+	}
+	var oldTotal statsTotals
+	if prev != nil {
+		oldTotal = prev.Total
+	}
+	row("total", total, oldTotal)
+}
+
+func formatDelta(n int64) string {
+	if n < 0 {
+		return "-" + engine.FormatBytes(uint64(-n))
+	}
+	return "+" + engine.FormatBytes(uint64(n))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLI_StatsReportsTotalsAndGrowth(t *testing.T) {
+	workspace := t.TempDir()
+	dest := filepath.Join(workspace, "dest")
+	snapshot := filepath.Join(workspace, "stats.json")
+
+	mustMkdir(t, filepath.Join(dest, "images", "2024", "202401"))
+	mustMkdir(t, filepath.Join(dest, "documents"))
+	mustMkdir(t, filepath.Join(dest, ".classifier"))
+	writeFile(t, filepath.Join(dest, "images", "2024", "202401"), "big.jpg", strings.Repeat("x", 2048))
+	writeFile(t, filepath.Join(dest, "documents"), "a.txt", "aaaa")
+	writeFile(t, filepath.Join(dest, ".classifier"), "checkpoint.csv", "ignored")
+	writeFile(t, dest, "warn.csv", "ignored")
+
+	res := runCLI(t, workspace, "stats", "-top", "1", "-save", snapshot, absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	for _, want := range []string{"documents", "images", "2024", undatedYear, "largest files", "big.jpg"} {
+		if !strings.Contains(res.stdout, want) {
+			t.Fatalf("expected %q in output:\n%s", want, res.stdout)
+		}
+	}
+	if strings.Contains(res.stdout, "a.txt") {
+		t.Fatalf("-top 1 must list only the largest file:\n%s", res.stdout)
+	}
+
+	data, err := os.ReadFile(snapshot)
+	if err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+	var snap statsSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		t.Fatalf("parse snapshot: %v", err)
+	}
+	if snap.Total != (statsTotals{Files: 2, Bytes: 2052}) {
+		t.Fatalf("unexpected total: %+v", snap.Total)
+	}
+	if snap.Years["2024"] != (statsTotals{Files: 1, Bytes: 2048}) {
+		t.Fatalf("unexpected 2024 totals: %+v", snap.Years["2024"])
+	}
+
+	writeFile(t, filepath.Join(dest, "documents"), "b.txt", "bb")
+	res = runCLI(t, workspace, "stats", "-compare", snapshot, absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	var documents string
+	for _, line := range strings.Split(res.stdout, "\n") {
+		if strings.Contains(line, "documents") {
+			documents = strings.Join(strings.Fields(line), " ")
+			break
+		}
+	}
+	if documents != "documents 2 6 B +1 +2 B" {
+		t.Fatalf("unexpected growth row %q in:\n%s", documents, res.stdout)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLI_SyncDeletionsTakesOutFilesWhoseSourcesWereDeleted(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	card := filepath.Join(workspace, "card")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	mustMkdir(t, card)
+	writeFile(t, src, "alpha.txt", "alpha")
+	writeFile(t, src, "bravo.txt", "bravo")
+	writeFile(t, card, "charlie.txt", "charlie")
+
+	for _, from := range []string{src, card} {
+		res := runCLI(t, workspace, absPath(t, from), absPath(t, dest))
+		if res.err != nil {
+			t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+		}
+	}
+	if err := os.Remove(filepath.Join(src, "alpha.txt")); err != nil {
+		t.Fatal(err)
+	}
+	// Deleted from another source than the one being synced.
+	if err := os.Remove(filepath.Join(card, "charlie.txt")); err != nil {
+		t.Fatal(err)
+	}
+	alpha := filepath.Join(dest, "documents", "alpha.txt")
+
+	res := runCLI(t, workspace, "-sync-deletions", "-dry-run", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	if !strings.Contains(res.stdout, "remove "+alpha) || strings.Contains(res.stdout, "charlie") {
+		t.Fatalf("unexpected plan: %s", res.stdout)
+	}
+
+	// Without a terminal to confirm on, only -force takes files out.
+	res = runCLI(t, workspace, "-sync-deletions", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, alpha, "alpha")
+	if !strings.Contains(res.stderr, "use -force") {
+		t.Fatalf("expected a hint to use -force, got: %s", res.stderr)
+	}
+
+	res = runCLI(t, workspace, "-sync-deletions", "-force", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	if _, err := os.Stat(alpha); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be taken out, got %v", alpha, err)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "bravo.txt"), "bravo")
+	assertFileContent(t, filepath.Join(dest, "documents", "charlie.txt"), "charlie")
+
+	logs, err := filepath.Glob(filepath.Join(dest, ".classifier", "moves", "*.csv"))
+	if err != nil || len(logs) != 1 {
+		t.Fatalf("expected one move log, got %v (%v)", logs, err)
+	}
+	if !strings.Contains(res.stdout, "classifier undo "+logs[0]) {
+		t.Fatalf("expected the undo command on stdout, got: %s", res.stdout)
+	}
+	res = runCLI(t, workspace, "undo", logs[0], absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, alpha, "alpha")
+}
+
+func TestCLI_SyncDeletionsFlagValidation(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+
+	for _, args := range [][]string{
+		{"-force"},
+		{"-sync-deletions", "-watch", "1m"},
+	} {
+		res := runCLI(t, workspace, append(args, absPath(t, src), absPath(t, dest))...)
+		if res.err == nil || !strings.Contains(res.stderr, "-force") {
+			t.Fatalf("%v: expected a usage error, got %v, stderr: %s", args, res.err, res.stderr)
+		}
+	}
+}
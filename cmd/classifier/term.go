@@ -0,0 +1,36 @@
+package main
+
+import "os"
+
+// ANSI color codes used for terminal output.
+const (
+	colorRed    = "31"
+	colorGreen  = "32"
+	colorYellow = "33"
+	colorDim    = "2"
+)
+
+// palette colors text only when enabled, i.e. when writing to a terminal
+// and neither NO_COLOR nor -no-color asked for plain output.
+type palette struct {
+	enabled bool
+}
+
+func newPalette(f *os.File) palette {
+	return palette{enabled: isTerminal(f) && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb"}
+}
+
+func (p palette) paint(color, s string) string {
+	if !p.enabled {
+		return s
+	}
+	return "\x1b[" + color + "m" + s + "\x1b[0m"
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// stderrColors is switched off by -no-color.
+var stderrColors = newPalette(os.Stderr)
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+func TestCLI_ThumbnailsMirrorTheArchive(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	content := noisyPNG(t, 800, 600)
+	writeFile(t, src, "2024-01-31_a.png", content)
+	writeFile(t, src, "copy.png", content)
+	writeFile(t, src, "notes.txt", "doc")
+
+	res := runCLI(t, workspace, "-thumbnails", "-thumbnail-size", "100", "-html-report", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+
+	thumb := filepath.Join(dest, engine.ThumbnailDir, "images", "2024", "202401", "2024-01-31_a.png.jpg")
+	f, err := os.Open(thumb)
+	if err != nil {
+		t.Fatalf("expected thumbnail: %v", err)
+	}
+	defer f.Close()
+	cfg, err := jpeg.DecodeConfig(f)
+	if err != nil {
+		t.Fatalf("decode thumbnail: %v", err)
+	}
+	if cfg.Width != 100 || cfg.Height != 75 {
+		t.Fatalf("unexpected thumbnail size %dx%d", cfg.Width, cfg.Height)
+	}
+	if _, err := os.Stat(filepath.Join(dest, engine.ThumbnailDir, "documents")); !os.IsNotExist(err) {
+		t.Fatalf("expected no thumbnails for documents, got %v", err)
+	}
+
+	report := readFile(t, filepath.Join(dest, engine.StateDir, "duplicates.html"))
+	if !strings.Contains(report, `src="../.thumbnails/images/2024/202401/2024-01-31_a.png.jpg"`) {
+		t.Fatalf("expected report to link the stored thumbnail:\n%.2000s", report)
+	}
+
+	res = runCLI(t, workspace, "export", absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	if strings.Contains(res.stdout, engine.ThumbnailDir) {
+		t.Fatalf("thumbnails must not be part of the catalog:\n%s", res.stdout)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+type otlpRequest struct {
+	ResourceSpans []struct {
+		ScopeSpans []struct {
+			Spans []struct {
+				TraceID      string `json:"traceId"`
+				SpanID       string `json:"spanId"`
+				ParentSpanID string `json:"parentSpanId"`
+				Name         string `json:"name"`
+			} `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+func TestCLI_ExportsTracesToOTLPEndpoint(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		reqs []otlpRequest
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req otlpRequest
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" || json.Unmarshal(body, &req) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		reqs = append(reqs, req)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "doc")
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const parentID = "00f067aa0ba902b7"
+	res := runCLIEnv(t, []string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT=" + srv.URL,
+		"TRACEPARENT=00-" + traceID + "-" + parentID + "-01",
+	}, workspace, absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reqs) != 1 {
+		t.Fatalf("expected 1 export request, got %d", len(reqs))
+	}
+	ids := make(map[string]string)
+	parents := make(map[string]string)
+	for _, s := range reqs[0].ResourceSpans[0].ScopeSpans[0].Spans {
+		if s.TraceID != traceID {
+			t.Fatalf("span %s has trace id %s, want %s", s.Name, s.TraceID, traceID)
+		}
+		ids[s.Name] = s.SpanID
+		parents[s.Name] = s.ParentSpanID
+	}
+	for _, name := range []string{"run", "walk", "classify", "hash", "copy"} {
+		if ids[name] == "" {
+			t.Fatalf("missing %s span; got %v", name, ids)
+		}
+	}
+	if parents["run"] != parentID {
+		t.Fatalf("run span must continue TRACEPARENT, parent is %q", parents["run"])
+	}
+	if parents["walk"] != ids["run"] || parents["copy"] != ids["run"] || parents["hash"] != ids["walk"] {
+		t.Fatalf("unexpected span tree: ids %v, parents %v", ids, parents)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+const undoUsage = "usage: classifier undo [-dry-run] <move-log> <dest-abs-dir>"
+
+// runUndo puts back the files of a move log written by reclassify,
+// migrate or -sync-deletions.
+func runUndo(ctx context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("undo", flag.ContinueOnError)
+	var dryRun bool
+	flagSet.BoolVar(&dryRun, "dry-run", false, "only print the moves")
+	if err := parseFlags(flagSet, args, undoUsage); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 2 {
+		return errors.New("expected 2 arguments; " + undoUsage)
+	}
+	dest := flagSet.Arg(1)
+	if !filepath.IsAbs(dest) {
+		return errors.New("destination must be an absolute path; " + undoUsage)
+	}
+	return undoMoves(dest, flagSet.Arg(0), dryRun)
+}
+
+// undoMoves moves the files of a move log back and reports the ones it
+// had to leave alone.
+func undoMoves(dest, log string, dryRun bool) error {
+	moved, skipped, err := engine.UndoMoves(dest, log, dryRun, os.Stdout)
+	for _, m := range skipped {
+		warnf("left %s alone: it is no longer at %s or %s is taken", m.From, m.To, m.From)
+	}
+	if err != nil {
+		return err
+	}
+	logf(prioInfo, "undo: %d moved back, %d left alone", moved, len(skipped))
+	return nil
+}
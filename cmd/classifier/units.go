@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+// sizeFlag is a byte count flag accepting K, M, G and T suffixes (powers of
+// 1024, with optional "B" or "iB"), e.g. "500M" or "1.5GiB".
+type sizeFlag int64
+
+func (s *sizeFlag) String() string {
+	if s == nil || *s == 0 {
+		return "0"
+	}
+	return engine.FormatBytes(uint64(*s))
+}
+
+func (s *sizeFlag) Set(v string) error {
+	n, err := parseSize(v)
+	if err != nil {
+		return err
+	}
+	*s = sizeFlag(n)
+	return nil
+}
+
+func parseSize(v string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(v))
+	str = strings.TrimSuffix(strings.TrimSuffix(str, "B"), "I")
+
+	mult := int64(1)
+	if str != "" {
+		if i := strings.IndexByte("KMGT", str[len(str)-1]); i >= 0 {
+			mult = int64(1) << (10 * (i + 1))
+			str = str[:len(str)-1]
+		}
+	}
+
+	f, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid size %q", v)
+	}
+	return int64(f * float64(mult)), nil
+}
+
+// modeFlag is the permission bits of a file in octal, e.g. "0644"; zero
+// means unset.
+type modeFlag os.FileMode
+
+func (m *modeFlag) String() string {
+	if m == nil || *m == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%04o", uint32(*m))
+}
+
+func (m *modeFlag) Set(v string) error {
+	n, err := strconv.ParseUint(strings.TrimSpace(v), 8, 32)
+	if err != nil || n == 0 || n > 0o777 {
+		return fmt.Errorf("invalid file mode %q: want octal permission bits such as 0644", v)
+	}
+	*m = modeFlag(n)
+	return nil
+}
+
+// timeFlag is a point in time given either as a date ("2024-01-31",
+// "2024-01-31T15:04:05", RFC 3339) or as an age relative to now ("30d",
+// "2w", "36h"). An age is resolved by at, so that with -watch it moves
+// along with every pass.
+type timeFlag struct {
+	v string
+}
+
+func (f *timeFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.v
+}
+
+func (f *timeFlag) Set(v string) error {
+	if _, err := parseTimeOrAge(v, time.Now()); err != nil {
+		return err
+	}
+	f.v = v
+	return nil
+}
+
+// at returns the point in time of the flag as of now, or the zero time
+// when it is not set.
+func (f *timeFlag) at(now time.Time) time.Time {
+	if f.v == "" {
+		return time.Time{}
+	}
+	t, _ := parseTimeOrAge(f.v, now)
+	return t
+}
+
+func parseTimeOrAge(v string, now time.Time) (time.Time, error) {
+	str := strings.TrimSpace(v)
+	for _, layout := range []string{time.DateOnly, "2006-01-02T15:04:05", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, str, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	d, err := parseAge(str)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: want a date like 2024-01-31 or an age like 30d, 2w or 12h", v)
+	}
+	return now.Add(-d), nil
+}
+
+// ageFlag is a length of time that also accepts days and weeks, e.g.
+// "10m", "36h" or "7d".
+type ageFlag time.Duration
+
+func (a *ageFlag) String() string {
+	if a == nil || *a == 0 {
+		return "0"
+	}
+	return time.Duration(*a).String()
+}
+
+func (a *ageFlag) Set(v string) error {
+	d, err := parseAge(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("invalid age %q: want a duration like 10m, 12h or 7d", v)
+	}
+	*a = ageFlag(d)
+	return nil
+}
+
+// parseAge parses a non-negative duration of time.ParseDuration or a
+// number of days ("d") or weeks ("w").
+func parseAge(str string) (time.Duration, error) {
+	if str != "" {
+		if i := strings.IndexByte("dw", str[len(str)-1]); i >= 0 {
+			n, err := strconv.ParseFloat(str[:len(str)-1], 64)
+			if err == nil && n >= 0 {
+				days := n * float64([]int{1, 7}[i])
+				return time.Duration(days * float64(24*time.Hour)), nil
+			}
+		}
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", str)
+	}
+	return d, nil
+}
+
+// dimensionsFlag is a pixel size given as WIDTHxHEIGHT, e.g. "640x480".
+type dimensionsFlag struct {
+	width, height int
+}
+
+func (d *dimensionsFlag) String() string {
+	if d == nil || (d.width == 0 && d.height == 0) {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", d.width, d.height)
+}
+
+func (d *dimensionsFlag) Set(v string) error {
+	w, h, err := parseDimensions(v)
+	if err != nil {
+		return err
+	}
+	d.width, d.height = w, h
+	return nil
+}
+
+func parseDimensions(v string) (int, int, error) {
+	ws, hs, ok := strings.Cut(strings.ToLower(strings.TrimSpace(v)), "x")
+	w, werr := strconv.Atoi(ws)
+	h, herr := strconv.Atoi(hs)
+	if !ok || werr != nil || herr != nil || w < 0 || h < 0 {
+		return 0, 0, fmt.Errorf("invalid dimensions %q: want WIDTHxHEIGHT like 640x480", v)
+	}
+	return w, h, nil
+}
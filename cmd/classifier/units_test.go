@@ -0,0 +1,169 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "1024", want: 1024},
+		{in: "500K", want: 500 << 10},
+		{in: "1.5GiB", want: 3 << 29},
+		{in: "2gb", want: 2 << 30},
+		{in: "1T", want: 1 << 40},
+		{in: "", wantErr: true},
+		{in: "-1M", wantErr: true},
+		{in: "ten", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Fatalf("parseSize(%q): expected error, got %d", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Fatalf("parseSize(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestModeFlag(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    modeFlag
+		wantErr bool
+	}{
+		{in: "0644", want: 0o644},
+		{in: "640", want: 0o640},
+		{in: "0", wantErr: true},
+		{in: "1777", wantErr: true},
+		{in: "rw-r--r--", wantErr: true},
+	}
+	for _, tt := range tests {
+		var got modeFlag
+		err := got.Set(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Fatalf("Set(%q): expected error, got %s", tt.in, got.String())
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Fatalf("Set(%q) = %s, %v; want %s", tt.in, got.String(), err, tt.want.String())
+		}
+	}
+}
+
+func TestParseDimensions(t *testing.T) {
+	tests := []struct {
+		in      string
+		w, h    int
+		wantErr bool
+	}{
+		{in: "640x480", w: 640, h: 480},
+		{in: " 1920X1080 ", w: 1920, h: 1080},
+		{in: "640", wantErr: true},
+		{in: "640x", wantErr: true},
+		{in: "-1x480", wantErr: true},
+	}
+	for _, tt := range tests {
+		w, h, err := parseDimensions(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Fatalf("parseDimensions(%q): expected error, got %dx%d", tt.in, w, h)
+			}
+			continue
+		}
+		if err != nil || w != tt.w || h != tt.h {
+			t.Fatalf("parseDimensions(%q) = %dx%d, %v; want %dx%d", tt.in, w, h, err, tt.w, tt.h)
+		}
+	}
+}
+
+func TestParseTimeOrAge(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "30d", want: now.AddDate(0, 0, -30)},
+		{in: "2w", want: now.AddDate(0, 0, -14)},
+		{in: "36h", want: now.Add(-36 * time.Hour)},
+		{in: "2024-01-31", want: time.Date(2024, 1, 31, 0, 0, 0, 0, time.Local)},
+		{in: "2024-01-31T08:30:00", want: time.Date(2024, 1, 31, 8, 30, 0, 0, time.Local)},
+		{in: "2024-01-31T08:30:00Z", want: time.Date(2024, 1, 31, 8, 30, 0, 0, time.UTC)},
+		{in: "", wantErr: true},
+		{in: "-3d", wantErr: true},
+		{in: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeOrAge(tt.in, now)
+		if tt.wantErr {
+			if err == nil {
+				t.Fatalf("parseTimeOrAge(%q): expected error, got %v", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || !got.Equal(tt.want) {
+			t.Fatalf("parseTimeOrAge(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestTimeFlag_AgeMovesWithNow(t *testing.T) {
+	first := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+	later := first.Add(time.Hour)
+	var age, date timeFlag
+	if err := age.Set("2d"); err != nil {
+		t.Fatal(err)
+	}
+	if err := date.Set("2024-01-31"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := age.at(later), later.AddDate(0, 0, -2); !got.Equal(want) || !age.at(first).Equal(first.AddDate(0, 0, -2)) {
+		t.Fatalf("expected an age resolved against each now, got %v at %v", got, later)
+	}
+	if !date.at(first).Equal(date.at(later)) {
+		t.Fatalf("expected a date to stay put, got %v and %v", date.at(first), date.at(later))
+	}
+	if !new(timeFlag).at(first).IsZero() {
+		t.Fatal("expected an unset flag to be the zero time")
+	}
+}
+
+func TestAgeFlag(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "10m", want: 10 * time.Minute},
+		{in: "36h", want: 36 * time.Hour},
+		{in: "7d", want: 7 * 24 * time.Hour},
+		{in: "1.5w", want: 252 * time.Hour},
+		{in: "", wantErr: true},
+		{in: "-1d", wantErr: true},
+		{in: "2024-01-31", wantErr: true},
+	}
+	for _, tt := range tests {
+		var a ageFlag
+		err := a.Set(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Fatalf("ageFlag.Set(%q): expected error, got %v", tt.in, time.Duration(a))
+			}
+			continue
+		}
+		if err != nil || time.Duration(a) != tt.want {
+			t.Fatalf("ageFlag.Set(%q) = %v, %v; want %v", tt.in, time.Duration(a), err, tt.want)
+		}
+	}
+}
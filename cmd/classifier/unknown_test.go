@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+func TestCLI_ReportsUnknownExtensions(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+
+	mustMkdir(t, filepath.Join(src, "nested"))
+	writeFile(t, src, "a.heic", "1")
+	writeFile(t, src, "b.HEIC", "2")
+	writeFile(t, filepath.Join(src, "nested"), "c.heic", "3")
+	writeFile(t, src, "d.heic", "4")
+	writeFile(t, src, "Makefile", "5")
+	writeFile(t, src, "notes.txt", "6")
+
+	res := runCLI(t, workspace, absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+
+	got := strings.Split(strings.TrimSpace(readFile(t, filepath.Join(dest, engine.StateDir, "unknown.csv"))), "\n")
+	want := []string{
+		"heic,4," + filepath.Join(src, "a.heic") + "," + filepath.Join(src, "b.HEIC") + "," + filepath.Join(src, "d.heic"),
+		"(none),1," + filepath.Join(src, "Makefile"),
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected unknown.csv:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestCLI_NoUnknownReportWhenEverythingIsKnown(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "notes.txt", "doc")
+
+	res := runCLI(t, workspace, absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dest, engine.StateDir, "unknown.csv")); !os.IsNotExist(err) {
+		t.Fatalf("expected no unknown.csv, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+const verifyUsage = "usage: classifier verify <dest-abs-dir>"
+
+// runVerify re-hashes every file the run manifests of a destination say
+// was copied and lists the ones that are gone or whose content changed.
+// Each file is hashed with the algorithm its manifest recorded, so
+// histories that switched algorithms verify as a whole. Files reclassify
+// moved are checked where they went, and files dedupe deleted on purpose
+// are not reported.
+func runVerify(ctx context.Context, args []string) error {
+	flagSet := flag.NewFlagSet("verify", flag.ContinueOnError)
+	if err := parseFlags(flagSet, args, verifyUsage); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		return errors.New("expected 1 argument; " + verifyUsage)
+	}
+	dest := flagSet.Arg(0)
+	if !filepath.IsAbs(dest) {
+		return errors.New("destination must be an absolute path; " + verifyUsage)
+	}
+
+	manifests, err := engine.Manifests(dest)
+	if err != nil {
+		return fmt.Errorf("read manifests: %w", err)
+	}
+	moved, err := engine.LoadRelocations(dest)
+	if err != nil {
+		return err
+	}
+	// A later copy to the same path supersedes an earlier one.
+	copied := make(map[string]engine.ManifestEntry)
+	var order []string
+	for _, path := range manifests {
+		entries, err := engine.ReadManifest(path)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.Action != engine.EventCopied || e.Hash == "" {
+				continue
+			}
+			e.Dest = moved.Where(e.Dest)
+			if _, ok := copied[e.Dest]; !ok {
+				order = append(order, e.Dest)
+			}
+			copied[e.Dest] = e
+		}
+	}
+	deleted, err := deletedFiles(filepath.Join(dest, engine.StateDir, "deleted.csv"))
+	if err != nil {
+		return err
+	}
+
+	bad := 0
+	for _, path := range order {
+		if deleted[path] {
+			continue
+		}
+		e := copied[path]
+		hash, err := engine.FileHashWith(ctx, path, e.HashAlgorithm)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			fmt.Fprintf(os.Stdout, "missing\t%s\n", path)
+		case err != nil:
+			return err
+		case hash != e.Hash:
+			fmt.Fprintf(os.Stdout, "changed\t%s\n", path)
+		default:
+			continue
+		}
+		bad++
+	}
+
+	if bad > 0 {
+		return fmt.Errorf("%d of %d copied files failed verification", bad, len(order))
+	}
+	return nil
+}
+
+// deletedFiles returns the paths recorded in a deletion manifest.
+func deletedFiles(manifest string) (map[string]bool, error) {
+	deleted := make(map[string]bool)
+	f, err := os.Open(manifest)
+	if errors.Is(err, os.ErrNotExist) {
+		return deleted, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read deletion manifest: %w", err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return deleted, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read deletion manifest: %w", err)
+		}
+		if len(row) > 1 {
+			deleted[row[1]] = true
+		}
+	}
+}
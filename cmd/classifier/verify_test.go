@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+func TestCLI_VerifyChecksManifestsOfMixedAlgorithms(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "alpha")
+
+	res := runCLI(t, workspace, absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+
+	// A manifest written before the algorithm was recorded, and one of a
+	// run that hashed with SHA-512.
+	docs := filepath.Join(dest, "documents")
+	writeFile(t, docs, "bravo.txt", "bravo")
+	writeFile(t, docs, "charlie.txt", "charlie")
+	manifests := filepath.Join(dest, engine.StateDir, "manifests")
+	legacy := sha256.Sum256([]byte("bravo"))
+	writeFile(t, manifests, "00000000T000000Z-1.csv",
+		"action,src,dest,size,hash,error\n"+
+			"copied,/old/bravo.txt,"+filepath.Join(docs, "bravo.txt")+",5,"+hex.EncodeToString(legacy[:])+",\n")
+	sum := sha512.Sum512([]byte("charlie"))
+	writeFile(t, manifests, "00000000T000001Z-1.csv",
+		"action,src,dest,size,hash,hash_algorithm,error\n"+
+			"copied,/old/charlie.txt,"+filepath.Join(docs, "charlie.txt")+",7,"+hex.EncodeToString(sum[:])+",sha512,\n")
+
+	res = runCLI(t, workspace, "verify", absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected intact files to verify, got: %v, stdout: %s, stderr: %s", res.err, res.stdout, res.stderr)
+	}
+
+	writeFile(t, docs, "charlie.txt", "tampered")
+	res = runCLI(t, workspace, "verify", absPath(t, dest))
+	if res.exitCode != 1 {
+		t.Fatalf("expected exit code 1 for a changed file, got %d, stderr: %s", res.exitCode, res.stderr)
+	}
+	if got, want := strings.TrimSpace(res.stdout), "changed\t"+filepath.Join(docs, "charlie.txt"); got != want {
+		t.Fatalf("unexpected report:\ngot  %q\nwant %q", got, want)
+	}
+	if !strings.Contains(res.stderr, "1 of 3 copied files") {
+		t.Fatalf("expected summary on stderr, got: %s", res.stderr)
+	}
+}
+
+func TestCLI_HashSHA256TreeIsRecordedAndVerified(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "alpha")
+
+	res := runCLI(t, workspace, "-hash", "sha256-tree", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	assertFileContent(t, filepath.Join(dest, "documents", "alpha.txt"), "alpha")
+	manifests, err := engine.Manifests(absPath(t, dest))
+	if err != nil || len(manifests) != 1 {
+		t.Fatalf("expected 1 manifest, got %v (%v)", manifests, err)
+	}
+	entries, err := engine.ReadManifest(manifests[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].HashAlgorithm != "sha256-tree" || len(entries[0].Hash) != 64 {
+		t.Fatalf("expected one sha256-tree row, got %+v", entries)
+	}
+	res = runCLI(t, workspace, "verify", absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected the copy to verify, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+
+	res = runCLI(t, workspace, "-hash", "md5", absPath(t, src), absPath(t, dest))
+	if res.exitCode == 0 || !strings.Contains(res.stderr, "want one of sha256, sha512, sha256-tree") {
+		t.Fatalf("expected a usage error, got exit %d, stderr: %s", res.exitCode, res.stderr)
+	}
+}
+
+func TestCLI_RelativePathsSurviveARemount(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "alpha")
+	writeFile(t, src, "copy.txt", "alpha")
+
+	res := runCLI(t, workspace, "-relative-paths", absPath(t, src), absPath(t, dest))
+	if res.err != nil {
+		t.Fatalf("expected success, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+	warn := strings.TrimSpace(readFile(t, filepath.Join(dest, engine.StateDir, "warn.csv")))
+	if want := "copy.txt," + filepath.Join("documents", "alpha.txt") + "," + absPath(t, src) + "," + absPath(t, dest); warn != want {
+		t.Fatalf("unexpected warn.csv:\ngot  %q\nwant %q", warn, want)
+	}
+
+	moved := filepath.Join(workspace, "mnt")
+	if err := os.Rename(dest, moved); err != nil {
+		t.Fatal(err)
+	}
+	res = runCLI(t, workspace, "verify", absPath(t, moved))
+	if res.err != nil {
+		t.Fatalf("expected the moved destination to verify, got error: %v, stderr: %s", res.err, res.stderr)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// watchJob is one source of -watch: pass classifies it and interval is the
+// pause after each pass.
+type watchJob struct {
+	name     string
+	interval time.Duration
+	pass     func(context.Context) error
+}
+
+// watchSources runs the pass of each job, then again interval after it
+// finishes, until ctx is cancelled. This is the unattended mode behind
+// -watch: a pass that fails, e.g. on a source that is not mounted, is logged
+// and retried instead of ending the process, and SIGHUP calls reload before
+// the next pass. Cancelling ctx, e.g. with SIGTERM, is a clean shutdown.
+// Passes run one at a time, whichever is due first, so sources that share a
+// destination do not run into each other. jobs, which must not be empty, is
+// called again after each reload, which makes every source due at once.
+func watchSources(ctx context.Context, reload func() error, jobs func() []watchJob) error {
+	reloads, stopReloads := watchReloadSignals()
+	defer stopReloads()
+	notifyService("READY=1")
+	defer notifyService("STOPPING=1")
+
+	current := jobs()
+	// due holds when each source is next due by name; sources not in it
+	// are due now.
+	due := make(map[string]time.Time)
+	for {
+		next := current[0]
+		for _, job := range current[1:] {
+			if due[job.name].Before(due[next.name]) {
+				next = job
+			}
+		}
+		if at := due[next.name]; time.Until(at) > 0 {
+			notifyService("STATUS=next pass at " + at.Format(time.TimeOnly))
+			timer := time.NewTimer(time.Until(at))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil
+			case <-reloads:
+				timer.Stop()
+				notifyService("RELOADING=1")
+				if err := reload(); err != nil {
+					logf(prioErr, "reload config: %v; keeping the previous one", err)
+				} else {
+					logf(prioNotice, "config reloaded")
+					current = jobs()
+				}
+				clear(due)
+				notifyService("READY=1")
+				continue
+			case <-timer.C:
+			}
+		}
+
+		if err := next.pass(ctx); err != nil {
+			if ctx.Err() != nil {
+				logf(prioNotice, "%v", err)
+				return nil
+			}
+			logf(prioErr, "%v", err)
+		}
+		due[next.name] = time.Now().Add(next.interval)
+	}
+}
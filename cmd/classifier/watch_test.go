@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchSources_RerunsUntilCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	passes := 0
+	pass := func(ctx context.Context) error {
+		passes++
+		switch passes {
+		case 1:
+			// A failing pass must not end the watch.
+			return errors.New("source not mounted")
+		case 3:
+			cancel()
+			return ctx.Err()
+		}
+		return nil
+	}
+	jobs := func() []watchJob { return []watchJob{{interval: time.Millisecond, pass: pass}} }
+	if err := watchSources(ctx, func() error { return nil }, jobs); err != nil {
+		t.Fatalf("cancelling must be a clean shutdown, got %v", err)
+	}
+	if passes != 3 {
+		t.Fatalf("expected 3 passes, got %d", passes)
+	}
+}
+
+func TestWatchSources_RunsEachSourceOnItsSchedule(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	var order []string
+	job := func(name string, interval time.Duration) watchJob {
+		return watchJob{name: name, interval: interval, pass: func(ctx context.Context) error {
+			order = append(order, name)
+			if len(order) == 4 {
+				cancel()
+			}
+			return nil
+		}}
+	}
+	jobs := func() []watchJob {
+		return []watchJob{job("slow", time.Hour), job("fast", time.Millisecond)}
+	}
+	if err := watchSources(ctx, func() error { return nil }, jobs); err != nil {
+		t.Fatalf("cancelling must be a clean shutdown, got %v", err)
+	}
+	if got := strings.Join(order, " "); got != "slow fast fast fast" {
+		t.Fatalf("unexpected passes: %s", got)
+	}
+}
@@ -0,0 +1,44 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// CASDir holds, in a content-addressed destination, one blob per distinct
+// content, named after its hash.
+const CASDir = ".cas"
+
+// blobPath returns where the blob of hash lives for files of category.
+func (d *destination) blobPath(category, hash string) string {
+	return filepath.Join(d.rootFor(category), CASDir, hash[:2], hash[2:])
+}
+
+// blobTarget returns the path a content-addressed copy of hash has to be
+// written to, or "" when the blob is already stored and only needs a new
+// link.
+func (d *destination) blobTarget(ops fileOps, category, hash string) (string, error) {
+	blob := d.blobPath(category, hash)
+	if _, err := os.Lstat(blob); err == nil {
+		return "", nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("stat blob %s: %w", blob, err)
+	}
+	if err := ops.mkdirAll(filepath.Dir(blob)); err != nil {
+		return "", fmt.Errorf("create blob folder: %w", err)
+	}
+	return blob, nil
+}
+
+// linkBlob makes path, the file's place in the category and date tree, a
+// hard link to blob. Hard links are plain files to every other tool and
+// survive moves, which is why the tree does not use symbolic links.
+func (o fileOps) linkBlob(blob, path string) error {
+	if err := o.link(blob, path); err != nil {
+		return fmt.Errorf("link %s to its blob: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// StateDir holds the tool's own bookkeeping inside a destination.
+const StateDir = ".classifier"
+
+// IsToolDir reports whether a directory directly below a destination is
+// maintained by the tool rather than part of the archive.
+func IsToolDir(name string) bool {
+	return name == StateDir || name == ThumbnailDir || name == CASDir
+}
+
+// checkpoint records the source files a stopped run has already dealt with,
+// so the next run can resume without re-hashing them. Entries are only
+// trusted while the source file's size and modification time are unchanged.
+type checkpoint struct {
+	path    string
+	entries map[string]checkpointEntry
+	order   []string
+}
+
+type checkpointEntry struct {
+	size    int64
+	modTime int64
+	hash    string
+	relPath string
+}
+
+func loadCheckpoint(dest string) (*checkpoint, error) {
+	cp := &checkpoint{
+		path:    filepath.Join(dest, StateDir, "checkpoint.csv"),
+		entries: make(map[string]checkpointEntry),
+	}
+
+	f, err := os.Open(cp.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cp, nil
+		}
+		return nil, fmt.Errorf("read checkpoint: %w", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 5
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint %s: %w", cp.path, err)
+	}
+	for _, rec := range records {
+		size, err := strconv.ParseInt(rec[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("read checkpoint %s: invalid size %q", cp.path, rec[1])
+		}
+		modTime, err := strconv.ParseInt(rec[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("read checkpoint %s: invalid mtime %q", cp.path, rec[2])
+		}
+		cp.add(rec[0], checkpointEntry{size: size, modTime: modTime, hash: rec[3], relPath: rec[4]})
+	}
+	return cp, nil
+}
+
+func (c *checkpoint) add(src string, e checkpointEntry) {
+	if _, ok := c.entries[src]; !ok {
+		c.order = append(c.order, src)
+	}
+	c.entries[src] = e
+}
+
+// done reports whether src was handled by an earlier run and is unchanged.
+func (c *checkpoint) done(src string, info fs.FileInfo) bool {
+	e, ok := c.entries[src]
+	return ok && e.size == info.Size() && e.modTime == info.ModTime().UnixNano()
+}
+
+// seed loads the hashes of checkpointed files into the dedup indexes so
+// duplicates of them are still recognised after a resume.
+func (c *checkpoint) seed(dests []*destination) {
+	for _, src := range c.order {
+		e := c.entries[src]
+		if e.relPath == "" {
+			continue
+		}
+		// Paths start with the category folder, which tells their root.
+		category, _, _ := strings.Cut(filepath.ToSlash(e.relPath), "/")
+		for _, d := range dests {
+			if _, exists := d.hashIndex[e.hash]; !exists {
+				d.hashIndex[e.hash] = filepath.Join(d.rootFor(category), e.relPath)
+			}
+		}
+	}
+}
+
+func (c *checkpoint) record(f plannedFile, primary *destination) {
+	var rel string
+	if p, ok := primary.hashIndex[f.hash]; ok {
+		rel, _ = filepath.Rel(primary.rootFor(f.category), p)
+	}
+	c.add(f.srcPath, checkpointEntry{
+		size:    f.info.Size(),
+		modTime: f.info.ModTime().UnixNano(),
+		hash:    f.hash,
+		relPath: rel,
+	})
+}
+
+// save writes the checkpoint to a temporary file and renames it into
+// place, so a crash while saving leaves the previous checkpoint intact.
+func (c *checkpoint) save() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	tmp, err := createTemp(c.path, 0o644)
+	if err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := csv.NewWriter(tmp)
+	for _, src := range c.order {
+		e := c.entries[src]
+		rec := []string{src, strconv.FormatInt(e.size, 10), strconv.FormatInt(e.modTime, 10), e.hash, e.relPath}
+		if err := w.Write(rec); err != nil {
+			tmp.Close()
+			return fmt.Errorf("write checkpoint: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	return nil
+}
+
+func (c *checkpoint) remove() error {
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpointSaveReplacesTheFile(t *testing.T) {
+	dest := t.TempDir()
+	cp, err := loadCheckpoint(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, src := range []string{"/src/a.txt", "/src/b.txt"} {
+		cp.add(src, checkpointEntry{size: int64(i), modTime: 1, hash: "h", relPath: "documents/" + filepath.Base(src)})
+		if err := cp.save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(cp.path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "checkpoint.csv" {
+		t.Fatalf("expected only the checkpoint left behind, got %v", entries)
+	}
+	loaded, err := loadCheckpoint(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.order) != 2 || loaded.entries["/src/b.txt"].relPath != "documents/b.txt" {
+		t.Fatalf("unexpected checkpoint %+v", loaded.entries)
+	}
+}
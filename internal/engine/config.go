@@ -0,0 +1,533 @@
+package engine
+
+import (
+	"cmp"
+	"embed"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config maps file extensions to categories and lists the patterns that
+// date images and movies by their file name.
+type Config struct {
+	Categories      []Category `yaml:"categories"`
+	DefaultCategory string     `yaml:"default_category"`
+	DatePatterns    []string   `yaml:"date_patterns"`
+	// DateLayout is the Go time layout of the date folders of images and
+	// movies, "/" separating nested folders; empty means
+	// DefaultDateLayout.
+	DateLayout string `yaml:"date_layout,omitempty"`
+	// Aliases maps former category names to current ones, so files
+	// already filed under a renamed category are still recognised.
+	Aliases map[string]string `yaml:"aliases,omitempty"`
+	// Exclude holds name patterns, as for filepath.Match, of the source
+	// files and directories to leave out, e.g. *.tmp or .thumbnails.
+	Exclude []string `yaml:"exclude,omitempty"`
+	// RenameExtensions maps source extensions, matched regardless of case
+	// and without the dot, to the extensions their copies are given, e.g.
+	// jpeg: jpg, or JPG: jpg to lower-case it, so the archive names files
+	// alike. The src column of the manifest keeps the source name.
+	RenameExtensions map[string]string `yaml:"rename_extensions,omitempty"`
+	// Sources are the directories -watch classifies when the command line
+	// names none.
+	Sources []Source `yaml:"sources,omitempty"`
+	// Profiles are named variants of the config; see WithProfile.
+	Profiles map[string]Profile `yaml:"profiles,omitempty"`
+}
+
+// Profile bundles the settings of one kind of run, such as photos or
+// paperwork, within a config; those it sets replace the config's.
+type Profile struct {
+	Categories      []Category `yaml:"categories,omitempty"`
+	DefaultCategory string     `yaml:"default_category,omitempty"`
+	DatePatterns    []string   `yaml:"date_patterns,omitempty"`
+	DateLayout      string     `yaml:"date_layout,omitempty"`
+	Exclude         []string   `yaml:"exclude,omitempty"`
+	// Dest is the destination of runs whose command line names only the
+	// source.
+	Dest string `yaml:"dest,omitempty"`
+}
+
+// WithProfile returns the config with the settings of the profile name.
+func (c Config) WithProfile(name string) (Config, error) {
+	p, ok := c.Profiles[name]
+	if !ok {
+		return Config{}, fmt.Errorf("profile %q is not in the config", name)
+	}
+	out := c
+	if p.Categories != nil {
+		out.Categories = p.Categories
+	}
+	out.DefaultCategory = cmp.Or(p.DefaultCategory, c.DefaultCategory)
+	if p.DatePatterns != nil {
+		out.DatePatterns = p.DatePatterns
+	}
+	out.DateLayout = cmp.Or(p.DateLayout, c.DateLayout)
+	if p.Exclude != nil {
+		out.Exclude = p.Exclude
+	}
+	return out, nil
+}
+
+// Source is a directory classified into its own destination on its own
+// schedule, with its own categories; see Config.ForSource.
+type Source struct {
+	Path string `yaml:"path"`
+	Dest string `yaml:"dest"`
+	// Interval is the pause after each pass over the source; zero means
+	// the -watch interval.
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// Categories replace the categories of the same name for the source's
+	// files; the others are added.
+	Categories []Category `yaml:"categories,omitempty"`
+	// DatePatterns, when set, replace the date patterns of the config,
+	// Exclude adds to its exclude patterns and MinBytes sets the min_bytes
+	// of categories by name.
+	DatePatterns []string         `yaml:"date_patterns,omitempty"`
+	Exclude      []string         `yaml:"exclude,omitempty"`
+	MinBytes     map[string]int64 `yaml:"min_bytes,omitempty"`
+}
+
+// ForSource returns the config the files of s are classified with.
+func (c Config) ForSource(s Source) Config {
+	out := c
+	out.Sources = nil
+	out.Categories = slices.Clone(c.Categories)
+	for _, override := range s.Categories {
+		i := slices.IndexFunc(out.Categories, func(cat Category) bool { return cat.Name == override.Name })
+		if i < 0 {
+			out.Categories = append(out.Categories, override)
+			continue
+		}
+		out.Categories[i] = override
+	}
+	if len(s.DatePatterns) > 0 {
+		out.DatePatterns = s.DatePatterns
+	}
+	out.Exclude = append(slices.Clip(c.Exclude), s.Exclude...)
+	for _, name := range slices.Sorted(maps.Keys(s.MinBytes)) {
+		i := slices.IndexFunc(out.Categories, func(cat Category) bool { return cat.Name == name })
+		if i < 0 {
+			out.Categories = append(out.Categories, Category{Name: name})
+			i = len(out.Categories) - 1
+		}
+		out.Categories[i].MinBytes = s.MinBytes[name]
+	}
+	return out
+}
+
+// excludePatterns checks the exclude patterns of cfg.
+func excludePatterns(cfg Config) ([]string, error) {
+	for _, pattern := range cfg.Exclude {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("exclude pattern %q: %w", pattern, err)
+		}
+	}
+	return cfg.Exclude, nil
+}
+
+// Category is a destination folder and the extensions that go to it.
+type Category struct {
+	Name       string   `yaml:"name"`
+	Extensions []string `yaml:"extensions"`
+	// Root, when set, is the absolute directory the category's folder is
+	// created in instead of the destination given on the command line.
+	Root string `yaml:"root,omitempty"`
+	// PostCommand is run on every file copied into the category; see
+	// PostPlaceholders. OnPostFailure is one of PostFailurePolicies.
+	PostCommand   []string `yaml:"post_command,omitempty"`
+	OnPostFailure string   `yaml:"on_post_failure,omitempty"`
+	// Layout, when LayoutAlphabetical, files undated files in a folder
+	// per first letter of their name.
+	Layout string `yaml:"layout,omitempty"`
+	// VideoBuckets send the category's videos that match one of them, the
+	// first that does, to the bucket's category instead.
+	VideoBuckets []VideoBucket `yaml:"video_buckets,omitempty"`
+	// MinBytes skips the category's files smaller than it, and
+	// MinBytesByExtension the files of single extensions instead. For
+	// images they replace -min-image-bytes. Skipped files are listed in
+	// skipped.csv.
+	MinBytes            int64            `yaml:"min_bytes,omitempty"`
+	MinBytesByExtension map[string]int64 `yaml:"min_bytes_by_extension,omitempty"`
+	// MinDuration skips the category's MP4, MOV and Matroska videos
+	// shorter than it, going by their header, before video buckets are
+	// tried; videos whose header cannot be read are kept. Skipped videos
+	// are listed in skipped.csv.
+	MinDuration time.Duration `yaml:"min_duration,omitempty"`
+}
+
+// VideoBucket matches videos by the duration and frame size in their MP4,
+// MOV or Matroska header; zero limits are not checked. A video whose header
+// cannot be read matches no bucket.
+type VideoBucket struct {
+	Category    string        `yaml:"category"`
+	MinDuration time.Duration `yaml:"min_duration,omitempty"`
+	// MaxDuration matches videos shorter than it.
+	MaxDuration time.Duration `yaml:"max_duration,omitempty"`
+	MinWidth    int           `yaml:"min_width,omitempty"`
+	MinHeight   int           `yaml:"min_height,omitempty"`
+}
+
+func (b VideoBucket) matches(v videoInfo) bool {
+	return (b.MinDuration == 0 || v.duration >= b.MinDuration) &&
+		(b.MaxDuration == 0 || (v.duration > 0 && v.duration < b.MaxDuration)) &&
+		v.width >= b.MinWidth && v.height >= b.MinHeight
+}
+
+//go:embed config.yaml
+var embeddedFS embed.FS
+
+type dateResolver struct {
+	patterns []*regexp.Regexp
+}
+
+// LoadConfig reads and validates the YAML config at path, or returns the
+// embedded default config when path is empty. See ReadConfig for the paths
+// accepted.
+func LoadConfig(path string) (Config, error) {
+	if path == "" {
+		return loadEmbeddedConfig()
+	}
+	data, err := ReadConfig(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return ParseConfig(path, data)
+}
+
+// ReadConfig returns the bytes of the config at path, which is a file or an
+// http(s) URL, or of the embedded config when path is empty. A URL may pin
+// the config's SHA-256 as a "#sha256=<hex>" fragment; a config that does not
+// match it is rejected.
+func ReadConfig(path string) ([]byte, error) {
+	if path == "" {
+		data, err := embeddedFS.ReadFile("config.yaml")
+		if err != nil {
+			return nil, fmt.Errorf("read embedded config: %w", err)
+		}
+		return data, nil
+	}
+	if isConfigURL(path) {
+		return fetchConfig(path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	return data, nil
+}
+
+// ParseConfig validates and decodes config data read from path.
+func ParseConfig(path string, data []byte) (Config, error) {
+	if isConfigURL(path) {
+		// Keep the pinned hash out of error messages.
+		path, _, _ = strings.Cut(path, "#")
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return Config{}, fmt.Errorf("parse config: %w", err)
+	}
+	if err := validateConfig(path, &doc); err != nil {
+		return Config{}, fmt.Errorf("invalid config:\n%w", err)
+	}
+
+	var cfg Config
+	if err := doc.Decode(&cfg); err != nil {
+		return Config{}, fmt.Errorf("parse config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+func loadEmbeddedConfig() (Config, error) {
+	var cfg Config
+	data, err := embeddedFS.ReadFile("config.yaml")
+	if err != nil {
+		return Config{}, fmt.Errorf("read embedded config: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("parse embedded config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// categoryAliases maps former category names to current ones.
+type categoryAliases map[string]string
+
+// current follows the aliases from name to the category it is called now.
+func (a categoryAliases) current(name string) string {
+	// Bounded, so an alias cycle cannot loop forever.
+	for range len(a) {
+		to, ok := a[name]
+		if !ok || to == name {
+			break
+		}
+		name = to
+	}
+	return name
+}
+
+// former lists, sorted, the names that lead to the category name.
+func (a categoryAliases) former(name string) []string {
+	var names []string
+	for from := range a {
+		if from != name && a.current(from) == name {
+			names = append(names, from)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// categoryResolver looks file extensions up in the config.
+type categoryResolver struct {
+	extToCategory map[string]string
+}
+
+func newCategoryResolver(cfg Config) categoryResolver {
+	resolver := categoryResolver{extToCategory: map[string]string{}}
+	for _, cat := range cfg.Categories {
+		for _, ext := range cat.Extensions {
+			clean := strings.TrimPrefix(strings.ToLower(ext), ".")
+			if clean == "" {
+				continue
+			}
+			resolver.extToCategory[clean] = cat.Name
+		}
+	}
+
+	return resolver
+}
+
+// ResolveCategory reports the category listed for the extension of path.
+// Multi-part extensions count: the longest listed suffix wins, so a
+// tar.gz rule takes backup.tar.gz before a gz rule does.
+func (r categoryResolver) ResolveCategory(path string) (string, bool) {
+	name := strings.ToLower(filepath.Base(path))
+	for i := strings.IndexByte(name, '.'); i >= 0; {
+		if cat, ok := r.extToCategory[name[i+1:]]; ok {
+			return cat, true
+		}
+		next := strings.IndexByte(name[i+1:], '.')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return "", false
+}
+
+func newDateResolver(patterns []string) (dateResolver, error) {
+	if len(patterns) == 0 {
+		return dateResolver{}, nil
+	}
+
+	res := dateResolver{patterns: make([]*regexp.Regexp, 0, len(patterns))}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return dateResolver{}, fmt.Errorf("compile date pattern %q: %w", p, err)
+		}
+		res.patterns = append(res.patterns, re)
+	}
+	return res, nil
+}
+
+// ResolveDate matches the file name of path against the date patterns.
+func (r dateResolver) ResolveDate(path string) (int, time.Month, bool) {
+	name := filepath.Base(path)
+	for _, re := range r.patterns {
+		matches := re.FindStringSubmatch(name)
+		if matches == nil {
+			continue
+		}
+
+		subexpNames := re.SubexpNames()
+		var year, month string
+		for i, v := range subexpNames {
+			if v == "year" {
+				year = matches[i]
+			}
+			if v == "month" {
+				month = matches[i]
+			}
+		}
+		if year == "" && len(matches) >= 3 {
+			year = matches[1]
+			month = matches[2]
+		}
+		if year == "" || month == "" {
+			year, month = fallbackYearMonth(matches)
+		}
+		if len(year) == 4 && len(month) == 2 {
+			return yearMonth(year, month)
+		}
+	}
+	return 0, 0, false
+}
+
+func fallbackYearMonth(matches []string) (string, string) {
+	var year, month string
+	for _, m := range matches {
+		if len(m) == 4 && allDigits(m) && year == "" {
+			year = m
+			continue
+		}
+		if len(m) == 2 && allDigits(m) && month == "" {
+			month = m
+		}
+		if year != "" && month != "" {
+			break
+		}
+	}
+	return year, month
+}
+
+func allDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// categoryRoots maps the categories that set their own root to it.
+func categoryRoots(cfg Config) (map[string]string, error) {
+	var roots map[string]string
+	for _, c := range cfg.Categories {
+		if c.Root == "" {
+			continue
+		}
+		if !filepath.IsAbs(c.Root) {
+			return nil, fmt.Errorf("category %q: root %s is not an absolute path", c.Name, c.Root)
+		}
+		if roots == nil {
+			roots = make(map[string]string)
+		}
+		roots[c.Name] = filepath.Clean(c.Root)
+	}
+	return roots, nil
+}
+
+// alphabeticalCategories returns the categories with the alphabetical
+// layout.
+func alphabeticalCategories(cfg Config) (map[string]bool, error) {
+	out := make(map[string]bool)
+	for _, c := range cfg.Categories {
+		switch c.Layout {
+		case "":
+		case LayoutAlphabetical:
+			out[c.Name] = true
+		default:
+			return nil, fmt.Errorf("category %q: unknown layout %q, want %q", c.Name, c.Layout, LayoutAlphabetical)
+		}
+	}
+	return out, nil
+}
+
+// minSizes holds the size minimum of a category: all applies to its files
+// unless byExt has one for their extension.
+type minSizes struct {
+	all   int64
+	byExt map[string]int64
+}
+
+// extensionRenames checks the rename_extensions of cfg and returns them
+// with the source extensions lower-cased and without the leading dot.
+func extensionRenames(cfg Config) (map[string]string, error) {
+	out := make(map[string]string, len(cfg.RenameExtensions))
+	for _, from := range slices.Sorted(maps.Keys(cfg.RenameExtensions)) {
+		to := strings.TrimPrefix(cfg.RenameExtensions[from], ".")
+		if to == "" || !filepath.IsLocal(to) || strings.ContainsAny(to, `/\`) {
+			return nil, fmt.Errorf("rename_extensions: invalid extension %q for %q", cfg.RenameExtensions[from], from)
+		}
+		key := strings.TrimPrefix(strings.ToLower(from), ".")
+		if prev, ok := out[key]; ok && prev != to {
+			return nil, fmt.Errorf("rename_extensions: %q is renamed to both %q and %q", key, prev, to)
+		}
+		out[key] = to
+	}
+	return out, nil
+}
+
+// minSizeRules returns the size minimums of each category that has any,
+// with extensions lower-cased and without the leading dot.
+func minSizeRules(cfg Config) (map[string]minSizes, error) {
+	out := make(map[string]minSizes)
+	for _, c := range cfg.Categories {
+		if c.MinBytes < 0 {
+			return nil, fmt.Errorf("category %q: min_bytes must not be negative", c.Name)
+		}
+		if c.MinBytes == 0 && len(c.MinBytesByExtension) == 0 {
+			continue
+		}
+		rule := minSizes{all: c.MinBytes, byExt: make(map[string]int64)}
+		for ext, n := range c.MinBytesByExtension {
+			if n < 0 {
+				return nil, fmt.Errorf("category %q: min_bytes_by_extension of %q must not be negative", c.Name, ext)
+			}
+			rule.byExt[strings.TrimPrefix(strings.ToLower(ext), ".")] = n
+		}
+		out[c.Name] = rule
+	}
+	return out, nil
+}
+
+// minimum returns the size minimum of the file name, going by its longest
+// extension with a minimum of its own; ok is false without any.
+func (m minSizes) minimum(name string) (n int64, ok bool) {
+	name = strings.ToLower(name)
+	for i := strings.IndexByte(name, '.'); i >= 0; {
+		if n, ok := m.byExt[name[i+1:]]; ok {
+			return n, true
+		}
+		next := strings.IndexByte(name[i+1:], '.')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return m.all, m.all > 0
+}
+
+// minDurations returns the min_duration of each category that has one.
+func minDurations(cfg Config) (map[string]time.Duration, error) {
+	out := make(map[string]time.Duration)
+	for _, c := range cfg.Categories {
+		if c.MinDuration < 0 {
+			return nil, fmt.Errorf("category %q: min_duration must not be negative", c.Name)
+		}
+		if c.MinDuration > 0 {
+			out[c.Name] = c.MinDuration
+		}
+	}
+	return out, nil
+}
+
+// videoBuckets returns the video buckets of each category that has any.
+func videoBuckets(cfg Config) (map[string][]VideoBucket, error) {
+	out := make(map[string][]VideoBucket)
+	for _, c := range cfg.Categories {
+		for _, b := range c.VideoBuckets {
+			if err := categoryDir(b.Category); err != nil {
+				return nil, fmt.Errorf("category %q: video bucket: %w", c.Name, err)
+			}
+		}
+		if len(c.VideoBuckets) > 0 {
+			out[c.Name] = c.VideoBuckets
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCategoryResolver_LongestSuffixWins(t *testing.T) {
+	r := newCategoryResolver(Config{Categories: []Category{
+		{Name: "compressed", Extensions: []string{"gz", "zst"}},
+		{Name: "archives", Extensions: []string{".tar.gz", "TAR.ZST"}},
+	}})
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"/in/backup.tar.gz", "archives", true},
+		{"/in/backup.2024.TAR.zst", "archives", true},
+		{"/in/notes.txt.gz", "compressed", true},
+		{"/in/tar.gz", "compressed", true},
+		{"/in/README", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := r.ResolveCategory(tt.path)
+			if got != tt.want || ok != tt.ok {
+				t.Fatalf("ResolveCategory(%q) = %q, %v; want %q, %v", tt.path, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_URL(t *testing.T) {
+	const body = "categories:\n  - name: notes\n    extensions: [md]\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/classifier.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	url := srv.URL + "/classifier.yaml"
+	pin := HashConfig([]byte(body))
+
+	for _, path := range []string{url, url + "#sha256=" + pin, url + "#sha256=" + strings.ToUpper(pin)} {
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("LoadConfig(%q): %v", path, err)
+		}
+		if len(cfg.Categories) != 1 || cfg.Categories[0].Name != "notes" {
+			t.Fatalf("LoadConfig(%q) = %+v", path, cfg)
+		}
+	}
+
+	tests := []struct {
+		path, wantErr string
+	}{
+		{url + "#sha256=" + strings.Repeat("0", 64), "is pinned"},
+		{url + "#md5=abc", "unknown fragment"},
+		{srv.URL + "/missing.yaml", "404"},
+	}
+	for _, tt := range tests {
+		if _, err := LoadConfig(tt.path); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Fatalf("LoadConfig(%q): expected error containing %q, got %v", tt.path, tt.wantErr, err)
+		}
+	}
+}
+
+func TestConfig_ForSource(t *testing.T) {
+	const body = `categories:
+  - name: images
+    extensions: [jpg]
+  - name: documents
+    extensions: [txt]
+sources:
+  - path: /mnt/phone
+    dest: /archive
+    interval: 1h
+    categories:
+      - name: images
+        extensions: [jpg, heic]
+      - name: raw
+        extensions: [dng]
+    date_patterns: ['^WA(\d{4})(\d{2})']
+    exclude: ['.thumbnails']
+    min_bytes:
+      documents: 10
+      others: 20
+exclude: ['*.tmp']
+`
+	cfg, err := ParseConfig("c.yaml", []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Sources) != 1 || cfg.Sources[0].Interval != time.Hour {
+		t.Fatalf("unexpected sources: %+v", cfg.Sources)
+	}
+	got := cfg.ForSource(cfg.Sources[0])
+	var names []string
+	for _, c := range got.Categories {
+		names = append(names, c.Name+":"+strings.Join(c.Extensions, ","))
+	}
+	if want := "images:jpg,heic documents:txt raw:dng others:"; strings.Join(names, " ") != want || got.Sources != nil {
+		t.Fatalf("ForSource = %q, %d sources; want %q", strings.Join(names, " "), len(got.Sources), want)
+	}
+	if got.Categories[1].MinBytes != 10 || got.Categories[3].MinBytes != 20 {
+		t.Fatalf("expected the min_bytes of the source, got %+v", got.Categories)
+	}
+	if strings.Join(got.Exclude, " ") != "*.tmp .thumbnails" || len(got.DatePatterns) != 1 {
+		t.Fatalf("unexpected exclude %q and date patterns %q", got.Exclude, got.DatePatterns)
+	}
+	if len(cfg.Categories[0].Extensions) != 1 || len(cfg.Exclude) != 1 || cfg.Categories[1].MinBytes != 0 {
+		t.Fatalf("ForSource changed the config: %+v", cfg.Categories)
+	}
+
+	// Source categories are checked like the others.
+	_, err = ParseConfig("c.yaml", []byte("sources:\n  - path: /a\n    dest: /b\n    categories:\n      - name: x\n        extension: [y]\n"))
+	if err == nil || !strings.Contains(err.Error(), `sources[0].categories[0].extension: unknown key (did you mean "extensions"?)`) {
+		t.Fatalf("expected the misspelt key to be reported, got %v", err)
+	}
+}
+
+func TestConfig_WithProfile(t *testing.T) {
+	const body = `categories:
+  - name: documents
+    extensions: [txt]
+date_patterns: ['^(?P<year>\\d{4})-(?P<month>\\d{2})']
+profiles:
+  paperwork:
+    categories:
+      - name: invoices
+        extensions: [pdf]
+    default_category: unsorted
+    dest: /archive/paperwork
+`
+	cfg, err := ParseConfig("c.yaml", []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := cfg.WithProfile("paperwork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Categories) != 1 || got.Categories[0].Name != "invoices" || got.DefaultCategory != "unsorted" || len(got.DatePatterns) != 1 {
+		t.Fatalf("unexpected config of the profile: %+v", got)
+	}
+	if _, err := cfg.WithProfile("photos"); err == nil || !strings.Contains(err.Error(), `profile "photos" is not in the config`) {
+		t.Fatalf("expected an unknown profile to be rejected, got %v", err)
+	}
+}
+
+func TestRun_RenameExtensions(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "a.JPEG", "alpha")
+	writeFile(t, src, "b.JPG", "bravo")
+	writeFile(t, src, "c.mpeg4", "charlie")
+	writeFile(t, src, "d.png", "delta")
+	cfg := Config{
+		Categories: []Category{
+			{Name: "documents", Extensions: []string{"jpeg", "jpg", "mpeg4", "png"}},
+		},
+		RenameExtensions: map[string]string{"jpeg": "jpg", "JPG": "jpg", ".mpeg4": ".mp4"},
+	}
+
+	if _, err := Run(t.Context(), Options{Config: cfg, Source: src, Dest: dest, NoSpaceCheck: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.jpg", "b.jpg", "c.mp4", "d.png"} {
+		if _, err := os.Stat(filepath.Join(dest, "documents", name)); err != nil {
+			t.Errorf("expected %s: %v", name, err)
+		}
+	}
+	manifests, err := Manifests(dest)
+	if err != nil || len(manifests) != 1 {
+		t.Fatalf("expected one manifest, got %v (%v)", manifests, err)
+	}
+	entries, err := ReadManifest(manifests[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 || entries[0].Src != filepath.Join(src, "a.JPEG") || entries[0].Dest != filepath.Join(dest, "documents", "a.jpg") {
+		t.Fatalf("expected the manifest to keep the source names, got %+v", entries)
+	}
+
+	cfg.RenameExtensions = map[string]string{"jpeg": "jpg", "JPEG": "jpe"}
+	if _, err := Run(t.Context(), Options{Config: cfg, Source: src, Dest: dest, NoSpaceCheck: true}); err == nil || !strings.Contains(err.Error(), "renamed to both") {
+		t.Fatalf("expected conflicting renames to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type skippedEntry struct {
+	srcPath  string
+	destPath string
+}
+
+// copyFile streams src into every dest at once. Each copy is written to a
+// temporary file next to its dest and renamed into place only when complete,
+// so an interrupted copy never leaves a truncated file behind. Per-destination
+// failures are returned in errs; err is set only when the source itself could
+// not be read or ctx was cancelled. Each copy is created with the mode of
+// the same index in perms. With durable set, each copy is fsynced before it
+// is renamed into place. A single copy is made inside the kernel where the
+// platform and filesystems allow, falling back to streaming it.
+func copyFile(ctx context.Context, src string, dests []string, perms []os.FileMode, durable bool) ([]error, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, fmt.Errorf("open source file %s: %w", src, err)
+	}
+	defer in.Close()
+
+	errs := make([]error, len(dests))
+	tmps := make([]*os.File, len(dests))
+	w := &fanoutWriter{writers: make([]io.Writer, len(dests)), errs: make([]error, len(dests))}
+	for i, dest := range dests {
+		tmp, err := createTemp(dest, perms[i])
+		if err != nil {
+			errs[i] = fmt.Errorf("create destination file %s: %w", dest, err)
+			w.errs[i] = err
+			continue
+		}
+		tmps[i] = tmp
+		w.writers[i] = tmp
+	}
+
+	var copyErr error
+	if len(tmps) != 1 || tmps[0] == nil || !zeroCopy(ctx, tmps[0], in) {
+		_, copyErr = io.Copy(w, ctxReader{ctx: ctx, r: in})
+	}
+	readFailed := copyErr != nil && !errors.Is(copyErr, errNoWriters)
+	for i, tmp := range tmps {
+		if tmp == nil {
+			continue
+		}
+		if w.errs[i] != nil {
+			errs[i] = fmt.Errorf("copy %s -> %s: %w", src, dests[i], w.errs[i])
+		}
+		if durable && errs[i] == nil && !readFailed {
+			if err := tmp.Sync(); err != nil {
+				errs[i] = fmt.Errorf("sync destination file %s: %w", dests[i], err)
+			}
+		}
+		if err := tmp.Close(); err != nil && errs[i] == nil {
+			errs[i] = fmt.Errorf("close destination file %s: %w", dests[i], err)
+		}
+		if errs[i] == nil && !readFailed {
+			if err := os.Rename(tmp.Name(), dests[i]); err != nil {
+				errs[i] = fmt.Errorf("rename into place %s: %w", dests[i], err)
+			}
+		}
+		if errs[i] != nil || readFailed {
+			os.Remove(tmp.Name())
+		}
+	}
+	if readFailed {
+		return nil, fmt.Errorf("copy %s: %w", src, copyErr)
+	}
+
+	return errs, nil
+}
+
+// zeroCopyChunk is how much zeroCopy hands the kernel at once; ctx is
+// checked in between.
+const zeroCopyChunk = 8 << 20
+
+// createTemp creates a hidden temporary file next to dest.
+func createTemp(dest string, perm os.FileMode) (*os.File, error) {
+	dir, base := filepath.Split(dest)
+	for i := 0; ; i++ {
+		name := filepath.Join(dir, fmt.Sprintf(".%s.%d-%d.classifier-tmp", base, os.Getpid(), i))
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		return f, err
+	}
+}
+
+// ctxReader stops a copy or hash as soon as ctx is cancelled.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// uniqueDestPath reports present when a candidate already holds the same
+// content, so re-runs skip files copied previously. Names in use are told
+// apart by folder, the slug of the source folder, when it is set and else
+// by a number alone.
+func uniqueDestPath(ctx context.Context, dir, name, folder string, size int64, hash string, hashFn func(context.Context, string) (string, error), reserved pathSet) (string, bool, error) {
+	return findDestPath(ctx, dir, name, folder, size, hash, hashFn, reserved, false)
+}
+
+// claimDestPath is uniqueDestPath for a file about to be copied: the free
+// name it returns is created, empty, with O_EXCL, so no other run or worker
+// can take it before the copy is renamed over it. A name created by someone
+// else in between is passed over like any name in use. The caller removes
+// the empty file when the copy does not happen.
+func claimDestPath(ctx context.Context, dir, name, folder string, size int64, hash string, hashFn func(context.Context, string) (string, error), reserved pathSet) (string, bool, error) {
+	return findDestPath(ctx, dir, name, folder, size, hash, hashFn, reserved, true)
+}
+
+func findDestPath(ctx context.Context, dir, name, folder string, size int64, hash string, hashFn func(context.Context, string) (string, error), reserved pathSet, claim bool) (string, bool, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if folder != "" {
+		// alpha.jpg from nested/ becomes alpha__nested.jpg, then
+		// alpha__nested_1.jpg.
+		base += "__" + folder
+	}
+
+	for i := 0; ; i++ {
+		candidate := filepath.Join(dir, name)
+		switch {
+		case i > 0 && folder != "":
+			candidate = filepath.Join(dir, base+ext)
+			if i > 1 {
+				candidate = filepath.Join(dir, fmt.Sprintf("%s_%d%s", base, i-1, ext))
+			}
+		case i > 0:
+			candidate = filepath.Join(dir, fmt.Sprintf("%s_%d%s", base, i, ext))
+		}
+		if reserved.has(candidate) {
+			continue
+		}
+		path, err := reserved.onDisk(candidate)
+		if err != nil {
+			return "", false, err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return "", false, fmt.Errorf("stat destination %s: %w", path, err)
+			}
+			if !claim {
+				return candidate, false, nil
+			}
+			f, err := os.OpenFile(candidate, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+			if errors.Is(err, os.ErrExist) {
+				continue
+			}
+			if err != nil {
+				return "", false, fmt.Errorf("create destination file %s: %w", candidate, err)
+			}
+			if err := f.Close(); err != nil {
+				return "", false, fmt.Errorf("create destination file %s: %w", candidate, err)
+			}
+			return candidate, false, nil
+		}
+		if !info.Mode().IsRegular() || info.Size() != size {
+			continue
+		}
+		existing, err := hashFn(ctx, path)
+		if err != nil {
+			return "", false, err
+		}
+		if existing == hash {
+			return path, true, nil
+		}
+	}
+}
+
+// Hash algorithms a run can deduplicate by.
+const (
+	hashSHA256     = "sha256"
+	hashSHA512     = "sha512"
+	hashSHA256Tree = "sha256-tree"
+)
+
+// HashAlgorithms are the valid values of Options.Hash.
+var HashAlgorithms = []string{hashSHA256, hashSHA512, hashSHA256Tree}
+
+var hashAlgorithms = map[string]func() hash.Hash{
+	hashSHA256:     sha256.New,
+	hashSHA512:     sha512.New,
+	hashSHA256Tree: newTreeHash,
+}
+
+// FileHash returns the hex SHA-256 of the file at path.
+func FileHash(ctx context.Context, path string) (string, error) {
+	return hashFileWith(ctx, path, sha256.New)
+}
+
+// hashFileWith hashes path with newHash, or SHA-256 when it is nil.
+func hashFileWith(ctx context.Context, path string, newHash func() hash.Hash) (string, error) {
+	if newHash == nil {
+		newHash = sha256.New
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open for hash %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := newHash()
+	if sum, ok, err := hashLargeFile(ctx, f, h); ok {
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%x", sum), nil
+	}
+	if _, err := io.Copy(h, ctxReader{ctx: ctx, r: f}); err != nil {
+		return "", fmt.Errorf("hash %s: %w", path, err)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func writeWarnings(path string, entries []skippedEntry, paths reportPaths, stamp reportStamp) error {
+	f, err := stamp.open(path)
+	if err != nil {
+		return fmt.Errorf("write warnings: %w", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	for _, e := range entries {
+		src, srcRoot := paths.src(e.srcPath)
+		dest, destRoot := paths.dest(e.destPath)
+		row := []string{src, dest}
+		if paths.relative {
+			row = append(row, srcRoot, destRoot)
+		}
+		if err := w.Write(stamp.row(row)); err != nil {
+			return fmt.Errorf("write warnings: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("write warnings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCopyFile_CancelledLeavesNoPartialFile(t *testing.T) {
+	workspace := t.TempDir()
+	writeFile(t, workspace, "alpha.txt", strings.Repeat("a", 1024))
+	out := filepath.Join(workspace, "out")
+	mustMkdir(t, out)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := copyFile(ctx, filepath.Join(workspace, "alpha.txt"), []string{filepath.Join(out, "alpha.txt")}, []os.FileMode{0o644}, false)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatalf("read output dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected no files after cancelled copy, got %d (first: %s)", len(entries), entries[0].Name())
+	}
+}
+
+func TestCopyFile_SingleAndMirroredCopiesMatchTheSource(t *testing.T) {
+	workspace := t.TempDir()
+	data := make([]byte, zeroCopyChunk+12345)
+	rand.NewChaCha8([32]byte{}).Read(data)
+	src := filepath.Join(workspace, "movie.mp4")
+	if err := os.WriteFile(src, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dests := range [][]string{{"single.mp4"}, {"first.mp4", "mirror.mp4"}} {
+		paths := make([]string, len(dests))
+		perms := make([]os.FileMode, len(dests))
+		for i, name := range dests {
+			paths[i], perms[i] = filepath.Join(workspace, name), 0o644
+		}
+		errs, err := copyFile(context.Background(), src, paths, perms, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, path := range paths {
+			if errs[i] != nil {
+				t.Fatal(errs[i])
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("%s differs from its source", dests[i])
+			}
+		}
+	}
+}
+
+func TestZeroCopy_LeavesOffsetsForThePlainCopy(t *testing.T) {
+	workspace := t.TempDir()
+	data := []byte(strings.Repeat("0123456789", 1000))
+	srcPath := filepath.Join(workspace, "src")
+	if err := os.WriteFile(srcPath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.Open(srcPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := os.Create(filepath.Join(workspace, "dst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	// Both files are part way already, as after a plain copy was started.
+	if _, err := io.CopyN(dst, src, 10); err != nil {
+		t.Fatal(err)
+	}
+	if !zeroCopy(context.Background(), dst, src) {
+		if _, err := io.Copy(dst, src); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := os.ReadFile(dst.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("expected the copy to match its source, got %d of %d bytes", len(got), len(data))
+	}
+}
+
+func TestUniqueDestPath_CaseInsensitiveReservations(t *testing.T) {
+	dir := t.TempDir()
+	noHash := func(context.Context, string) (string, error) { return "", nil }
+	tests := []struct {
+		name string
+		fold bool
+		want string
+	}{
+		{"case-sensitive", false, "img_001.jpg"},
+		{"case-insensitive", true, "img_001_1.jpg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reserved := newPathSet(tt.fold, false)
+			reserved.add(filepath.Join(dir, "IMG_001.JPG"))
+			got, present, err := uniqueDestPath(t.Context(), dir, "img_001.jpg", "", 3, "h", noHash, reserved)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if present || got != filepath.Join(dir, tt.want) {
+				t.Fatalf("got %s (present %v), want %s", got, present, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaimDestPath_CreatesTheNameAndPassesOverClaimedOnes(t *testing.T) {
+	dir := t.TempDir()
+	noHash := func(context.Context, string) (string, error) { return "", nil }
+	reserved := newPathSet(false, false)
+	for _, want := range []string{"img_001.jpg", "img_001_1.jpg"} {
+		got, present, err := claimDestPath(t.Context(), dir, "img_001.jpg", "", 3, "h", noHash, reserved)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if present || got != filepath.Join(dir, want) {
+			t.Fatalf("got %s (present %v), want %s", got, present, want)
+		}
+		info, err := os.Stat(got)
+		if err != nil || info.Size() != 0 {
+			t.Fatalf("expected %s to be claimed as an empty file, got %v (%v)", got, info, err)
+		}
+	}
+
+	// uniqueDestPath only looks: the next free name is not created.
+	got, _, err := uniqueDestPath(t.Context(), dir, "img_001.jpg", "", 3, "h", noHash, reserved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(got); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected %s not to exist, got %v", got, err)
+	}
+}
+
+func mustMkdir(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		t.Fatalf("failed to create directory %s: %v", path, err)
+	}
+}
+
+func writeFile(t *testing.T, dir, name, contents string) {
+	t.Helper()
+	fullPath := filepath.Join(dir, name)
+	if err := os.WriteFile(fullPath, []byte(contents), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", fullPath, err)
+	}
+}
@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// sourceRemover deletes a source file once every destination holds a copy
+// whose hash has been re-verified on disk, and appends each deletion to
+// .classifier/deleted.csv for auditing, together with the trash location
+// when the source was moved there.
+type sourceRemover struct {
+	ops      fileOps
+	manifest string
+	// useTrash moves sources to the OS trash instead of unlinking them.
+	useTrash bool
+}
+
+func newSourceRemover(dest string, ops fileOps, useTrash bool) *sourceRemover {
+	return &sourceRemover{ops: ops, manifest: filepath.Join(dest, StateDir, "deleted.csv"), useTrash: useTrash}
+}
+
+// remove deletes f's source if it is safely stored everywhere. Problems are
+// recorded on the destinations and leave the source in place.
+func (r *sourceRemover) remove(ctx context.Context, f plannedFile, dests []*destination) error {
+	var kept []string
+	for _, d := range dests {
+		path, ok := d.hashIndex[f.hash]
+		if !ok {
+			// The copy failed on this destination; it is already reported.
+			return nil
+		}
+		hash, err := r.ops.hash(ctx, path)
+		if err != nil {
+			d.fail(f.srcPath, fmt.Errorf("verify %s before deleting source: %w", path, err))
+			return nil
+		}
+		if hash != f.hash {
+			d.fail(f.srcPath, fmt.Errorf("verify %s before deleting source: content differs from source", path))
+			return nil
+		}
+		kept = append(kept, path)
+	}
+
+	if err := r.ops.readOnly.check(f.srcPath); err != nil {
+		dests[0].fail(f.srcPath, fmt.Errorf("delete source: %w", err))
+		return nil
+	}
+	trashed, err := DisposeFile(f.srcPath, r.useTrash)
+	if err != nil {
+		dests[0].fail(f.srcPath, fmt.Errorf("delete source: %w", err))
+		return nil
+	}
+	return LogDeletion(r.manifest, f.srcPath, kept[0], f.hash, trashed)
+}
+
+// LogDeletion appends a deleted file, the copy that was kept in its place,
+// their hash and the trash location (if any) to a deletion manifest.
+func LogDeletion(manifest, deleted, kept, hash, trashed string) error {
+	if err := os.MkdirAll(filepath.Dir(manifest), 0o755); err != nil {
+		return fmt.Errorf("write deletion manifest: %w", err)
+	}
+	out, err := os.OpenFile(manifest, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("write deletion manifest: %w", err)
+	}
+	defer out.Close()
+
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{time.Now().Format(time.RFC3339), deleted, kept, hash, trashed}); err != nil {
+		return fmt.Errorf("write deletion manifest: %w", err)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("write deletion manifest: %w", err)
+	}
+	return out.Sync()
+}
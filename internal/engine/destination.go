@@ -0,0 +1,477 @@
+package engine
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// destination is one output root. The primary destination and every mirror
+// keep their own dedup index and reports, so a failing mirror never hides
+// what happened on the others.
+type destination struct {
+	root string
+	// roots holds the categories placed outside root; only the primary
+	// destination has any.
+	roots map[string]string
+	// folderNames tells copies apart from other files of the same name by
+	// the slug of their source folder.
+	folderNames bool
+	// reports holds warn.csv, errors.csv and duplicates.html; empty means
+	// the StateDir of root.
+	reports   string
+	hashIndex map[string]string
+	// archived maps hashes to the files earlier runs recorded in their
+	// manifests; entries are checked against the disk on first use.
+	archived map[string]string
+	// reserved holds paths claimed by this run that may not exist on disk
+	// yet, e.g. during a dry run. It folds case when the destination
+	// filesystem does.
+	reserved pathSet
+	// shardSize caps the files per folder; fileCounts caches how many
+	// each folder holds.
+	shardSize  int
+	fileCounts map[string]int
+	// cas stores each content once below CASDir and makes the category
+	// and date tree of hard links to it.
+	cas bool
+	// fileMode, when set, is the mode of every copy instead of the mode of
+	// its source.
+	fileMode os.FileMode
+	// caps holds the filesystem limits of the folders below which
+	// categories are created, by folder, as checkFilesystems found them.
+	caps    map[string]fsCaps
+	skipped []skippedEntry
+	failed  []failedEntry
+}
+
+type failedEntry struct {
+	srcPath  string
+	destPath string
+	err      error
+}
+
+type placement struct {
+	dest *destination
+	path string
+	err  error
+}
+
+var (
+	errNoWriters       = errors.New("all destinations failed")
+	errBudgetExhausted = errors.New("copy budget exhausted")
+	// errLargerThanBudget is returned for a file bigger than the whole
+	// budget; it is skipped so that it does not stop every run.
+	errLargerThanBudget = errors.New("larger than the copy budget")
+	errOutOfTime        = errors.New("time box reached")
+)
+
+// copyBudget caps the bytes a run may copy; a zero limit means unlimited.
+type copyBudget struct {
+	limit int64
+	used  int64
+}
+
+func (b *copyBudget) allows(n int64) bool {
+	return b.limit <= 0 || b.used+n <= b.limit
+}
+
+func newDestination(root string, nfc bool) *destination {
+	return &destination{
+		root:       root,
+		hashIndex:  make(map[string]string),
+		archived:   make(map[string]string),
+		reserved:   newPathSet(foldsCase(root), nfc),
+		fileCounts: make(map[string]int),
+	}
+}
+
+// conflictFolder returns the slug copies of src are told apart by from
+// other files of the same name, or "" to number them alone.
+func (d *destination) conflictFolder(src string) string {
+	if !d.folderNames {
+		return ""
+	}
+	return folderSlug(filepath.Base(filepath.Dir(src)))
+}
+
+// reportsDir returns the directory the reports of the destination are
+// written to.
+func (d *destination) reportsDir() string {
+	if d.reports != "" {
+		return d.reports
+	}
+	return filepath.Join(d.root, StateDir)
+}
+
+// rootFor returns the directory category's folder is created in.
+func (d *destination) rootFor(category string) string {
+	if root, ok := d.roots[category]; ok {
+		return root
+	}
+	return d.root
+}
+
+// fanOut places one planned file in every destination, reading the source
+// only once. It fails only when no destination could take the file; partial
+// failures are recorded on the affected destinations. errBudgetExhausted is
+// returned, before anything is written, when the copy would exceed budget,
+// and errLargerThanBudget when the file alone exceeds it.
+func fanOut(ctx context.Context, dests []*destination, f plannedFile, budget *copyBudget, ops fileOps, events Sink) error {
+	src, relDir, name, info, hash, category := f.srcPath, f.relDir, f.name, f.info, f.hash, f.category
+
+	var (
+		placements []*placement
+		pending    []*placement
+		done       bool
+	)
+	for _, d := range dests {
+		if existingPath, exists := d.hashIndex[hash]; exists && !f.keepDuplicate {
+			d.skipped = append(d.skipped, skippedEntry{srcPath: src, destPath: existingPath})
+			events.Emit(Event{Type: EventSkippedDuplicate, Src: src, Dest: existingPath, Category: category, Size: info.Size(), Hash: hash})
+			done = true
+			continue
+		}
+
+		p := &placement{dest: d}
+		placements = append(placements, p)
+		root := d.rootFor(category)
+
+		if archived, ok := d.archivedCopy(ctx, hash, ops); ok {
+			// Copied by an earlier run, possibly under another name.
+			p.path = archived
+			d.hashIndex[hash] = archived
+			events.Emit(Event{Type: EventSkippedPresent, Src: src, Dest: archived, Category: category, Size: info.Size(), Hash: hash})
+			done = true
+			continue
+		}
+		former, present, err := formerCopy(ctx, d, f, ops)
+		if err != nil {
+			p.path = filepath.Join(root, relDir)
+			p.err = err
+			continue
+		}
+		if present {
+			// Filed under the category's former name by an earlier run.
+			p.path = former
+			d.hashIndex[hash] = former
+			events.Emit(Event{Type: EventSkippedPresent, Src: src, Dest: former, Category: category, Size: info.Size(), Hash: hash})
+			done = true
+			continue
+		}
+
+		targetDir, err := d.shardFor(filepath.Join(root, relDir))
+		if err != nil {
+			p.path = filepath.Join(root, relDir)
+			p.err = err
+			continue
+		}
+		if err := ops.mkdirAll(targetDir); err != nil {
+			p.path = targetDir
+			p.err = fmt.Errorf("create category directory %s: %w", targetDir, err)
+			continue
+		}
+
+		if err := d.caps[root].fits(filepath.Join(targetDir, name), info.Size()); err != nil {
+			p.path = filepath.Join(targetDir, name)
+			p.err = err
+			continue
+		}
+		// Hard links never replace a file, so content-addressed
+		// destinations need not claim the name.
+		find := claimDestPath
+		if d.cas {
+			find = uniqueDestPath
+		}
+		finalPath, present, err := find(ctx, targetDir, name, d.conflictFolder(src), info.Size(), hash, ops.hash, d.reserved)
+		if err != nil {
+			p.path = targetDir
+			p.err = err
+			continue
+		}
+		p.path = finalPath
+		if present {
+			// Already copied by a previous run.
+			d.hashIndex[hash] = finalPath
+			events.Emit(Event{Type: EventSkippedPresent, Src: src, Dest: finalPath, Category: category, Size: info.Size(), Hash: hash})
+			done = true
+			continue
+		}
+		pending = append(pending, p)
+	}
+
+	if len(pending) > 0 {
+		if budget.limit > 0 && info.Size() > budget.limit {
+			unclaim(ops, pending, nil)
+			return errLargerThanBudget
+		}
+		if !budget.allows(info.Size()) {
+			unclaim(ops, pending, nil)
+			return errBudgetExhausted
+		}
+		// Content-addressed destinations get the blob, unless they hold it
+		// already, and then a link to it.
+		errs := make([]error, len(pending))
+		retries := make([]int, len(pending))
+		blobs := make([]string, len(pending))
+		var paths []string
+		var perms []os.FileMode
+		var idx []int
+		for i, p := range pending {
+			target := p.path
+			if p.dest.cas {
+				blobs[i] = p.dest.blobPath(category, hash)
+				if target, errs[i] = p.dest.blobTarget(ops, category, hash); target == "" {
+					continue
+				}
+			}
+			paths = append(paths, target)
+			perms = append(perms, p.dest.modeFor(p.dest.rootFor(category), info.Mode()))
+			idx = append(idx, i)
+		}
+		if len(paths) > 0 {
+			if err := checkUnchanged(src, info); err != nil {
+				unclaim(ops, pending, nil)
+				return err
+			}
+			copyErrs, copyRetries, err := ops.copyVerified(ctx, src, paths, perms, hash)
+			if err != nil {
+				unclaim(ops, pending, nil)
+				return err
+			}
+			// A source that changed while it was read leaves torn copies.
+			if err := checkUnchanged(src, info); err != nil {
+				for j, path := range paths {
+					if copyErrs[j] == nil {
+						ops.remove(path)
+					}
+				}
+				unclaim(ops, pending, nil)
+				return err
+			}
+			budget.used += info.Size()
+			for j, i := range idx {
+				errs[i], retries[i] = copyErrs[j], copyRetries[j]
+			}
+		}
+		unclaim(ops, pending, errs)
+		for i, p := range pending {
+			p.err = errs[i]
+			if p.err == nil && blobs[i] != "" {
+				p.err = ops.linkBlob(blobs[i], p.path)
+			}
+			if p.err == nil {
+				p.dest.hashIndex[hash] = p.path
+				p.dest.reserved.add(p.path)
+				p.dest.placed(p.path)
+				events.Emit(Event{Type: EventCopied, Src: src, Dest: p.path, Category: category, Size: info.Size(), Hash: hash, Retries: retries[i]})
+				done = true
+			}
+		}
+	}
+
+	var firstErr error
+	for _, p := range placements {
+		if p.err == nil {
+			continue
+		}
+		if firstErr == nil {
+			firstErr = p.err
+		}
+		if done {
+			p.dest.failed = append(p.dest.failed, failedEntry{srcPath: src, destPath: p.path, err: p.err})
+			events.Emit(errorEvent(src, p.path, category, p.err))
+		}
+	}
+	if !done {
+		return firstErr
+	}
+	return nil
+}
+
+// modeFor returns the mode a copy below root of a source with mode src is
+// created with: the configured file mode or else the permission bits of the
+// source, except on filesystems that do not keep permissions, such as exFAT
+// or some SMB mounts, where copies get DefaultFileMode.
+func (d *destination) modeFor(root string, src os.FileMode) os.FileMode {
+	switch {
+	case d.fileMode != 0:
+		return d.fileMode
+	case d.caps[root].noPermissions:
+		return DefaultFileMode
+	}
+	return src.Perm()
+}
+
+// unclaim removes the names claimed for the pending placements whose copy
+// failed, by errs, or, with errs nil, did not happen at all.
+func unclaim(ops fileOps, pending []*placement, errs []error) {
+	for i, p := range pending {
+		if !p.dest.cas && (errs == nil || errs[i] != nil) {
+			ops.remove(p.path)
+		}
+	}
+}
+
+// formerCopy looks for a copy of f, same name and content, in the folders
+// of the categories f's category was renamed from and, when the
+// destination shards, in its target folder and the numbered sub-folders of
+// it, since the folder chosen for a new copy may be another one.
+func formerCopy(ctx context.Context, d *destination, f plannedFile, ops fileOps) (string, bool, error) {
+	root := d.rootFor(f.category)
+	var dirs []string
+	for _, rel := range f.formerDirs {
+		dirs = append(dirs, filepath.Join(root, rel))
+	}
+	if d.shardSize > 0 {
+		target := filepath.Join(root, f.relDir)
+		dirs = append(append(dirs, target), shards(target)...)
+	}
+	for _, dir := range dirs {
+		path, present, err := uniqueDestPath(ctx, dir, f.name, d.conflictFolder(f.srcPath), f.info.Size(), f.hash, ops.hash, d.reserved)
+		if err != nil || present {
+			return path, present, err
+		}
+	}
+	return "", false, nil
+}
+
+// preview prints what fanOut would do with f without writing anything, and
+// emits the events fanOut would. It updates the dedup indexes and
+// reservations so later files see the simulated outcome.
+func preview(ctx context.Context, dests []*destination, f plannedFile, ops fileOps, w io.Writer, events Sink) error {
+	for _, d := range dests {
+		if existingPath, exists := d.hashIndex[f.hash]; exists {
+			fmt.Fprintf(w, "duplicate %s = %s\n", f.srcPath, existingPath)
+			events.Emit(Event{Type: EventSkippedDuplicate, Src: f.srcPath, Dest: existingPath, Category: f.category, Size: f.info.Size(), Hash: f.hash})
+			continue
+		}
+		finalPath, present := d.archivedCopy(ctx, f.hash, ops)
+		if !present {
+			var err error
+			if finalPath, present, err = formerCopy(ctx, d, f, ops); err != nil {
+				return err
+			}
+		}
+		if !present {
+			targetDir, err := d.shardFor(filepath.Join(d.rootFor(f.category), f.relDir))
+			if err != nil {
+				return err
+			}
+			finalPath, present, err = uniqueDestPath(ctx, targetDir, f.name, d.conflictFolder(f.srcPath), f.info.Size(), f.hash, ops.hash, d.reserved)
+			if err != nil {
+				return err
+			}
+		}
+		ev := Event{Type: EventCopied, Src: f.srcPath, Dest: finalPath, Category: f.category, Size: f.info.Size(), Hash: f.hash}
+		if present {
+			fmt.Fprintf(w, "present %s = %s\n", f.srcPath, finalPath)
+			ev.Type = EventSkippedPresent
+		} else {
+			fmt.Fprintf(w, "copy %s -> %s\n", f.srcPath, finalPath)
+		}
+		events.Emit(ev)
+		d.hashIndex[f.hash] = finalPath
+		d.reserved.add(finalPath)
+		if !present {
+			d.placed(finalPath)
+		}
+	}
+	return nil
+}
+
+// fanoutWriter is an io.MultiWriter that keeps writing to the healthy
+// writers after one of them fails, remembering each writer's error.
+type fanoutWriter struct {
+	writers []io.Writer
+	errs    []error
+}
+
+func (w *fanoutWriter) Write(p []byte) (int, error) {
+	live := 0
+	for i, wr := range w.writers {
+		if w.errs[i] != nil {
+			continue
+		}
+		n, err := wr.Write(p)
+		if err == nil && n < len(p) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			w.errs[i] = err
+			continue
+		}
+		live++
+	}
+	if live == 0 {
+		return 0, errNoWriters
+	}
+	return len(p), nil
+}
+
+func writeFailures(path string, entries []failedEntry, paths reportPaths, stamp reportStamp) error {
+	f, err := stamp.open(path)
+	if err != nil {
+		return fmt.Errorf("write errors: %w", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	for _, e := range entries {
+		src, srcRoot := paths.src(e.srcPath)
+		dest, destRoot := paths.dest(e.destPath)
+		row := []string{src, dest, e.err.Error()}
+		if paths.relative {
+			row = append(row, srcRoot, destRoot)
+		}
+		if err := w.Write(stamp.row(row)); err != nil {
+			return fmt.Errorf("write errors: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("write errors: %w", err)
+	}
+	return nil
+}
+
+// fail records a file that could not be placed in any destination.
+func (d *destination) fail(src string, err error) {
+	d.failed = append(d.failed, failedEntry{srcPath: src, err: err})
+}
+
+// writeReports writes warn.csv and errors.csv, and with htmlReport also
+// duplicates.html, to the reports directory, with paths as paths says.
+func (d *destination) writeReports(ops fileOps, htmlReport bool, paths reportPaths, stamp reportStamp) error {
+	if len(d.skipped) == 0 && len(d.failed) == 0 {
+		return nil
+	}
+	dir := d.reportsDir()
+	if err := ops.mkdirAll(dir); err != nil {
+		return fmt.Errorf("create reports directory: %w", err)
+	}
+	if len(d.skipped) > 0 {
+		if err := writeWarnings(filepath.Join(dir, "warn.csv"), d.skipped, paths, stamp); err != nil {
+			return err
+		}
+		if htmlReport {
+			hashes := make(map[string]string, len(d.hashIndex))
+			for hash, path := range d.hashIndex {
+				hashes[path] = hash
+			}
+			if err := writeDuplicateReport(dir, d.root, d.skipped, hashes); err != nil {
+				return err
+			}
+		}
+	}
+	if len(d.failed) > 0 {
+		if err := writeFailures(filepath.Join(dir, "errors.csv"), d.failed, paths, stamp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
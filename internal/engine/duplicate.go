@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// What a run does with a duplicate; see Options.ResolveDuplicate.
+const (
+	DuplicateKeep   = "keep"
+	DuplicateSkip   = "skip"
+	DuplicateRename = "rename"
+)
+
+// DefaultSimilarity is the perceptual similarity from which two images
+// count as near-duplicates.
+const DefaultSimilarity = 0.9
+
+// Duplicate is a file about to be skipped because Existing holds the same
+// content or, unless Exact, about to be copied although it looks like the
+// image Existing.
+type Duplicate struct {
+	Src      string
+	Existing string
+	Exact    bool
+	// Score is 1 for exact duplicates and the perceptual similarity of
+	// the images otherwise.
+	Score float64
+}
+
+// DuplicateResolution answers a Duplicate: DuplicateKeep copies the file
+// as usual, next to Existing, DuplicateSkip leaves it out and
+// DuplicateRename copies it as Name.
+type DuplicateResolution struct {
+	Action string
+	Name   string
+}
+
+// duplicateResolver asks about the duplicates and near-duplicates of a
+// run before they are placed.
+type duplicateResolver struct {
+	resolve func(Duplicate) DuplicateResolution
+	// hashes and paths are the perceptual hashes of the images placed in
+	// the primary destination so far and where they went.
+	hashes []uint64
+	paths  []string
+	// current is the perceptual hash of the file last asked about, if it
+	// is an image.
+	current uint64
+	isImage bool
+}
+
+// ask returns f prepared for fanOut according to the answer about it, or
+// the path of the file it looks like when it is to be left out. Files
+// without a duplicate are returned unchanged.
+func (r *duplicateResolver) ask(f plannedFile, primary *destination) (plannedFile, string, error) {
+	dup := Duplicate{Src: f.srcPath}
+	if existing, ok := primary.hashIndex[f.hash]; ok {
+		dup.Existing, dup.Exact, dup.Score = existing, true, 1
+	}
+	r.current, r.isImage = 0, false
+	if hash, err := PerceptualHash(f.srcPath); err == nil {
+		r.current, r.isImage = hash, true
+		if dup.Existing == "" {
+			for i, h := range r.hashes {
+				if score := Similarity(hash, h); score >= DefaultSimilarity && score > dup.Score {
+					dup.Existing, dup.Score = r.paths[i], score
+				}
+			}
+		}
+	}
+	if dup.Existing == "" {
+		return f, "", nil
+	}
+
+	answer := r.resolve(dup)
+	switch answer.Action {
+	case DuplicateSkip:
+		if !dup.Exact {
+			return f, dup.Existing, nil
+		}
+	case DuplicateKeep:
+		f.keepDuplicate = true
+	case DuplicateRename:
+		if answer.Name == "" || answer.Name != filepath.Base(answer.Name) || answer.Name == "." || answer.Name == ".." {
+			return f, "", fmt.Errorf("rename %s: %q is not a file name", f.srcPath, answer.Name)
+		}
+		f.name, f.keepDuplicate = answer.Name, true
+	default:
+		return f, "", fmt.Errorf("unknown answer %q about duplicate %s", answer.Action, f.srcPath)
+	}
+	return f, "", nil
+}
+
+// placed remembers the file last asked about, now at path, for the
+// near-duplicates that follow it.
+func (r *duplicateResolver) placed(path string) {
+	if r.isImage {
+		r.hashes = append(r.hashes, r.current)
+		r.paths = append(r.paths, path)
+	}
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Event types, also the "type" field of -output ndjson.
+const (
+	EventDiscovered       = "discovered"
+	EventClassified       = "classified"
+	EventSkippedSmall     = "skipped-small"
+	EventSkippedFiltered  = "skipped-filtered"
+	EventSkippedDuplicate = "skipped-duplicate"
+	EventSkippedPresent   = "skipped-present"
+	EventCopied           = "copied"
+	// EventRemoved is a destination file taken out because its source was
+	// deleted; Src is the deleted source and Dest where the file was.
+	EventRemoved = "removed"
+	EventError   = "error"
+)
+
+// Event is one decision taken on a source file.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	Src      string    `json:"src"`
+	Dest     string    `json:"dest,omitempty"`
+	Category string    `json:"category,omitempty"`
+	// Dir is the directory relative to the destination root.
+	Dir   string `json:"dir,omitempty"`
+	Size  int64  `json:"size,omitempty"`
+	Hash  string `json:"hash,omitempty"`
+	Error string `json:"error,omitempty"`
+	// Err is the error behind Error, for callers that inspect it.
+	Err error `json:"-"`
+	// Reason says why a file was filtered out.
+	Reason string `json:"reason,omitempty"`
+	// Retries counts the copies made again because Options.Verify found
+	// the copy differing from the source.
+	Retries int `json:"retries,omitempty"`
+}
+
+// Sink receives events as they happen; Finish is called once the run
+// has placed every file it is going to.
+type Sink interface {
+	Emit(Event)
+	Finish()
+}
+
+type discardEvents struct{}
+
+func (discardEvents) Emit(Event) {}
+func (discardEvents) Finish()    {}
+
+// ndjsonEvents writes one JSON object per line.
+type ndjsonEvents struct {
+	enc *json.Encoder
+}
+
+// NewNDJSONSink returns a Sink that writes each event to w as one line of
+// JSON.
+func NewNDJSONSink(w io.Writer) Sink {
+	return &ndjsonEvents{enc: json.NewEncoder(w)}
+}
+
+func (s *ndjsonEvents) Emit(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	// A broken stdout must not abort the run; the reports still get written.
+	_ = s.enc.Encode(ev)
+}
+
+func (s *ndjsonEvents) Finish() {}
+
+func errorEvent(src, dest, category string, err error) Event {
+	return Event{Type: EventError, Src: src, Dest: dest, Category: category, Error: err.Error(), Err: err}
+}
+
+// multiEvents hands every event to several sinks.
+type multiEvents []Sink
+
+// MultiSink returns a Sink that hands every event to each of sinks, leaving
+// out nil ones.
+func MultiSink(sinks ...Sink) Sink {
+	var m multiEvents
+	for _, s := range sinks {
+		if s != nil {
+			m = append(m, s)
+		}
+	}
+	return m
+}
+
+func (m multiEvents) Emit(ev Event) {
+	for _, s := range m {
+		s.Emit(ev)
+	}
+}
+
+func (m multiEvents) Finish() {
+	for _, s := range m {
+		s.Finish()
+	}
+}
@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Exif holds the EXIF fields that tell photos apart.
+type Exif struct {
+	// DateTimeOriginal is the capture time as written by the camera,
+	// "2006:01:02 15:04:05", followed by ".SubSecTimeOriginal" if set.
+	DateTimeOriginal string
+	Model            string
+}
+
+var errNoExif = errors.New("no EXIF data")
+
+// ReadExif reads the capture time and camera model from the EXIF block of
+// a JPEG file.
+func ReadExif(path string) (Exif, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Exif{}, err
+	}
+	defer f.Close()
+	tiff, err := jpegExif(bufio.NewReader(f))
+	if err != nil {
+		return Exif{}, fmt.Errorf("read EXIF of %s: %w", path, err)
+	}
+	x, err := parseTIFF(tiff)
+	if err != nil {
+		return Exif{}, fmt.Errorf("read EXIF of %s: %w", path, err)
+	}
+	return x, nil
+}
+
+// jpegExif returns the TIFF structure inside the APP1 Exif segment.
+func jpegExif(r *bufio.Reader) ([]byte, error) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return nil, errors.New("not a JPEG file")
+	}
+	for {
+		var marker [4]byte
+		if _, err := io.ReadFull(r, marker[:2]); err != nil {
+			return nil, errNoExif
+		}
+		if marker[0] != 0xFF {
+			return nil, errNoExif
+		}
+		if marker[1] == 0xD8 || (marker[1] >= 0xD0 && marker[1] <= 0xD7) || marker[1] == 0x01 || marker[1] == 0xFF {
+			continue
+		}
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			// The image data starts; metadata comes before it.
+			return nil, errNoExif
+		}
+		if _, err := io.ReadFull(r, marker[2:]); err != nil {
+			return nil, errNoExif
+		}
+		n := int(binary.BigEndian.Uint16(marker[2:])) - 2
+		if n < 0 {
+			return nil, errNoExif
+		}
+		seg := make([]byte, n)
+		if _, err := io.ReadFull(r, seg); err != nil {
+			return nil, errNoExif
+		}
+		if marker[1] == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return seg[6:], nil
+		}
+	}
+}
+
+// EXIF tags ReadExif looks at.
+const (
+	tagModel              = 0x0110
+	tagExifIFD            = 0x8769
+	tagDateTimeOriginal   = 0x9003
+	tagSubSecTimeOriginal = 0x9291
+)
+
+func parseTIFF(b []byte) (Exif, error) {
+	if len(b) < 8 {
+		return Exif{}, errNoExif
+	}
+	var order binary.ByteOrder
+	switch string(b[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return Exif{}, errors.New("invalid TIFF byte order")
+	}
+
+	var x Exif
+	var subSec string
+	var exifIFD uint32
+	readIFD := func(off uint32, fn func(tag uint16, typ uint16, count uint32, value []byte)) error {
+		if int64(off)+2 > int64(len(b)) {
+			return errors.New("IFD out of range")
+		}
+		n := int(order.Uint16(b[off:]))
+		for i := range n {
+			e := int(off) + 2 + 12*i
+			if e+12 > len(b) {
+				return errors.New("IFD out of range")
+			}
+			fn(order.Uint16(b[e:]), order.Uint16(b[e+2:]), order.Uint32(b[e+4:]), b[e+8:e+12])
+		}
+		return nil
+	}
+	// ascii returns an ASCII value, stored inline when it fits in four bytes.
+	ascii := func(count uint32, value []byte) string {
+		data := value
+		if count > 4 {
+			off := order.Uint32(value)
+			if int64(off)+int64(count) > int64(len(b)) {
+				return ""
+			}
+			data = b[off : off+count]
+		} else {
+			data = value[:count]
+		}
+		return strings.TrimSpace(strings.TrimRight(string(data), "\x00"))
+	}
+
+	err := readIFD(order.Uint32(b[4:]), func(tag, typ uint16, count uint32, value []byte) {
+		switch {
+		case tag == tagModel && typ == 2:
+			x.Model = ascii(count, value)
+		case tag == tagExifIFD:
+			exifIFD = order.Uint32(value)
+		}
+	})
+	if err != nil {
+		return Exif{}, err
+	}
+	if exifIFD != 0 {
+		err := readIFD(exifIFD, func(tag, typ uint16, count uint32, value []byte) {
+			switch {
+			case tag == tagDateTimeOriginal && typ == 2:
+				x.DateTimeOriginal = ascii(count, value)
+			case tag == tagSubSecTimeOriginal && typ == 2:
+				subSec = ascii(count, value)
+			}
+		})
+		if err != nil {
+			return Exif{}, err
+		}
+	}
+	if x.DateTimeOriginal != "" && subSec != "" {
+		x.DateTimeOriginal += "." + subSec
+	}
+	return x, nil
+}
+
+// ImageDimensions reads the pixel size of an image from its header; see
+// imageDimensions for the formats.
+func ImageDimensions(path string) (width, height int, err error) {
+	return imageDimensions(path)
+}
@@ -0,0 +1,281 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// errFileTimeout marks a hash or copy of a single file that took longer than
+// -file-timeout. The file is reported and the run moves on.
+var errFileTimeout = errors.New("file operation timed out")
+
+// errSourceChanged marks a source file whose size or modification time
+// changed after the walk found it, such as a download still in progress.
+// Its hash or copy may be torn, so it is left for a later run.
+var errSourceChanged = errors.New("source changed during the run; left for a later run")
+
+// skipsFile reports whether err fails only the file at hand: it timed out,
+// changed while the run read it, does not fit on the destination filesystem
+// or, on Windows, stayed in use by another program through every retry.
+// Such files are reported and the run moves on.
+func skipsFile(err error) bool {
+	return errors.Is(err, errFileTimeout) || errors.Is(err, errSourceChanged) || errors.Is(err, errFileTooLarge) || errors.Is(err, errPathTooLong) || isLocked(err)
+}
+
+// checkUnchanged fails with errSourceChanged when the file at path no
+// longer has the size and modification time of info, or is gone.
+func checkUnchanged(path string, info fs.FileInfo) error {
+	now, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w: %v", path, errSourceChanged, err)
+	}
+	if now.Size() != info.Size() || !now.ModTime().Equal(info.ModTime()) {
+		return fmt.Errorf("%s: %w", path, errSourceChanged)
+	}
+	return nil
+}
+
+// maxBackoff caps the exponential delay between retries.
+const maxBackoff = time.Minute
+
+// fileOps performs the per-file I/O of a run under its failure policy.
+type fileOps struct {
+	timeout time.Duration
+	// retries is how many times a transient failure is retried, waiting
+	// backoff, 2*backoff, 4*backoff, ... in between.
+	retries int
+	backoff time.Duration
+	// durable fsyncs every copy before it is renamed into place.
+	durable bool
+	// verify re-hashes every copy and copies it again, up to
+	// verifyRetries times, while it differs from the source.
+	verify        bool
+	verifyRetries int
+	// newHash is the content hash files are deduplicated by; nil means
+	// SHA-256.
+	newHash func() hash.Hash
+	// readOnly refuses copies into a read-only source.
+	readOnly *readOnlyFS
+	// warnf, if set, is told about every retry.
+	warnf func(format string, args ...any)
+}
+
+func (o fileOps) hash(ctx context.Context, path string) (string, error) {
+	return retry(ctx, o, "hash "+path, func() (string, error) {
+		return withFileTimeout(ctx, o.timeout, func(ctx context.Context) (string, error) {
+			return hashFileWith(ctx, path, o.newHash)
+		})
+	})
+}
+
+// copy retries a failed read of src as a whole, and a failed write only for
+// the destinations that failed.
+func (o fileOps) copy(ctx context.Context, src string, dests []string, perms []os.FileMode) ([]error, error) {
+	errs := make([]error, len(dests))
+	var todo []string
+	var todoPerms []os.FileMode
+	var idx []int
+	for i, dest := range dests {
+		if errs[i] = o.readOnly.check(dest); errs[i] == nil {
+			todo, todoPerms, idx = append(todo, dest), append(todoPerms, perms[i]), append(idx, i)
+		}
+	}
+	if len(todo) == 0 {
+		return errs, nil
+	}
+
+	for attempt := 0; ; attempt++ {
+		subErrs, err := withFileTimeout(ctx, o.timeout, func(ctx context.Context) ([]error, error) {
+			return copyFile(ctx, src, todo, todoPerms, o.durable)
+		})
+		if err != nil {
+			if attempt >= o.retries || !isTransient(err) {
+				return nil, err
+			}
+			o.warnRetry(src, attempt, o.retries, err)
+		} else {
+			var nextTodo []string
+			var nextPerms []os.FileMode
+			var nextIdx []int
+			var transientErr error
+			for j, i := range idx {
+				errs[i] = subErrs[j]
+				if subErrs[j] != nil && isTransient(subErrs[j]) {
+					nextTodo = append(nextTodo, todo[j])
+					nextPerms = append(nextPerms, todoPerms[j])
+					nextIdx = append(nextIdx, i)
+					transientErr = subErrs[j]
+				}
+			}
+			if len(nextTodo) == 0 || attempt >= o.retries {
+				return errs, nil
+			}
+			o.warnRetry(src, attempt, o.retries, transientErr)
+			todo, todoPerms, idx = nextTodo, nextPerms, nextIdx
+		}
+		if err := o.sleep(ctx, attempt); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// errCopyMismatch marks a copy that still differed from its source after
+// every copy -verify made.
+var errCopyMismatch = errors.New("copy differs from the source")
+
+// copyVerified is copy followed, with verify set, by a check of every copy
+// against hash, the hash of src. Copies that differ, as after a transfer
+// error on a flaky USB link, are made again up to verifyRetries times;
+// retries counts the copies made again by destination. A copy that still
+// differs is removed and fails with errCopyMismatch.
+func (o fileOps) copyVerified(ctx context.Context, src string, dests []string, perms []os.FileMode, hash string) (errs []error, retries []int, err error) {
+	errs, err = o.copy(ctx, src, dests, perms)
+	retries = make([]int, len(dests))
+	if err != nil || !o.verify {
+		return errs, retries, err
+	}
+	idx := make([]int, len(dests))
+	for i := range idx {
+		idx[i] = i
+	}
+	for attempt := 0; ; attempt++ {
+		var differ []int
+		for _, i := range idx {
+			if errs[i] != nil {
+				continue
+			}
+			got, err := o.hash(ctx, dests[i])
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, retries, ctx.Err()
+				}
+				errs[i] = fmt.Errorf("verify %s: %w", dests[i], err)
+				continue
+			}
+			if got != hash {
+				differ = append(differ, i)
+			}
+		}
+		if len(differ) == 0 {
+			return errs, retries, nil
+		}
+		if attempt >= o.verifyRetries {
+			for _, i := range differ {
+				o.remove(dests[i])
+				errs[i] = fmt.Errorf("verify %s: %w after %d copies", dests[i], errCopyMismatch, attempt+1)
+			}
+			return errs, retries, nil
+		}
+		todo := make([]string, len(differ))
+		todoPerms := make([]os.FileMode, len(differ))
+		for j, i := range differ {
+			todo[j], todoPerms[j] = dests[i], perms[i]
+			retries[i]++
+			if o.warnf != nil {
+				o.warnf("copy %s differs from %s, copying again (%d/%d)", dests[i], src, attempt+1, o.verifyRetries)
+			}
+		}
+		subErrs, err := o.copy(ctx, src, todo, todoPerms)
+		if err != nil {
+			return nil, retries, err
+		}
+		for j, i := range differ {
+			errs[i] = subErrs[j]
+		}
+		idx = differ
+	}
+}
+
+func retry[T any](ctx context.Context, o fileOps, what string, fn func() (T, error)) (T, error) {
+	for attempt := 0; ; attempt++ {
+		v, err := fn()
+		if err == nil || attempt >= o.retries || !isTransient(err) {
+			return v, err
+		}
+		o.warnRetry(what, attempt, o.retries, err)
+		if err := o.sleep(ctx, attempt); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+}
+
+func (o fileOps) sleep(ctx context.Context, attempt int) error {
+	d := o.backoff << attempt
+	if d > maxBackoff || d < 0 {
+		d = maxBackoff
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isTransient reports whether err may go away on retry. Only the errors in
+// transientErrors, timed out reads and, on Windows, files another program
+// has open or locked are; anything else, such as a full disk, a missing
+// file or a permission problem, fails the same way again.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, errFileTimeout) {
+		return false
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) || isLocked(err) {
+		return true
+	}
+	for _, target := range transientErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o fileOps) warnRetry(what string, attempt, retries int, err error) {
+	if o.warnf == nil {
+		return
+	}
+	o.warnf("retrying %s (%d/%d): %v", what, attempt+1, retries, err)
+}
+
+// withFileTimeout runs fn under its own deadline. A read stuck in the kernel
+// (e.g. on a failing sector) cannot be interrupted, so fn runs in a goroutine
+// that is abandoned on timeout; its ctx is cancelled so it cleans up its temp
+// files once the read finally returns.
+func withFileTimeout[T any](ctx context.Context, timeout time.Duration, fn func(context.Context) (T, error)) (T, error) {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := fn(ctx)
+		done <- result{v: v, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.v, r.err
+	case <-ctx.Done():
+		var zero T
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return zero, fmt.Errorf("%w after %s", errFileTimeout, timeout)
+		}
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,190 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWithFileTimeout(t *testing.T) {
+	stuck := func(ctx context.Context) (string, error) {
+		// Simulates a read that ignores cancellation for a while.
+		time.Sleep(200 * time.Millisecond)
+		return "late", ctx.Err()
+	}
+
+	start := time.Now()
+	_, err := withFileTimeout(context.Background(), 20*time.Millisecond, stuck)
+	if !errors.Is(err, errFileTimeout) {
+		t.Fatalf("expected errFileTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Fatalf("expected to give up promptly, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = withFileTimeout(ctx, time.Second, stuck)
+	if !errors.Is(err, context.Canceled) || errors.Is(err, errFileTimeout) {
+		t.Fatalf("expected cancellation to win over timeout, got %v", err)
+	}
+
+	got, err := withFileTimeout(context.Background(), time.Second, func(context.Context) (string, error) {
+		return "ok", nil
+	})
+	if err != nil || got != "ok" {
+		t.Fatalf("expected fast operation to succeed, got %q, %v", got, err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	transient := &fs.PathError{Op: "read", Path: "/mnt/nas/a.txt", Err: os.ErrDeadlineExceeded}
+	tests := []struct {
+		name      string
+		failures  []error
+		retries   int
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "succeeds after transient failures", failures: []error{transient, transient}, retries: 3, wantCalls: 3},
+		{name: "gives up after retries", failures: []error{transient, transient, transient}, retries: 2, wantCalls: 3, wantErr: transient},
+		{name: "does not retry missing files", failures: []error{fs.ErrNotExist}, retries: 3, wantCalls: 1, wantErr: fs.ErrNotExist},
+		{name: "does not retry timeouts", failures: []error{errFileTimeout}, retries: 3, wantCalls: 1, wantErr: errFileTimeout},
+		{name: "does not retry permission problems", failures: []error{fs.ErrPermission}, retries: 3, wantCalls: 1, wantErr: fs.ErrPermission},
+		{name: "does not retry unknown errors", failures: []error{errCopyMismatch}, retries: 3, wantCalls: 1, wantErr: errCopyMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := fileOps{retries: tt.retries, backoff: time.Millisecond}
+			calls := 0
+			got, err := retry(context.Background(), ops, "test", func() (string, error) {
+				calls++
+				if calls <= len(tt.failures) {
+					return "", tt.failures[calls-1]
+				}
+				return "ok", nil
+			})
+			if calls != tt.wantCalls {
+				t.Fatalf("expected %d calls, got %d", tt.wantCalls, calls)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || got != "ok" {
+				t.Fatalf("expected success, got %q, %v", got, err)
+			}
+		})
+	}
+}
+
+// flakyHash is a SHA-256 that, for its first bad instances, hashes an extra
+// byte, as if the copy it reads back came across a flaky link.
+func flakyHash(bad int) func() hash.Hash {
+	return func() hash.Hash {
+		h := sha256.New()
+		if bad > 0 {
+			bad--
+			h.Write([]byte{0})
+		}
+		return h
+	}
+}
+
+func TestCopyVerified(t *testing.T) {
+	sum := sha256.Sum256([]byte("content"))
+	want := hex.EncodeToString(sum[:])
+	tests := []struct {
+		name        string
+		bad         int
+		wantRetries int
+		wantErr     error
+	}{
+		{name: "keeps a matching copy", bad: 0, wantRetries: 0},
+		{name: "copies again after a mismatch", bad: 1, wantRetries: 1},
+		{name: "gives up after the retries", bad: 3, wantRetries: 2, wantErr: errCopyMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, dir, "src.txt", "content")
+			dest := filepath.Join(dir, "dest.txt")
+			ops := fileOps{verify: true, verifyRetries: 2, newHash: flakyHash(tt.bad)}
+
+			errs, retries, err := ops.copyVerified(t.Context(), filepath.Join(dir, "src.txt"), []string{dest}, []os.FileMode{0o644}, want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if retries[0] != tt.wantRetries || !errors.Is(errs[0], tt.wantErr) || (tt.wantErr == nil && errs[0] != nil) {
+				t.Fatalf("got retries %d, error %v; want %d, %v", retries[0], errs[0], tt.wantRetries, tt.wantErr)
+			}
+			_, statErr := os.Stat(dest)
+			if exists := statErr == nil; exists != (tt.wantErr == nil) {
+				t.Fatalf("expected the copy to exist only when it verified, stat: %v", statErr)
+			}
+		})
+	}
+}
+
+// touchOn appends to the source file of the first event of type typ, as a
+// download still in progress would.
+type touchOn struct {
+	typ     string
+	touched bool
+}
+
+func (s *touchOn) Emit(ev Event) {
+	if ev.Type != s.typ || s.touched {
+		return
+	}
+	s.touched = true
+	f, err := os.OpenFile(ev.Src, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		panic(err)
+	}
+	f.WriteString(" and more")
+	f.Close()
+}
+
+func (s *touchOn) Finish() {}
+
+func TestRun_SourceChangedDuringTheRunIsLeftForLater(t *testing.T) {
+	for _, typ := range []string{EventDiscovered, EventClassified} {
+		t.Run(typ, func(t *testing.T) {
+			workspace := t.TempDir()
+			src := filepath.Join(workspace, "src")
+			dest := filepath.Join(workspace, "dest")
+			mustMkdir(t, src)
+			writeFile(t, src, "a.txt", "alpha")
+			cfg := Config{Categories: []Category{{Name: "documents", Extensions: []string{"txt"}}}}
+
+			res, err := Run(t.Context(), Options{Config: cfg, Source: src, Dest: dest, Events: &touchOn{typ: typ}, NoSpaceCheck: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Copied != 0 || len(res.Failures) != 1 || !errors.Is(res.Failures[0].Err, errSourceChanged) {
+				t.Fatalf("expected a.txt left out as changed, got %d copied and failures %v", res.Copied, res.Failures)
+			}
+			if entries, err := os.ReadDir(filepath.Join(dest, "documents")); err == nil && len(entries) > 0 {
+				t.Fatalf("expected no copy of a.txt, got %v", entries)
+			}
+
+			res, err = Run(t.Context(), Options{Config: cfg, Source: src, Dest: dest, NoSpaceCheck: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data, err := os.ReadFile(filepath.Join(dest, "documents", "a.txt")); res.Copied != 1 || err != nil || string(data) != "alpha and more" {
+				t.Fatalf("expected the next run to copy a.txt whole, got %q (%v)", data, err)
+			}
+		})
+	}
+}
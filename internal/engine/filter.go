@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Filter holds the run-level filters that leave source files out of a
+// run before they are classified.
+type Filter struct {
+	NewerThan time.Time
+	OlderThan time.Time
+	// MinAge leaves out files modified less than this long before they
+	// are looked at, such as downloads still being written; they are
+	// classified by a later run once they have settled.
+	MinAge  time.Duration
+	MinSize int64
+	// MaxSize of zero means no upper limit.
+	MaxSize int64
+	// SkipHidden leaves out dotfiles, dot-directories and files with the
+	// Windows hidden attribute.
+	SkipHidden bool
+	// HydratePlaceholders reads cloud placeholders, such as OneDrive
+	// online-only files, so they download and are classified like any
+	// other file. By default they are left out, as their sizes and hashes
+	// say nothing about the content.
+	HydratePlaceholders bool
+	// MaxDepth limits how far below the source directory files are taken
+	// from; 1 is the top level only and 0 means no limit.
+	MaxDepth int
+	// AllowMIME, when set, keeps only the files whose MIME type, sniffed
+	// from their content, matches one of its patterns, and DenyMIME
+	// leaves out those that match one of its. Patterns are MIME types,
+	// such as application/x-executable, or wildcards, such as video/*.
+	AllowMIME []string
+	DenyMIME  []string
+	// Only, when set, keeps only the files of these categories and
+	// SkipCategories leaves out those of these, going by the category a
+	// file ends up in after aliases and video buckets.
+	Only           []string
+	SkipCategories []string
+}
+
+// filteredEntry is a source file left out by a Filter; they are listed
+// in skipped.csv.
+type filteredEntry struct {
+	srcPath string
+	reason  string
+}
+
+// hidden reports whether a file or directory is skipped by -skip-hidden.
+func (f Filter) hidden(name string, info fs.FileInfo) bool {
+	if !f.SkipHidden {
+		return false
+	}
+	return strings.HasPrefix(name, ".") || (info != nil && hasHiddenAttribute(info))
+}
+
+// exclude returns why info is filtered out, or "" to keep the file.
+func (f Filter) exclude(info fs.FileInfo) string {
+	if reason := f.placeholder(info); reason != "" {
+		return reason
+	}
+	if size := info.Size(); size < f.MinSize {
+		return "smaller than -min-size"
+	} else if f.MaxSize > 0 && size > f.MaxSize {
+		return "larger than -max-size"
+	}
+	mod := info.ModTime()
+	if !f.NewerThan.IsZero() && mod.Before(f.NewerThan) {
+		return "modified before -newer-than"
+	}
+	if !f.OlderThan.IsZero() && !mod.Before(f.OlderThan) {
+		return "modified after -older-than"
+	}
+	if f.MinAge > 0 && time.Since(mod) < f.MinAge {
+		return "modified within -min-age"
+	}
+	return ""
+}
+
+// excludeCategory returns why the files of category are filtered out, or
+// "" to keep them.
+func (f Filter) excludeCategory(category string) string {
+	if slices.Contains(f.SkipCategories, category) {
+		return "category " + category + " skipped by -skip-category"
+	}
+	if len(f.Only) > 0 && !slices.Contains(f.Only, category) {
+		return "category " + category + " not in -only"
+	}
+	return ""
+}
+
+func writeFiltered(path string, entries []filteredEntry, paths reportPaths, stamp reportStamp) error {
+	f, err := stamp.open(path)
+	if err != nil {
+		return fmt.Errorf("write skipped files: %w", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	for _, e := range entries {
+		src, srcRoot := paths.src(e.srcPath)
+		row := []string{src, e.reason}
+		if paths.relative {
+			row = append(row, srcRoot)
+		}
+		if err := w.Write(stamp.row(row)); err != nil {
+			return fmt.Errorf("write skipped files: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("write skipped files: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// What happens to the forks of a file: the AppleDouble "._" files macOS
+// writes next to files on drives that cannot hold resource forks and
+// extended attributes, and the NTFS alternate data streams of a file,
+// such as the Zone.Identifier of downloads.
+const (
+	// ForksCopy copies the forks of a file next to each of its copies.
+	ForksCopy = "copy"
+	// ForksStrip leaves them out and lists them in skipped.csv.
+	ForksStrip = "strip"
+)
+
+// ForkModes lists the accepted values of Options.Forks.
+var ForkModes = []string{ForksCopy, ForksStrip}
+
+// appleDoublePrefix starts the name of the AppleDouble file of a file.
+const appleDoublePrefix = "._"
+
+// appleDoubleMagic starts every AppleDouble file.
+var appleDoubleMagic = []byte{0x00, 0x05, 0x16, 0x07}
+
+// IsAppleDouble reports whether name is the name of an AppleDouble file
+// rather than of an archived file.
+func IsAppleDouble(name string) bool {
+	return strings.HasPrefix(name, appleDoublePrefix) && len(name) > len(appleDoublePrefix)
+}
+
+// hasAppleDoubleHeader reports whether the file at path starts like an
+// AppleDouble file, so that other files whose names start with "._" are
+// classified as usual.
+func hasAppleDoubleHeader(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, len(appleDoubleMagic))
+	_, err = io.ReadFull(f, magic)
+	return err == nil && bytes.Equal(magic, appleDoubleMagic)
+}
+
+// appleDoubleOf returns the path of the AppleDouble file of path.
+func appleDoubleOf(path string) string {
+	return filepath.Join(filepath.Dir(path), appleDoublePrefix+filepath.Base(path))
+}
+
+// noteAppleDouble takes the AppleDouble file at path out of the plan: it
+// is left out under ForksStrip and otherwise copied along with its file.
+func (p *planner) noteAppleDouble(path string, info fs.FileInfo) {
+	if p.forks == ForksStrip {
+		const reason = "AppleDouble file left out by -forks strip"
+		p.filtered = append(p.filtered, filteredEntry{srcPath: path, reason: reason})
+		p.events.Emit(Event{Type: EventSkippedFiltered, Src: path, Size: info.Size(), Reason: reason})
+		return
+	}
+	if p.appleDouble == nil {
+		p.appleDouble = make(map[string]string)
+	}
+	primary := filepath.Join(filepath.Dir(path), strings.TrimPrefix(filepath.Base(path), appleDoublePrefix))
+	p.appleDouble[primary] = path
+}
+
+// forkCarrier copies the forks of the files of a run next to their
+// copies, or lists them as left out.
+type forkCarrier struct {
+	strip bool
+	// appleDouble maps files to their AppleDouble files not carried yet.
+	appleDouble map[string]string
+	ops         fileOps
+	events      Sink
+	warnf       func(format string, args ...any)
+	// preview, when set, gets what a dry run would copy instead.
+	preview io.Writer
+	left    []filteredEntry
+}
+
+// carry copies the forks of the source file src next to each of its
+// copies at landed.
+func (c *forkCarrier) carry(ctx context.Context, src string, landed []string) {
+	if fork, ok := c.appleDouble[src]; ok && len(landed) > 0 {
+		delete(c.appleDouble, src)
+		c.copyAppleDouble(ctx, fork, landed)
+	}
+	streams, err := alternateStreams(src)
+	if err != nil {
+		c.warnf("%v", err)
+		return
+	}
+	for _, stream := range streams {
+		if c.strip {
+			c.leave(src+streamLabel(stream), "alternate data stream left out by -forks strip")
+			continue
+		}
+		for _, path := range landed {
+			if c.preview != nil {
+				fmt.Fprintf(c.preview, "copy %s -> %s\n", src+streamLabel(stream), path+streamLabel(stream))
+				continue
+			}
+			if err := copyStream(c.ops, src+stream, path+stream); err != nil {
+				c.warnf("%v", err)
+			}
+		}
+	}
+}
+
+func (c *forkCarrier) copyAppleDouble(ctx context.Context, fork string, landed []string) {
+	dests := make([]string, len(landed))
+	perms := make([]os.FileMode, len(landed))
+	for i, path := range landed {
+		dests[i] = appleDoubleOf(path)
+		perms[i] = DefaultFileMode
+		if info, err := os.Stat(path); err == nil {
+			perms[i] = info.Mode().Perm()
+		}
+		if c.preview != nil {
+			fmt.Fprintf(c.preview, "copy %s -> %s\n", fork, dests[i])
+		}
+	}
+	if c.preview != nil {
+		return
+	}
+	errs, err := c.ops.copy(ctx, fork, dests, perms)
+	if err != nil {
+		c.warnf("%v", err)
+		return
+	}
+	for _, err := range errs {
+		if err != nil {
+			c.warnf("%v", err)
+		}
+	}
+}
+
+// leftBehind returns the forks the run left out, those of files it did not
+// copy included.
+func (c *forkCarrier) leftBehind() []filteredEntry {
+	for _, primary := range slices.Sorted(maps.Keys(c.appleDouble)) {
+		c.leave(c.appleDouble[primary], "AppleDouble file of a file this run did not copy")
+	}
+	c.appleDouble = nil
+	return c.left
+}
+
+func (c *forkCarrier) leave(path, reason string) {
+	c.left = append(c.left, filteredEntry{srcPath: path, reason: reason})
+	c.events.Emit(Event{Type: EventSkippedFiltered, Src: path, Reason: reason})
+}
+
+// streamLabel shortens a stream name such as ":Zone.Identifier:$DATA" to
+// how it is written after a file name, ":Zone.Identifier".
+func streamLabel(stream string) string {
+	return strings.TrimSuffix(stream, ":$DATA")
+}
+
+// copyStream copies the alternate data stream src to dst.
+func copyStream(ops fileOps, src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("copy stream %s: %w", src, err)
+	}
+	defer in.Close()
+	out, err := ops.create(dst)
+	if err != nil {
+		return fmt.Errorf("copy stream %s: %w", src, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("copy stream %s: %w", src, err)
+	}
+	return out.Close()
+}
+
+// moveAppleDouble moves the AppleDouble file of a file moved from from to
+// to along with it, unless there is none or to already has one.
+func moveAppleDouble(from, to string) error {
+	if _, err := os.Lstat(appleDoubleOf(from)); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if _, err := os.Lstat(appleDoubleOf(to)); err == nil {
+		return nil
+	}
+	if err := os.Rename(appleDoubleOf(from), appleDoubleOf(to)); err != nil {
+		return fmt.Errorf("move AppleDouble file of %s: %w", from, err)
+	}
+	return nil
+}
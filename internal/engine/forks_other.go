@@ -0,0 +1,9 @@
+//go:build !windows
+
+package engine
+
+// alternateStreams returns the names of the alternate data streams of the
+// file at path; only NTFS has them.
+func alternateStreams(path string) ([]string, error) {
+	return nil, nil
+}
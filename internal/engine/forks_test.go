@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const appleDoubleHeader = "\x00\x05\x16\x07\x00\x02\x00\x00"
+
+func TestRun_AppleDoubleFilesFollowTheirFiles(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	mustMkdir(t, src)
+	writeFile(t, src, "notes.txt", "notes")
+	writeFile(t, src, "._notes.txt", appleDoubleHeader+"notes fork")
+	writeFile(t, src, "._deleted.txt", appleDoubleHeader+"orphan fork")
+	// Not AppleDouble, whatever the name says.
+	writeFile(t, src, "._todo.txt", "todo")
+	cfg := Config{Categories: []Category{{Name: "documents", Extensions: []string{"txt"}}}}
+
+	tests := []struct {
+		forks    string
+		wantFork bool
+		skipped  []string
+	}{
+		{forks: "", wantFork: true, skipped: []string{
+			filepath.Join(src, "._deleted.txt") + ",AppleDouble file of a file this run did not copy",
+		}},
+		{forks: ForksStrip, skipped: []string{
+			filepath.Join(src, "._deleted.txt") + ",AppleDouble file left out by -forks strip",
+			filepath.Join(src, "._notes.txt") + ",AppleDouble file left out by -forks strip",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run("forks="+tt.forks, func(t *testing.T) {
+			dest := filepath.Join(workspace, "dest-"+tt.forks)
+			if _, err := Run(t.Context(), Options{Config: cfg, Source: src, Dest: dest, Forks: tt.forks, NoSpaceCheck: true}); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(filepath.Join(dest, "documents", "._notes.txt"))
+			if tt.wantFork && string(data) != appleDoubleHeader+"notes fork" {
+				t.Fatalf("expected the fork next to the copy, got %q (%v)", data, err)
+			} else if !tt.wantFork && !os.IsNotExist(err) {
+				t.Fatalf("expected the fork to be left out, got %q (%v)", data, err)
+			}
+			if data, err := os.ReadFile(filepath.Join(dest, "documents", "._todo.txt")); err != nil || string(data) != "todo" {
+				t.Fatalf("expected ._todo.txt to be classified as a file, got %q (%v)", data, err)
+			}
+			if _, err := os.Stat(filepath.Join(dest, "documents", "._deleted.txt")); !os.IsNotExist(err) {
+				t.Fatalf("expected the orphan fork to be left out, got %v", err)
+			}
+			skipped, err := os.ReadFile(filepath.Join(dest, StateDir, "skipped.csv"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := strings.TrimSpace(string(skipped)), strings.Join(tt.skipped, "\n"); got != want {
+				t.Fatalf("unexpected skipped.csv:\ngot  %q\nwant %q", got, want)
+			}
+		})
+	}
+
+	if _, _, ok := ArchivedPath(workspace, filepath.Join(workspace, "dest-", "documents", "._notes.txt")); ok {
+		t.Fatal("expected an AppleDouble file not to count as archived")
+	}
+	if _, err := Run(t.Context(), Options{Config: cfg, Source: src, Dest: filepath.Join(workspace, "x"), Forks: "keep"}); err == nil {
+		t.Fatal("expected an unknown forks mode to be rejected")
+	}
+}
+
+func TestMoveFile_TakesTheAppleDoubleFileAlong(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "a")
+	writeFile(t, dir, "._a.txt", appleDoubleHeader)
+	log, err := startMoveLog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.close()
+	if err := moveFile(filepath.Join(dir, "a.txt"), filepath.Join(dir, "sub", "a.txt"), log); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sub", "._a.txt")); err != nil {
+		t.Fatalf("expected the AppleDouble file to move along: %v", err)
+	}
+}
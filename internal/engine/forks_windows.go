@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procFindFirstStreamW = syscall.NewLazyDLL("kernel32.dll").NewProc("FindFirstStreamW")
+	procFindNextStreamW  = syscall.NewLazyDLL("kernel32.dll").NewProc("FindNextStreamW")
+)
+
+// errorInvalidParameter is what FindFirstStreamW fails with on volumes
+// without streams, such as FAT and exFAT.
+const errorInvalidParameter syscall.Errno = 87
+
+// win32FindStreamData mirrors WIN32_FIND_STREAM_DATA.
+type win32FindStreamData struct {
+	streamSize int64
+	streamName [syscall.MAX_PATH + 36]uint16
+}
+
+// alternateStreams returns the names of the alternate data streams of the
+// file at path, such as ":Zone.Identifier:$DATA", leaving out its main
+// stream.
+func alternateStreams(path string) ([]string, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	var data win32FindStreamData
+	h, _, err := procFindFirstStreamW.Call(uintptr(unsafe.Pointer(p)), 0, uintptr(unsafe.Pointer(&data)), 0)
+	if syscall.Handle(h) == syscall.InvalidHandle {
+		if errors.Is(err, syscall.ERROR_HANDLE_EOF) || errors.Is(err, errorInvalidParameter) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("list streams of %s: %w", path, err)
+	}
+	defer syscall.FindClose(syscall.Handle(h))
+	var streams []string
+	for {
+		if name := syscall.UTF16ToString(data.streamName[:]); name != "::$DATA" {
+			streams = append(streams, name)
+		}
+		if r, _, err := procFindNextStreamW.Call(h, uintptr(unsafe.Pointer(&data))); r == 0 {
+			if errors.Is(err, syscall.ERROR_HANDLE_EOF) {
+				return streams, nil
+			}
+			return streams, fmt.Errorf("list streams of %s: %w", path, err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"unicode/utf16"
+)
+
+// fsCaps is what the filesystem of a destination folder can hold.
+type fsCaps struct {
+	// fsType names the filesystem, e.g. "vfat" or "NTFS"; it is empty when
+	// unknown.
+	fsType string
+	// maxFileSize is the largest file it holds, maxName the longest file
+	// name and maxPath the longest path; zero means no known limit. Names
+	// are measured in bytes, or with utf16Names in UTF-16 code units.
+	maxFileSize int64
+	maxName     int
+	maxPath     int
+	utf16Names  bool
+	// noPermissions is set when a mode set on a file does not stick, as
+	// on exFAT or SMB mounts that map every file to one mode.
+	noPermissions bool
+}
+
+// DefaultFileMode is the mode of copies on filesystems that do not keep
+// permissions, unless Options.FileMode says otherwise.
+const DefaultFileMode os.FileMode = 0o644
+
+// fatMaxFileSize is the largest file FAT32 holds, 4 GiB less one byte.
+const fatMaxFileSize = 1<<32 - 1
+
+var (
+	errFileTooLarge = errors.New("file too large for the destination filesystem")
+	errPathTooLong  = errors.New("path too long for the destination filesystem")
+)
+
+// fits returns why a file of size cannot be written at path, or nil.
+func (c fsCaps) fits(path string, size int64) error {
+	if c.maxFileSize > 0 && size > c.maxFileSize {
+		return fmt.Errorf("%w: %s holds files up to %s", errFileTooLarge, c.fsType, FormatBytes(uint64(c.maxFileSize)))
+	}
+	name := len(filepath.Base(path))
+	if c.utf16Names {
+		name = len(utf16.Encode([]rune(filepath.Base(path))))
+	}
+	if c.maxName > 0 && name > c.maxName {
+		return fmt.Errorf("%w: file names up to %d characters", errPathTooLong, c.maxName)
+	}
+	if c.maxPath > 0 && len(path) >= c.maxPath {
+		return fmt.Errorf("%w: paths up to %d bytes", errPathTooLong, c.maxPath-1)
+	}
+	return nil
+}
+
+// checkFilesystems looks at the filesystems the files of plan go to before
+// anything is copied. It fails when a folder cannot be written to and, with
+// xattrs, warns when copies cannot record their source in extended
+// attributes. The limits it finds, such as the 4 GiB files of FAT32, are
+// kept on each destination, so files beyond them are reported as failed
+// instead of failing halfway through their copy; the number of such files
+// is warned about up front.
+//
+// Where permissions do not stick, copies are not created with the mode of
+// their source but with DefaultFileMode, and the change is warned about.
+func checkFilesystems(dests []*destination, plan []plannedFile, xattrs bool, warnf func(string, ...any)) error {
+	for _, d := range dests {
+		d.caps = make(map[string]fsCaps)
+		for _, f := range plan {
+			root := d.rootFor(f.category)
+			if _, ok := d.caps[root]; ok {
+				continue
+			}
+			keepsPerms, err := probeFolder(root, xattrs, warnf)
+			if err != nil {
+				return err
+			}
+			caps, err := fsCapabilities(root)
+			if err != nil {
+				warnf("filesystem of %s: %v", root, err)
+			}
+			caps.noPermissions = !keepsPerms
+			d.caps[root] = caps
+			if caps.noPermissions && d.fileMode == 0 {
+				warnf("%s does not keep file permissions; copies get mode %s instead of the mode of their source (-file-mode sets another)", root, DefaultFileMode)
+			}
+		}
+
+		var unfit int
+		var first error
+		for _, f := range plan {
+			root := d.rootFor(f.category)
+			if err := d.caps[root].fits(filepath.Join(root, f.relDir, f.name), f.info.Size()); err != nil {
+				if unfit == 0 {
+					first = fmt.Errorf("%s: %w", f.srcPath, err)
+				}
+				unfit++
+			}
+		}
+		if unfit > 0 {
+			warnf("%d files do not fit on %s and will be reported as failed, e.g. %v", unfit, d.root, first)
+		}
+	}
+	return nil
+}
+
+// probeFolder writes and removes a file in dir to find out whether copies
+// can be written there, whether their permissions stick and, with xattrs,
+// whether they carry extended attributes.
+func probeFolder(dir string, xattrs bool, warnf func(string, ...any)) (keepsPerms bool, err error) {
+	f, err := createTemp(filepath.Join(dir, "probe"), 0o644)
+	if err != nil {
+		return false, fmt.Errorf("destination %s is not writable: %w", dir, err)
+	}
+	defer os.Remove(f.Name())
+	if err := f.Close(); err != nil {
+		return false, fmt.Errorf("destination %s is not writable: %w", dir, err)
+	}
+	if xattrs && !hasXattrs(f.Name()) {
+		warnf("%s has no extended attributes; copies will not record their source (-no-xattrs silences this)", dir)
+	}
+	if runtime.GOOS == "windows" {
+		// A mode there only sets the read-only attribute, which every
+		// filesystem keeps.
+		return true, nil
+	}
+	const probeMode = 0o600
+	if err := os.Chmod(f.Name(), probeMode); err != nil {
+		return false, nil
+	}
+	info, err := os.Stat(f.Name())
+	return err == nil && info.Mode().Perm() == probeMode, nil
+}
@@ -0,0 +1,19 @@
+//go:build darwin || freebsd
+
+package engine
+
+import "golang.org/x/sys/unix"
+
+// fsCapabilities reads the type and limits of the filesystem of path.
+func fsCapabilities(path string) (fsCaps, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return fsCaps{}, err
+	}
+	caps := fsCaps{fsType: unix.ByteSliceToString(st.Fstypename[:]), maxName: 255, maxPath: unix.PathMax}
+	switch caps.fsType {
+	case "msdos", "msdosfs":
+		caps.maxFileSize = fatMaxFileSize
+	}
+	return caps, nil
+}
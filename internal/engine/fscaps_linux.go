@@ -0,0 +1,19 @@
+package engine
+
+import "golang.org/x/sys/unix"
+
+// fsCapabilities reads the type and limits of the filesystem of path.
+func fsCapabilities(path string) (fsCaps, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return fsCaps{}, err
+	}
+	caps := fsCaps{maxName: int(st.Namelen), maxPath: unix.PathMax}
+	switch st.Type {
+	case unix.MSDOS_SUPER_MAGIC:
+		caps.fsType, caps.maxFileSize = "vfat", fatMaxFileSize
+	case unix.EXFAT_SUPER_MAGIC:
+		caps.fsType = "exfat"
+	}
+	return caps, nil
+}
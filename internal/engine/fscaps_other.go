@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package engine
+
+// fsCapabilities knows no limits here.
+func fsCapabilities(string) (fsCaps, error) {
+	return fsCaps{}, nil
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFSCaps_Fits(t *testing.T) {
+	fat := fsCaps{fsType: "vfat", maxFileSize: fatMaxFileSize, maxName: 255, maxPath: 4096}
+	tests := []struct {
+		name    string
+		caps    fsCaps
+		path    string
+		size    int64
+		wantErr error
+	}{
+		{name: "fits", caps: fat, path: "/mnt/usb/movies/trip.mp4", size: fatMaxFileSize},
+		{name: "larger than FAT32 holds", caps: fat, path: "/mnt/usb/movies/trip.mp4", size: fatMaxFileSize + 1, wantErr: errFileTooLarge},
+		{name: "name too long", caps: fat, path: "/mnt/usb/documents/" + strings.Repeat("a", 252) + ".txt", wantErr: errPathTooLong},
+		{name: "path too long", caps: fat, path: "/mnt" + strings.Repeat("/folder", 600) + "/a.txt", wantErr: errPathTooLong},
+		{name: "names counted in UTF-16", caps: fsCaps{maxName: 6, utf16Names: true}, path: "/d/ああ.jpg"},
+		{name: "no known limits", path: "/d/" + strings.Repeat("a", 1000), size: 1 << 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.caps.fits(tt.path, tt.size); !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFanOut_SkipsFilesBeyondTheFilesystemLimits(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	mustMkdir(t, dest)
+	writeFile(t, src, "small.txt", "tiny")
+	writeFile(t, src, "large.txt", "larger than the limit")
+
+	cfg, err := loadEmbeddedConfig()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	p := &planner{resolver: newCategoryResolver(cfg), dates: dateResolver{}, cp: &checkpoint{}, events: discardEvents{}}
+	plan, err := p.build(context.Background(), src)
+	if err != nil {
+		t.Fatalf("build plan: %v", err)
+	}
+	d := newDestination(dest, false)
+	if err := checkFilesystems([]*destination{d}, plan, false, func(string, ...any) {}); err != nil {
+		t.Fatal(err)
+	}
+	d.caps[dest] = fsCaps{fsType: "vfat", maxFileSize: 10}
+
+	for _, f := range plan {
+		err := fanOut(t.Context(), []*destination{d}, f, &copyBudget{}, fileOps{}, discardEvents{})
+		switch f.name {
+		case "small.txt":
+			if err != nil {
+				t.Fatalf("expected small.txt to be copied, got %v", err)
+			}
+		case "large.txt":
+			if !errors.Is(err, errFileTooLarge) || !skipsFile(err) {
+				t.Fatalf("expected large.txt to be skipped as too large, got %v", err)
+			}
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dest, "documents", "small.txt")); err != nil || string(data) != "tiny" {
+		t.Fatalf("expected the copy of small.txt, got %q (%v)", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "documents", "large.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no copy of large.txt, got %v", err)
+	}
+	entries, err := os.ReadDir(dest)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected only the documents folder and no probe file left, got %v (%v)", entries, err)
+	}
+}
+
+func TestDestination_ModeFor(t *testing.T) {
+	tests := []struct {
+		name          string
+		fileMode      os.FileMode
+		noPermissions bool
+		want          os.FileMode
+	}{
+		{name: "source mode", want: 0o600},
+		{name: "no permissions on the filesystem", noPermissions: true, want: DefaultFileMode},
+		{name: "configured mode", fileMode: 0o640, noPermissions: true, want: 0o640},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDestination("/dest", false)
+			d.fileMode = tt.fileMode
+			d.caps = map[string]fsCaps{"/dest": {noPermissions: tt.noPermissions}}
+			if got := d.modeFor("/dest", 0o600); got != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
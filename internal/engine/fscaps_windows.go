@@ -0,0 +1,30 @@
+package engine
+
+import (
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// fsCapabilities reads the type and limits of the volume of path. Paths
+// have no limit of their own: Go writes long paths with the \\?\ prefix.
+func fsCapabilities(path string) (fsCaps, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return fsCaps{}, err
+	}
+	volume := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumePathName(p, &volume[0], uint32(len(volume))); err != nil {
+		return fsCaps{}, err
+	}
+	var maxComponent, flags uint32
+	name := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumeInformation(&volume[0], nil, 0, nil, &maxComponent, &flags, &name[0], uint32(len(name))); err != nil {
+		return fsCaps{}, err
+	}
+	caps := fsCaps{fsType: windows.UTF16ToString(name), maxName: int(maxComponent), utf16Names: true}
+	if strings.HasPrefix(caps.fsType, "FAT") {
+		caps.maxFileSize = fatMaxFileSize
+	}
+	return caps, nil
+}
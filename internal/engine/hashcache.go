@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// hashCache remembers the hashes of source files by device, inode, size
+// and modification time, so re-runs over a large source do not read files
+// again that have not changed. It lives in the state folder of the
+// destination and is shared by the planner's hash workers.
+type hashCache struct {
+	path      string
+	algorithm string
+	mu        sync.Mutex
+	entries   map[fileIdentity]string
+	changed   bool
+}
+
+// fileIdentity is what has to be unchanged for a cached hash to be used.
+type fileIdentity struct {
+	dev, ino uint64
+	size     int64
+	modTime  int64
+}
+
+// identify returns the identity of a file; ok is false where the
+// filesystem gives no inode numbers.
+func identify(info fs.FileInfo) (id fileIdentity, ok bool) {
+	dev, ino, ok := inode(info)
+	if !ok {
+		return id, false
+	}
+	return fileIdentity{dev: dev, ino: ino, size: info.Size(), modTime: info.ModTime().UnixNano()}, true
+}
+
+// loadHashCache reads the cache of dest for hashes of algorithm; those of
+// other algorithms are dropped.
+func loadHashCache(dest, algorithm string) (*hashCache, error) {
+	c := &hashCache{
+		path:      filepath.Join(dest, StateDir, "hashcache.csv"),
+		algorithm: algorithm,
+		entries:   make(map[fileIdentity]string),
+	}
+	f, err := os.Open(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read hash cache: %w", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 6
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read hash cache %s: %w", c.path, err)
+	}
+	for _, rec := range records {
+		if rec[4] != algorithm {
+			c.changed = true
+			continue
+		}
+		var nums [4]int64
+		for i := range nums {
+			if nums[i], err = strconv.ParseInt(rec[i], 10, 64); err != nil {
+				return nil, fmt.Errorf("read hash cache %s: invalid number %q", c.path, rec[i])
+			}
+		}
+		id := fileIdentity{dev: uint64(nums[0]), ino: uint64(nums[1]), size: nums[2], modTime: nums[3]}
+		c.entries[id] = rec[5]
+	}
+	return c, nil
+}
+
+// get returns the cached hash of the file described by info.
+func (c *hashCache) get(info fs.FileInfo) (string, bool) {
+	id, ok := identify(info)
+	if !ok {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hash, ok := c.entries[id]
+	return hash, ok
+}
+
+// put caches hash for the file described by info.
+func (c *hashCache) put(info fs.FileInfo, hash string) {
+	id, ok := identify(info)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries[id] != hash {
+		c.entries[id] = hash
+		c.changed = true
+	}
+}
+
+// save writes the cache back if it changed.
+func (c *hashCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.changed {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("write hash cache: %w", err)
+	}
+	tmp, err := createTemp(c.path, 0o644)
+	if err != nil {
+		return fmt.Errorf("write hash cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := csv.NewWriter(tmp)
+	for id, hash := range c.entries {
+		rec := []string{
+			strconv.FormatInt(int64(id.dev), 10), strconv.FormatInt(int64(id.ino), 10),
+			strconv.FormatInt(id.size, 10), strconv.FormatInt(id.modTime, 10), c.algorithm, hash,
+		}
+		if err := w.Write(rec); err != nil {
+			tmp.Close()
+			return fmt.Errorf("write hash cache: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write hash cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write hash cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("write hash cache: %w", err)
+	}
+	c.changed = false
+	return nil
+}
@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHashCache(t *testing.T) {
+	dest := t.TempDir()
+	path := filepath.Join(t.TempDir(), "a.jpg")
+	if err := os.WriteFile(path, []byte("photo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := identify(info); !ok {
+		t.Skip("no inode numbers here")
+	}
+
+	c, err := loadHashCache(dest, hashSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.put(info, "cafe")
+	if err := c.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = loadHashCache(dest, hashSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash, ok := c.get(info); !ok || hash != "cafe" {
+		t.Fatalf("get = %q, %v; want the cached hash", hash, ok)
+	}
+	later := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if _, ok := c.get(changed); ok {
+		t.Fatal("expected no cached hash after the file changed")
+	}
+
+	// Hashes of another algorithm are not used.
+	c, err = loadHashCache(dest, "sha512")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.get(info); ok {
+		t.Fatal("expected no cached hash for another algorithm")
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package engine
+
+import "io/fs"
+
+// hasHiddenAttribute reports whether Explorer hides the file; only Windows
+// has such an attribute.
+func hasHiddenAttribute(fs.FileInfo) bool {
+	return false
+}
@@ -0,0 +1,12 @@
+package engine
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// hasHiddenAttribute reports whether Explorer hides the file.
+func hasHiddenAttribute(info fs.FileInfo) bool {
+	attrs, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	return ok && attrs.FileAttributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0
+}
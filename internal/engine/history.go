@@ -0,0 +1,252 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// BuildVersion is the module version or VCS revision from the build info.
+func BuildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			return s.Value
+		}
+	}
+	return "(devel)"
+}
+
+// Run statuses recorded in the audit log.
+const (
+	runCompleted   = "completed"
+	runStopped     = "stopped"
+	runInterrupted = "interrupted"
+	runFailed      = "failed"
+)
+
+// RunRecord is one audit log entry. The log lives in
+// <dest>/.classifier/history/<yyyy-mm>.jsonl, one line per run; the
+// manifest it points at lists every file the run placed or skipped.
+type RunRecord struct {
+	ID           string    `json:"id"`
+	Started      time.Time `json:"started"`
+	Finished     time.Time `json:"finished"`
+	Status       string    `json:"status"`
+	Error        string    `json:"error,omitempty"`
+	Version      string    `json:"version"`
+	ConfigPath   string    `json:"config_path,omitempty"`
+	ConfigHash   string    `json:"config_hash"`
+	Source       string    `json:"source"`
+	Destinations []string  `json:"destinations"`
+	Args         []string  `json:"args"`
+	Manifest     string    `json:"manifest"`
+	// HashAlgorithm is the algorithm of the hashes in the manifest.
+	HashAlgorithm string         `json:"hash_algorithm"`
+	Counts        map[string]int `json:"counts"`
+	BytesCopied   int64          `json:"bytes_copied"`
+	// VerifyRetries counts the copies -verify had made again.
+	VerifyRetries int `json:"verify_retries,omitempty"`
+	// Categories counts the files classified into each category, and
+	// UnknownExtensions by extension those no resolver had a category for.
+	Categories        map[string]int `json:"categories,omitempty"`
+	UnknownExtensions map[string]int `json:"unknown_extensions,omitempty"`
+}
+
+// runRecorder is the Sink that writes the run manifest and counts
+// events for the audit log entry.
+type runRecorder struct {
+	dest     string
+	paths    reportPaths
+	record   RunRecord
+	manifest *os.File
+	w        *csv.Writer
+}
+
+// ConfigHash returns the SHA-256 of the config at path, or of the embedded
+// config when path is empty.
+func ConfigHash(path string) (string, error) {
+	data, err := ReadConfig(path)
+	if err != nil {
+		return "", err
+	}
+	return HashConfig(data), nil
+}
+
+// HashConfig returns the SHA-256 of config data as recorded in the audit
+// log.
+func HashConfig(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// startRun creates the manifest of a new run in dest. Runs started by the
+// same process within a second get a counter appended to their id. Paths
+// are recorded as paths says, with root columns when they are relative.
+func startRun(dest string, record RunRecord, paths reportPaths) (*runRecorder, error) {
+	record.Started = time.Now().UTC()
+	if record.Version == "" {
+		record.Version = BuildVersion()
+	}
+	record.Counts = make(map[string]int)
+	record.Categories = make(map[string]int)
+
+	base := record.Started.Format("20060102T150405Z") + "-" + strconv.Itoa(os.Getpid())
+	var f *os.File
+	for i := 1; ; i++ {
+		record.ID = base
+		if i > 1 {
+			record.ID += "-" + strconv.Itoa(i)
+		}
+		record.Manifest = filepath.Join(StateDir, "manifests", record.ID+".csv")
+		path := filepath.Join(dest, record.Manifest)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("create manifest: %w", err)
+		}
+		var err error
+		f, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("create manifest: %w", err)
+		}
+		break
+	}
+	w := csv.NewWriter(f)
+	header := []string{"action", "src", "dest", "size", "hash", "hash_algorithm", "error"}
+	if paths.relative {
+		header = append(header, "src_root", "dest_root")
+	}
+	if err := w.Write(header); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("write manifest: %w", err)
+	}
+	return &runRecorder{dest: dest, paths: paths, record: record, manifest: f, w: w}, nil
+}
+
+func (r *runRecorder) Emit(ev Event) {
+	r.record.Counts[ev.Type]++
+	switch ev.Type {
+	case EventClassified:
+		r.record.Categories[ev.Category]++
+		return
+	case EventCopied:
+		r.record.BytesCopied += ev.Size
+		r.record.VerifyRetries += ev.Retries
+	case EventSkippedDuplicate, EventSkippedPresent, EventRemoved, EventError:
+	default:
+		return
+	}
+	algorithm := ""
+	if ev.Hash != "" {
+		algorithm = r.record.HashAlgorithm
+	}
+	row := []string{ev.Type, ev.Src, ev.Dest, strconv.FormatInt(ev.Size, 10), ev.Hash, algorithm, ev.Error}
+	if r.paths.relative {
+		src, srcRoot := r.paths.src(ev.Src)
+		dest, destRoot := r.paths.dest(ev.Dest)
+		if destRoot == r.paths.primary {
+			// Found again through the manifest, however it is mounted.
+			destRoot = "."
+		}
+		row = append(row, srcRoot, destRoot)
+		row[1], row[2] = src, dest
+	}
+	// Write errors surface when the manifest is closed.
+	_ = r.w.Write(row)
+}
+
+// noteUnknown records the unknown extensions of the run.
+func (r *runRecorder) noteUnknown(unknown map[string]*unknownExtension) {
+	if len(unknown) == 0 {
+		return
+	}
+	r.record.UnknownExtensions = make(map[string]int, len(unknown))
+	for ext, u := range unknown {
+		r.record.UnknownExtensions[ext] = u.count
+	}
+}
+
+func (r *runRecorder) Finish() {
+	r.w.Flush()
+}
+
+// close completes the manifest and appends the run to the audit log.
+func (r *runRecorder) close(status string, runErr error) error {
+	r.w.Flush()
+	werr := r.w.Error()
+	if err := r.manifest.Close(); err != nil && werr == nil {
+		werr = err
+	}
+	if werr != nil {
+		return fmt.Errorf("write manifest: %w", werr)
+	}
+
+	r.record.Finished = time.Now().UTC()
+	r.record.Status = status
+	if runErr != nil {
+		r.record.Error = runErr.Error()
+	}
+	line, err := json.Marshal(r.record)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(r.dest, StateDir, "history", r.record.Started.Format("2006-01")+".jsonl")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("write audit log: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("write audit log: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("write audit log: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write audit log: %w", err)
+	}
+	return nil
+}
+
+// ReadHistory returns the audit log entries of dest, oldest first. Lines
+// that do not parse, such as one cut short by a crash, are left out.
+func ReadHistory(dest string) ([]RunRecord, error) {
+	logs, err := filepath.Glob(filepath.Join(dest, StateDir, "history", "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	var records []RunRecord
+	for _, path := range logs {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read audit log: %w", err)
+		}
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			var rec RunRecord
+			if json.Unmarshal(line, &rec) != nil || rec.ID == "" {
+				continue
+			}
+			records = append(records, rec)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Started.Before(records[j].Started) })
+	return records, nil
+}
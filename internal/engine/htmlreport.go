@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// reportGroup is a file kept in the destination together with the
+// sources that were skipped because they had the same content.
+type reportGroup struct {
+	Hash       string
+	Kept       reportFile
+	Duplicates []reportFile
+}
+
+type reportFile struct {
+	Path      string
+	Size      string
+	ModTime   string
+	Kept      bool
+	Missing   bool
+	Thumbnail template.URL
+}
+
+var duplicateReportTemplate = template.Must(template.New("duplicates").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Duplicates</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+section { border-bottom: 1px solid #ccc; padding: 1em 0; }
+.files { display: flex; flex-wrap: wrap; gap: 1em; }
+figure { margin: 0; width: 220px; }
+figure.kept { outline: 2px solid #2a2; }
+img { max-width: 200px; max-height: 200px; display: block; }
+figcaption { font-size: 0.8em; word-break: break-all; }
+.hash { font-family: monospace; color: #666; }
+</style>
+</head>
+<body>
+<h1>{{len .}} duplicate groups</h1>
+{{range .}}<section>
+<div class="hash">{{.Hash}}</div>
+<div class="files">
+{{template "file" .Kept}}
+{{range .Duplicates}}{{template "file" .}}
+{{end}}</div>
+</section>
+{{end}}</body>
+</html>
+{{define "file"}}<figure{{if .Kept}} class="kept"{{end}}>
+{{if .Thumbnail}}<img src="{{.Thumbnail}}" alt="">{{end}}
+<figcaption>{{.Path}}<br>{{if .Missing}}missing{{else}}{{.Size}}, {{.ModTime}}{{end}}</figcaption>
+</figure>{{end}}
+`))
+
+// writeDuplicateReport writes dir/duplicates.html showing every kept file
+// of the destination root next to the sources skipped as its duplicates,
+// with thumbnails for images.
+func writeDuplicateReport(dir, root string, entries []skippedEntry, hashes map[string]string) error {
+	groups := make(map[string]*reportGroup)
+	var order []string
+	for _, e := range entries {
+		g, ok := groups[e.destPath]
+		if !ok {
+			g = &reportGroup{Hash: hashes[e.destPath], Kept: describeReportFile(dir, root, e.destPath)}
+			g.Kept.Kept = true
+			groups[e.destPath] = g
+			order = append(order, e.destPath)
+		}
+		g.Duplicates = append(g.Duplicates, describeReportFile(dir, root, e.srcPath))
+	}
+	sort.Strings(order)
+	list := make([]*reportGroup, len(order))
+	for i, kept := range order {
+		list[i] = groups[kept]
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, "duplicates.html"), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("write duplicate report: %w", err)
+	}
+	defer f.Close()
+	if err := duplicateReportTemplate.Execute(f, list); err != nil {
+		return fmt.Errorf("write duplicate report: %w", err)
+	}
+	return nil
+}
+
+// describeReportFile links the stored thumbnail of files inside root,
+// relative to the report in dir, and embeds a freshly rendered one for
+// anything else.
+func describeReportFile(dir, root, path string) reportFile {
+	rf := reportFile{Path: path}
+	info, err := os.Stat(path)
+	if err != nil {
+		rf.Missing = true
+		return rf
+	}
+	rf.Size = FormatBytes(uint64(info.Size()))
+	rf.ModTime = info.ModTime().Format(time.DateTime)
+	if thumb, ok := thumbnailPath(root, path); ok {
+		if _, err := os.Stat(thumb); err == nil {
+			rel, _ := filepath.Rel(dir, thumb)
+			rf.Thumbnail = template.URL((&url.URL{Path: filepath.ToSlash(rel)}).String())
+			return rf
+		}
+	}
+	if thumb, err := imageThumbnail(path, DefaultThumbnailSize); err == nil {
+		rf.Thumbnail = template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(thumb))
+	}
+	return rf
+}
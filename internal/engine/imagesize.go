@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+)
+
+// imageDimensions reads the pixel size of the image at path from its
+// header: GIF, JPEG and PNG through the image decoders, HEIC and AVIF from
+// the ispe property of their largest image.
+func imageDimensions(path string) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	if cfg, _, err := image.DecodeConfig(f); err == nil {
+		return cfg.Width, cfg.Height, nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	var head [8]byte
+	if _, err := f.ReadAt(head[:], 0); err != nil || string(head[4:8]) != "ftyp" {
+		return 0, 0, fmt.Errorf("read dimensions of %s: %w", path, image.ErrFormat)
+	}
+	w, h, err := heifDimensions(f, info.Size())
+	if err != nil {
+		return 0, 0, fmt.Errorf("read dimensions of %s: %w", path, err)
+	}
+	return w, h, nil
+}
+
+func heifDimensions(r io.ReaderAt, size int64) (int, int, error) {
+	var w, h int
+	var walk func(typ string, body, end int64) (bool, error)
+	walk = func(typ string, body, end int64) (bool, error) {
+		switch typ {
+		case "meta":
+			// A full box: version and flags come before the children.
+			return false, walkBoxes(r, body+4, end, walk)
+		case "iprp", "ipco":
+			return true, nil
+		case "ispe":
+			var b [12]byte
+			if _, err := r.ReadAt(b[:], body); err != nil {
+				return false, err
+			}
+			iw, ih := int(binary.BigEndian.Uint32(b[4:8])), int(binary.BigEndian.Uint32(b[8:12]))
+			// Thumbnails and grid tiles have their own, smaller ispe.
+			if iw*ih > w*h {
+				w, h = iw, ih
+			}
+		}
+		return false, nil
+	}
+	if err := walkBoxes(r, 0, size, walk); err != nil {
+		return 0, 0, err
+	}
+	if w == 0 || h == 0 {
+		return 0, 0, errors.New("no image size in the HEIF header")
+	}
+	return w, h, nil
+}
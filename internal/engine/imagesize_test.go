@@ -0,0 +1,27 @@
+package engine
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func ispe(w, h uint32) []byte {
+	b := make([]byte, 4, 12)
+	b = binary.BigEndian.AppendUint32(b, w)
+	return mp4Box("ispe", binary.BigEndian.AppendUint32(b, h))
+}
+
+func TestImageDimensions_HEIF(t *testing.T) {
+	// A thumbnail's ispe comes first; the primary image is the larger one.
+	meta := mp4Box("meta", make([]byte, 4), mp4Box("iprp", mp4Box("ipco", ispe(320, 240), ispe(4032, 3024))))
+	path := filepath.Join(t.TempDir(), "photo.heic")
+	if err := os.WriteFile(path, append(mp4Box("ftyp", []byte("heic\x00\x00\x00\x00mif1")), meta...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w, h, err := imageDimensions(path)
+	if err != nil || w != 4032 || h != 3024 {
+		t.Fatalf("got %dx%d, %v; want 4032x3024", w, h, err)
+	}
+}
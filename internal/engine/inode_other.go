@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd
+
+package engine
+
+import "io/fs"
+
+// inode reports that there are no inode numbers to go by here.
+func inode(fs.FileInfo) (dev, ino uint64, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,17 @@
+//go:build linux || darwin || freebsd
+
+package engine
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// inode returns the device and inode number of a file.
+func inode(info fs.FileInfo) (dev, ino uint64, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint64(st.Dev), uint64(st.Ino), true
+}
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LayoutAlphabetical is the category layout that files by first letter.
+const LayoutAlphabetical = "alphabetical"
+
+// letterDir is the folder of the alphabetical layout for a file name: its
+// lower-cased first letter, "0-9" for a digit and "_" for anything else.
+func letterDir(name string) string {
+	r, _ := utf8.DecodeRuneInString(name)
+	switch {
+	case unicode.IsLetter(r):
+		return string(unicode.ToLower(r))
+	case unicode.IsDigit(r):
+		return "0-9"
+	}
+	return "_"
+}
+
+// DefaultDateLayout files dated images and movies in a year folder holding
+// yyyymm folders.
+const DefaultDateLayout = "2006/200601"
+
+// dateLayout returns the date folder layout of cfg.
+func dateLayout(cfg Config) (string, error) {
+	if cfg.DateLayout == "" {
+		return DefaultDateLayout, nil
+	}
+	if err := CheckDateLayout(cfg.DateLayout); err != nil {
+		return "", err
+	}
+	return cfg.DateLayout, nil
+}
+
+// CheckDateLayout checks that layout, a Go time layout with "/" between
+// nested folders, names the year and the month of a date in valid folder
+// names.
+func CheckDateLayout(layout string) error {
+	for _, dir := range strings.Split(layout, "/") {
+		if dir == "" || dir == "." || dir == ".." || strings.ContainsAny(dir, `\:`) {
+			return fmt.Errorf("invalid date layout %q: %q is not a folder name", layout, dir)
+		}
+	}
+	for _, d := range []time.Time{time.Date(2024, time.November, 1, 0, 0, 0, 0, time.UTC), time.Date(1999, time.February, 1, 0, 0, 0, 0, time.UTC)} {
+		year, month, ok := parseDateDir(layout, d.Format(layout))
+		if !ok || year != d.Year() || month != d.Month() {
+			return fmt.Errorf("invalid date layout %q: it must name the year and the month, e.g. 2006/01", layout)
+		}
+	}
+	return nil
+}
+
+// dateDir is the folder of a date under layout, or DefaultDateLayout when
+// layout is empty.
+func dateDir(layout string, year int, month time.Month) string {
+	if layout == "" {
+		layout = DefaultDateLayout
+	}
+	return filepath.FromSlash(time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).Format(layout))
+}
+
+// parseDateDir reads the date of a folder path, "/"-separated, written
+// under layout.
+func parseDateDir(layout, dir string) (int, time.Month, bool) {
+	t, err := time.Parse(layout, dir)
+	if err != nil || t.Format(layout) != dir {
+		return 0, 0, false
+	}
+	return t.Year(), t.Month(), true
+}
+
+// ArchivedPath splits a file below dest into its category and, for files in
+// a date folder of DefaultDateLayout, its "yyyy-mm" date. Files directly
+// under dest, such as reports, sidecars and AppleDouble files are not
+// archived and yield ok == false.
+func ArchivedPath(dest, path string) (category, date string, ok bool) {
+	return DatedPath(dest, path, DefaultDateLayout)
+}
+
+// DatedPath is ArchivedPath for date folders of layout.
+func DatedPath(dest, path, layout string) (category, date string, ok bool) {
+	rel, err := filepath.Rel(dest, path)
+	if err != nil {
+		return "", "", false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) < 2 || IsSidecar(path) || IsAppleDouble(filepath.Base(path)) {
+		return "", "", false
+	}
+	if n := strings.Count(layout, "/") + 1; len(parts) > n+1 {
+		if year, month, ok := parseDateDir(layout, strings.Join(parts[1:n+1], "/")); ok {
+			date = fmt.Sprintf("%04d-%02d", year, int(month))
+		}
+	}
+	return parts[0], date, true
+}
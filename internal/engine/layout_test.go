@@ -0,0 +1,41 @@
+package engine
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckDateLayout(t *testing.T) {
+	for _, layout := range []string{DefaultDateLayout, "2006/01", "2006-01", "2006/01-Jan"} {
+		if err := CheckDateLayout(layout); err != nil {
+			t.Errorf("%q: unexpected error: %v", layout, err)
+		}
+	}
+	for _, layout := range []string{"2006", "01", "2006//01", "../2006/01", "photos", `2006\01`} {
+		if err := CheckDateLayout(layout); err == nil {
+			t.Errorf("%q: expected an error", layout)
+		}
+	}
+}
+
+func TestDatedPath(t *testing.T) {
+	dest := filepath.FromSlash("/dest")
+	tests := []struct {
+		layout, path   string
+		category, date string
+		ok             bool
+	}{
+		{DefaultDateLayout, "images/2024/202401/a.jpg", "images", "2024-01", true},
+		{DefaultDateLayout, "images/2024/202301/a.jpg", "images", "", true},
+		{DefaultDateLayout, "images/2024-01/a.jpg", "images", "", true},
+		{"2006-01", "images/2024-01/a.jpg", "images", "2024-01", true},
+		{"2006-01", "images/2024-01", "images", "", true},
+		{"2006-01", "report.csv", "", "", false},
+	}
+	for _, tt := range tests {
+		category, date, ok := DatedPath(dest, filepath.Join(dest, filepath.FromSlash(tt.path)), tt.layout)
+		if category != tt.category || date != tt.date || ok != tt.ok {
+			t.Errorf("%s under %q: got %q, %q, %v", tt.path, tt.layout, category, date, ok)
+		}
+	}
+}
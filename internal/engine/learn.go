@@ -0,0 +1,165 @@
+package engine
+
+import (
+	"cmp"
+	"context"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// learnDatePatterns are the file name conventions Learn looks for: camera,
+// phone and messenger names that carry the date the file was taken.
+var learnDatePatterns = []string{
+	`^(?P<year>\d{4})-(?P<month>\d{2})-(?P<day>\d{2})`,
+	`^(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})_\d{6}`,
+	`^IMG_(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})_`,
+	`^VID_(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})_`,
+	`^PXL_(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})_`,
+	`^(?:IMG|VID)-(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})-WA\d+`,
+	`^Screenshot[ _](?P<year>\d{4})-?(?P<month>\d{2})-?(?P<day>\d{2})`,
+}
+
+// learnDateLayouts are the date folder layouts Learn recognises below the
+// category folders.
+var learnDateLayouts = []string{DefaultDateLayout, "2006/01", "2006-01", "200601", "2006/2006-01"}
+
+// minLearnedPatternFiles is how many file names a date pattern must match
+// before Learn suggests it, so a single odd name is not taken for a habit.
+const minLearnedPatternFiles = 2
+
+// Learned is what Learn found in an organized tree.
+type Learned struct {
+	// Config holds the inferred categories, date patterns and date layout.
+	Config Config
+	// Files is the number of files looked at.
+	Files int
+	// Ambiguous lists, by extension, the folders holding files of an
+	// extension that was given to another folder, with their file counts.
+	Ambiguous map[string]map[string]int
+}
+
+// Learn infers rules from a tree that was organized by hand, with one
+// top-level folder per category: every extension goes to the folder holding
+// most of its files, date patterns are kept when file names follow them and
+// the date layout is the one most files sit in. Files directly in dir,
+// without an extension or in hidden or tool folders are not looked at.
+func Learn(ctx context.Context, dir string) (Learned, error) {
+	patterns := make([]dateResolver, len(learnDatePatterns))
+	for i, p := range learnDatePatterns {
+		r, err := newDateResolver([]string{p})
+		if err != nil {
+			return Learned{}, err
+		}
+		patterns[i] = r
+	}
+	// byExt counts files by extension and folder.
+	byExt := make(map[string]map[string]int)
+	folders := make(map[string]bool)
+	patternFiles := make([]int, len(patterns))
+	layoutFiles := make([]int, len(learnDateLayouts))
+	files := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if d.IsDir() {
+			if strings.HasPrefix(d.Name(), ".") || IsToolDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || IsSidecar(d.Name()) || IsAppleDouble(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(d.Name()), "."))
+		if len(parts) < 2 || ext == "" || categoryDir(parts[0]) != nil {
+			return nil
+		}
+		files++
+		folder := parts[0]
+		folders[folder] = true
+		if byExt[ext] == nil {
+			byExt[ext] = make(map[string]int)
+		}
+		byExt[ext][folder]++
+
+		for i, r := range patterns {
+			if _, _, ok := r.ResolveDate(path); ok {
+				patternFiles[i]++
+			}
+		}
+		// The date folders sit between the category folder and the file.
+		between := strings.Join(parts[1:len(parts)-1], "/")
+		for i, layout := range learnDateLayouts {
+			if _, _, ok := parseDateDir(layout, between); ok {
+				layoutFiles[i]++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Learned{}, err
+	}
+
+	learned := Learned{Files: files, Ambiguous: make(map[string]map[string]int)}
+	extensions := make(map[string][]string)
+	for ext, counts := range byExt {
+		var best string
+		for folder, n := range counts {
+			if best == "" || n > counts[best] || n == counts[best] && folder < best {
+				best = folder
+			}
+		}
+		extensions[best] = append(extensions[best], ext)
+		if len(counts) > 1 {
+			others := make(map[string]int)
+			for folder, n := range counts {
+				if folder != best {
+					others[folder] = n
+				}
+			}
+			learned.Ambiguous[ext] = others
+		}
+	}
+	for folder := range folders {
+		exts := extensions[folder]
+		if len(exts) == 0 {
+			continue
+		}
+		// The most common extensions first, as in the config a person writes.
+		slices.SortFunc(exts, func(a, b string) int {
+			return cmp.Or(cmp.Compare(byExt[b][folder], byExt[a][folder]), cmp.Compare(a, b))
+		})
+		learned.Config.Categories = append(learned.Config.Categories, Category{Name: folder, Extensions: exts})
+	}
+	slices.SortFunc(learned.Config.Categories, func(a, b Category) int { return cmp.Compare(a.Name, b.Name) })
+
+	for i, n := range patternFiles {
+		if n >= minLearnedPatternFiles {
+			learned.Config.DatePatterns = append(learned.Config.DatePatterns, learnDatePatterns[i])
+		}
+	}
+	best := 0
+	for i, n := range layoutFiles {
+		if n > layoutFiles[best] {
+			best = i
+		}
+	}
+	if layoutFiles[best] > 0 && learnDateLayouts[best] != DefaultDateLayout {
+		learned.Config.DateLayout = learnDateLayouts[best]
+	}
+	return learned, nil
+}
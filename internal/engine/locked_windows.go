@@ -0,0 +1,13 @@
+package engine
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// isLocked reports whether err is Windows refusing access to a file another
+// program has open or locked.
+func isLocked(err error) bool {
+	return errors.Is(err, windows.ERROR_SHARING_VIOLATION) || errors.Is(err, windows.ERROR_LOCK_VIOLATION)
+}
@@ -0,0 +1,19 @@
+package engine
+
+import (
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestSkipsFile_LockedByAnotherProgram(t *testing.T) {
+	err := fmt.Errorf("hash: %w", &fs.PathError{Op: "open", Path: `C:\in\a.pst`, Err: windows.ERROR_SHARING_VIOLATION})
+	if !skipsFile(err) {
+		t.Fatal("a sharing violation must skip the file, not stop the run")
+	}
+	if skipsFile(fs.ErrPermission) {
+		t.Fatal("permission errors must not count as locked")
+	}
+}
@@ -0,0 +1,348 @@
+package engine
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// legacyHashAlgorithm is the algorithm of manifests written before the
+// algorithm was recorded; runs always hashed with SHA-256 then.
+const legacyHashAlgorithm = hashSHA256
+
+// ManifestEntry is one row of a run manifest.
+type ManifestEntry struct {
+	Action string
+	Src    string
+	Dest   string
+	Size   int64
+	Hash   string
+	// HashAlgorithm names the algorithm of Hash; rows of manifests that
+	// predate the column report SHA-256.
+	HashAlgorithm string
+	Error         string
+	// SrcRoot and DestRoot are the roots Src and Dest were recorded
+	// relative to with Options.RelativePaths; ReadManifest returns the
+	// paths joined to them. A DestRoot of "." is the destination the
+	// manifest is kept in, wherever it is mounted now.
+	SrcRoot  string
+	DestRoot string
+}
+
+// manifestDest returns the destination the manifest at path is kept in,
+// below <dest>/.classifier/manifests.
+func manifestDest(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(filepath.Dir(filepath.Dir(abs))), nil
+}
+
+// resolveManifestPath joins a path of a manifest row recorded relative to
+// root to it; a root of "." is dest, the destination of the manifest.
+// Absolute paths are returned as they are.
+func resolveManifestPath(path, root, dest string) string {
+	if path == "" || filepath.IsAbs(path) || root == "" {
+		return path
+	}
+	if root == "." {
+		root = dest
+	}
+	return filepath.Join(root, path)
+}
+
+// reportPaths records the paths of manifests and reports relative to their
+// roots with Options.RelativePaths, so they stay valid when drives are
+// mounted elsewhere. The zero value keeps paths absolute.
+type reportPaths struct {
+	relative bool
+	source   string
+	// primary is the primary destination; roots holds it, the mirrors
+	// and the roots of categories.
+	primary string
+	roots   []string
+}
+
+// reportStamp appends the rows of warn.csv, errors.csv and skipped.csv to
+// the rows of earlier runs with Options.AppendReports, stamped with the id
+// and start of the run that wrote them. The zero value rewrites the
+// reports every run.
+type reportStamp struct {
+	runID   string
+	started string
+}
+
+func newReportStamp(o Options, record RunRecord) reportStamp {
+	if !o.AppendReports {
+		return reportStamp{}
+	}
+	return reportStamp{runID: record.ID, started: record.Started.UTC().Format(time.RFC3339)}
+}
+
+// open opens the report at path for the rows of this run.
+func (s reportStamp) open(path string) (*os.File, error) {
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if s.runID != "" {
+		flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	return os.OpenFile(path, flag, 0o644)
+}
+
+// row appends the run columns to a row when the reports are appended to.
+func (s reportStamp) row(row []string) []string {
+	if s.runID == "" {
+		return row
+	}
+	return append(row, s.runID, s.started)
+}
+
+func newReportPaths(o Options, dests []*destination) reportPaths {
+	if !o.RelativePaths {
+		return reportPaths{}
+	}
+	r := reportPaths{relative: true, source: o.Source, primary: dests[0].root}
+	for _, d := range dests {
+		r.roots = append(r.roots, d.root)
+		for _, root := range d.roots {
+			r.roots = append(r.roots, root)
+		}
+	}
+	return r
+}
+
+// rel returns path relative to the deepest of roots holding it and that
+// root, or path and "" when paths are absolute or no root holds it.
+func (r reportPaths) rel(path string, roots ...string) (string, string) {
+	if !r.relative || path == "" {
+		return path, ""
+	}
+	best := ""
+	for _, root := range roots {
+		if root != "" && within(root, path) && len(root) > len(best) {
+			best = root
+		}
+	}
+	if best == "" {
+		return path, ""
+	}
+	rel, err := filepath.Rel(best, path)
+	if err != nil {
+		return path, ""
+	}
+	return rel, best
+}
+
+func (r reportPaths) src(path string) (string, string) { return r.rel(path, r.source) }
+
+func (r reportPaths) dest(path string) (string, string) { return r.rel(path, r.roots...) }
+
+// Manifests returns the manifests of all runs into dest, oldest first.
+func Manifests(dest string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dest, StateDir, "manifests", "*.csv"))
+	if err != nil {
+		return nil, err
+	}
+	// Run ids start with their UTC start time, so names sort by age.
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// ReadManifest reads a run manifest. Columns are looked up by the header
+// row, so manifests of older and newer versions read alike.
+func ReadManifest(path string) ([]ManifestEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	defer f.Close()
+	dest, err := manifestDest(path)
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read manifest %s: %w", path, err)
+	}
+	col := make(map[string]int, len(header))
+	for i, name := range header {
+		col[name] = i
+	}
+	field := func(row []string, name string) string {
+		if i, ok := col[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	var entries []ManifestEntry
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read manifest %s: %w", path, err)
+		}
+		e := ManifestEntry{
+			Action:        field(row, "action"),
+			Src:           field(row, "src"),
+			Dest:          field(row, "dest"),
+			Hash:          field(row, "hash"),
+			HashAlgorithm: field(row, "hash_algorithm"),
+			Error:         field(row, "error"),
+			SrcRoot:       field(row, "src_root"),
+			DestRoot:      field(row, "dest_root"),
+		}
+		e.Src = resolveManifestPath(e.Src, e.SrcRoot, dest)
+		e.Dest = resolveManifestPath(e.Dest, e.DestRoot, dest)
+		if s := field(row, "size"); s != "" {
+			if e.Size, err = strconv.ParseInt(s, 10, 64); err != nil {
+				return nil, fmt.Errorf("read manifest %s: invalid size %q", path, s)
+			}
+		}
+		if e.Hash != "" && e.HashAlgorithm == "" {
+			e.HashAlgorithm = legacyHashAlgorithm
+		}
+		entries = append(entries, e)
+	}
+}
+
+// FileHashWith returns the hex digest of the file at path under the named
+// algorithm, as recorded in manifests.
+func FileHashWith(ctx context.Context, path, algorithm string) (string, error) {
+	newHash, ok := hashAlgorithms[algorithm]
+	if !ok {
+		return "", fmt.Errorf("unknown hash algorithm %q", algorithm)
+	}
+	return hashFileWith(ctx, path, newHash)
+}
+
+// seedFromManifests loads the hashes earlier runs recorded for files in
+// the primary destination, so re-ingesting an overlapping source finds
+// what is already there without hashing the whole destination. Only
+// hashes of the run's algorithm are used, and files reclassify moved are
+// looked for where they went.
+func seedFromManifests(dests []*destination, algorithm string, warnf func(string, ...any)) error {
+	primary := dests[0]
+	paths, err := Manifests(primary.root)
+	if err != nil {
+		return fmt.Errorf("read manifests: %w", err)
+	}
+	moved, err := LoadRelocations(primary.root)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		entries, err := ReadManifest(path)
+		if err != nil {
+			warnf("skipping manifest: %v", err)
+			continue
+		}
+		for _, e := range entries {
+			if e.Hash == "" || e.Dest == "" || e.Action == EventError || e.HashAlgorithm != algorithm {
+				continue
+			}
+			category, rel, ok := primary.locate(moved.Where(e.Dest))
+			if !ok {
+				continue
+			}
+			for _, d := range dests {
+				d.archived[e.Hash] = filepath.Join(d.rootFor(category), rel)
+			}
+		}
+	}
+	return nil
+}
+
+// seedShared indexes the files archived in the other destinations others
+// as duplicates for every destination that does not hold them itself.
+// Files that are gone since their run are left out.
+func seedShared(dests []*destination, others []string, algorithm string, warnf func(string, ...any)) error {
+	for _, other := range others {
+		paths, err := Manifests(other)
+		if err != nil {
+			return fmt.Errorf("read manifests of %s: %w", other, err)
+		}
+		moved, err := LoadRelocations(other)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			entries, err := ReadManifest(path)
+			if err != nil {
+				warnf("skipping manifest: %v", err)
+				continue
+			}
+			for _, e := range entries {
+				if e.Hash == "" || e.Dest == "" || e.Action == EventError || e.HashAlgorithm != algorithm {
+					continue
+				}
+				archived := moved.Where(e.Dest)
+				if _, err := os.Stat(archived); err != nil {
+					continue
+				}
+				for _, d := range dests {
+					if _, own := d.archived[e.Hash]; !own {
+						if _, ok := d.hashIndex[e.Hash]; !ok {
+							d.hashIndex[e.Hash] = archived
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// locate splits a path under the destination into its category and the
+// path relative to the root the category's folder is created in.
+func (d *destination) locate(path string) (category, rel string, ok bool) {
+	within := func(root, path string) (string, bool) {
+		r, err := filepath.Rel(root, path)
+		if err != nil || r == "." || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+			return "", false
+		}
+		return r, true
+	}
+	for category, root := range d.roots {
+		if r, ok := within(filepath.Join(root, category), path); ok {
+			return category, filepath.Join(category, r), true
+		}
+	}
+	r, ok := within(d.root, path)
+	if !ok {
+		return "", "", false
+	}
+	category, _, _ = strings.Cut(filepath.ToSlash(r), "/")
+	if category == StateDir {
+		return "", "", false
+	}
+	return category, r, true
+}
+
+// archivedCopy returns the file an earlier run recorded with hash, after
+// checking it still holds that content.
+func (d *destination) archivedCopy(ctx context.Context, hash string, ops fileOps) (string, bool) {
+	path, ok := d.archived[hash]
+	if !ok {
+		return "", false
+	}
+	delete(d.archived, hash)
+	got, err := ops.hash(ctx, path)
+	return path, err == nil && got == hash
+}
@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun_SharedDedupe(t *testing.T) {
+	workspace := t.TempDir()
+	phone, camera := filepath.Join(workspace, "phone"), filepath.Join(workspace, "camera")
+	first, second := filepath.Join(workspace, "first"), filepath.Join(workspace, "second")
+	for _, dir := range []string{phone, camera} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(phone, "a.txt"), []byte("same"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(camera, "b.txt"), []byte("same"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(camera, "c.txt"), []byte("other"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{Categories: []Category{{Name: "documents", Extensions: []string{"txt"}}}}
+
+	if _, err := Run(t.Context(), Options{Config: cfg, Source: phone, Dest: first, NoSpaceCheck: true}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Run(t.Context(), Options{Config: cfg, Source: camera, Dest: second, SharedDedupe: []string{first}, NoSpaceCheck: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Copied != 1 || res.Duplicates != 1 {
+		t.Fatalf("expected 1 copied and 1 duplicate of the other destination, got %+v", res)
+	}
+	if _, err := os.Stat(filepath.Join(second, "documents", "b.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected b.txt to be left out as a duplicate, got %v", err)
+	}
+}
+
+func TestRun_RelativePaths(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest, mirror := filepath.Join(workspace, "dest"), filepath.Join(workspace, "mirror")
+	mustMkdir(t, filepath.Join(src, "trip"))
+	writeFile(t, src, "a.txt", "same")
+	writeFile(t, filepath.Join(src, "trip"), "b.txt", "same")
+	cfg := Config{Categories: []Category{{Name: "documents", Extensions: []string{"txt"}}}}
+
+	if _, err := Run(t.Context(), Options{Config: cfg, Source: src, Dest: dest, Mirrors: []string{mirror}, RelativePaths: true, NoSpaceCheck: true}); err != nil {
+		t.Fatal(err)
+	}
+	manifests, err := Manifests(dest)
+	if err != nil || len(manifests) != 1 {
+		t.Fatalf("expected 1 manifest, got %v (%v)", manifests, err)
+	}
+	data, err := os.ReadFile(manifests[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := strings.Split(strings.TrimSpace(string(data)), "\n")
+	want := []string{
+		"action,src,dest,size,hash,hash_algorithm,error,src_root,dest_root",
+		"copied,a.txt," + filepath.Join("documents", "a.txt") + ",4,",
+		"copied,a.txt," + filepath.Join("documents", "a.txt") + ",4,",
+		"skipped-duplicate," + filepath.Join("trip", "b.txt") + "," + filepath.Join("documents", "a.txt") + ",4,",
+		"skipped-duplicate," + filepath.Join("trip", "b.txt") + "," + filepath.Join("documents", "a.txt") + ",4,",
+	}
+	if len(rows) != len(want) || rows[0] != want[0] {
+		t.Fatalf("unexpected manifest:\n%s", data)
+	}
+	for i, row := range rows[1:] {
+		if !strings.HasPrefix(row, want[i+1]) {
+			t.Fatalf("row %d: expected %q..., got %q", i+1, want[i+1], row)
+		}
+	}
+	if !strings.HasSuffix(rows[1], ","+src+",.") || !strings.HasSuffix(rows[2], ","+src+","+mirror) {
+		t.Fatalf("expected the roots of the copies, got %q and %q", rows[1], rows[2])
+	}
+	warn, err := os.ReadFile(filepath.Join(dest, StateDir, "warn.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(warn)); got != filepath.Join("trip", "b.txt")+","+filepath.Join("documents", "a.txt")+","+src+","+dest {
+		t.Fatalf("unexpected warn.csv: %q", got)
+	}
+
+	// The destination is found again where it is mounted now.
+	moved := filepath.Join(workspace, "remounted")
+	if err := os.Rename(dest, moved); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ReadManifest(filepath.Join(moved, StateDir, "manifests", filepath.Base(manifests[0])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[0].Src != filepath.Join(src, "a.txt") || entries[0].Dest != filepath.Join(moved, "documents", "a.txt") {
+		t.Fatalf("expected paths joined to their roots, got %+v", entries[0])
+	}
+	if entries[1].Dest != filepath.Join(mirror, "documents", "a.txt") {
+		t.Fatalf("expected the mirror copy below the mirror, got %+v", entries[1])
+	}
+}
+
+func TestRun_AppendReportsKeepsEveryRun(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "a.txt", "same")
+	writeFile(t, src, "b.txt", "same")
+	cfg := Config{Categories: []Category{{Name: "documents", Extensions: []string{"txt"}}}}
+
+	var runIDs []string
+	for range 2 {
+		res, err := Run(t.Context(), Options{Config: cfg, Source: src, Dest: dest, AppendReports: true, NoSpaceCheck: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Either run skips b.txt as a duplicate of a.txt.
+		runIDs = append(runIDs, res.RunID)
+	}
+	if runIDs[0] == runIDs[1] {
+		t.Fatalf("expected two runs, got %q", runIDs)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dest, StateDir, "warn.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(rows) != 2 {
+		t.Fatalf("expected a row from each run, got:\n%s", data)
+	}
+	for i, row := range rows {
+		fields := strings.Split(row, ",")
+		if len(fields) != 4 || fields[0] != filepath.Join(src, "b.txt") || fields[2] != runIDs[i] || !strings.HasSuffix(fields[3], "Z") {
+			t.Fatalf("row %d: expected src, dest, run id %s and start time, got %q", i, runIDs[i], row)
+		}
+	}
+}
+
+func TestRun_ReportsDirIsLeftOutOfTheWalk(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	reports := filepath.Join(src, "reports")
+	mustMkdir(t, src)
+	writeFile(t, src, "a.txt", "same")
+	writeFile(t, src, "b.txt", "same")
+	cfg := Config{Categories: []Category{{Name: "documents", Extensions: []string{"txt", "csv"}}}}
+
+	for range 2 {
+		if _, err := Run(t.Context(), Options{Config: cfg, Source: src, Dest: dest, ReportsDir: reports, NoSpaceCheck: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(reports, "warn.csv")); err != nil {
+		t.Fatalf("expected warn.csv in the reports directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, StateDir, "warn.csv")); !os.IsNotExist(err) {
+		t.Fatalf("expected no warn.csv in the destination, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "documents", "warn.csv")); !os.IsNotExist(err) {
+		t.Fatalf("expected the second run to leave the reports out, got %v", err)
+	}
+}
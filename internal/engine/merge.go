@@ -0,0 +1,359 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// MergeOptions configures a merge of archives written by Run into Dest.
+type MergeOptions struct {
+	// Archives are merged in order; on a name collision with different
+	// content the file of the earlier archive keeps its name.
+	Archives []string
+	Dest     string
+
+	// DryRun writes nothing; the decisions are printed to Preview and
+	// emitted as events instead.
+	DryRun  bool
+	Preview io.Writer
+
+	Events Sink
+	// Warnf, if set, receives problems that do not fail the merge.
+	Warnf func(format string, args ...any)
+
+	// Args and Version are recorded in the audit log.
+	Args    []string
+	Version string
+}
+
+// Merge copies the archived files of every archive to the same relative
+// path in Dest. Content already in Dest, or merged from an earlier archive,
+// is skipped as a duplicate, and a different file under a taken name gets
+// a numbered name. The manifests and audit log entries of the archives are
+// carried over with their paths pointing into Dest, and the merge itself
+// is recorded as a run of Dest, with warn.csv and errors.csv written as
+// after a run. Thumbnails are not merged.
+func Merge(ctx context.Context, o MergeOptions) (res Result, err error) {
+	events := o.Events
+	if events == nil {
+		events = discardEvents{}
+	}
+	events = multiEvents{events, resultCounter{res: &res}}
+	warnf := o.Warnf
+	if warnf == nil {
+		warnf = func(string, ...any) {}
+	}
+	out := o.Preview
+	if out == nil {
+		out = io.Discard
+	}
+	for _, a := range o.Archives {
+		info, err := os.Stat(a)
+		if err != nil {
+			return res, fmt.Errorf("read archive: %w", err)
+		}
+		if !info.IsDir() {
+			return res, fmt.Errorf("%w: %s", ErrSourceNotDir, a)
+		}
+	}
+
+	dest := newDestination(o.Dest, false)
+	dests := []*destination{dest}
+	if err := seedFromManifests(dests, hashSHA256, warnf); err != nil {
+		return res, err
+	}
+	if !o.DryRun {
+		if err := os.MkdirAll(o.Dest, 0o755); err != nil {
+			return res, fmt.Errorf("create destination: %w", err)
+		}
+		rec, err := startRun(o.Dest, RunRecord{
+			Version:       o.Version,
+			Destinations:  []string{o.Dest},
+			Args:          o.Args,
+			HashAlgorithm: hashSHA256,
+		}, reportPaths{})
+		if err != nil {
+			return res, err
+		}
+		res.RunID = rec.record.ID
+		events = multiEvents{events, rec}
+		defer func() {
+			res.Status = runCompleted
+			switch {
+			case errors.Is(err, context.Canceled):
+				res.Status = runInterrupted
+			case err != nil:
+				res.Status = runFailed
+			}
+			if cerr := rec.close(res.Status, err); cerr != nil && err == nil {
+				err = cerr
+			}
+		}()
+	}
+
+	ops := fileOps{warnf: o.Warnf}
+	budget := &copyBudget{}
+	for _, archive := range o.Archives {
+		files, err := archivedFiles(archive)
+		if err != nil {
+			return res, err
+		}
+		merged := make(mergedPaths)
+		for _, f := range files {
+			if f.hash, err = ops.hash(ctx, f.srcPath); err != nil {
+				return res, err
+			}
+			if o.DryRun {
+				if err := preview(ctx, dests, f, ops, out, events); err != nil {
+					return res, err
+				}
+				continue
+			}
+			err := fanOut(ctx, dests, f, budget, ops, multiEvents{events, merged})
+			if skipsFile(err) {
+				dest.fail(f.srcPath, err)
+				events.Emit(errorEvent(f.srcPath, "", f.category, err))
+				continue
+			}
+			if err != nil {
+				return res, err
+			}
+		}
+		if o.DryRun {
+			continue
+		}
+		if err := mergeManifests(archive, o.Dest, merged); err != nil {
+			return res, err
+		}
+		if err := mergeHistory(archive, o.Dest); err != nil {
+			return res, err
+		}
+	}
+	events.Finish()
+	if o.DryRun {
+		return res, nil
+	}
+	for _, f := range dest.failed {
+		res.Failures = append(res.Failures, Failure{Src: f.srcPath, Dest: f.destPath, Err: f.err})
+	}
+	return res, dest.writeReports(fileOps{}, false, reportPaths{}, reportStamp{})
+}
+
+// archivedFiles lists the files below archive that Run placed there,
+// leaving out reports and the tool's own folders.
+func archivedFiles(archive string) ([]plannedFile, error) {
+	var files []plannedFile
+	err := filepath.WalkDir(archive, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if IsToolDir(d.Name()) && path != archive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		category, _, ok := ArchivedPath(archive, path)
+		if !ok || !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(archive, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, plannedFile{srcPath: path, name: d.Name(), info: info, category: category, relDir: filepath.Dir(rel)})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read archive: %w", err)
+	}
+	return files, nil
+}
+
+// mergedPaths is the Sink that maps every merged file to the path that
+// holds its content in the destination.
+type mergedPaths map[string]string
+
+func (m mergedPaths) Emit(ev Event) {
+	switch ev.Type {
+	case EventCopied, EventSkippedDuplicate, EventSkippedPresent:
+		m[ev.Src] = ev.Dest
+	}
+}
+
+func (mergedPaths) Finish() {}
+
+// mergeManifests copies the manifests of archive to dest, pointing every
+// row at where its file was merged to, also for files reclassify moved
+// in the archive. Rows of files no longer in the
+// archive are dropped; manifests dest already has are left alone, so an
+// interrupted merge can be run again.
+func mergeManifests(archive, dest string, merged mergedPaths) error {
+	paths, err := Manifests(archive)
+	if err != nil {
+		return fmt.Errorf("read manifests: %w", err)
+	}
+	moved, err := LoadRelocations(archive)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := mergeManifest(path, filepath.Join(dest, StateDir, "manifests", filepath.Base(path)), merged, moved); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mergeManifest(src, dst string, merged mergedPaths, moved Relocations) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("read manifest: %w", err)
+	}
+	defer in.Close()
+	r := csv.NewReader(in)
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return fmt.Errorf("read manifest %s: %w", src, err)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	col, rootCol := -1, -1
+	for i, name := range rows[0] {
+		switch name {
+		case "dest":
+			col = i
+		case "dest_root":
+			rootCol = i
+		}
+	}
+	archive, err := manifestDest(src)
+	if err != nil {
+		return fmt.Errorf("read manifest %s: %w", src, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("merge manifest: %w", err)
+	}
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("merge manifest: %w", err)
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	for i, row := range rows {
+		if i > 0 && col >= 0 && col < len(row) && row[col] != "" {
+			from := row[col]
+			if rootCol >= 0 && rootCol < len(row) {
+				// Merged rows point at absolute paths in dest.
+				from = resolveManifestPath(from, row[rootCol], archive)
+				row[rootCol] = ""
+			}
+			to, ok := merged[moved.Where(from)]
+			if !ok {
+				continue
+			}
+			row[col] = to
+		}
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("merge manifest: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("merge manifest: %w", err)
+	}
+	return f.Close()
+}
+
+// mergeHistory appends the audit log entries of archive to those of dest,
+// skipping runs dest already lists.
+func mergeHistory(archive, dest string) error {
+	logs, err := filepath.Glob(filepath.Join(archive, StateDir, "history", "*.jsonl"))
+	if err != nil {
+		return err
+	}
+	for _, src := range logs {
+		dst := filepath.Join(dest, StateDir, "history", filepath.Base(src))
+		known, err := historyIDs(dst)
+		if err != nil {
+			return err
+		}
+		lines, err := os.ReadFile(src)
+		if err != nil {
+			return fmt.Errorf("read audit log: %w", err)
+		}
+		var add []byte
+		for _, line := range bytes.Split(lines, []byte("\n")) {
+			id, ok := historyID(line)
+			if !ok || known[id] {
+				continue
+			}
+			known[id] = true
+			add = append(append(add, line...), '\n')
+		}
+		if len(add) == 0 {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return fmt.Errorf("write audit log: %w", err)
+		}
+		f, err := os.OpenFile(dst, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("write audit log: %w", err)
+		}
+		if _, err := f.Write(add); err != nil {
+			f.Close()
+			return fmt.Errorf("write audit log: %w", err)
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("write audit log: %w", err)
+		}
+	}
+	return nil
+}
+
+// historyIDs returns the ids of the runs in an audit log file.
+func historyIDs(path string) (map[string]bool, error) {
+	ids := make(map[string]bool)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ids, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read audit log: %w", err)
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if id, ok := historyID(line); ok {
+			ids[id] = true
+		}
+	}
+	return ids, nil
+}
+
+func historyID(line []byte) (string, bool) {
+	var rec struct {
+		ID string `json:"id"`
+	}
+	if json.Unmarshal(line, &rec) != nil || rec.ID == "" {
+		return "", false
+	}
+	return rec.ID, true
+}
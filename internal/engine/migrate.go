@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MigrateOptions configures a move of Dest's date folders from one layout
+// to another.
+type MigrateOptions struct {
+	Dest     string
+	From, To string
+
+	// DryRun moves nothing; the plan is printed to Preview instead.
+	DryRun  bool
+	Preview io.Writer
+}
+
+// MigrateDates moves every file in a date folder of layout From to the
+// folder of the same date under layout To, keeping what is below the date
+// folder, such as shard folders. Dates come from the folder names alone.
+// Moves are recorded in a move log that UndoMoves reverses, and date
+// folders left empty are removed. Categories with a root of their own are
+// not touched.
+func MigrateDates(ctx context.Context, o MigrateOptions) (res ReclassifyResult, err error) {
+	out := o.Preview
+	if out == nil {
+		out = io.Discard
+	}
+	for _, layout := range []string{o.From, o.To} {
+		if err := CheckDateLayout(layout); err != nil {
+			return res, err
+		}
+	}
+	files, err := archivedFiles(o.Dest)
+	if err != nil {
+		return res, err
+	}
+
+	ops := fileOps{}
+	d := newDestination(o.Dest, false)
+	var log *moveLog
+	var emptied []string
+	defer func() {
+		if log == nil {
+			return
+		}
+		if cerr := log.close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		removeEmptyDirs(o.Dest, emptied)
+	}()
+	n := strings.Count(o.From, "/") + 1
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		parts := strings.Split(filepath.ToSlash(f.relDir), "/")
+		if len(parts) < n+1 {
+			res.Unchanged++
+			continue
+		}
+		year, month, ok := parseDateDir(o.From, strings.Join(parts[1:n+1], "/"))
+		if !ok {
+			res.Unchanged++
+			continue
+		}
+		dir := filepath.Join(o.Dest, parts[0], dateDir(o.To, year, month), filepath.FromSlash(strings.Join(parts[n+1:], "/")))
+		if dir == filepath.Dir(f.srcPath) {
+			res.Unchanged++
+			continue
+		}
+
+		to := filepath.Join(dir, f.name)
+		if _, err := os.Lstat(to); err == nil || d.reserved.has(to) {
+			if f.hash, err = ops.hash(ctx, f.srcPath); err != nil {
+				return res, err
+			}
+			var present bool
+			if to, present, err = uniqueDestPath(ctx, dir, f.name, "", f.info.Size(), f.hash, ops.hash, d.reserved); err != nil {
+				return res, err
+			}
+			if present {
+				fmt.Fprintf(out, "duplicate %s = %s\n", f.srcPath, to)
+				res.Duplicates++
+				continue
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return res, fmt.Errorf("stat destination %s: %w", to, err)
+		}
+		d.reserved.add(to)
+		fmt.Fprintf(out, "move %s -> %s\n", f.srcPath, to)
+		res.Moved++
+		if o.DryRun {
+			continue
+		}
+		if log == nil {
+			if log, err = startMoveLog(o.Dest); err != nil {
+				return res, err
+			}
+			res.Moves = log.path
+		}
+		if err := moveFile(f.srcPath, to, log); err != nil {
+			return res, err
+		}
+		emptied = append(emptied, filepath.Dir(f.srcPath))
+	}
+	return res, nil
+}
+
+// removeEmptyDirs removes each of dirs and then its parents below the
+// category folder, as long as they are empty.
+func removeEmptyDirs(dest string, dirs []string) {
+	for _, dir := range dirs {
+		for {
+			rel, err := filepath.Rel(dest, dir)
+			if err != nil || !strings.Contains(filepath.ToSlash(rel), "/") {
+				break
+			}
+			if os.Remove(dir) != nil {
+				break
+			}
+			dir = filepath.Dir(dir)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// executableMagic maps the leading bytes of executables, which
+// http.DetectContentType leaves as application/octet-stream, to their MIME
+// types.
+var executableMagic = []struct {
+	magic    string
+	mimeType string
+}{
+	{"\x7fELF", "application/x-executable"},
+	{"MZ", "application/vnd.microsoft.portable-executable"},
+	{"\xfe\xed\xfa\xce", "application/x-mach-binary"},
+	{"\xfe\xed\xfa\xcf", "application/x-mach-binary"},
+	{"\xce\xfa\xed\xfe", "application/x-mach-binary"},
+	{"\xcf\xfa\xed\xfe", "application/x-mach-binary"},
+	{"#!", "text/x-shellscript"},
+}
+
+// SniffMIME returns the MIME type of the file at path going by its first
+// 512 bytes, without parameters such as the charset.
+func SniffMIME(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", fmt.Errorf("sniff %s: %w", path, err)
+	}
+	head = head[:n]
+	for _, m := range executableMagic {
+		if bytes.HasPrefix(head, []byte(m.magic)) {
+			return m.mimeType, nil
+		}
+	}
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return "application/octet-stream", nil
+	}
+	return mediaType, nil
+}
+
+// CheckMIMEPattern reports whether pattern is a MIME type such as
+// image/png or a wildcard such as image/* that AllowMIME and DenyMIME
+// accept.
+func CheckMIMEPattern(pattern string) error {
+	typ, sub, ok := strings.Cut(pattern, "/")
+	if !ok || typ == "" || sub == "" || strings.ContainsAny(pattern, " ;,") || (typ == "*" && sub != "*") {
+		return fmt.Errorf("%q is not a MIME type or a pattern such as image/*", pattern)
+	}
+	return nil
+}
+
+// mimeMatches reports whether mimeType matches one of patterns.
+func mimeMatches(patterns []string, mimeType string) bool {
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if p == "*/*" || p == mimeType || (strings.HasSuffix(p, "/*") && strings.HasPrefix(mimeType, strings.TrimSuffix(p, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludeType returns why the file at path is filtered out by its MIME
+// type, or "" to keep it. Files that cannot be read are kept, to fail
+// where they are copied.
+func (f Filter) excludeType(path string) string {
+	if len(f.AllowMIME) == 0 && len(f.DenyMIME) == 0 {
+		return ""
+	}
+	mimeType, err := SniffMIME(path)
+	if err != nil {
+		return ""
+	}
+	if mimeMatches(f.DenyMIME, mimeType) {
+		return fmt.Sprintf("MIME type %s denied by -deny-mime", mimeType)
+	}
+	if len(f.AllowMIME) > 0 && !mimeMatches(f.AllowMIME, mimeType) {
+		return fmt.Sprintf("MIME type %s not in -allow-mime", mimeType)
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package engine
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// A move log lists the files one reclassify or undo moved within a
+// destination, one "from,to" row each, in
+// <dest>/.classifier/moves/<id>.csv.
+const movesDir = "moves"
+
+// Move is one file moved within a destination.
+type Move struct {
+	From, To string
+}
+
+// moveLog appends the moves of one reclassify or undo as they happen.
+type moveLog struct {
+	path string
+	f    *os.File
+	w    *csv.Writer
+}
+
+func startMoveLog(dest string) (*moveLog, error) {
+	dir := filepath.Join(dest, StateDir, movesDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create move log: %w", err)
+	}
+	base := time.Now().UTC().Format("20060102T150405Z") + "-" + strconv.Itoa(os.Getpid())
+	for i := 1; ; i++ {
+		id := base
+		if i > 1 {
+			id += "-" + strconv.Itoa(i)
+		}
+		path := filepath.Join(dir, id+".csv")
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("create move log: %w", err)
+		}
+		l := &moveLog{path: path, f: f, w: csv.NewWriter(f)}
+		if err := l.add(Move{From: "from", To: "to"}); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return l, nil
+	}
+}
+
+// add records a move right away, so the log is complete up to the last
+// move even if the process dies.
+func (l *moveLog) add(m Move) error {
+	if err := l.w.Write([]string{m.From, m.To}); err != nil {
+		return fmt.Errorf("write move log: %w", err)
+	}
+	l.w.Flush()
+	if err := l.w.Error(); err != nil {
+		return fmt.Errorf("write move log: %w", err)
+	}
+	return nil
+}
+
+func (l *moveLog) close() error {
+	if err := l.f.Close(); err != nil {
+		return fmt.Errorf("write move log: %w", err)
+	}
+	return nil
+}
+
+// ReadMoves reads a move log.
+func ReadMoves(path string) ([]Move, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read move log: %w", err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	var moves []Move
+	for i := 0; ; i++ {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return moves, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read move log %s: %w", path, err)
+		}
+		if i > 0 {
+			moves = append(moves, Move{From: row[0], To: row[1]})
+		}
+	}
+}
+
+// Relocations follows files through the move logs of a destination.
+type Relocations map[string]string
+
+// LoadRelocations replays the move logs of dest, oldest first.
+func LoadRelocations(dest string) (Relocations, error) {
+	logs, err := filepath.Glob(filepath.Join(dest, StateDir, movesDir, "*.csv"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(logs)
+	// at maps every path that was moved to where it is now; from lists
+	// the original paths now at a path.
+	at := make(Relocations)
+	from := make(map[string][]string)
+	for _, log := range logs {
+		moves, err := ReadMoves(log)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range moves {
+			origins, ok := from[m.From]
+			if !ok {
+				origins = []string{m.From}
+			}
+			delete(from, m.From)
+			for _, o := range origins {
+				at[o] = m.To
+			}
+			from[m.To] = append(from[m.To], origins...)
+		}
+	}
+	return at, nil
+}
+
+// Where returns where the file recorded at path is now.
+func (r Relocations) Where(path string) string {
+	if to, ok := r[path]; ok {
+		return to
+	}
+	return path
+}
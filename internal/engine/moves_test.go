@@ -0,0 +1,36 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRelocations(t *testing.T) {
+	dest := t.TempDir()
+	dir := filepath.Join(dest, StateDir, movesDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	logs := map[string]string{
+		"20240101T000000Z-1.csv": "from,to\n/d/a,/d/b\n/d/c,/d/e\n",
+		"20240102T000000Z-1.csv": "from,to\n/d/b,/d/x\n",
+		// An undo of the second log.
+		"20240103T000000Z-1.csv": "from,to\n/d/x,/d/b\n",
+		"20240104T000000Z-1.csv": "from,to\n/d/e,/d/c\n",
+	}
+	for name, data := range logs {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	moved, err := LoadRelocations(dest)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	for path, want := range map[string]string{"/d/a": "/d/b", "/d/b": "/d/b", "/d/c": "/d/c", "/d/z": "/d/z"} {
+		if got := moved.Where(path); got != want {
+			t.Errorf("Where(%s) = %s, want %s", path, got, want)
+		}
+	}
+}
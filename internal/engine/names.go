@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// pathSet holds destination paths. On filesystems that fold case,
+// IMG_001.JPG and img_001.jpg are the same file, so they are the same
+// entry here too. With nfc, names that differ only in their Unicode
+// normalization, like the NFD names macOS produces and the NFC ones from
+// Windows, are the same name as well.
+type pathSet struct {
+	fold  bool
+	nfc   bool
+	paths map[string]bool
+	// listings caches the names on disk per directory by their NFC form,
+	// for nfc.
+	listings map[string]map[string]string
+}
+
+func newPathSet(fold, nfc bool) pathSet {
+	return pathSet{fold: fold, nfc: nfc, paths: make(map[string]bool), listings: make(map[string]map[string]string)}
+}
+
+func (s pathSet) key(path string) string {
+	if s.nfc {
+		path = norm.NFC.String(path)
+	}
+	if s.fold {
+		return strings.ToLower(path)
+	}
+	return path
+}
+
+// onDisk returns the file already in the destination that path names,
+// spelled as it is on disk. Without nfc that is path itself.
+func (s pathSet) onDisk(path string) (string, error) {
+	if !s.nfc {
+		return path, nil
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return path, nil
+	}
+	dir := filepath.Dir(path)
+	names, ok := s.listings[dir]
+	if !ok {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("list destination %s: %w", dir, err)
+		}
+		names = make(map[string]string, len(entries))
+		for _, e := range entries {
+			names[norm.NFC.String(e.Name())] = e.Name()
+		}
+		s.listings[dir] = names
+	}
+	if name, ok := names[norm.NFC.String(filepath.Base(path))]; ok {
+		return filepath.Join(dir, name), nil
+	}
+	return path, nil
+}
+
+func (s pathSet) has(path string) bool { return s.paths[s.key(path)] }
+
+func (s pathSet) add(path string) { s.paths[s.key(path)] = true }
+
+// foldsCase reports whether the filesystem holding dir treats names that
+// differ only in case as the same. It looks dir, or its nearest existing
+// ancestor with a cased name, up under the case-swapped name, so nothing
+// is written. When no such directory exists it goes by the OS default.
+func foldsCase(dir string) bool {
+	for p := filepath.Clean(dir); ; p = filepath.Dir(p) {
+		base := filepath.Base(p)
+		if swapped := swapCase(base); swapped != base {
+			if info, err := os.Stat(p); err == nil {
+				other, err := os.Stat(filepath.Join(filepath.Dir(p), swapped))
+				return err == nil && os.SameFile(info, other)
+			}
+		}
+		if filepath.Dir(p) == p {
+			break
+		}
+	}
+	return runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+}
+
+func swapCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
+
+// maxNameUnits bounds sanitized file names in UTF-16 code units, how NTFS
+// and exFAT count their 255-unit limit, leaving room for the _N suffix
+// of a name collision.
+const maxNameUnits = 240
+
+// windowsReservedNames cannot be used as file names on Windows, with or
+// without an extension.
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// How copies are named when another file of the same name is in their
+// folder.
+const (
+	// NameConflictsSuffix numbers them: IMG.jpg, IMG_1.jpg, IMG_2.jpg.
+	NameConflictsSuffix = "suffix"
+	// NameConflictsFolder adds the name of their source folder first, so
+	// IMG.jpg from holiday/ becomes IMG__holiday.jpg, then
+	// IMG__holiday_1.jpg.
+	NameConflictsFolder = "folder"
+)
+
+// NameConflictModes lists the accepted values of Options.NameConflicts.
+var NameConflictModes = []string{NameConflictsSuffix, NameConflictsFolder}
+
+// maxFolderSlug caps the runes of a folder slug, so names stay short.
+const maxFolderSlug = 32
+
+// folderSlug turns a folder name into part of a file name: letters and
+// digits are kept, lower-cased, and every run of anything else becomes a
+// single -.
+func folderSlug(name string) string {
+	var b strings.Builder
+	n, gap := 0, false
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			gap = true
+			continue
+		}
+		if n >= maxFolderSlug {
+			break
+		}
+		if gap && n > 0 {
+			b.WriteByte('-')
+			n++
+		}
+		gap = false
+		b.WriteRune(unicode.ToLower(r))
+		n++
+	}
+	return b.String()
+}
+
+// sanitizeName makes name valid on NTFS and exFAT: characters they reject
+// become _, trailing dots and spaces are dropped, device names like CON
+// get a leading _ and long names are shortened, keeping the extension.
+func sanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "_"
+	}
+
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if windowsReservedNames[strings.ToUpper(strings.TrimRight(base, ". "))] {
+		base = "_" + base
+	}
+	if utf16Len(ext) > maxNameUnits/2 {
+		// An absurd extension is kept as part of the name instead.
+		base, ext = base+ext, ""
+	}
+	if utf16Len(base)+utf16Len(ext) > maxNameUnits {
+		for utf16Len(base)+utf16Len(ext) > maxNameUnits {
+			_, size := utf8.DecodeLastRuneInString(base)
+			base = base[:len(base)-size]
+		}
+		if base = strings.TrimRight(base, ". "); base == "" {
+			base = "_"
+		}
+	}
+	return base + ext
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeName(t *testing.T) {
+	long := strings.Repeat("あ", 300)
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"valid", "IMG_0001.JPG", "IMG_0001.JPG"},
+		{"illegal characters", `a:b?c*d<e>f|g"h\i.txt`, "a_b_c_d_e_f_g_h_i.txt"},
+		{"control characters", "tab\there.txt", "tab_here.txt"},
+		{"trailing dots and spaces", "notes. . ", "notes"},
+		{"inner dots kept", "archive..txt", "archive..txt"},
+		{"device name", "con.txt", "_con.txt"},
+		{"device name without extension", "NUL", "_NUL"},
+		{"only dots", "...", "_"},
+		{"long name keeps extension", long + ".jpeg", strings.Repeat("あ", maxNameUnits-5) + ".jpeg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeName(tt.in); got != tt.want {
+				t.Fatalf("sanitizeName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFolderSlug(t *testing.T) {
+	for in, want := range map[string]string{
+		"nested":                "nested",
+		"Summer Holiday 2024":   "summer-holiday-2024",
+		"  --odd__name!! ":      "odd-name",
+		"写真":                    "写真",
+		"...":                   "",
+		strings.Repeat("a", 40): strings.Repeat("a", maxFolderSlug),
+	} {
+		if got := folderSlug(in); got != want {
+			t.Errorf("folderSlug(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRun_NameConflictsByFolder(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, filepath.Join(src, "nested"))
+	mustMkdir(t, filepath.Join(src, "other"))
+	writeFile(t, src, "alpha.txt", "one")
+	writeFile(t, filepath.Join(src, "nested"), "alpha.txt", "two")
+	writeFile(t, filepath.Join(src, "other"), "alpha.txt", "three")
+	cfg := Config{Categories: []Category{{Name: "documents", Extensions: []string{"txt"}}}}
+
+	for range 2 {
+		if _, err := Run(t.Context(), Options{Config: cfg, Source: src, Dest: dest, NameConflicts: NameConflictsFolder, NoSpaceCheck: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, want := range map[string]string{
+		"alpha.txt":         "one",
+		"alpha__nested.txt": "two",
+		"alpha__other.txt":  "three",
+	} {
+		if data, err := os.ReadFile(filepath.Join(dest, "documents", name)); err != nil || string(data) != want {
+			t.Errorf("%s: got %q (%v), want %q", name, data, err, want)
+		}
+	}
+	if entries, err := os.ReadDir(filepath.Join(dest, "documents")); err != nil || len(entries) != 3 {
+		t.Fatalf("expected the second run to copy nothing again, got %v (%v)", entries, err)
+	}
+
+	if _, err := Run(t.Context(), Options{Config: cfg, Source: src, Dest: dest, NameConflicts: "nested", NoSpaceCheck: true}); err == nil {
+		t.Fatal("expected an unknown mode to be refused")
+	}
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrSourceOverlapsDestination is returned by Run when the source lies
+// where the run writes, so that it would classify its own copies.
+var ErrSourceOverlapsDestination = errors.New("source overlaps a destination")
+
+// overlapTarget is a directory a run writes to. Inside shared ones, the
+// destinations and mirrors, only the category and tool folders are
+// written; a drop folder such as <dest>/inbox may be the source.
+type overlapTarget struct {
+	dir    string
+	shared bool
+}
+
+// sourceOverlaps checks the source of o against every directory the run
+// writes to, roots holding the category roots. It returns those inside
+// the source, as paths below o.Source the walk leaves out, and fails with
+// ErrSourceOverlapsDestination when the source is one of them or lies
+// inside one. Categories from o.Categories are only known by name once
+// files are resolved, so a source inside their folders is not caught.
+func sourceOverlaps(o Options, roots map[string]string, warnf func(format string, args ...any)) ([]string, error) {
+	if o.Source == "" {
+		return nil, nil
+	}
+	targets := []overlapTarget{{dir: o.Dest, shared: true}}
+	for _, m := range o.Mirrors {
+		targets = append(targets, overlapTarget{dir: m, shared: true})
+	}
+	for _, root := range roots {
+		targets = append(targets, overlapTarget{dir: root})
+	}
+	if o.ReportsDir != "" {
+		targets = append(targets, overlapTarget{dir: o.ReportsDir})
+	}
+
+	src := resolvePath(o.Source)
+	folders := categoryFolders(o.Config)
+	var skip []string
+	for _, t := range targets {
+		dir := resolvePath(t.dir)
+		switch {
+		case dir == src:
+			return nil, fmt.Errorf("%w: %s is both the source and a destination", ErrSourceOverlapsDestination, t.dir)
+		case within(src, dir):
+			rel, _ := filepath.Rel(src, dir)
+			skip = append(skip, filepath.Join(o.Source, rel))
+			warnf("%s is inside the source; leaving it out", t.dir)
+		case within(dir, src):
+			rel, _ := filepath.Rel(dir, src)
+			top, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+			if !t.shared || IsToolDir(top) || folders[top] {
+				return nil, fmt.Errorf("%w: %s is inside %s, where copies are written", ErrSourceOverlapsDestination, o.Source, t.dir)
+			}
+		}
+	}
+	return skip, nil
+}
+
+// categoryFolders returns the names of the folders the categories of cfg
+// are filed in below a destination.
+func categoryFolders(cfg Config) map[string]bool {
+	folders := map[string]bool{defaultCategory(cfg): true}
+	for _, c := range cfg.Categories {
+		folders[c.Name] = true
+	}
+	for _, name := range cfg.Aliases {
+		folders[name] = true
+	}
+	return folders
+}
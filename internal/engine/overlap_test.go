@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun_DestinationInsideTheSourceIsLeftOut(t *testing.T) {
+	src := t.TempDir()
+	dest := filepath.Join(src, "archive")
+	writeFile(t, src, "a.txt", "alpha")
+	cfg := Config{Categories: []Category{{Name: "documents", Extensions: []string{"txt"}}}}
+
+	for run := range 2 {
+		var warnings []string
+		res, err := Run(t.Context(), Options{Config: cfg, Source: src, Dest: dest, NoSpaceCheck: true, Warnf: func(format string, args ...any) {
+			warnings = append(warnings, fmt.Sprintf(format, args...))
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Copied != 1-run || res.Duplicates != 0 {
+			t.Fatalf("run %d: expected the copies left out of the walk, got %d copied and %d duplicates", run, res.Copied, res.Duplicates)
+		}
+		if len(warnings) != 1 || warnings[0] != dest+" is inside the source; leaving it out" {
+			t.Fatalf("run %d: unexpected warnings %q", run, warnings)
+		}
+	}
+}
+
+func TestRun_RefusesASourceWhereCopiesAreWritten(t *testing.T) {
+	workspace := t.TempDir()
+	dest := filepath.Join(workspace, "dest")
+	cfg := Config{Categories: []Category{{Name: "documents", Extensions: []string{"txt"}}}}
+	rooted := Config{Categories: []Category{{Name: "documents", Extensions: []string{"txt"}, Root: filepath.Join(workspace, "docs")}}}
+	for _, tt := range []struct {
+		name string
+		o    Options
+		src  string
+	}{
+		{"the destination", Options{Config: cfg}, dest},
+		{"a mirror", Options{Config: cfg, Mirrors: []string{filepath.Join(workspace, "mirror")}}, filepath.Join(workspace, "mirror")},
+		{"a category folder", Options{Config: cfg}, filepath.Join(dest, "documents", "2024")},
+		{"the default category folder", Options{Config: cfg}, filepath.Join(dest, "others")},
+		{"the state folder", Options{Config: cfg}, filepath.Join(dest, StateDir)},
+		{"a category root", Options{Config: rooted}, filepath.Join(workspace, "docs", "old")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mustMkdir(t, tt.src)
+			tt.o.Source, tt.o.Dest, tt.o.NoSpaceCheck = tt.src, dest, true
+			if _, err := Run(t.Context(), tt.o); !errors.Is(err, ErrSourceOverlapsDestination) {
+				t.Fatalf("expected ErrSourceOverlapsDestination, got %v", err)
+			}
+		})
+	}
+
+	inbox := filepath.Join(dest, "inbox")
+	mustMkdir(t, inbox)
+	writeFile(t, inbox, "a.txt", "alpha")
+	res, err := Run(t.Context(), Options{Config: cfg, Source: inbox, Dest: dest, NoSpaceCheck: true})
+	if err != nil {
+		t.Fatalf("expected a drop folder inside the destination to be allowed, got %v", err)
+	}
+	if res.Copied != 1 {
+		t.Fatalf("expected a.txt copied from the drop folder, got %d", res.Copied)
+	}
+}
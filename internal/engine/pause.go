@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"context"
+	"sync"
+)
+
+// Pauser lets a running job be paused and resumed between files without
+// aborting it. A nil Pauser never pauses.
+type Pauser struct {
+	mu      sync.Mutex
+	resumed chan struct{}
+}
+
+// NewPauser returns a Pauser that is not paused.
+func NewPauser() *Pauser {
+	return &Pauser{}
+}
+
+// Pause makes the next wait block until Resume is called. It reports whether
+// the state changed.
+func (p *Pauser) Pause() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.resumed != nil {
+		return false
+	}
+	p.resumed = make(chan struct{})
+	return true
+}
+
+// Resume releases waiters. It reports whether the job was paused.
+func (p *Pauser) Resume() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.resumed == nil {
+		return false
+	}
+	close(p.resumed)
+	p.resumed = nil
+	return true
+}
+
+// wait blocks while the job is paused, giving up when ctx is cancelled.
+func (p *Pauser) wait(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	ch := p.resumed
+	p.mu.Unlock()
+	if ch == nil {
+		return nil
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPauser_BlocksUntilResumed(t *testing.T) {
+	p := NewPauser()
+	ctx := context.Background()
+	if err := p.wait(ctx); err != nil { // not paused: must not block
+		t.Fatalf("unexpected wait error: %v", err)
+	}
+
+	if !p.Pause() {
+		t.Fatalf("expected first pause to change state")
+	}
+	if p.Pause() {
+		t.Fatalf("expected repeated pause to be a no-op")
+	}
+
+	released := make(chan struct{})
+	go func() {
+		_ = p.wait(ctx)
+		close(released)
+	}()
+
+	select {
+	case <-released:
+		t.Fatalf("wait returned while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if !p.Resume() {
+		t.Fatalf("expected resume to change state")
+	}
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatalf("wait did not return after resume")
+	}
+	if p.Resume() {
+		t.Fatalf("expected resume without pause to be a no-op")
+	}
+}
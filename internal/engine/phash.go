@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"os"
+)
+
+// PerceptualHash decodes a GIF, JPEG or PNG file and returns its difference
+// hash: the image is shrunk to 9x8 grey pixels and every bit tells whether
+// a pixel is brighter than its right neighbour. Re-encoded, resized or
+// slightly edited copies of a picture get hashes that differ in few bits.
+func PerceptualHash(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return 0, fmt.Errorf("decode %s: %w", path, err)
+	}
+	grey := greyGrid(img, 9, 8)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if grey[y*9+x] > grey[y*9+x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash, nil
+}
+
+// Similarity scores two perceptual hashes from 0, unrelated, to 1, alike.
+func Similarity(a, b uint64) float64 {
+	return 1 - float64(bits.OnesCount64(a^b))/64
+}
+
+// greyGrid shrinks img to w by h pixels, ignoring its aspect ratio, and
+// returns their luminance row by row, averaging the source pixels behind
+// each one.
+func greyGrid(img image.Image, w, h int) []uint64 {
+	b := img.Bounds()
+	out := make([]uint64, w*h)
+	for y := 0; y < h; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/h, b.Min.Y+(y+1)*b.Dy()/h
+		for x := 0; x < w; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/w, b.Min.X+(x+1)*b.Dx()/w
+			var sum, n uint64
+			for sy := y0; sy < max(y1, y0+1); sy++ {
+				for sx := x0; sx < max(x1, x0+1); sx++ {
+					r, g, bl, _ := img.At(sx, sy).RGBA()
+					sum += (299*uint64(r) + 587*uint64(g) + 114*uint64(bl)) / 1000
+					n++
+				}
+			}
+			out[y*w+x] = sum / n
+		}
+	}
+	return out
+}
@@ -0,0 +1,25 @@
+package engine
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// isICloudStub reports whether name is the stand-in iCloud Drive leaves for
+// a file that is not downloaded: .IMG_0001.JPG.icloud for IMG_0001.JPG.
+// Reading it does not download the file, so it is always skipped.
+func isICloudStub(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".icloud")
+}
+
+// placeholder returns why info is a cloud placeholder that would be skipped,
+// or "" for a file whose content is on disk.
+func (f Filter) placeholder(info fs.FileInfo) string {
+	if isICloudStub(info.Name()) {
+		return "iCloud placeholder (not downloaded)"
+	}
+	if !f.HydratePlaceholders && hasPlaceholderAttribute(info) {
+		return "cloud placeholder (not downloaded)"
+	}
+	return ""
+}
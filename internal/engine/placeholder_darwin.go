@@ -0,0 +1,16 @@
+package engine
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// sfDataless is the st_flags bit of a file whose content was evicted to
+// iCloud Drive or a File Provider; reading it downloads it.
+const sfDataless = 0x40000000
+
+// hasPlaceholderAttribute reports whether the file is dataless.
+func hasPlaceholderAttribute(info fs.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && st.Flags&sfDataless != 0
+}
@@ -0,0 +1,11 @@
+//go:build !windows && !darwin
+
+package engine
+
+import "io/fs"
+
+// hasPlaceholderAttribute reports whether the file is an online-only
+// placeholder; no sync client marks files that way here.
+func hasPlaceholderAttribute(fs.FileInfo) bool {
+	return false
+}
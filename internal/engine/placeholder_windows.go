@@ -0,0 +1,19 @@
+package engine
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+const (
+	fileAttributeOffline            = 0x1000
+	fileAttributeRecallOnOpen       = 0x40000
+	fileAttributeRecallOnDataAccess = 0x400000
+)
+
+// hasPlaceholderAttribute reports whether the file is online-only, as
+// OneDrive and other cloud sync clients mark them; reading it downloads it.
+func hasPlaceholderAttribute(info fs.FileInfo) bool {
+	attrs, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	return ok && attrs.FileAttributes&(fileAttributeOffline|fileAttributeRecallOnOpen|fileAttributeRecallOnDataAccess) != 0
+}
@@ -0,0 +1,677 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/sky0621/classifier/internal/trace"
+)
+
+// DefaultMinImageBytes is the size below which images are skipped as too
+// small to keep.
+const DefaultMinImageBytes int64 = 1 << 20 // 1 MiB
+
+// plannedFile is a source file that passed classification and filtering and
+// will be handed to the destinations.
+type plannedFile struct {
+	srcPath  string
+	name     string
+	info     fs.FileInfo
+	hash     string
+	category string
+	relDir   string
+	// formerDirs are where relDir was before its category was renamed;
+	// copies found there count as present.
+	formerDirs []string
+	// keepDuplicate copies the file even where a copy of its content was
+	// already placed, as asked by Options.ResolveDuplicate.
+	keepDuplicate bool
+}
+
+// planner classifies and hashes source files without writing anything, so
+// the whole run can be checked before the first copy.
+type planner struct {
+	resolver CategoryResolver
+	dates    DateResolver
+	// defaultCategory takes the files resolver has no category for.
+	defaultCategory string
+	// aliases renames categories from resolver and defaultCategory.
+	aliases categoryAliases
+	// dateLayout names the date folders; empty means DefaultDateLayout.
+	dateLayout string
+	// alphabetical holds the categories that file undated files by their
+	// first letter.
+	alphabetical map[string]bool
+	// buckets reroutes videos of a category by their header.
+	buckets map[string][]VideoBucket
+	// Images below minImageBytes, or with fewer pixels than
+	// minImageWidth by minImageHeight, are skipped; zero turns a check off.
+	minImageBytes                 int64
+	minImageWidth, minImageHeight int
+	// minSizes holds the size minimums of the config by category.
+	minSizes map[string]minSizes
+	// minDurations holds the min_duration of the config by category.
+	minDurations map[string]time.Duration
+	// cp holds files dealt with by an interrupted run; they are left out.
+	cp     *checkpoint
+	pause  *Pauser
+	ops    fileOps
+	events Sink
+	filter Filter
+	// excludes are the exclude patterns of the config.
+	excludes []string
+	// renames maps lower-cased source extensions to those of the copies.
+	renames map[string]string
+	// skipDirs are directories the walk leaves out: the destinations and
+	// reports directory inside the source.
+	skipDirs []string
+
+	// failed lists files that could not be planned but did not stop the run.
+	failed []failedEntry
+	// filtered lists files left out by filter.
+	filtered []filteredEntry
+	// unknown tallies the extensions that fell into the default category.
+	unknown map[string]*unknownExtension
+
+	// limit stops planning after this many files; with sample they are
+	// picked at random from the whole source instead.
+	limit  int
+	sample bool
+	// order is one of ProcessingOrders, empty meaning lexical; it decides which copy of a
+	// duplicate is kept and what -max-bytes gets to first.
+	order string
+	// workers hashes this many files at once; 0 or 1 hashes them one by one
+	// as they are found.
+	workers int
+	// nfc names destination files in Unicode NFC.
+	nfc bool
+	// sanitize makes destination file names valid on NTFS and exFAT.
+	sanitize bool
+	// hashes, when set, saves hashing files unchanged since an earlier
+	// run.
+	hashes *hashCache
+	// forks is one of ForkModes, empty meaning ForksCopy; appleDouble
+	// maps files to the AppleDouble files to copy along with them.
+	forks       string
+	appleDouble map[string]string
+
+	planned    []plannedFile
+	candidates []candidate
+	seen       int
+}
+
+// newPlanner sets a planner up with the rules of o.Config, the resolvers
+// and the filters of o. The caller fills in its checkpoint, events and file
+// operations.
+func newPlanner(o Options) (*planner, error) {
+	resolver := o.Categories
+	if resolver == nil {
+		resolver = newCategoryResolver(o.Config)
+	}
+	dates := o.Dates
+	if dates == nil {
+		builtin, err := newDateResolver(o.Config.DatePatterns)
+		if err != nil {
+			return nil, err
+		}
+		dates = builtin
+	}
+	alphabetical, err := alphabeticalCategories(o.Config)
+	if err != nil {
+		return nil, err
+	}
+	layout, err := dateLayout(o.Config)
+	if err != nil {
+		return nil, err
+	}
+	minImageBytes := o.MinImageBytes
+	if minImageBytes == 0 {
+		minImageBytes = DefaultMinImageBytes
+	}
+	buckets, err := videoBuckets(o.Config)
+	if err != nil {
+		return nil, err
+	}
+	sizes, err := minSizeRules(o.Config)
+	if err != nil {
+		return nil, err
+	}
+	durations, err := minDurations(o.Config)
+	if err != nil {
+		return nil, err
+	}
+	excludes, err := excludePatterns(o.Config)
+	if err != nil {
+		return nil, err
+	}
+	renames, err := extensionRenames(o.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &planner{
+		resolver:        resolver,
+		dates:           dates,
+		defaultCategory: defaultCategory(o.Config),
+		aliases:         categoryAliases(o.Config.Aliases),
+		dateLayout:      layout,
+		alphabetical:    alphabetical,
+		buckets:         buckets,
+		minImageBytes:   minImageBytes,
+		minImageWidth:   o.MinImageWidth,
+		minImageHeight:  o.MinImageHeight,
+		minSizes:        sizes,
+		minDurations:    durations,
+		excludes:        excludes,
+		renames:         renames,
+		filter:          o.Filter,
+		limit:           o.Limit,
+		sample:          o.Sample,
+		order:           o.Order,
+		workers:         o.Workers,
+		nfc:             o.NormalizeNames,
+		sanitize:        o.SanitizeNames,
+		forks:           o.Forks,
+	}, nil
+}
+
+func (p *planner) build(ctx context.Context, src string) ([]plannedFile, error) {
+	ctx, walkSpan := trace.Start(ctx, "walk", map[string]any{"classifier.source": src})
+	err := p.walk(ctx, src, p.add)
+	return p.done(ctx, walkSpan, err)
+}
+
+// walk calls visit for every file below src that is not hidden, excluded
+// or deeper than the filter allows.
+func (p *planner) walk(ctx context.Context, src string, visit func(context.Context, string, fs.FileInfo) error) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("stat source entry %s: %w", path, err)
+		}
+		if path != src && (p.filter.hidden(d.Name(), info) || p.excluded(d.Name())) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if slices.Contains(p.skipDirs, path) {
+				return filepath.SkipDir
+			}
+			if p.filter.MaxDepth > 0 && path != src && depthBelow(src, path) >= p.filter.MaxDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return visit(ctx, path, info)
+	})
+}
+
+// excluded reports whether name matches an exclude pattern of the config.
+func (p *planner) excluded(name string) bool {
+	for _, pattern := range p.excludes {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// depthBelow counts the path elements of path below root.
+func depthBelow(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// buildFrom plans an explicit list of files instead of walking a directory.
+// Files that cannot be read are reported as failed rather than ending the
+// run, like files that time out.
+func (p *planner) buildFrom(ctx context.Context, paths []string) ([]plannedFile, error) {
+	ctx, walkSpan := trace.Start(ctx, "walk", map[string]any{"classifier.files": len(paths)})
+	var err error
+	for _, path := range paths {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		info, lerr := os.Lstat(path)
+		if lerr != nil {
+			p.failed = append(p.failed, failedEntry{srcPath: path, err: lerr})
+			p.events.Emit(errorEvent(path, "", "", lerr))
+			continue
+		}
+		if p.filter.hidden(filepath.Base(path), info) || p.excluded(filepath.Base(path)) {
+			continue
+		}
+		if err = p.add(ctx, path, info); err != nil {
+			break
+		}
+	}
+	return p.done(ctx, walkSpan, err)
+}
+
+// errLimitReached stops the walk once -limit files are planned.
+var errLimitReached = errors.New("file limit reached")
+
+// candidate is a classified file waiting to be hashed, with its position in
+// the walk.
+type candidate struct {
+	seq int
+	f   plannedFile
+}
+
+// Processing orders for -order.
+const (
+	OrderLexical = "lexical"
+	OrderNewest  = "newest"
+	OrderLargest = "largest"
+)
+
+// ProcessingOrders are the valid values of Options.Order.
+var ProcessingOrders = []string{OrderLexical, OrderNewest, OrderLargest}
+
+// add plans one source file. With -sample, an -order other than the walk
+// order or several workers, the file only becomes a candidate that done
+// picks and hashes.
+func (p *planner) add(ctx context.Context, path string, info fs.FileInfo) error {
+	f := p.classify(ctx, path, info)
+	if f == nil {
+		return nil
+	}
+	if p.sample || p.order == OrderNewest || p.order == OrderLargest || p.workers > 1 {
+		p.seen++
+		c := candidate{seq: p.seen, f: *f}
+		switch {
+		case !p.sample || len(p.candidates) < p.limit:
+			p.candidates = append(p.candidates, c)
+			if !p.sample && p.limit > 0 && len(p.candidates) >= p.limit && p.order != OrderNewest && p.order != OrderLargest {
+				return errLimitReached
+			}
+		default:
+			// Reservoir sampling keeps every file equally likely to be
+			// picked without knowing their number in advance.
+			if j := rand.IntN(p.seen); j < p.limit {
+				p.candidates[j] = c
+			}
+		}
+		return nil
+	}
+	ok, err := p.hashFile(ctx, f)
+	if ok {
+		p.planned = append(p.planned, *f)
+		if p.limit > 0 && len(p.planned) >= p.limit {
+			return errLimitReached
+		}
+	}
+	return err
+}
+
+// done puts the candidates in processing order, keeps the first -limit of
+// them, hashes them and returns the plan.
+func (p *planner) done(ctx context.Context, walkSpan *trace.Span, err error) ([]plannedFile, error) {
+	if errors.Is(err, errLimitReached) {
+		err = nil
+	}
+	if err == nil && len(p.candidates) > 0 {
+		sort.SliceStable(p.candidates, func(i, j int) bool {
+			a, b := p.candidates[i], p.candidates[j]
+			switch p.order {
+			case OrderNewest:
+				if ta, tb := a.f.info.ModTime(), b.f.info.ModTime(); !ta.Equal(tb) {
+					return ta.After(tb)
+				}
+			case OrderLargest:
+				if sa, sb := a.f.info.Size(), b.f.info.Size(); sa != sb {
+					return sa > sb
+				}
+			}
+			return a.seq < b.seq
+		})
+		if p.limit > 0 && len(p.candidates) > p.limit {
+			p.candidates = p.candidates[:p.limit]
+		}
+		if p.workers > 1 {
+			err = p.hashParallel(ctx)
+		} else {
+			for _, c := range p.candidates {
+				ok, herr := p.hashFile(ctx, &c.f)
+				if herr != nil {
+					err = herr
+					break
+				}
+				if ok {
+					p.planned = append(p.planned, c.f)
+				}
+			}
+		}
+	}
+	walkSpan.Finish(err)
+	if err != nil {
+		return nil, err
+	}
+	return p.planned, nil
+}
+
+// classify decides where a source file goes. It returns nil for files that
+// are left out of the run.
+func (p *planner) classify(ctx context.Context, path string, info fs.FileInfo) *plannedFile {
+	if !info.Mode().IsRegular() {
+		// Skip non-regular files (symlinks, devices, etc.).
+		return nil
+	}
+	if p.cp.done(path, info) {
+		return nil
+	}
+	if IsAppleDouble(filepath.Base(path)) && hasAppleDoubleHeader(path) {
+		p.noteAppleDouble(path, info)
+		return nil
+	}
+	p.events.Emit(Event{Type: EventDiscovered, Src: path, Size: info.Size()})
+	reason := p.filter.exclude(info)
+	if reason == "" {
+		reason = p.filter.excludeType(path)
+	}
+	if reason != "" {
+		p.filtered = append(p.filtered, filteredEntry{srcPath: path, reason: reason})
+		p.events.Emit(Event{Type: EventSkippedFiltered, Src: path, Size: info.Size(), Reason: reason})
+		return nil
+	}
+
+	name := filepath.Base(path)
+	_, classifySpan := trace.Start(ctx, "classify", map[string]any{"file.path": path, "file.size": info.Size()})
+	category, known := p.resolver.ResolveCategory(path)
+	if !known || category == "" {
+		category = p.defaultCategory
+		p.noteUnknown(path)
+	} else if err := categoryDir(category); err != nil {
+		classifySpan.Finish(err)
+		p.failed = append(p.failed, failedEntry{srcPath: path, err: err})
+		p.events.Emit(errorEvent(path, "", "", err))
+		return nil
+	}
+	category = p.aliases.current(category)
+	var subDir string
+	if category == "images" || category == "movies" {
+		if year, month, ok := p.dates.ResolveDate(path); ok {
+			subDir = dateDir(p.dateLayout, year, month)
+		}
+	}
+	if reason := p.tooShort(path, category); reason != "" {
+		classifySpan.Finish(nil)
+		p.filtered = append(p.filtered, filteredEntry{srcPath: path, reason: reason})
+		p.events.Emit(Event{Type: EventSkippedFiltered, Src: path, Category: category, Size: info.Size(), Reason: reason})
+		return nil
+	}
+	category = p.bucket(path, category)
+	if reason := p.filter.excludeCategory(category); reason != "" {
+		classifySpan.Finish(nil)
+		p.filtered = append(p.filtered, filteredEntry{srcPath: path, reason: reason})
+		p.events.Emit(Event{Type: EventSkippedFiltered, Src: path, Category: category, Size: info.Size(), Reason: reason})
+		return nil
+	}
+	if p.nfc {
+		name = norm.NFC.String(name)
+	}
+	if p.sanitize {
+		name = sanitizeName(name)
+	}
+	if subDir == "" && p.alphabetical[category] {
+		subDir = letterDir(name)
+	}
+	relDir := filepath.Join(category, subDir)
+	var formerDirs []string
+	for _, name := range p.aliases.former(category) {
+		formerDirs = append(formerDirs, filepath.Join(name, subDir))
+	}
+	classifySpan.Set("classifier.category", category)
+	classifySpan.Finish(nil)
+
+	if reason := p.tooSmall(path, name, category, info); reason != "" {
+		// Skip tiny files to avoid noise.
+		p.filtered = append(p.filtered, filteredEntry{srcPath: path, reason: reason})
+		p.events.Emit(Event{Type: EventSkippedSmall, Src: path, Category: category, Size: info.Size(), Reason: reason})
+		return nil
+	}
+	return &plannedFile{srcPath: path, name: p.renameExtension(name), info: info, category: category, relDir: relDir, formerDirs: formerDirs}
+}
+
+// renameExtension gives name the extension its own is renamed to by the
+// config, if any.
+func (p *planner) renameExtension(name string) string {
+	ext := filepath.Ext(name)
+	to, ok := p.renames[strings.ToLower(strings.TrimPrefix(ext, "."))]
+	if !ok || ext == "" || ext == name {
+		return name
+	}
+	return strings.TrimSuffix(name, ext) + "." + to
+}
+
+// tooSmall returns why the file at path falls below the byte minimum of
+// its category or extension or, for images, below the image minimums, or
+// "" when it does not. The pixel minimum holds in either orientation;
+// images whose dimensions cannot be read are judged by their byte size
+// only.
+func (p *planner) tooSmall(path, name, category string, info fs.FileInfo) string {
+	if n, ok := p.minSizes[category].minimum(name); ok {
+		if info.Size() < n {
+			return fmt.Sprintf("smaller than the %d bytes of min_bytes", n)
+		}
+	} else if category == "images" && p.minImageBytes > 0 && info.Size() < p.minImageBytes {
+		return "smaller than -min-image-bytes"
+	}
+	if category != "images" || (p.minImageWidth <= 0 && p.minImageHeight <= 0) {
+		return ""
+	}
+	w, h, err := imageDimensions(path)
+	if err != nil {
+		return ""
+	}
+	if max(w, h) < max(p.minImageWidth, p.minImageHeight) || min(w, h) < min(p.minImageWidth, p.minImageHeight) {
+		return "fewer pixels than -min-image-dimensions"
+	}
+	return ""
+}
+
+// tooShort returns why the video at path is shorter than the min_duration
+// of its category, or "" when it is not or its header cannot be read.
+func (p *planner) tooShort(path, category string) string {
+	limit, ok := p.minDurations[category]
+	if !ok {
+		return ""
+	}
+	v, err := probeVideo(path)
+	if err != nil || v.duration <= 0 || v.duration >= limit {
+		return ""
+	}
+	return fmt.Sprintf("shorter than the %s of min_duration", limit)
+}
+
+// bucket returns the category of the first video bucket of category that
+// the video at path matches, or category itself.
+func (p *planner) bucket(path, category string) string {
+	buckets := p.buckets[category]
+	if len(buckets) == 0 {
+		return category
+	}
+	v, err := probeVideo(path)
+	if err != nil {
+		return category
+	}
+	for _, b := range buckets {
+		if b.matches(v) {
+			return b.Category
+		}
+	}
+	return category
+}
+
+// hashFile fills in f.hash. It returns false for files that timed out or
+// are locked, which are recorded as failed instead of stopping the run.
+func (p *planner) hashFile(ctx context.Context, f *plannedFile) (bool, error) {
+	hash, err := p.computeHash(ctx, f)
+	return p.recordHash(f, hash, err)
+}
+
+func (p *planner) computeHash(ctx context.Context, f *plannedFile) (string, error) {
+	if err := p.pause.wait(ctx); err != nil {
+		return "", err
+	}
+	if p.hashes != nil {
+		if hash, ok := p.hashes.get(f.info); ok {
+			return hash, nil
+		}
+	}
+	_, hashSpan := trace.Start(ctx, "hash", map[string]any{"file.path": f.srcPath, "file.size": f.info.Size()})
+	hash, err := p.ops.hash(ctx, f.srcPath)
+	if err == nil {
+		// A file still being written hashes to content no copy will have.
+		err = checkUnchanged(f.srcPath, f.info)
+	}
+	hashSpan.Finish(err)
+	if err == nil && p.hashes != nil {
+		p.hashes.put(f.info, hash)
+	}
+	return hash, err
+}
+
+func (p *planner) recordHash(f *plannedFile, hash string, err error) (bool, error) {
+	if skipsFile(err) {
+		p.failed = append(p.failed, failedEntry{srcPath: f.srcPath, err: err})
+		p.events.Emit(errorEvent(f.srcPath, "", f.category, err))
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	f.hash = hash
+	p.events.Emit(Event{Type: EventClassified, Src: f.srcPath, Category: f.category, Dir: f.relDir, Size: f.info.Size(), Hash: hash})
+	return true, nil
+}
+
+// hashParallel hashes the candidates with p.workers goroutines. Results are
+// recorded in candidate order as soon as they are available, so events and
+// the plan come out the same as with a single worker.
+func (p *planner) hashParallel(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		i    int
+		hash string
+		err  error
+	}
+	jobs := make(chan int)
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for range min(p.workers, len(p.candidates)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				hash, err := p.computeHash(ctx, &p.candidates[i].f)
+				results <- result{i: i, hash: hash, err: err}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range p.candidates {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	ready := make([]*result, len(p.candidates))
+	next := 0
+	var firstErr error
+	for r := range results {
+		ready[r.i] = &r
+		for ; next < len(ready) && ready[next] != nil; next++ {
+			if firstErr != nil {
+				continue
+			}
+			f := &p.candidates[next].f
+			ok, err := p.recordHash(f, ready[next].hash, ready[next].err)
+			if err != nil {
+				firstErr = err
+				cancel()
+				continue
+			}
+			if ok {
+				p.planned = append(p.planned, *f)
+			}
+		}
+	}
+	if firstErr == nil && next < len(ready) {
+		// Cancelled before every candidate was handed out.
+		firstErr = ctx.Err()
+	}
+	return firstErr
+}
+
+// ReadFileList reads absolute paths separated by newlines, or by NUL bytes
+// when the list contains any (find -print0), from path or, for "-", stdin.
+// Repeated paths are listed once.
+func ReadFileList(path string) ([]string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read file list: %w", err)
+	}
+	sep := "\n"
+	if bytes.IndexByte(data, 0) >= 0 {
+		sep = "\x00"
+	}
+	var paths []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(data), sep) {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			return nil, fmt.Errorf("file list entries must be absolute paths: %q", line)
+		}
+		line = filepath.Clean(line)
+		if !seen[line] {
+			seen[line] = true
+			paths = append(paths, line)
+		}
+	}
+	return paths, nil
+}
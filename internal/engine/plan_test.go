@@ -0,0 +1,38 @@
+package engine
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPlannerSampleIsUniform(t *testing.T) {
+	dir := t.TempDir()
+	const files, limit, runs = 10, 2, 2000
+	for i := 0; i < files; i++ {
+		writeFile(t, dir, string(rune('a'+i))+".txt", string(rune('a'+i)))
+	}
+	cfg, err := loadEmbeddedConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for r := 0; r < runs; r++ {
+		p := &planner{resolver: newCategoryResolver(cfg), dates: dateResolver{}, cp: &checkpoint{}, events: discardEvents{}, limit: limit, sample: true}
+		plan, err := p.build(t.Context(), dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(plan) != limit || plan[0].srcPath >= plan[1].srcPath {
+			t.Fatalf("expected %d sampled files in walk order, got %v", limit, plan)
+		}
+		for _, f := range plan {
+			counts[filepath.Base(f.srcPath)]++
+		}
+	}
+	// Each file is expected runs*limit/files = 400 times.
+	for name, n := range counts {
+		if n < 300 || n > 500 {
+			t.Fatalf("file %s sampled %d times; sampling looks biased: %v", name, n, counts)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// landedFiles collects where a file was copied to.
+type landedFiles []string
+
+func (l *landedFiles) Emit(ev Event) {
+	if ev.Type == EventCopied {
+		*l = append(*l, ev.Dest)
+	}
+}
+
+func (l *landedFiles) Finish() {}
+
+// What a failing post command does to the run.
+const (
+	PostFailureWarn = "warn"
+	PostFailureFail = "fail"
+	PostFailureStop = "stop"
+)
+
+// PostFailurePolicies lists the accepted on_post_failure values.
+var PostFailurePolicies = []string{PostFailureWarn, PostFailureFail, PostFailureStop}
+
+// PostPlaceholders are replaced in every argument of a post command.
+var PostPlaceholders = []string{"{dest}", "{src}", "{category}"}
+
+type postCommand struct {
+	argv      []string
+	onFailure string
+}
+
+// postResult is a post command that failed.
+type postResult struct {
+	f         plannedFile
+	dest      string
+	onFailure string
+	err       error
+}
+
+// postCommands runs the post commands of the categories on copied files in
+// the background, at most jobs at a time. Failures are collected for the
+// run loop to act on, so events are only emitted from there.
+type postCommands struct {
+	commands map[string]postCommand
+	sem      chan struct{}
+	wg       sync.WaitGroup
+
+	mu     sync.Mutex
+	failed []postResult
+}
+
+// newPostCommands returns nil when no category has a post command; a nil
+// postCommands runs nothing.
+func newPostCommands(cfg Config, jobs int) (*postCommands, error) {
+	commands := make(map[string]postCommand)
+	for _, c := range cfg.Categories {
+		if c.OnPostFailure != "" && !slices.Contains(PostFailurePolicies, c.OnPostFailure) {
+			return nil, fmt.Errorf("category %q: unknown on_post_failure %q, want one of %s",
+				c.Name, c.OnPostFailure, strings.Join(PostFailurePolicies, ", "))
+		}
+		if len(c.PostCommand) == 0 {
+			continue
+		}
+		if c.PostCommand[0] == "" {
+			return nil, fmt.Errorf("category %q: post_command has no program", c.Name)
+		}
+		policy := c.OnPostFailure
+		if policy == "" {
+			policy = PostFailureWarn
+		}
+		commands[c.Name] = postCommand{argv: c.PostCommand, onFailure: policy}
+	}
+	if len(commands) == 0 {
+		return nil, nil
+	}
+	return &postCommands{commands: commands, sem: make(chan struct{}, max(jobs, 1))}, nil
+}
+
+// start runs the post command of f's category on its copy at dest. It
+// blocks while jobs commands are already running.
+func (p *postCommands) start(ctx context.Context, f plannedFile, dest string) {
+	if p == nil {
+		return
+	}
+	cmd, ok := p.commands[f.category]
+	if !ok {
+		return
+	}
+	p.sem <- struct{}{}
+	p.wg.Add(1)
+	go func() {
+		defer func() {
+			<-p.sem
+			p.wg.Done()
+		}()
+		if err := runPostCommand(ctx, cmd.argv, f, dest); err != nil {
+			p.mu.Lock()
+			p.failed = append(p.failed, postResult{f: f, dest: dest, onFailure: cmd.onFailure, err: err})
+			p.mu.Unlock()
+		}
+	}()
+}
+
+// failures returns the commands that failed since the last call.
+func (p *postCommands) failures() []postResult {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	failed := p.failed
+	p.failed = nil
+	return failed
+}
+
+// wait blocks until every started command has finished.
+func (p *postCommands) wait() []postResult {
+	if p == nil {
+		return nil
+	}
+	p.wg.Wait()
+	return p.failures()
+}
+
+func runPostCommand(ctx context.Context, argv []string, f plannedFile, dest string) error {
+	r := strings.NewReplacer("{dest}", dest, "{src}", f.srcPath, "{category}", f.category)
+	args := make([]string, len(argv))
+	for i, a := range argv {
+		args[i] = r.Replace(a)
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		if out := bytes.TrimSpace(output.Bytes()); len(out) > 0 {
+			return fmt.Errorf("post command %s: %w: %s", args[0], err, out)
+		}
+		return fmt.Errorf("post command %s: %w", args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrReadOnlySource is returned when a run with Options.ReadOnlySource
+// would write, create or remove a file of the source.
+var ErrReadOnlySource = errors.New("source is read-only")
+
+// readOnlyFS guards a source given with Options.ReadOnlySource, such as a
+// mounted evidence or backup image. Every folder a run creates copies in
+// and every file it writes or removes is checked with it, after symbolic
+// links are resolved, so neither a destination inside the source nor a
+// category that leads into it can change the source; source files
+// themselves are only ever opened for reading. A nil *readOnlyFS allows
+// everything.
+type readOnlyFS struct {
+	roots []string
+}
+
+// newReadOnlyFS guards the source of o, or its listed files, when
+// o.ReadOnlySource is set.
+func newReadOnlyFS(o Options) *readOnlyFS {
+	if !o.ReadOnlySource {
+		return nil
+	}
+	g := &readOnlyFS{}
+	if o.Source != "" {
+		g.roots = append(g.roots, resolvePath(o.Source))
+	}
+	for _, f := range o.Files {
+		g.roots = append(g.roots, resolvePath(f))
+	}
+	return g
+}
+
+// check fails with ErrReadOnlySource when path is in the source.
+func (g *readOnlyFS) check(path string) error {
+	if g == nil {
+		return nil
+	}
+	resolved := resolvePath(path)
+	for _, root := range g.roots {
+		if within(root, resolved) {
+			return fmt.Errorf("%w: refusing to write %s", ErrReadOnlySource, path)
+		}
+	}
+	return nil
+}
+
+// The fileOps methods below are the writes of a run besides copy. Each
+// checks the paths it changes with readOnly first, so that none of them
+// reaches into a read-only source, not even through a symbolic link in a
+// destination.
+
+func (o fileOps) mkdirAll(dir string) error {
+	if err := o.readOnly.check(dir); err != nil {
+		return err
+	}
+	return os.MkdirAll(dir, 0o755)
+}
+
+func (o fileOps) create(path string) (*os.File, error) {
+	if err := o.readOnly.check(path); err != nil {
+		return nil, err
+	}
+	return os.Create(path)
+}
+
+func (o fileOps) writeFile(path string, data []byte, perm os.FileMode) error {
+	if err := o.readOnly.check(path); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, perm)
+}
+
+func (o fileOps) rename(from, to string) error {
+	if err := o.readOnly.check(from); err != nil {
+		return err
+	}
+	if err := o.readOnly.check(to); err != nil {
+		return err
+	}
+	return os.Rename(from, to)
+}
+
+func (o fileOps) link(oldname, newname string) error {
+	if err := o.readOnly.check(newname); err != nil {
+		return err
+	}
+	return os.Link(oldname, newname)
+}
+
+func (o fileOps) remove(path string) error {
+	if err := o.readOnly.check(path); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (o fileOps) setProvenance(path, src, runID string) error {
+	if err := o.readOnly.check(path); err != nil {
+		return err
+	}
+	return setProvenance(path, src, runID)
+}
+
+// resolvePath is path made absolute with the symbolic links of its longest
+// existing part resolved, so a path that does not exist yet is compared
+// by where it would be created.
+func resolvePath(path string) string {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	var rest []string
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...)
+		}
+		if filepath.Dir(dir) == dir {
+			return path
+		}
+		rest = append([]string{filepath.Base(dir)}, rest...)
+	}
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun_ReadOnlySourceIsLeftAlone(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	mustMkdir(t, src)
+	writeFile(t, src, "a.txt", "alpha")
+	cfg := Config{Categories: []Category{{Name: "documents", Extensions: []string{"txt"}}}}
+
+	link := filepath.Join(workspace, "link")
+	if err := os.Symlink(src, link); err != nil {
+		t.Skipf("symlinks: %v", err)
+	}
+	rooted := Config{Categories: []Category{{Name: "documents", Extensions: []string{"txt"}, Root: filepath.Join(src, "documents")}}}
+	for _, tt := range []struct {
+		name string
+		o    Options
+	}{
+		{"destination inside", Options{Config: cfg, Dest: filepath.Join(src, "out")}},
+		{"destination through a link", Options{Config: cfg, Dest: filepath.Join(link, "out")}},
+		{"mirror inside", Options{Config: cfg, Dest: filepath.Join(workspace, "dest"), Mirrors: []string{filepath.Join(src, "mirror")}}},
+		{"category root inside", Options{Config: rooted, Dest: filepath.Join(workspace, "dest")}},
+		{"delete source", Options{Config: cfg, Dest: filepath.Join(workspace, "dest"), DeleteSource: true}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.o.Source, tt.o.ReadOnlySource, tt.o.NoSpaceCheck = src, true, true
+			if _, err := Run(t.Context(), tt.o); !errors.Is(err, ErrReadOnlySource) {
+				t.Fatalf("expected ErrReadOnlySource, got %v", err)
+			}
+		})
+	}
+	if entries, err := os.ReadDir(src); err != nil || len(entries) != 1 {
+		t.Fatalf("expected the source to hold a.txt only, got %v (%v)", entries, err)
+	}
+
+	dest := filepath.Join(workspace, "dest")
+	if _, err := Run(t.Context(), Options{Config: cfg, Source: src, Dest: dest, ReadOnlySource: true, NoSpaceCheck: true}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dest, "documents", "a.txt")); err != nil || string(data) != "alpha" {
+		t.Fatalf("expected the copy of a.txt, got %q (%v)", data, err)
+	}
+}
+
+func TestFileOps_CopyRefusesDestinationsInAReadOnlySource(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	mustMkdir(t, src)
+	writeFile(t, src, "a.txt", "alpha")
+
+	ops := fileOps{readOnly: newReadOnlyFS(Options{Source: src, ReadOnlySource: true})}
+	outside := filepath.Join(workspace, "a.txt")
+	inside := filepath.Join(src, "copy of a.txt")
+	errs, err := ops.copy(t.Context(), filepath.Join(src, "a.txt"), []string{inside, outside}, []os.FileMode{0o644, 0o644})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(errs[0], ErrReadOnlySource) || errs[1] != nil {
+		t.Fatalf("expected only the copy into the source to be refused, got %v", errs)
+	}
+	if _, err := os.Stat(inside); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected no copy in the source, got %v", err)
+	}
+	if data, err := os.ReadFile(outside); err != nil || string(data) != "alpha" {
+		t.Fatalf("expected the copy outside the source, got %q (%v)", data, err)
+	}
+}
+
+func TestRun_ReadOnlySourceIsLeftAloneThroughLinksInTheDestination(t *testing.T) {
+	cfg := Config{Categories: []Category{
+		{Name: "documents", Extensions: []string{"txt"}},
+		{Name: "images", Extensions: []string{"png"}},
+	}}
+	for _, tt := range []struct {
+		name    string
+		link    string
+		wantErr bool
+	}{
+		{name: "category folder", link: "documents", wantErr: true},
+		{name: "thumbnail folder", link: ThumbnailDir},
+		{name: "state folder", link: StateDir, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			workspace := t.TempDir()
+			src := filepath.Join(workspace, "src")
+			dest := filepath.Join(workspace, "dest")
+			mustMkdir(t, filepath.Join(src, "inner"))
+			mustMkdir(t, dest)
+			writeFile(t, src, "a.txt", "alpha")
+			var img bytes.Buffer
+			if err := png.Encode(&img, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+				t.Fatal(err)
+			}
+			writeFile(t, src, "b.png", img.String())
+			if err := os.Symlink(filepath.Join(src, "inner"), filepath.Join(dest, tt.link)); err != nil {
+				t.Skipf("symlinks: %v", err)
+			}
+
+			_, err := Run(t.Context(), Options{Config: cfg, Source: src, Dest: dest, ReadOnlySource: true, Thumbnails: true, MinImageBytes: 1, NoSpaceCheck: true, Warnf: func(string, ...any) {}})
+			if tt.wantErr != errors.Is(err, ErrReadOnlySource) || (!tt.wantErr && err != nil) {
+				t.Fatalf("expected ErrReadOnlySource %v, got %v", tt.wantErr, err)
+			}
+			if entries, err := os.ReadDir(filepath.Join(src, "inner")); err != nil || len(entries) != 0 {
+				t.Fatalf("expected nothing written into the source, got %v (%v)", entries, err)
+			}
+		})
+	}
+}
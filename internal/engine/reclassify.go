@@ -0,0 +1,237 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// ReclassifyOptions configures a reclassification of Dest under Config.
+type ReclassifyOptions struct {
+	Config Config
+	Dest   string
+
+	// DryRun moves nothing; the plan is printed to Preview instead.
+	DryRun  bool
+	Preview io.Writer
+}
+
+// ReclassifyResult sums up a reclassification.
+type ReclassifyResult struct {
+	// Moves is the path of the move log; it is empty for dry runs and
+	// when nothing moved.
+	Moves string
+	// Moved files changed folder; Duplicates stayed where they were since
+	// their new folder already holds the same content; Unchanged files are
+	// where the config puts them.
+	Moved      int
+	Duplicates int
+	Unchanged  int
+}
+
+// Reclassify files every archived file of Dest again under the config and
+// moves the ones whose category or date folder changed, into the root of
+// their category when it has one. Every move is recorded in a move log
+// that UndoMoves reverses. Files keep their names, apart from extensions
+// renamed by the config, with a number added on a collision. Emptied
+// folders are left for prune.
+func Reclassify(ctx context.Context, o ReclassifyOptions) (res ReclassifyResult, err error) {
+	out := o.Preview
+	if out == nil {
+		out = io.Discard
+	}
+	dates, err := newDateResolver(o.Config.DatePatterns)
+	if err != nil {
+		return res, err
+	}
+	alphabetical, err := alphabeticalCategories(o.Config)
+	if err != nil {
+		return res, err
+	}
+	buckets, err := videoBuckets(o.Config)
+	if err != nil {
+		return res, err
+	}
+	layout, err := dateLayout(o.Config)
+	if err != nil {
+		return res, err
+	}
+	roots, err := categoryRoots(o.Config)
+	if err != nil {
+		return res, err
+	}
+	renames, err := extensionRenames(o.Config)
+	if err != nil {
+		return res, err
+	}
+	ops := fileOps{}
+	p := &planner{
+		resolver:        newCategoryResolver(o.Config),
+		dates:           dates,
+		defaultCategory: defaultCategory(o.Config),
+		aliases:         categoryAliases(o.Config.Aliases),
+		dateLayout:      layout,
+		alphabetical:    alphabetical,
+		buckets:         buckets,
+		renames:         renames,
+		cp:              &checkpoint{},
+		ops:             ops,
+		events:          discardEvents{},
+	}
+
+	files, err := archivedFiles(o.Dest)
+	if err != nil {
+		return res, err
+	}
+	for _, root := range slices.Sorted(maps.Values(roots)) {
+		rooted, err := archivedFiles(root)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+		files = append(files, rooted...)
+	}
+	d := newDestination(o.Dest, false)
+	d.roots = roots
+	var log *moveLog
+	defer func() {
+		if log == nil {
+			return
+		}
+		if cerr := log.close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	for _, cur := range files {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		f := p.classify(ctx, cur.srcPath, cur.info)
+		if f == nil {
+			res.Unchanged++
+			continue
+		}
+		dir := filepath.Join(d.rootFor(f.category), f.relDir)
+		if inFolder(filepath.Dir(cur.srcPath), dir) && f.name == cur.name {
+			res.Unchanged++
+			continue
+		}
+		if f.hash, err = ops.hash(ctx, f.srcPath); err != nil {
+			return res, err
+		}
+		to, present, err := uniqueDestPath(ctx, dir, f.name, "", cur.info.Size(), f.hash, ops.hash, d.reserved)
+		if err != nil {
+			return res, err
+		}
+		if present {
+			fmt.Fprintf(out, "duplicate %s = %s\n", f.srcPath, to)
+			res.Duplicates++
+			continue
+		}
+		d.reserved.add(to)
+		fmt.Fprintf(out, "move %s -> %s\n", f.srcPath, to)
+		res.Moved++
+		if o.DryRun {
+			continue
+		}
+		if log == nil {
+			if log, err = startMoveLog(o.Dest); err != nil {
+				return res, err
+			}
+			res.Moves = log.path
+		}
+		if err := moveFile(f.srcPath, to, log); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// inFolder reports whether a file in dir is in folder want or one of its
+// numbered shard folders.
+func inFolder(dir, want string) bool {
+	if dir == want {
+		return true
+	}
+	base := filepath.Base(dir)
+	return filepath.Dir(dir) == want && len(base) == 4 && allDigits(base)
+}
+
+// moveFile renames from to to, together with its sidecar and AppleDouble
+// file, and records it in log.
+func moveFile(from, to string, log *moveLog) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+		return fmt.Errorf("move %s: %w", from, err)
+	}
+	if _, err := os.Lstat(to); err == nil {
+		return fmt.Errorf("move %s: %s already exists", from, to)
+	}
+	if err := os.Rename(from, to); err != nil {
+		return fmt.Errorf("move %s: %w", from, err)
+	}
+	if err := log.add(Move{From: from, To: to}); err != nil {
+		return err
+	}
+	if err := moveSidecar(from, to); err != nil {
+		return err
+	}
+	return moveAppleDouble(from, to)
+}
+
+// UndoMoves moves the files of a move log in dest back, newest first, and
+// records that in a move log of its own. Files that have since moved on or
+// whose old path is taken again are left alone and returned as skipped.
+func UndoMoves(dest, path string, dryRun bool, preview io.Writer) (moved int, skipped []Move, err error) {
+	if preview == nil {
+		preview = io.Discard
+	}
+	moves, err := ReadMoves(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	var log *moveLog
+	defer func() {
+		if log == nil {
+			return
+		}
+		if cerr := log.close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	for i := len(moves) - 1; i >= 0; i-- {
+		m := moves[i]
+		if _, err := os.Lstat(m.To); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				return moved, skipped, err
+			}
+			skipped = append(skipped, m)
+			continue
+		}
+		if _, err := os.Lstat(m.From); err == nil {
+			skipped = append(skipped, m)
+			continue
+		}
+		fmt.Fprintf(preview, "move %s -> %s\n", m.To, m.From)
+		moved++
+		if dryRun {
+			continue
+		}
+		if log == nil {
+			if log, err = startMoveLog(dest); err != nil {
+				return moved, skipped, err
+			}
+		}
+		if err := moveFile(m.To, m.From, log); err != nil {
+			return moved, skipped, err
+		}
+	}
+	return moved, skipped, nil
+}
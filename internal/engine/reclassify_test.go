@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReclassify(t *testing.T) {
+	docs := Category{Name: "documents", Extensions: []string{"txt", "text"}}
+	rooted := docs
+	rooted.Root = "docs"
+	for _, tt := range []struct {
+		name      string
+		cfg       Config
+		from, to  string
+		unchanged bool
+	}{
+		{"into another category", Config{Categories: []Category{docs}}, "dest/others/a.txt", "dest/documents/a.txt", false},
+		{"into the root of its category", Config{Categories: []Category{rooted}}, "dest/others/a.txt", "docs/documents/a.txt", false},
+		{"out of the root of its category", Config{Categories: []Category{docs}}, "docs/documents/a.txt", "dest/documents/a.txt", false},
+		{"already in the root of its category", Config{Categories: []Category{rooted}}, "docs/documents/a.txt", "docs/documents/a.txt", true},
+		{"with a renamed extension", Config{Categories: []Category{docs}, RenameExtensions: map[string]string{"text": "txt"}}, "dest/documents/a.text", "dest/documents/a.txt", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			workspace := t.TempDir()
+			dest := filepath.Join(workspace, "dest")
+			for i, c := range tt.cfg.Categories {
+				if c.Root != "" {
+					tt.cfg.Categories[i].Root = filepath.Join(workspace, c.Root)
+				}
+			}
+			from, to := filepath.Join(workspace, tt.from), filepath.Join(workspace, tt.to)
+			mustMkdir(t, dest)
+			mustMkdir(t, filepath.Dir(from))
+			writeFile(t, filepath.Dir(from), filepath.Base(from), "alpha")
+			if tt.cfg.Categories[0].Root == "" {
+				// The old root of the category is still read.
+				tt.cfg.Categories = append(tt.cfg.Categories, Category{Name: "archive", Extensions: []string{"zip"}, Root: filepath.Join(workspace, "docs")})
+			}
+
+			res, err := Reclassify(t.Context(), ReclassifyOptions{Config: tt.cfg, Dest: dest})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.unchanged != (res.Unchanged == 1) || tt.unchanged == (res.Moved == 1) {
+				t.Fatalf("expected unchanged %v, got %+v", tt.unchanged, res)
+			}
+			if data, err := os.ReadFile(to); err != nil || string(data) != "alpha" {
+				t.Fatalf("expected the file at %s: %v", to, err)
+			}
+			if from != to {
+				if _, err := os.Stat(from); !os.IsNotExist(err) {
+					t.Fatalf("expected %s moved away, got %v", from, err)
+				}
+			}
+		})
+	}
+}
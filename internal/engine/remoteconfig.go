@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxRemoteConfig bounds the size of a config fetched over HTTP.
+const maxRemoteConfig = 1 << 20
+
+var configClient = &http.Client{Timeout: 30 * time.Second}
+
+func isConfigURL(path string) bool {
+	return strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://")
+}
+
+// fetchConfig downloads a config and checks it against the SHA-256 pinned
+// in the URL's fragment, if any.
+func fetchConfig(url string) ([]byte, error) {
+	url, fragment, _ := strings.Cut(url, "#")
+	var pinned string
+	if fragment != "" {
+		hash, ok := strings.CutPrefix(fragment, "sha256=")
+		if !ok {
+			return nil, fmt.Errorf("config %s: unknown fragment %q, want #sha256=<hex>", url, fragment)
+		}
+		pinned = strings.ToLower(hash)
+	}
+
+	resp, err := configClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("read config %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteConfig+1))
+	if err != nil {
+		return nil, fmt.Errorf("read config %s: %w", url, err)
+	}
+	if len(data) > maxRemoteConfig {
+		return nil, fmt.Errorf("read config %s: larger than %s", url, FormatBytes(maxRemoteConfig))
+	}
+	if pinned != "" {
+		sum := sha256.Sum256(data)
+		if got := hex.EncodeToString(sum[:]); got != pinned {
+			return nil, fmt.Errorf("config %s has sha256 %s, but %s is pinned", url, got, pinned)
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CategoryResolver decides which category a source file belongs to. ok is
+// false when it has no answer, leaving the file to the next resolver of a
+// chain or, failing all of them, to the default category.
+type CategoryResolver interface {
+	ResolveCategory(path string) (category string, ok bool)
+}
+
+// DateResolver dates an image or movie so it is filed below year/yyyymm.
+// ok is false when it cannot tell, which leaves the file in its category
+// folder.
+type DateResolver interface {
+	ResolveDate(path string) (year int, month time.Month, ok bool)
+}
+
+// ExtensionCategories is the built-in CategoryResolver: it looks the file
+// extension up in cfg.Categories.
+func ExtensionCategories(cfg Config) CategoryResolver {
+	return newCategoryResolver(cfg)
+}
+
+// FilenameDates is the built-in DateResolver: it matches the file name
+// against patterns, as date_patterns in the config.
+func FilenameDates(patterns []string) (DateResolver, error) {
+	return newDateResolver(patterns)
+}
+
+// defaultCategory is where files go that no resolver has a category for.
+func defaultCategory(cfg Config) string {
+	if cfg.DefaultCategory == "" {
+		return "others"
+	}
+	return cfg.DefaultCategory
+}
+
+// categoryDir checks that a category from a resolver names a folder below
+// the destination.
+func categoryDir(category string) error {
+	if !filepath.IsLocal(category) || strings.ContainsAny(category, `/\`) {
+		return fmt.Errorf("invalid category %q: must be a single folder name", category)
+	}
+	return nil
+}
+
+// yearMonth parses the digits found by a date pattern.
+func yearMonth(year, month string) (int, time.Month, bool) {
+	y, err := strconv.Atoi(year)
+	if err != nil {
+		return 0, 0, false
+	}
+	m, err := strconv.Atoi(month)
+	if err != nil {
+		return 0, 0, false
+	}
+	return y, time.Month(m), true
+}
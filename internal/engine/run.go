@@ -0,0 +1,685 @@
+// Package engine plans and carries out classification runs. It is shared
+// by the classifier command and the public classify package.
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/sky0621/classifier/internal/trace"
+)
+
+// Options configures a run. Dest is required, and either Source or Files;
+// every other field may be left at its zero value.
+type Options struct {
+	Config Config
+	// Categories and Dates replace the config's extensions and date
+	// patterns when set.
+	Categories CategoryResolver
+	Dates      DateResolver
+	// Source is walked for files to classify. When it is empty, Files
+	// lists them instead.
+	Source string
+	Files  []string
+	Dest   string
+	// Mirrors are further destinations that receive the same files.
+	Mirrors []string
+	// SharedDedupe are other destinations whose archived files, going by
+	// their manifests, count as duplicates instead of being copied again.
+	SharedDedupe []string
+	// NormalizeNames names destination files in Unicode NFC and treats
+	// names that differ only in their normalization as the same name.
+	NormalizeNames bool
+	// SanitizeNames replaces what NTFS and exFAT do not allow in file
+	// names, for destinations on such drives.
+	SanitizeNames bool
+	// ShardSize, when positive, caps the files placed in one folder; once
+	// a folder holds that many, new files go to numbered sub-folders of
+	// it, 0001, 0002 and so on.
+	ShardSize int
+	// ContentAddressed stores every distinct content once, as a blob
+	// named after its hash below CASDir, and makes the category and date
+	// tree of hard links to the blobs. The destination must support hard
+	// links.
+	ContentAddressed bool
+
+	// DryRun writes nothing; the decisions are printed to Preview and
+	// emitted as events instead.
+	DryRun  bool
+	Preview io.Writer
+
+	NoSpaceCheck bool
+	// FileMode, when set, is the mode of every copy. Otherwise copies get
+	// the permission bits of their source, or DefaultFileMode on
+	// filesystems that do not keep permissions.
+	FileMode os.FileMode
+	// NoXattrs leaves out the extended attributes XattrSource and
+	// XattrRunID that copies otherwise get where the filesystem has them.
+	NoXattrs bool
+	// NoHashCache hashes every source file, instead of reusing the hashes
+	// of files whose device, inode, size and modification time are
+	// unchanged since an earlier run into the same destination.
+	NoHashCache bool
+	// Verify re-hashes every copy once it is in place and copies it again,
+	// up to VerifyRetries times, while it differs from the source; a copy
+	// that still differs is removed and the file reported as failed.
+	Verify        bool
+	VerifyRetries int
+	// MaxBytes stops the run cleanly once this many bytes were copied.
+	MaxBytes int64
+	// MaxDuration stops the run cleanly, between two files, once it has
+	// been going this long.
+	MaxDuration  time.Duration
+	FileTimeout  time.Duration
+	Retries      int
+	RetryBackoff time.Duration
+
+	DeleteSource bool
+	Trash        bool
+	// ReadOnlySource guarantees the source is left as it is: Run refuses
+	// DeleteSource, Filter.HydratePlaceholders and destinations inside the
+	// source, and fails any write below it with ErrReadOnlySource.
+	ReadOnlySource bool
+	// RelativePaths records paths in manifests, warn.csv, errors.csv and
+	// skipped.csv relative to the source and the destination root holding
+	// them, with the roots in columns of their own, so they stay valid
+	// when drives are mounted elsewhere.
+	RelativePaths bool
+	// ReportsDir holds warn.csv, errors.csv, skipped.csv, unknown.csv
+	// and duplicates.html of Dest; empty means Dest's StateDir, so the
+	// reports stay out of the archive. Mirrors keep theirs in their own
+	// StateDir. The walk leaves ReportsDir out.
+	ReportsDir string
+	// AppendReports appends to warn.csv, errors.csv and skipped.csv
+	// instead of rewriting them, with the run id and start time of every
+	// row in two more columns, so they keep the history of every run.
+	AppendReports bool
+	// SyncDeletions takes destination files out whose sources below
+	// Source were deleted since an earlier run copied them, once
+	// ConfirmRemoval agrees. They are moved to
+	// .classifier/removed/<run id> and recorded in a move log, so they
+	// can be put back.
+	SyncDeletions  bool
+	ConfirmRemoval func(paths []string) bool
+	// ResolveDuplicate, when set, is asked before a file is skipped as a
+	// duplicate and before an image is copied that looks like one placed
+	// earlier in the run. Dry runs do not ask.
+	ResolveDuplicate func(Duplicate) DuplicateResolution
+
+	HTMLReport    bool
+	Thumbnails    bool
+	ThumbnailSize int
+	// Sidecars writes a SidecarExt file next to every copied file with
+	// its source path, modification time, hash and run id.
+	Sidecars bool
+	// Forks is one of ForkModes and decides what happens to AppleDouble
+	// "._" files and NTFS alternate data streams: ForksCopy, the default,
+	// copies them next to the copies of their files and ForksStrip leaves
+	// them out. Either way, those left behind are listed in skipped.csv.
+	Forks string
+	// NameConflicts is one of NameConflictModes and decides how a copy is
+	// named when another file of the same name is in its folder:
+	// NameConflictsSuffix, the default, numbers it and NameConflictsFolder
+	// adds the name of its source folder first.
+	NameConflicts string
+
+	// MinImageBytes skips images smaller than it; zero means
+	// DefaultMinImageBytes and a negative value turns the check off.
+	// MinImageWidth and MinImageHeight also skip images with fewer pixels,
+	// in either orientation, going by the image header.
+	MinImageBytes                 int64
+	MinImageWidth, MinImageHeight int
+
+	Filter Filter
+	Limit  int
+	Sample bool
+	// Order is one of ProcessingOrders; empty means lexical.
+	Order string
+	// Workers hashes this many source files at once.
+	Workers int
+	// PostJobs runs this many post commands at once; zero means one.
+	PostJobs int
+	// Hash names the content hash files are deduplicated by, one of
+	// HashAlgorithms: "sha256" (the default), "sha512" or "sha256-tree",
+	// which hashes files of 64 MiB and more on every core.
+	Hash string
+
+	Events Sink
+	Pause  *Pauser
+	// Warnf, if set, receives problems that do not fail the run.
+	Warnf func(format string, args ...any)
+
+	// ConfigPath, ConfigHash, Args and Version are recorded in the audit
+	// log; an empty Version is taken from the build info.
+	ConfigPath string
+	ConfigHash string
+	Args       []string
+	Version    string
+}
+
+// Result sums up a run. Files placed in several destinations are counted
+// once per destination.
+type Result struct {
+	// RunID names the audit log entry and manifest; it is empty for dry
+	// runs.
+	RunID string
+	// Status is completed, stopped, interrupted or failed.
+	Status string
+
+	Copied      int
+	BytesCopied int64
+	Duplicates  int
+	Present     int
+	Small       int
+	Filtered    int
+	Failed      int
+	// VerifyRetries counts the copies Verify had made again.
+	VerifyRetries int
+	// Removed counts destination files taken out by SyncDeletions;
+	// RemovedLog is the move log that puts them back.
+	Removed    int
+	RemovedLog string
+
+	// Stopped is set when MaxBytes or, with OutOfTime, MaxDuration ended
+	// the run early; Remaining files were left for the next run.
+	// BudgetUsed counts each copied file once against MaxBytes, however
+	// many destinations it went to.
+	Stopped    bool
+	OutOfTime  bool
+	Remaining  int
+	BudgetUsed int64
+	// Failures lists the files that could not be placed.
+	Failures []Failure
+}
+
+// Failure is a file that could not be placed in a destination. Dest is
+// empty when the file failed before a destination was chosen.
+type Failure struct {
+	Src  string
+	Dest string
+	Err  error
+}
+
+// ErrSourceNotDir is returned by Run when Options.Source is not a
+// directory.
+var ErrSourceNotDir = errors.New("source is not a directory")
+
+// resultCounter is the Sink that fills in the counts of a Result.
+type resultCounter struct {
+	res *Result
+}
+
+func (c resultCounter) Emit(ev Event) {
+	switch ev.Type {
+	case EventCopied:
+		c.res.Copied++
+		c.res.BytesCopied += ev.Size
+		c.res.VerifyRetries += ev.Retries
+	case EventSkippedDuplicate:
+		c.res.Duplicates++
+	case EventSkippedPresent:
+		c.res.Present++
+	case EventSkippedSmall:
+		c.res.Small++
+	case EventSkippedFiltered:
+		c.res.Filtered++
+	case EventRemoved:
+		c.res.Removed++
+	case EventError:
+		c.res.Failed++
+	}
+}
+
+func (resultCounter) Finish() {}
+
+// Run classifies the source files into the destinations. The Result is
+// filled in as far as the run got, also when it returns an error.
+func Run(ctx context.Context, o Options) (res Result, err error) {
+	var deadline time.Time
+	if o.MaxDuration > 0 {
+		deadline = time.Now().Add(o.MaxDuration)
+	}
+	events := o.Events
+	if events == nil {
+		events = discardEvents{}
+	}
+	events = multiEvents{events, resultCounter{res: &res}}
+	warnf := o.Warnf
+	if warnf == nil {
+		warnf = func(string, ...any) {}
+	}
+	out := o.Preview
+	if out == nil {
+		out = io.Discard
+	}
+	hashName := o.Hash
+	if hashName == "" {
+		hashName = hashSHA256
+	}
+	newHash, ok := hashAlgorithms[hashName]
+	if !ok {
+		return res, fmt.Errorf("unknown hash algorithm %q", o.Hash)
+	}
+	if o.Forks != "" && !slices.Contains(ForkModes, o.Forks) {
+		return res, fmt.Errorf("unknown forks mode %q", o.Forks)
+	}
+	if o.NameConflicts != "" && !slices.Contains(NameConflictModes, o.NameConflicts) {
+		return res, fmt.Errorf("unknown name conflict mode %q", o.NameConflicts)
+	}
+
+	p, err := newPlanner(o)
+	if err != nil {
+		return res, err
+	}
+
+	if o.Source != "" {
+		srcInfo, err := os.Stat(o.Source)
+		if err != nil {
+			return res, fmt.Errorf("read source: %w", err)
+		}
+		if !srcInfo.IsDir() {
+			return res, fmt.Errorf("%w: %s", ErrSourceNotDir, o.Source)
+		}
+	}
+
+	roots, err := categoryRoots(o.Config)
+	if err != nil {
+		return res, err
+	}
+	readOnly := newReadOnlyFS(o)
+	if readOnly != nil {
+		switch {
+		case o.DeleteSource:
+			return res, fmt.Errorf("%w: cannot delete source files", ErrReadOnlySource)
+		case o.Filter.HydratePlaceholders:
+			return res, fmt.Errorf("%w: cannot download cloud placeholders", ErrReadOnlySource)
+		}
+		dirs := append([]string{o.Dest}, o.Mirrors...)
+		// The state folders hold the checkpoint, hash cache and history.
+		for _, dir := range dirs {
+			dirs = append(dirs, filepath.Join(dir, StateDir))
+		}
+		if o.ReportsDir != "" {
+			dirs = append(dirs, o.ReportsDir)
+		}
+		for _, root := range roots {
+			dirs = append(dirs, root)
+		}
+		for _, dir := range dirs {
+			if err := readOnly.check(dir); err != nil {
+				return res, err
+			}
+		}
+	}
+	if p.skipDirs, err = sourceOverlaps(o, roots, warnf); err != nil {
+		return res, err
+	}
+	var posts *postCommands
+	if !o.DryRun {
+		if posts, err = newPostCommands(o.Config, o.PostJobs); err != nil {
+			return res, err
+		}
+		// Commands still running when the run fails must not outlive it.
+		defer posts.wait()
+	}
+	if !o.DryRun {
+		if err := os.MkdirAll(o.Dest, 0o755); err != nil {
+			return res, fmt.Errorf("create destination: %w", err)
+		}
+		for category, root := range roots {
+			if err := os.MkdirAll(root, 0o755); err != nil {
+				return res, fmt.Errorf("create destination of category %q: %w", category, err)
+			}
+		}
+	}
+
+	primary := newDestination(o.Dest, o.NormalizeNames)
+	primary.roots = roots
+	primary.reports = o.ReportsDir
+	dests := []*destination{primary}
+	for _, m := range o.Mirrors {
+		if !o.DryRun {
+			if err := os.MkdirAll(m, 0o755); err != nil {
+				return res, fmt.Errorf("create mirror destination: %w", err)
+			}
+		}
+		dests = append(dests, newDestination(m, o.NormalizeNames))
+	}
+	for _, d := range dests {
+		d.shardSize = o.ShardSize
+		d.cas = o.ContentAddressed
+		d.folderNames = o.NameConflicts == NameConflictsFolder
+		d.fileMode = o.FileMode
+	}
+
+	cp, err := loadCheckpoint(o.Dest)
+	if err != nil {
+		return res, err
+	}
+	cp.seed(dests)
+	if err := seedFromManifests(dests, hashName, warnf); err != nil {
+		return res, err
+	}
+	if err := seedShared(dests, o.SharedDedupe, hashName, warnf); err != nil {
+		return res, err
+	}
+
+	paths := newReportPaths(o, dests)
+	var stamp reportStamp
+	var stopErr error
+	var rec *runRecorder
+	if !o.DryRun {
+		roots := make([]string, len(dests))
+		for i, d := range dests {
+			roots[i] = d.root
+		}
+		rec, err = startRun(o.Dest, RunRecord{
+			ConfigPath:    o.ConfigPath,
+			ConfigHash:    o.ConfigHash,
+			Version:       o.Version,
+			Source:        o.Source,
+			Destinations:  roots,
+			Args:          o.Args,
+			HashAlgorithm: hashName,
+		}, paths)
+		if err != nil {
+			return res, err
+		}
+		res.RunID = rec.record.ID
+		stamp = newReportStamp(o, rec.record)
+		events = multiEvents{events, rec}
+		defer func() {
+			res.Status = runCompleted
+			switch {
+			case errors.Is(err, context.Canceled):
+				res.Status = runInterrupted
+			case err != nil:
+				res.Status = runFailed
+			case stopErr != nil:
+				res.Status = runStopped
+			}
+			if cerr := rec.close(res.Status, err); cerr != nil && err == nil {
+				err = cerr
+			}
+		}()
+	}
+
+	ops := fileOps{
+		timeout:       o.FileTimeout,
+		retries:       o.Retries,
+		backoff:       o.RetryBackoff,
+		durable:       o.DeleteSource,
+		newHash:       newHash,
+		readOnly:      readOnly,
+		warnf:         o.Warnf,
+		verify:        o.Verify,
+		verifyRetries: o.VerifyRetries,
+	}
+	p.cp, p.pause, p.ops, p.events = cp, o.Pause, ops, events
+	if !o.NoHashCache {
+		if p.hashes, err = loadHashCache(o.Dest, hashName); err != nil {
+			return res, err
+		}
+	}
+	var plan []plannedFile
+	if o.Source == "" {
+		plan, err = p.buildFrom(ctx, o.Files)
+	} else {
+		plan, err = p.build(ctx, o.Source)
+	}
+	if err != nil {
+		return res, err
+	}
+	if rec != nil {
+		rec.noteUnknown(p.unknown)
+	}
+	if p.hashes != nil && !o.DryRun {
+		if err := p.hashes.save(); err != nil {
+			warnf("%v", err)
+		}
+	}
+	forks := &forkCarrier{strip: o.Forks == ForksStrip, appleDouble: p.appleDouble, ops: ops, events: events, warnf: warnf}
+	if o.DryRun {
+		forks.preview = out
+		for _, f := range plan {
+			var landed landedFiles
+			if err := preview(ctx, dests, f, ops, out, multiEvents{events, &landed}); err != nil {
+				return res, err
+			}
+			forks.carry(ctx, f.srcPath, landed)
+			if o.DeleteSource {
+				fmt.Fprintf(out, "delete %s\n", f.srcPath)
+			}
+		}
+		if o.SyncDeletions && o.Source != "" {
+			list, err := orphans(dests, o.Source)
+			if err != nil {
+				return res, err
+			}
+			previewOrphans(out, list)
+		}
+		return res, nil
+	}
+	if !o.NoSpaceCheck {
+		if err := checkFreeSpace(dests, plan, o.MaxBytes, freeSpace); err != nil {
+			return res, err
+		}
+	}
+	if err := checkFilesystems(dests, plan, !o.NoXattrs, warnf); err != nil {
+		return res, err
+	}
+
+	var remover *sourceRemover
+	if o.DeleteSource {
+		remover = newSourceRemover(o.Dest, ops, o.Trash)
+	}
+
+	var thumbs *thumbnailer
+	if o.Thumbnails {
+		size := o.ThumbnailSize
+		if size <= 0 {
+			size = DefaultThumbnailSize
+		}
+		thumbs = newThumbnailer(size, ops)
+	}
+
+	// reportPost acts on failed post commands according to their
+	// category's policy; it returns an error when the run should stop.
+	reportPost := func(failed []postResult) error {
+		var stop error
+		for _, r := range failed {
+			if r.onFailure == PostFailureWarn {
+				warnf("%s: %v", r.dest, r.err)
+				continue
+			}
+			for _, d := range dests {
+				if d.hashIndex[r.f.hash] == r.dest {
+					d.failed = append(d.failed, failedEntry{srcPath: r.f.srcPath, destPath: r.dest, err: r.err})
+				}
+			}
+			events.Emit(errorEvent(r.f.srcPath, r.dest, r.f.category, r.err))
+			if r.onFailure == PostFailureStop && stop == nil {
+				stop = fmt.Errorf("%s: %w", r.dest, r.err)
+			}
+		}
+		return stop
+	}
+
+	var dupes *duplicateResolver
+	if o.ResolveDuplicate != nil {
+		dupes = &duplicateResolver{resolve: o.ResolveDuplicate}
+	}
+
+	budget := &copyBudget{limit: o.MaxBytes}
+	var postErr error
+	for i, f := range plan {
+		err := o.Pause.wait(ctx)
+		if err == nil && !deadline.IsZero() && !time.Now().Before(deadline) {
+			err = errOutOfTime
+		}
+		var landed landedFiles
+		if err == nil && dupes != nil {
+			var lookalike string
+			if f, lookalike, err = dupes.ask(f, dests[0]); err != nil {
+				return res, err
+			}
+			if lookalike != "" {
+				for _, d := range dests {
+					d.skipped = append(d.skipped, skippedEntry{srcPath: f.srcPath, destPath: lookalike})
+				}
+				events.Emit(Event{Type: EventSkippedDuplicate, Src: f.srcPath, Dest: lookalike, Category: f.category, Size: f.info.Size(), Hash: f.hash})
+				cp.record(f, dests[0])
+				continue
+			}
+		}
+		if err == nil {
+			_, copySpan := trace.Start(ctx, "copy", map[string]any{"file.path": f.srcPath, "file.size": f.info.Size(), "classifier.category": f.category})
+			err = fanOut(ctx, dests, f, budget, ops, multiEvents{events, &landed})
+			copySpan.Finish(err)
+		}
+		if errors.Is(err, errLargerThanBudget) {
+			warnf("%s is larger than the copy budget of %s; skipping it", f.srcPath, FormatBytes(uint64(o.MaxBytes)))
+			continue
+		}
+		if skipsFile(err) {
+			for _, d := range dests {
+				d.fail(f.srcPath, err)
+			}
+			events.Emit(errorEvent(f.srcPath, "", f.category, err))
+			continue
+		}
+		if errors.Is(err, errBudgetExhausted) || errors.Is(err, errOutOfTime) || errors.Is(err, context.Canceled) {
+			res.Remaining = len(plan) - i
+			stopErr = err
+			break
+		}
+		if err != nil {
+			return res, err
+		}
+		cp.record(f, dests[0])
+		if dupes != nil && len(landed) > 0 {
+			dupes.placed(landed[0])
+		}
+		if thumbs != nil {
+			for _, d := range dests {
+				path, ok := d.hashIndex[f.hash]
+				if !ok {
+					continue
+				}
+				if err := thumbs.ensure(ctx, d.rootFor(f.category), path, f.category); err != nil {
+					warnf("thumbnail of %s: %v", path, err)
+				}
+			}
+		}
+		if !o.NoXattrs {
+			for _, path := range landed {
+				if err := ops.setProvenance(path, f.srcPath, res.RunID); err != nil {
+					warnf("%s: %v", path, err)
+				}
+			}
+		}
+		if o.Sidecars {
+			for _, path := range landed {
+				s := sidecar{Source: f.srcPath, ModTime: f.info.ModTime(), Hash: f.hash, HashAlgorithm: hashName, RunID: res.RunID}
+				if err := writeSidecar(ops, path, s); err != nil {
+					warnf("%s: %v", path, err)
+				}
+			}
+		}
+		forks.carry(ctx, f.srcPath, landed)
+		for _, path := range landed {
+			posts.start(ctx, f, path)
+		}
+		if remover != nil {
+			_, deleteSpan := trace.Start(ctx, "delete", map[string]any{"file.path": f.srcPath})
+			err := remover.remove(ctx, f, dests)
+			deleteSpan.Finish(err)
+			if err != nil {
+				return res, err
+			}
+		}
+		if postErr = reportPost(posts.failures()); postErr != nil {
+			res.Remaining = len(plan) - i - 1
+			break
+		}
+	}
+	if err := reportPost(posts.wait()); err != nil && postErr == nil {
+		postErr = err
+	}
+
+	if o.SyncDeletions && o.Source != "" && res.Remaining == 0 && postErr == nil {
+		list, err := orphans(dests, o.Source)
+		if err != nil {
+			return res, err
+		}
+		paths := make([]string, len(list))
+		for i, orphan := range list {
+			paths[i] = orphan.path
+		}
+		if len(list) > 0 && o.ConfirmRemoval != nil && o.ConfirmRemoval(paths) {
+			if res.RemovedLog, err = removeOrphans(o.Dest, res.RunID, list, events); err != nil {
+				return res, err
+			}
+		}
+	}
+
+	if res.Remaining > 0 {
+		if err := cp.save(); err != nil {
+			return res, err
+		}
+	} else if err := cp.remove(); err != nil {
+		return res, err
+	}
+
+	p.filtered = append(p.filtered, forks.leftBehind()...)
+	events.Finish()
+
+	if len(p.filtered) > 0 || len(p.unknown) > 0 {
+		if err := ops.mkdirAll(primary.reportsDir()); err != nil {
+			return res, fmt.Errorf("create reports directory: %w", err)
+		}
+	}
+	if len(p.filtered) > 0 {
+		if err := writeFiltered(filepath.Join(primary.reportsDir(), "skipped.csv"), p.filtered, paths, stamp); err != nil {
+			return res, err
+		}
+	}
+	if len(p.unknown) > 0 {
+		if err := writeUnknownExtensions(filepath.Join(primary.reportsDir(), "unknown.csv"), p.unknown); err != nil {
+			return res, err
+		}
+	}
+
+	for _, f := range p.failed {
+		for _, d := range dests {
+			d.fail(f.srcPath, f.err)
+		}
+	}
+
+	var reportErr error
+	for _, d := range dests {
+		for _, f := range d.failed {
+			res.Failures = append(res.Failures, Failure{Src: f.srcPath, Dest: f.destPath, Err: f.err})
+		}
+		if err := d.writeReports(ops, o.HTMLReport, paths, stamp); err != nil && reportErr == nil {
+			reportErr = err
+		}
+	}
+
+	if postErr != nil {
+		return res, fmt.Errorf("post command failed with %d files left, re-run to resume: %w", res.Remaining, postErr)
+	}
+	if errors.Is(stopErr, context.Canceled) {
+		return res, fmt.Errorf("interrupted with %d files left, re-run to resume: %w", res.Remaining, stopErr)
+	}
+	res.Stopped = stopErr != nil
+	res.OutOfTime = errors.Is(stopErr, errOutOfTime)
+	res.BudgetUsed = budget.used
+	return res, reportErr
+}
@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ScanSizeClasses are the upper bounds of the size classes of
+// ScanReport.Sizes; the last class holds the files of at least the last
+// bound.
+var ScanSizeClasses = []int64{1 << 10, 1 << 20, 10 << 20, 100 << 20, 1 << 30}
+
+// ScanTotals counts files, their bytes and how many of them are named with
+// a date.
+type ScanTotals struct {
+	Files int
+	Bytes int64
+	Dated int
+}
+
+func (t *ScanTotals) add(size int64, dated bool) {
+	t.Files++
+	t.Bytes += size
+	if dated {
+		t.Dated++
+	}
+}
+
+// ScanReport is the inventory of a source that Scan takes.
+type ScanReport struct {
+	// Total counts every regular file of the source.
+	Total ScanTotals
+	// Extensions counts the files by lower-case extension; files without
+	// one are counted under "(none)".
+	Extensions map[string]ScanTotals
+	// Sizes counts the files by ScanSizeClasses, with one more class for
+	// the largest files.
+	Sizes []ScanTotals
+	// Oldest and Newest are the first days of the earliest and latest
+	// months a file is named with; they are zero without dated files.
+	Oldest, Newest time.Time
+	// Categories counts the files a run would place, by category.
+	Categories map[string]ScanTotals
+	// Skipped counts the files a run would leave out, by reason.
+	Skipped map[string]ScanTotals
+	// Unknown counts the files that fall into the default category for
+	// want of a rule, by extension.
+	Unknown map[string]int
+}
+
+// Scan takes the inventory of o.Source under the rules of o.Config and the
+// filters of o without hashing or copying anything, so the rules can be
+// tuned before a run. Only the fields of o that decide where files go and
+// which are left out are used.
+func Scan(ctx context.Context, o Options) (ScanReport, error) {
+	info, err := os.Stat(o.Source)
+	if err != nil {
+		return ScanReport{}, fmt.Errorf("read source: %w", err)
+	}
+	if !info.IsDir() {
+		return ScanReport{}, fmt.Errorf("%w: %s", ErrSourceNotDir, o.Source)
+	}
+	p, err := newPlanner(o)
+	if err != nil {
+		return ScanReport{}, err
+	}
+	p.cp, p.events = &checkpoint{}, discardEvents{}
+
+	report := ScanReport{
+		Extensions: make(map[string]ScanTotals),
+		Sizes:      make([]ScanTotals, len(ScanSizeClasses)+1),
+		Categories: make(map[string]ScanTotals),
+		Skipped:    make(map[string]ScanTotals),
+		Unknown:    make(map[string]int),
+	}
+	count := func(m map[string]ScanTotals, key string, size int64, dated bool) {
+		t := m[key]
+		t.add(size, dated)
+		m[key] = t
+	}
+	err = p.walk(ctx, o.Source, func(ctx context.Context, path string, info fs.FileInfo) error {
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		size := info.Size()
+		year, month, dated := p.dates.ResolveDate(path)
+		if dated {
+			d := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+			if report.Oldest.IsZero() || d.Before(report.Oldest) {
+				report.Oldest = d
+			}
+			if d.After(report.Newest) {
+				report.Newest = d
+			}
+		}
+		report.Total.add(size, dated)
+		ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+		if ext == "" {
+			ext = noExtension
+		}
+		count(report.Extensions, ext, size, dated)
+		class := 0
+		for class < len(ScanSizeClasses) && size >= ScanSizeClasses[class] {
+			class++
+		}
+		report.Sizes[class].add(size, dated)
+
+		filtered, failed := len(p.filtered), len(p.failed)
+		f := p.classify(ctx, path, info)
+		switch {
+		case f != nil:
+			count(report.Categories, f.category, size, dated)
+		case len(p.filtered) > filtered:
+			count(report.Skipped, p.filtered[len(p.filtered)-1].reason, size, dated)
+		case len(p.failed) > failed:
+			count(report.Skipped, p.failed[len(p.failed)-1].err.Error(), size, dated)
+		}
+		return nil
+	})
+	if err != nil {
+		return ScanReport{}, err
+	}
+	for ext, u := range p.unknown {
+		report.Unknown[ext] = u.count
+	}
+	return report, nil
+}
@@ -0,0 +1,257 @@
+package engine
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigSchema is the JSON Schema of the YAML config, printed by
+// `classifier config schema` for editors and used to validate user configs.
+//
+//go:embed config.schema.json
+var ConfigSchema []byte
+
+// jsonSchema is the subset of JSON Schema that config.schema.json uses.
+// Ref only points into the $defs of the root schema.
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Defs                 map[string]*jsonSchema `json:"$defs"`
+	Type                 string                 `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *additionalProperties  `json:"additionalProperties"`
+	Required             []string               `json:"required"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []string               `json:"enum"`
+	MinLength            *int                   `json:"minLength"`
+	Pattern              string                 `json:"pattern"`
+	Minimum              *float64               `json:"minimum"`
+}
+
+// additionalProperties is either false, rejecting keys that are not listed
+// in properties, or the schema the values of such keys must match.
+type additionalProperties struct {
+	allowed bool
+	schema  *jsonSchema
+}
+
+func (a *additionalProperties) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &a.allowed); err == nil {
+		return nil
+	}
+	a.allowed, a.schema = true, new(jsonSchema)
+	return json.Unmarshal(data, a.schema)
+}
+
+var configSchema = mustParseSchema(ConfigSchema)
+
+func mustParseSchema(data []byte) *jsonSchema {
+	var s jsonSchema
+	if err := json.Unmarshal(data, &s); err != nil {
+		panic(fmt.Sprintf("parse embedded config schema: %v", err))
+	}
+	s.resolve(s.Defs)
+	return &s
+}
+
+// resolve replaces the references below s with the definitions in defs
+// they point to.
+func (s *jsonSchema) resolve(defs map[string]*jsonSchema) {
+	children := []*jsonSchema{s.Items}
+	for _, p := range s.Properties {
+		children = append(children, p)
+	}
+	for _, d := range s.Defs {
+		children = append(children, d)
+	}
+	if s.AdditionalProperties != nil {
+		children = append(children, s.AdditionalProperties.schema)
+	}
+	for _, c := range children {
+		if c == nil {
+			continue
+		}
+		if c.Ref != "" {
+			def, ok := defs[strings.TrimPrefix(c.Ref, "#/$defs/")]
+			if !ok {
+				panic(fmt.Sprintf("embedded config schema: unknown $ref %q", c.Ref))
+			}
+			*c = *def
+			continue
+		}
+		c.resolve(defs)
+	}
+}
+
+// schemaError locates one violation in the config file.
+type schemaError struct {
+	line, column int
+	path         string
+	msg          string
+}
+
+func (e schemaError) Error() string {
+	if e.path == "" {
+		return fmt.Sprintf("%d:%d: %s", e.line, e.column, e.msg)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", e.line, e.column, e.path, e.msg)
+}
+
+// validateConfig checks a parsed YAML document against the config schema.
+// Every violation is reported on its own line as file:line:column.
+func validateConfig(file string, doc *yaml.Node) error {
+	if doc.Kind == yaml.DocumentNode {
+		if len(doc.Content) == 0 {
+			return nil
+		}
+		doc = doc.Content[0]
+	}
+	var errs []error
+	configSchema.validate(doc, "", &errs)
+	for i, err := range errs {
+		errs[i] = fmt.Errorf("%s:%w", file, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (s *jsonSchema) validate(n *yaml.Node, path string, errs *[]error) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, schemaError{line: n.Line, column: n.Column, path: path, msg: fmt.Sprintf(format, args...)})
+	}
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!null" {
+		// An empty value leaves the setting at its default.
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		if n.Kind != yaml.MappingNode {
+			fail("expected a mapping, got %s", describeNode(n))
+			return
+		}
+		seen := make(map[string]bool)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			seen[key.Value] = true
+			child := joinSchemaPath(path, key.Value)
+			prop, ok := s.Properties[key.Value]
+			if !ok {
+				switch extra := s.AdditionalProperties; {
+				case extra == nil:
+				case extra.schema != nil:
+					extra.schema.validate(value, child, errs)
+				case !extra.allowed:
+					*errs = append(*errs, schemaError{line: key.Line, column: key.Column, path: child, msg: "unknown key" + suggestKey(key.Value, s.Properties)})
+				}
+				continue
+			}
+			prop.validate(value, child, errs)
+		}
+		for _, r := range s.Required {
+			if !seen[r] {
+				fail("missing required key %q", r)
+			}
+		}
+	case "array":
+		if n.Kind != yaml.SequenceNode {
+			fail("expected a list, got %s", describeNode(n))
+			return
+		}
+		if s.Items != nil {
+			for i, item := range n.Content {
+				s.Items.validate(item, path+"["+strconv.Itoa(i)+"]", errs)
+			}
+		}
+	case "string":
+		if n.Kind != yaml.ScalarNode {
+			fail("expected a string, got %s", describeNode(n))
+			return
+		}
+		if s.MinLength != nil && utf8.RuneCountInString(n.Value) < *s.MinLength {
+			fail("must not be empty")
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, n.Value) {
+			fail("must be one of %q, got %q", s.Enum, n.Value)
+		}
+		if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(n.Value) {
+			fail("%q does not match %s", n.Value, s.Pattern)
+		}
+	case "integer", "number":
+		ok := n.Kind == yaml.ScalarNode && (n.Tag == "!!int" || (s.Type == "number" && n.Tag == "!!float"))
+		if !ok {
+			fail("expected %s, got %s", s.Type, describeNode(n))
+			return
+		}
+		if s.Minimum != nil {
+			if v, err := strconv.ParseFloat(n.Value, 64); err == nil && v < *s.Minimum {
+				fail("must be at least %v", *s.Minimum)
+			}
+		}
+	case "boolean":
+		if n.Kind != yaml.ScalarNode || n.Tag != "!!bool" {
+			fail("expected true or false, got %s", describeNode(n))
+		}
+	}
+}
+
+func joinSchemaPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func describeNode(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	}
+	return strconv.Quote(n.Value)
+}
+
+// suggestKey points at the known key closest to a misspelt one.
+func suggestKey(key string, props map[string]*jsonSchema) string {
+	best, bestDist := "", 3
+	for name := range props {
+		if d := editDistance(key, name); d < bestDist || (d == bestDist && best != "" && name < best) {
+			best, bestDist = name, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestEmbeddedConfigMatchesSchema(t *testing.T) {
+	data, err := embeddedFS.ReadFile("config.yaml")
+	if err != nil {
+		t.Fatalf("read embedded config: %v", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("parse embedded config: %v", err)
+	}
+	if err := validateConfig("config.yaml", &doc); err != nil {
+		t.Fatalf("embedded config violates the schema:\n%v", err)
+	}
+}
+
+func TestValidateConfigReportsLocations(t *testing.T) {
+	src := `categories:
+  - name: images
+    extension: [jpg]
+  - name: ""
+    extensions: jpg
+default_categroy: x
+date_patterns:
+  - [a]
+`
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(src), &doc); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	err := validateConfig("c.yaml", &doc)
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	want := []string{
+		`c.yaml:3:5: categories[0].extension: unknown key (did you mean "extensions"?)`,
+		`c.yaml:2:5: categories[0]: missing required key "extensions"`,
+		`c.yaml:4:11: categories[1].name: must not be empty`,
+		`c.yaml:5:17: categories[1].extensions: expected a list, got "jpg"`,
+		`c.yaml:6:1: default_categroy: unknown key (did you mean "default_category"?)`,
+		`c.yaml:8:5: date_patterns[0]: expected a string, got a list`,
+	}
+	if got := err.Error(); got != strings.Join(want, "\n") {
+		t.Fatalf("unexpected errors:\ngot:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+}
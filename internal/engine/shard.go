@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// shardName names the n-th numbered sub-folder of an overfull folder.
+func shardName(n int) string {
+	return fmt.Sprintf("%04d", n)
+}
+
+// shards lists the numbered sub-folders of dir that exist, in order.
+func shards(dir string) []string {
+	var out []string
+	for n := 1; ; n++ {
+		shard := filepath.Join(dir, shardName(n))
+		if info, err := os.Stat(shard); err != nil || !info.IsDir() {
+			return out
+		}
+		out = append(out, shard)
+	}
+}
+
+// shardFor returns dir while it holds fewer than the destination's shard
+// size of files, and otherwise the first numbered sub-folder of it that
+// does. Without a shard size it always returns dir.
+func (d *destination) shardFor(dir string) (string, error) {
+	if d.shardSize <= 0 {
+		return dir, nil
+	}
+	for n := 0; ; n++ {
+		candidate := dir
+		if n > 0 {
+			candidate = filepath.Join(dir, shardName(n))
+		}
+		count, err := d.fileCount(candidate)
+		if err != nil {
+			return "", err
+		}
+		if count < d.shardSize {
+			return candidate, nil
+		}
+	}
+}
+
+// fileCount returns how many files dir holds, counting those this run
+// placed there, including in a dry run.
+func (d *destination) fileCount(dir string) (int, error) {
+	if n, ok := d.fileCounts[dir]; ok {
+		return n, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, fmt.Errorf("count files in %s: %w", dir, err)
+	}
+	n := 0
+	for _, e := range entries {
+		if !e.IsDir() && !IsSidecar(e.Name()) && !IsAppleDouble(e.Name()) {
+			n++
+		}
+	}
+	d.fileCounts[dir] = n
+	return n, nil
+}
+
+// placed counts a file this run put at path.
+func (d *destination) placed(path string) {
+	if d.shardSize > 0 {
+		d.fileCounts[filepath.Dir(path)]++
+	}
+}
@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+)
+
+// SidecarExt is appended to the name of a copied file to name its sidecar.
+const SidecarExt = ".meta.json"
+
+// sidecar records where a copied file came from, next to the copy, so its
+// provenance survives the loss of the manifests.
+type sidecar struct {
+	Source        string    `json:"source"`
+	ModTime       time.Time `json:"mtime"`
+	Hash          string    `json:"hash"`
+	HashAlgorithm string    `json:"hash_algorithm"`
+	RunID         string    `json:"run_id"`
+}
+
+// IsSidecar reports whether name is the name of a sidecar rather than of
+// an archived file.
+func IsSidecar(name string) bool {
+	return strings.HasSuffix(name, SidecarExt)
+}
+
+// writeSidecar writes s next to the copy at path.
+func writeSidecar(ops fileOps, path string, s sidecar) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ops.writeFile(path+SidecarExt, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write sidecar: %w", err)
+	}
+	return nil
+}
+
+// moveSidecar moves the sidecar of a file moved from from to to along with
+// it, unless there is none or to already has one.
+func moveSidecar(from, to string) error {
+	if _, err := os.Lstat(from + SidecarExt); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if _, err := os.Lstat(to + SidecarExt); err == nil {
+		return nil
+	}
+	if err := os.Rename(from+SidecarExt, to+SidecarExt); err != nil {
+		return fmt.Errorf("move sidecar of %s: %w", from, err)
+	}
+	return nil
+}
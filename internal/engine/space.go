@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// errSpaceUnknown is returned by freeSpace on platforms that cannot report
+// the free space of a filesystem; the pre-flight check is skipped there.
+var errSpaceUnknown = errors.New("free space unknown")
+
+// checkFreeSpace fails early when the unique content of the plan does not fit
+// on a destination. Files already sitting at their target path with the same
+// size are assumed to come from an earlier run and are not counted. Categories
+// with their own root are checked against that root. With a byte budget of
+// limit, no more than that is needed on any of them.
+func checkFreeSpace(dests []*destination, plan []plannedFile, limit int64, free func(string) (uint64, error)) error {
+	for _, d := range dests {
+		need := make(map[string]uint64)
+		var roots []string
+		seen := make(map[string]bool)
+		for _, f := range plan {
+			if seen[f.hash] {
+				continue
+			}
+			seen[f.hash] = true
+			root := d.rootFor(f.category)
+			if info, err := os.Stat(filepath.Join(root, f.relDir, f.name)); err == nil && info.Size() == f.info.Size() {
+				continue
+			}
+			if _, ok := need[root]; !ok {
+				roots = append(roots, root)
+			}
+			need[root] += uint64(f.info.Size())
+		}
+		if limit > 0 {
+			for root, n := range need {
+				need[root] = min(n, uint64(limit))
+			}
+		}
+
+		for _, root := range roots {
+			have, err := free(root)
+			if errors.Is(err, errSpaceUnknown) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("check free space on %s: %w", root, err)
+			}
+			if need[root] > have {
+				return fmt.Errorf("not enough free space on %s: need %s, have %s (use -no-space-check to skip this check)",
+					root, FormatBytes(need[root]), FormatBytes(have))
+			}
+		}
+	}
+	return nil
+}
+
+// FormatBytes renders n with a binary unit, e.g. "1.5 GiB".
+func FormatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckFreeSpace(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	mustMkdir(t, filepath.Join(dest, "documents"))
+
+	writeFile(t, src, "alpha.txt", strings.Repeat("a", 600))
+	writeFile(t, src, "bravo.txt", strings.Repeat("a", 600))
+	writeFile(t, src, "charlie.txt", strings.Repeat("c", 400))
+	// charlie.txt is already at its destination from an earlier run.
+	writeFile(t, filepath.Join(dest, "documents"), "charlie.txt", strings.Repeat("c", 400))
+
+	cfg, err := loadEmbeddedConfig()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	p := &planner{resolver: newCategoryResolver(cfg), dates: dateResolver{}, cp: &checkpoint{}, events: discardEvents{}}
+	plan, err := p.build(context.Background(), src)
+	if err != nil {
+		t.Fatalf("build plan: %v", err)
+	}
+	dests := []*destination{newDestination(dest, false)}
+
+	tests := []struct {
+		name    string
+		free    uint64
+		limit   int64
+		wantErr bool
+	}{
+		{name: "enough space for unique content", free: 600},
+		{name: "not enough space", free: 599, wantErr: true},
+		{name: "enough space for the copy budget", free: 500, limit: 500},
+		{name: "not enough space for the copy budget", free: 499, limit: 500, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkFreeSpace(dests, plan, tt.limit, func(string) (uint64, error) { return tt.free, nil })
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "not enough free space") {
+					t.Fatalf("expected free space error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected success, got %v", err)
+			}
+		})
+	}
+
+	// With documents on their own root, that root needs the space.
+	other := filepath.Join(workspace, "other")
+	split := newDestination(dest, false)
+	split.roots = map[string]string{"documents": other}
+	var asked []string
+	err = checkFreeSpace([]*destination{split}, plan, 0, func(root string) (uint64, error) {
+		asked = append(asked, root)
+		return 1000, nil
+	})
+	if err != nil || len(asked) != 1 || asked[0] != other {
+		t.Fatalf("expected only %s to be checked, got %v (err %v)", other, asked, err)
+	}
+
+	err = checkFreeSpace(dests, plan, 0, func(string) (uint64, error) { return 0, errSpaceUnknown })
+	if err != nil {
+		t.Fatalf("expected unknown free space to be ignored, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "documents", "alpha.txt")); err == nil {
+		t.Fatalf("free space check must not write to the destination")
+	}
+}
+
+func TestRun_FileLargerThanTheBudgetIsSkipped(t *testing.T) {
+	src, dest := t.TempDir(), t.TempDir()
+	writeFile(t, src, "a.txt", strings.Repeat("a", 200))
+	writeFile(t, src, "b.txt", strings.Repeat("b", 50))
+	cfg := Config{Categories: []Category{{Name: "documents", Extensions: []string{"txt"}}}}
+
+	var warnings []string
+	res, err := Run(t.Context(), Options{Config: cfg, Source: src, Dest: dest, MaxBytes: 100, NoSpaceCheck: true, Warnf: func(format string, args ...any) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Copied != 1 || res.Stopped {
+		t.Fatalf("expected b.txt copied past a.txt, got %d copied (stopped %v)", res.Copied, res.Stopped)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "documents", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(src, "a.txt") + " is larger than the copy budget of 100 B; skipping it"
+	if len(warnings) != 1 || warnings[0] != want {
+		t.Fatalf("unexpected warnings %q", warnings)
+	}
+}
@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// removedDir holds, per run, the destination files a run with
+// SyncDeletions took out because their sources were deleted.
+const removedDir = "removed"
+
+// orphan is a destination file whose every known source is gone.
+type orphan struct {
+	path string
+	srcs []string
+	dest *destination
+}
+
+// orphans lists the files of dests that the manifests of the primary
+// destination trace back only to files below source, none of which still
+// exist. Files with a source elsewhere, e.g. on a card that is not
+// plugged in, are kept.
+func orphans(dests []*destination, source string) ([]orphan, error) {
+	primary := dests[0]
+	paths, err := Manifests(primary.root)
+	if err != nil {
+		return nil, fmt.Errorf("read manifests: %w", err)
+	}
+	moved, err := LoadRelocations(primary.root)
+	if err != nil {
+		return nil, err
+	}
+	srcs := make(map[string][]string)
+	for _, path := range paths {
+		entries, err := ReadManifest(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			switch e.Action {
+			case EventCopied, EventSkippedPresent, EventSkippedDuplicate:
+			default:
+				continue
+			}
+			dest := moved.Where(e.Dest)
+			srcs[dest] = append(srcs[dest], e.Src)
+		}
+	}
+
+	var out []orphan
+	for path, from := range srcs {
+		gone := true
+		for _, src := range from {
+			if !within(source, src) {
+				gone = false
+				break
+			}
+			if _, err := os.Lstat(src); !errors.Is(err, fs.ErrNotExist) {
+				gone = false
+				break
+			}
+		}
+		if !gone {
+			continue
+		}
+		for _, d := range dests {
+			if _, _, ok := d.locate(path); !ok {
+				continue
+			}
+			if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() {
+				out = append(out, orphan{path: path, srcs: from, dest: d})
+			}
+			break
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].path < out[j].path })
+	return out, nil
+}
+
+// within reports whether path is dir or below it.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+// removeOrphans moves orphans into the removed folder of run id in their
+// destination, recording each in a move log for undo and as an
+// EventRemoved. It returns the move log, or "" when nothing moved.
+func removeOrphans(dest string, id string, list []orphan, events Sink) (string, error) {
+	if len(list) == 0 {
+		return "", nil
+	}
+	log, err := startMoveLog(dest)
+	if err != nil {
+		return "", err
+	}
+	for _, o := range list {
+		category, rel, _ := o.dest.locate(o.path)
+		to := filepath.Join(o.dest.root, StateDir, removedDir, id, rel)
+		if err := moveFile(o.path, to, log); err != nil {
+			log.close()
+			return log.path, err
+		}
+		events.Emit(Event{Type: EventRemoved, Src: o.srcs[0], Dest: o.path, Category: category})
+	}
+	return log.path, log.close()
+}
+
+// previewOrphans prints what removeOrphans would do.
+func previewOrphans(w io.Writer, list []orphan) {
+	for _, o := range list {
+		fmt.Fprintf(w, "remove %s (source %s deleted)\n", o.path, o.srcs[0])
+	}
+}
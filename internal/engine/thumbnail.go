@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// ThumbnailDir mirrors the archive's layout with a small JPEG per image or
+// movie: <dest>/.thumbnails/images/2024/202401/a.png.jpg.
+const ThumbnailDir = ".thumbnails"
+
+// DefaultThumbnailSize is the longest edge of a thumbnail in pixels.
+const DefaultThumbnailSize = 160
+
+// thumbnailer creates the thumbnails of copied files. Movies need ffmpeg on
+// PATH and are left without a thumbnail otherwise.
+type thumbnailer struct {
+	size   int
+	ffmpeg string
+	ops    fileOps
+}
+
+func newThumbnailer(size int, ops fileOps) *thumbnailer {
+	ffmpeg, _ := exec.LookPath("ffmpeg")
+	return &thumbnailer{size: size, ffmpeg: ffmpeg, ops: ops}
+}
+
+// thumbnailPath returns where the thumbnail of file, which lies below root,
+// is stored.
+func thumbnailPath(root, file string) (string, bool) {
+	rel, err := filepath.Rel(root, file)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", false
+	}
+	return filepath.Join(root, ThumbnailDir, rel+".jpg"), true
+}
+
+// ensure creates the thumbnail of path unless it already exists. Files in
+// other categories and image formats the standard library cannot decode are
+// skipped without an error.
+func (t *thumbnailer) ensure(ctx context.Context, root, path, category string) error {
+	out, ok := thumbnailPath(root, path)
+	if !ok {
+		return nil
+	}
+	if _, err := os.Stat(out); err == nil {
+		return nil
+	}
+
+	var data []byte
+	var err error
+	switch {
+	case category == "images":
+		data, err = imageThumbnail(path, t.size)
+		if errors.Is(err, image.ErrFormat) {
+			return nil
+		}
+	case category == "movies" && t.ffmpeg != "":
+		data, err = t.videoThumbnail(ctx, path)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := t.ops.mkdirAll(filepath.Dir(out)); err != nil {
+		return fmt.Errorf("create thumbnail directory: %w", err)
+	}
+	tmp, err := createTemp(out, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write thumbnail: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write thumbnail: %w", err)
+	}
+	if err := t.ops.rename(tmp.Name(), out); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write thumbnail: %w", err)
+	}
+	return nil
+}
+
+// videoThumbnail has ffmpeg render the first frame of a movie as JPEG.
+func (t *thumbnailer) videoThumbnail(ctx context.Context, path string) ([]byte, error) {
+	size := strconv.Itoa(t.size)
+	cmd := exec.CommandContext(ctx, t.ffmpeg, "-nostdin", "-loglevel", "error",
+		"-i", path, "-frames:v", "1",
+		"-vf", "scale=w="+size+":h="+size+":force_original_aspect_ratio=decrease",
+		"-f", "image2", "-c:v", "mjpeg", "pipe:1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg %s: %w: %s", path, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("ffmpeg %s: no frame decoded", path)
+	}
+	return out, nil
+}
+
+// imageThumbnail decodes a GIF, JPEG or PNG file and returns a JPEG whose
+// longest edge is at most size pixels.
+func imageThumbnail(path string, size int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleImage(img, size), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, fmt.Errorf("encode thumbnail of %s: %w", path, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleImage shrinks img so its longest edge is at most size pixels,
+// averaging the source pixels behind each target pixel. Smaller images are
+// only copied.
+func scaleImage(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		dst := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+		return dst
+	}
+	tw, th := size, h*size/w
+	if h > w {
+		tw, th = w*size/h, size
+	}
+	tw, th = max(tw, 1), max(th, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < max(y1, y0+1); sy++ {
+				for sx := x0; sx < max(x1, x0+1); sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(bl / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	return dst
+}
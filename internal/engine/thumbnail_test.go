@@ -0,0 +1,23 @@
+package engine
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestScaleImageKeepsAspectRatio(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 100))
+	for i := 0; i < 400; i++ {
+		for j := 0; j < 100; j++ {
+			img.Set(i, j, color.RGBA{R: 200, A: 255})
+		}
+	}
+	got := scaleImage(img, 160)
+	if b := got.Bounds(); b.Dx() != 160 || b.Dy() != 40 {
+		t.Fatalf("unexpected thumbnail bounds %v", b)
+	}
+	if r, _, _, _ := got.At(10, 10).RGBA(); r>>8 != 200 {
+		t.Fatalf("expected averaged color to be preserved, got red %d", r>>8)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package engine
+
+import "syscall"
+
+// transientErrors are the errors isTransient retries: a busy or interrupted
+// system call and a timeout.
+var transientErrors = []error{syscall.EINTR, syscall.EBUSY, syscall.ETIMEDOUT}
@@ -0,0 +1,13 @@
+//go:build unix
+
+package engine
+
+import "syscall"
+
+// transientErrors are the errors isTransient retries: a busy or interrupted
+// system call, a timeout, and a network filesystem losing its server.
+var transientErrors = []error{
+	syscall.EAGAIN, syscall.EINTR, syscall.EBUSY, syscall.ETIMEDOUT,
+	syscall.ESTALE, syscall.ECONNRESET, syscall.ECONNABORTED,
+	syscall.ENETDOWN, syscall.ENETUNREACH, syscall.EHOSTDOWN, syscall.EHOSTUNREACH,
+}
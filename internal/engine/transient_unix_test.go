@@ -0,0 +1,35 @@
+//go:build unix
+
+package engine
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+	"testing"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "busy", err: syscall.EBUSY, want: true},
+		{name: "try again", err: &fs.PathError{Op: "read", Path: "/mnt/nas/a.txt", Err: syscall.EAGAIN}, want: true},
+		{name: "stale NFS handle", err: &fs.PathError{Op: "open", Path: "/mnt/nas/a.txt", Err: syscall.ESTALE}, want: true},
+		{name: "connection reset", err: syscall.ECONNRESET, want: true},
+		{name: "full disk", err: &fs.PathError{Op: "write", Path: "/dest/a.txt", Err: syscall.ENOSPC}},
+		{name: "permission denied", err: &fs.PathError{Op: "open", Path: "/dest/a.txt", Err: syscall.EACCES}},
+		{name: "missing file", err: syscall.ENOENT},
+		{name: "unknown", err: errors.New("unexpected EOF")},
+		{name: "file timeout", err: errFileTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransient(tt.err); got != tt.want {
+				t.Fatalf("isTransient(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package engine
+
+import "golang.org/x/sys/windows"
+
+// transientErrors are the errors isTransient retries: a busy device, a
+// timeout, and a network share dropping its connection.
+var transientErrors = []error{
+	windows.ERROR_BUSY, windows.ERROR_NOT_READY, windows.ERROR_SEM_TIMEOUT,
+	windows.ERROR_NETNAME_DELETED, windows.ERROR_UNEXP_NET_ERR, windows.ERROR_NETWORK_UNREACHABLE,
+}
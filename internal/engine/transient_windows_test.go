@@ -0,0 +1,30 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestRetry_LockedByAnotherProgram(t *testing.T) {
+	for _, errno := range []windows.Errno{windows.ERROR_SHARING_VIOLATION, windows.ERROR_LOCK_VIOLATION} {
+		t.Run(errno.Error(), func(t *testing.T) {
+			locked := fmt.Errorf("hash: %w", &fs.PathError{Op: "open", Path: `C:\in\a.pst`, Err: errno})
+			calls := 0
+			_, err := retry(context.Background(), fileOps{retries: 2, backoff: time.Millisecond}, "test", func() (string, error) {
+				calls++
+				if calls == 1 {
+					return "", locked
+				}
+				return "ok", nil
+			})
+			if err != nil || calls != 2 {
+				t.Fatalf("expected the locked file to be retried, got %d calls and %v", calls, err)
+			}
+		})
+	}
+}
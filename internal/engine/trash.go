@@ -0,0 +1,12 @@
+package engine
+
+import "os"
+
+// DisposeFile removes path, or moves it to the OS trash when useTrash is set.
+// It returns where the file went ("" when unlinked).
+func DisposeFile(path string, useTrash bool) (string, error) {
+	if useTrash {
+		return moveToTrash(path)
+	}
+	return "", os.Remove(path)
+}
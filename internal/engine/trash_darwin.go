@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// moveToTrash moves path to ~/.Trash, or to the .Trashes folder of its own
+// volume when it lives on another disk, like Finder does.
+func moveToTrash(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("find trash for %s: %w", abs, err)
+	}
+
+	trashDir := filepath.Join(home, ".Trash")
+	target := filepath.Join(trashDir, trashName(trashDir, filepath.Base(abs), "%s %d%s"))
+	err = os.Rename(abs, target)
+	if errors.Is(err, syscall.EXDEV) {
+		top, topErr := mountTop(abs)
+		if topErr != nil {
+			return "", fmt.Errorf("find trash for %s: %w", abs, topErr)
+		}
+		trashDir = filepath.Join(top, ".Trashes", strconv.Itoa(os.Getuid()))
+		if err := os.MkdirAll(trashDir, 0o700); err != nil {
+			return "", fmt.Errorf("create trash: %w", err)
+		}
+		target = filepath.Join(trashDir, trashName(trashDir, filepath.Base(abs), "%s %d%s"))
+		err = os.Rename(abs, target)
+	}
+	if err != nil {
+		return "", fmt.Errorf("move to trash: %w", err)
+	}
+	return target, nil
+}
+
+// trashName picks a name in dir that does not exist yet, keeping the
+// extension when adding a counter.
+func trashName(dir, name string, format string) string {
+	if _, err := os.Lstat(filepath.Join(dir, name)); os.IsNotExist(err) {
+		return name
+	}
+	ext := filepath.Ext(name)
+	base := name[:len(name)-len(ext)]
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf(format, base, i, ext)
+		if _, err := os.Lstat(filepath.Join(dir, candidate)); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
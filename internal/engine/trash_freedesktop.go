@@ -0,0 +1,118 @@
+//go:build unix && !darwin
+
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// moveToTrash follows the FreeDesktop.org Trash specification: files on the
+// home filesystem go to $XDG_DATA_HOME/Trash, others to the trash directory
+// at the top of their own mount, each with a .trashinfo record so desktop
+// environments can restore them.
+func moveToTrash(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	trashDir, infoPath, err := freedesktopTrashDir(abs)
+	if err != nil {
+		return "", fmt.Errorf("find trash for %s: %w", abs, err)
+	}
+	filesDir := filepath.Join(trashDir, "files")
+	infoDir := filepath.Join(trashDir, "info")
+	for _, dir := range []string{filesDir, infoDir} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return "", fmt.Errorf("create trash: %w", err)
+		}
+	}
+
+	// Creating the .trashinfo file exclusively reserves the name.
+	name := filepath.Base(abs)
+	var info *os.File
+	for i := 1; ; i++ {
+		candidate := name
+		if i > 1 {
+			candidate = name + "." + strconv.Itoa(i)
+		}
+		info, err = os.OpenFile(filepath.Join(infoDir, candidate+".trashinfo"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("create trash info: %w", err)
+		}
+		name = candidate
+		break
+	}
+
+	content := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: infoPath}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+	_, writeErr := info.WriteString(content)
+	closeErr := info.Close()
+	if err := errors.Join(writeErr, closeErr); err != nil {
+		os.Remove(info.Name())
+		return "", fmt.Errorf("write trash info: %w", err)
+	}
+
+	target := filepath.Join(filesDir, name)
+	if err := os.Rename(abs, target); err != nil {
+		os.Remove(info.Name())
+		return "", fmt.Errorf("move to trash: %w", err)
+	}
+	return target, nil
+}
+
+// freedesktopTrashDir returns the trash directory for abs and the path to
+// record in its .trashinfo (absolute for the home trash, relative to the
+// mount point otherwise).
+func freedesktopTrashDir(abs string) (string, string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", err
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	homeTrash := filepath.Join(dataHome, "Trash")
+	if err := os.MkdirAll(homeTrash, 0o700); err != nil {
+		return "", "", err
+	}
+
+	fileDev, err := deviceOf(abs)
+	if err != nil {
+		return "", "", err
+	}
+	homeDev, err := deviceOf(homeTrash)
+	if err != nil {
+		return "", "", err
+	}
+	if fileDev == homeDev {
+		return homeTrash, abs, nil
+	}
+
+	top, err := mountTop(abs)
+	if err != nil {
+		return "", "", err
+	}
+	rel, err := filepath.Rel(top, abs)
+	if err != nil {
+		return "", "", err
+	}
+	uid := strconv.Itoa(os.Getuid())
+
+	// $topdir/.Trash must be a real, sticky directory to be used.
+	shared := filepath.Join(top, ".Trash")
+	if info, err := os.Lstat(shared); err == nil && info.IsDir() && info.Mode()&os.ModeSticky != 0 {
+		return filepath.Join(shared, uid), rel, nil
+	}
+	return filepath.Join(top, ".Trash-"+uid), rel, nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix && !(windows && !386)
+
+package engine
+
+import "errors"
+
+func moveToTrash(string) (string, error) {
+	return "", errors.New("moving files to the trash is not supported on this platform")
+}
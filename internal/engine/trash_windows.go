@@ -0,0 +1,61 @@
+//go:build windows && !386
+
+package engine
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	foDelete          = 0x3
+	fofSilent         = 0x4
+	fofNoConfirmation = 0x10
+	fofAllowUndo      = 0x40
+	fofNoErrorUI      = 0x400
+)
+
+// shFileOpStruct mirrors SHFILEOPSTRUCTW. The 32-bit header packs it to one
+// byte, which is why this file excludes 386.
+type shFileOpStruct struct {
+	hwnd                  uintptr
+	wFunc                 uint32
+	pFrom                 *uint16
+	pTo                   *uint16
+	fFlags                uint16
+	fAnyOperationsAborted int32
+	hNameMappings         uintptr
+	lpszProgressTitle     *uint16
+}
+
+var procSHFileOperationW = syscall.NewLazyDLL("shell32.dll").NewProc("SHFileOperationW")
+
+// moveToTrash sends path to the Recycle Bin.
+func moveToTrash(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	from, err := syscall.UTF16FromString(abs)
+	if err != nil {
+		return "", err
+	}
+	// pFrom is a list terminated by an extra NUL.
+	from = append(from, 0)
+
+	op := shFileOpStruct{
+		wFunc:  foDelete,
+		pFrom:  &from[0],
+		fFlags: fofAllowUndo | fofNoConfirmation | fofSilent | fofNoErrorUI,
+	}
+	r, _, _ := procSHFileOperationW.Call(uintptr(unsafe.Pointer(&op)))
+	if r != 0 {
+		return "", fmt.Errorf("move to recycle bin: SHFileOperation error 0x%x", r)
+	}
+	if op.fAnyOperationsAborted != 0 {
+		return "", fmt.Errorf("move to recycle bin: operation aborted")
+	}
+	return "Recycle Bin", nil
+}
@@ -0,0 +1,165 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// treeLeafSize is the size of the leaves of sha256-tree. It is part of the
+// digest: changing it changes the hash of every file larger than a leaf.
+const treeLeafSize = 4 << 20
+
+// parallelHashSize is the size from which sha256-tree hashes the leaves of
+// a file on every core instead of one after another. Below it, starting
+// the workers costs more than it saves.
+const parallelHashSize = 64 << 20
+
+// treeHash is sha256-tree: the file is cut into leaves of treeLeafSize,
+// each hashed as SHA-256(0x00 || leaf), and the digest is
+// SHA-256(0x01 || leaf digests || length as a big-endian uint64). Leaves
+// do not depend on one another, so a large file can be hashed on several
+// cores with the same result as hashing it as a stream.
+type treeHash struct {
+	leaf hash.Hash
+	// inLeaf is the number of bytes of the current leaf written so far.
+	inLeaf  int
+	total   uint64
+	digests []byte
+}
+
+func newTreeHash() hash.Hash {
+	h := &treeHash{leaf: sha256.New()}
+	h.Reset()
+	return h
+}
+
+func (h *treeHash) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		chunk := min(len(p), treeLeafSize-h.inLeaf)
+		h.leaf.Write(p[:chunk])
+		h.inLeaf += chunk
+		h.total += uint64(chunk)
+		p = p[chunk:]
+		if h.inLeaf == treeLeafSize {
+			h.digests = h.leaf.Sum(h.digests)
+			h.startLeaf()
+		}
+	}
+	return n, nil
+}
+
+func (h *treeHash) Sum(b []byte) []byte {
+	digests := h.digests
+	if h.inLeaf > 0 {
+		digests = h.leaf.Sum(digests[:len(digests):len(digests)])
+	}
+	return treeRoot(b, digests, h.total)
+}
+
+func (h *treeHash) Reset() {
+	h.inLeaf, h.total, h.digests = 0, 0, h.digests[:0]
+	h.startLeaf()
+}
+
+func (h *treeHash) Size() int      { return sha256.Size }
+func (h *treeHash) BlockSize() int { return sha256.BlockSize }
+
+func (h *treeHash) startLeaf() {
+	h.leaf.Reset()
+	h.leaf.Write([]byte{0x00})
+	h.inLeaf = 0
+}
+
+// treeRoot appends the sha256-tree digest of the leaf digests of total
+// bytes to b.
+func treeRoot(b, digests []byte, total uint64) []byte {
+	root := sha256.New()
+	root.Write([]byte{0x01})
+	root.Write(digests)
+	root.Write(binary.BigEndian.AppendUint64(nil, total))
+	return root.Sum(b)
+}
+
+// hashTreeParallel returns the sha256-tree digest of the size bytes of f,
+// hashing its leaves with up to workers goroutines that each read their
+// own leaves. ctx is checked before every leaf.
+func hashTreeParallel(ctx context.Context, f io.ReaderAt, size int64, workers int) ([]byte, error) {
+	leaves := int((size + treeLeafSize - 1) / treeLeafSize)
+	digests := make([]byte, leaves*sha256.Size)
+	next := make(chan int)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for range min(workers, leaves) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, treeLeafSize)
+			h := sha256.New()
+			for i := range next {
+				off := int64(i) * treeLeafSize
+				n := int(min(size-off, treeLeafSize))
+				// A full read may end in io.EOF at the end of the file.
+				if read, err := f.ReadAt(buf[:n], off); err != nil && (read < n || err != io.EOF) {
+					errs <- err
+					return
+				}
+				h.Reset()
+				h.Write([]byte{0x00})
+				h.Write(buf[:n])
+				h.Sum(digests[i*sha256.Size : i*sha256.Size])
+			}
+		}()
+	}
+	var err error
+feed:
+	for i := range leaves {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		select {
+		case next <- i:
+		case err = <-errs:
+			break feed
+		case <-ctx.Done():
+			err = ctx.Err()
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	if err == nil {
+		select {
+		case err = <-errs:
+		default:
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return treeRoot(nil, digests, uint64(size)), nil
+}
+
+// hashLargeFile hashes f with every core when h is sha256-tree and f is at
+// least parallelHashSize; ok is false when f is to be hashed as a stream.
+func hashLargeFile(ctx context.Context, f *os.File, h hash.Hash) (sum []byte, ok bool, err error) {
+	if _, tree := h.(*treeHash); !tree {
+		return nil, false, nil
+	}
+	info, err := f.Stat()
+	if err != nil || info.Size() < parallelHashSize {
+		return nil, false, nil
+	}
+	sum, err = hashTreeParallel(ctx, f, info.Size(), runtime.GOMAXPROCS(0))
+	if err != nil {
+		return nil, true, fmt.Errorf("hash %s: %w", f.Name(), err)
+	}
+	return sum, true, nil
+}
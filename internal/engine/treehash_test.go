@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestTreeHash_ParallelMatchesStream(t *testing.T) {
+	data := make([]byte, 3*treeLeafSize+12345)
+	rand.NewChaCha8([32]byte{}).Read(data)
+
+	for _, size := range []int{0, 1, treeLeafSize, treeLeafSize + 1, len(data)} {
+		h := newTreeHash()
+		// Writes that straddle leaves.
+		if _, err := io.CopyBuffer(h, bytes.NewReader(data[:size]), make([]byte, 1000003)); err != nil {
+			t.Fatal(err)
+		}
+		stream := h.Sum(nil)
+		if again := h.Sum(nil); !bytes.Equal(stream, again) {
+			t.Fatalf("size %d: Sum changed the state", size)
+		}
+
+		// Leaves by hand, as documented.
+		var digests []byte
+		for off := 0; off < size; off += treeLeafSize {
+			leaf := sha256.Sum256(append([]byte{0x00}, data[off:min(off+treeLeafSize, size)]...))
+			digests = append(digests, leaf[:]...)
+		}
+		want := sha256.Sum256(binary.BigEndian.AppendUint64(append([]byte{0x01}, digests...), uint64(size)))
+		if !bytes.Equal(stream, want[:]) {
+			t.Fatalf("size %d: stream digest %x, want %x", size, stream, want)
+		}
+
+		for _, workers := range []int{1, 3, 8} {
+			parallel, err := hashTreeParallel(context.Background(), bytes.NewReader(data[:size]), int64(size), workers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(parallel, stream) {
+				t.Fatalf("size %d, %d workers: parallel digest %x, stream %x", size, workers, parallel, stream)
+			}
+		}
+	}
+}
+
+func TestTreeHash_ParallelStopsOnCancel(t *testing.T) {
+	data := make([]byte, 4*treeLeafSize)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := hashTreeParallel(ctx, bytes.NewReader(data), int64(len(data)), 2); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	short := bytes.NewReader(data[:treeLeafSize])
+	if _, err := hashTreeParallel(context.Background(), short, int64(len(data)), 2); err == nil {
+		t.Fatal("expected an error for a file shorter than its size")
+	}
+}
@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxUnknownExamples caps the example paths kept per unknown extension.
+const maxUnknownExamples = 3
+
+// noExtension stands for files without an extension in unknown.csv.
+const noExtension = "(none)"
+
+type unknownExtension struct {
+	count    int
+	examples []string
+}
+
+// noteUnknown counts a source file that no resolver had a category for.
+func (p *planner) noteUnknown(path string) {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if ext == "" {
+		ext = noExtension
+	}
+	if p.unknown == nil {
+		p.unknown = make(map[string]*unknownExtension)
+	}
+	u, ok := p.unknown[ext]
+	if !ok {
+		u = &unknownExtension{}
+		p.unknown[ext] = u
+	}
+	u.count++
+	if len(u.examples) < maxUnknownExamples {
+		u.examples = append(u.examples, path)
+	}
+}
+
+// writeUnknownExtensions writes one "extension,count,example..." line per
+// unknown extension, most frequent first.
+func writeUnknownExtensions(path string, unknown map[string]*unknownExtension) error {
+	exts := make([]string, 0, len(unknown))
+	for ext := range unknown {
+		exts = append(exts, ext)
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		a, b := unknown[exts[i]], unknown[exts[j]]
+		if a.count != b.count {
+			return a.count > b.count
+		}
+		return exts[i] < exts[j]
+	})
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("write unknown extensions: %w", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	for _, ext := range exts {
+		u := unknown[ext]
+		record := append([]string{ext, strconv.Itoa(u.count)}, u.examples...)
+		if err := w.Write(record); err != nil {
+			return fmt.Errorf("write unknown extensions: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("write unknown extensions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// videoInfo is what the container header of a video says about it.
+type videoInfo struct {
+	duration      time.Duration
+	width, height int
+}
+
+var errUnknownVideo = errors.New("not an MP4, MOV or Matroska file")
+
+// probeVideo reads the duration and the largest frame size of the video
+// tracks from an MP4/MOV or Matroska/WebM header, without decoding any
+// frames.
+func probeVideo(path string) (videoInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return videoInfo{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return videoInfo{}, err
+	}
+
+	var head [8]byte
+	if _, err := f.ReadAt(head[:], 0); err != nil {
+		return videoInfo{}, errUnknownVideo
+	}
+	var v videoInfo
+	switch {
+	case bytes.Equal(head[:4], []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		err = probeMatroska(f, info.Size(), &v)
+	case isMP4Box(string(head[4:8])):
+		err = probeMP4(f, info.Size(), &v)
+	default:
+		return videoInfo{}, errUnknownVideo
+	}
+	if err != nil {
+		return videoInfo{}, fmt.Errorf("probe %s: %w", path, err)
+	}
+	return v, nil
+}
+
+func isMP4Box(typ string) bool {
+	switch typ {
+	case "ftyp", "moov", "mdat", "free", "skip", "wide", "pnot":
+		return true
+	}
+	return false
+}
+
+// probeMP4 reads mvhd for the duration and the tkhd of every track for the
+// frame size.
+func probeMP4(r io.ReaderAt, size int64, v *videoInfo) error {
+	return walkBoxes(r, 0, size, func(typ string, body, end int64) (bool, error) {
+		switch typ {
+		case "moov", "trak":
+			return true, nil
+		case "mvhd":
+			return false, readMVHD(io.NewSectionReader(r, body, end-body), v)
+		case "tkhd":
+			return false, readTKHD(io.NewSectionReader(r, body, end-body), v)
+		}
+		return false, nil
+	})
+}
+
+// walkBoxes calls fn for every ISO BMFF box between start and end with the
+// offsets of its body; fn returns whether to descend into the box.
+func walkBoxes(r io.ReaderAt, start, end int64, fn func(typ string, body, end int64) (bool, error)) error {
+	for off := start; off+8 <= end; {
+		var hdr [16]byte
+		if _, err := r.ReadAt(hdr[:8], off); err != nil {
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(hdr[:4]))
+		typ := string(hdr[4:8])
+		body := off + 8
+		switch size {
+		case 0:
+			size = end - off
+		case 1:
+			if _, err := r.ReadAt(hdr[8:16], off+8); err != nil {
+				return err
+			}
+			size = int64(binary.BigEndian.Uint64(hdr[8:16]))
+			body = off + 16
+		}
+		if size < body-off || off+size > end {
+			return fmt.Errorf("box %q overruns its parent", typ)
+		}
+		descend, err := fn(typ, body, off+size)
+		if err != nil {
+			return err
+		}
+		if descend {
+			if err := walkBoxes(r, body, off+size, fn); err != nil {
+				return err
+			}
+		}
+		off += size
+	}
+	return nil
+}
+
+func readMVHD(r io.Reader, v *videoInfo) error {
+	var version [4]byte
+	if _, err := io.ReadFull(r, version[:]); err != nil {
+		return err
+	}
+	var timescale uint32
+	var duration uint64
+	if version[0] == 1 {
+		var b [28]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return err
+		}
+		timescale = binary.BigEndian.Uint32(b[16:20])
+		duration = binary.BigEndian.Uint64(b[20:28])
+	} else {
+		var b [16]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return err
+		}
+		timescale = binary.BigEndian.Uint32(b[8:12])
+		duration = uint64(binary.BigEndian.Uint32(b[12:16]))
+	}
+	if timescale > 0 {
+		v.duration = time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+	}
+	return nil
+}
+
+func readTKHD(r io.Reader, v *videoInfo) error {
+	var version [4]byte
+	if _, err := io.ReadFull(r, version[:]); err != nil {
+		return err
+	}
+	// Times, track ID and duration, then layer, group, volume and the
+	// matrix before the 16.16 fixed-point width and height.
+	skip := 20 + 52
+	if version[0] == 1 {
+		skip = 32 + 52
+	}
+	if _, err := io.CopyN(io.Discard, r, int64(skip)); err != nil {
+		return err
+	}
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return err
+	}
+	v.width = max(v.width, int(binary.BigEndian.Uint32(b[:4])>>16))
+	v.height = max(v.height, int(binary.BigEndian.Uint32(b[4:])>>16))
+	return nil
+}
+
+// Matroska element IDs the probe reads.
+const (
+	mkvSegment       = 0x18538067
+	mkvInfo          = 0x1549A966
+	mkvTimecodeScale = 0x2AD7B1
+	mkvDuration      = 0x4489
+	mkvTracks        = 0x1654AE6B
+	mkvTrackEntry    = 0xAE
+	mkvVideo         = 0xE0
+	mkvPixelWidth    = 0xB0
+	mkvPixelHeight   = 0xBA
+	mkvCluster       = 0x1F43B675
+)
+
+// probeMatroska reads the segment info and track headers of a Matroska or
+// WebM file; both come before the first cluster.
+func probeMatroska(r io.ReaderAt, size int64, v *videoInfo) error {
+	scale := uint64(time.Millisecond)
+	var duration float64
+	err := walkEBML(r, 0, size, func(id, data, n int64) (bool, error) {
+		switch id {
+		case mkvSegment, mkvInfo, mkvTracks, mkvTrackEntry, mkvVideo:
+			return true, nil
+		case mkvTimecodeScale:
+			s, err := readEBMLUint(r, data, n)
+			scale = s
+			return false, err
+		case mkvDuration:
+			d, err := readEBMLFloat(r, data, n)
+			duration = d
+			return false, err
+		case mkvPixelWidth:
+			w, err := readEBMLUint(r, data, n)
+			v.width = max(v.width, int(w))
+			return false, err
+		case mkvPixelHeight:
+			h, err := readEBMLUint(r, data, n)
+			v.height = max(v.height, int(h))
+			return false, err
+		case mkvCluster:
+			return false, errStopWalk
+		}
+		return false, nil
+	})
+	if err != nil && !errors.Is(err, errStopWalk) {
+		return err
+	}
+	v.duration = time.Duration(duration * float64(scale))
+	return nil
+}
+
+var errStopWalk = errors.New("stop")
+
+// errTruncatedEBML is returned for an element whose header runs past the
+// end of its parent.
+var errTruncatedEBML = errors.New("EBML element header runs past its parent")
+
+// walkEBML calls fn for every element between start and end; fn returns
+// whether to descend into the element.
+func walkEBML(r io.ReaderAt, start, end int64, fn func(id, data, size int64) (bool, error)) error {
+	for off := start; off < end; {
+		id, idLen, err := readVint(r, off, false)
+		if err != nil {
+			return err
+		}
+		size, sizeLen, err := readVint(r, off+idLen, true)
+		if err != nil {
+			return err
+		}
+		data := off + idLen + sizeLen
+		if data > end {
+			return errTruncatedEBML
+		}
+		if size < 0 || data+size > end {
+			// An unknown size runs to the end of the parent.
+			size = end - data
+		}
+		descend, err := fn(id, data, size)
+		if err != nil {
+			return err
+		}
+		if descend {
+			if err := walkEBML(r, data, data+size, fn); err != nil {
+				return err
+			}
+		}
+		off = data + size
+	}
+	return nil
+}
+
+// readVint reads an EBML variable-length integer at off. IDs keep their
+// length marker; sizes drop it, and a size of all ones is returned as -1.
+func readVint(r io.ReaderAt, off int64, isSize bool) (int64, int64, error) {
+	var b [8]byte
+	if _, err := r.ReadAt(b[:1], off); err != nil {
+		return 0, 0, err
+	}
+	n := 1
+	for mask := byte(0x80); n <= 8 && b[0]&mask == 0; mask >>= 1 {
+		n++
+	}
+	if n > 8 {
+		return 0, 0, errors.New("invalid EBML length")
+	}
+	if _, err := r.ReadAt(b[1:n], off+1); err != nil {
+		return 0, 0, err
+	}
+	var v uint64
+	for i := range n {
+		v = v<<8 | uint64(b[i])
+	}
+	if !isSize {
+		return int64(v), int64(n), nil
+	}
+	marker := uint64(1) << (7 * n)
+	v &^= marker
+	if v == marker-1 {
+		return -1, int64(n), nil
+	}
+	return int64(v), int64(n), nil
+}
+
+func readEBMLUint(r io.ReaderAt, off, n int64) (uint64, error) {
+	if n < 1 || n > 8 {
+		return 0, fmt.Errorf("EBML integer of %d bytes", n)
+	}
+	var b [8]byte
+	if _, err := r.ReadAt(b[8-n:], off); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b[:]), nil
+}
+
+func readEBMLFloat(r io.ReaderAt, off, n int64) (float64, error) {
+	var b [8]byte
+	switch n {
+	case 4:
+		if _, err := r.ReadAt(b[:4], off); err != nil {
+			return 0, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b[:4]))), nil
+	case 8:
+		if _, err := r.ReadAt(b[:], off); err != nil {
+			return 0, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b[:])), nil
+	}
+	return 0, fmt.Errorf("EBML float of %d bytes", n)
+}
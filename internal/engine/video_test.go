@@ -0,0 +1,191 @@
+package engine
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func mp4Box(typ string, body ...[]byte) []byte {
+	var data []byte
+	for _, b := range body {
+		data = append(data, b...)
+	}
+	box := binary.BigEndian.AppendUint32(nil, uint32(8+len(data)))
+	return append(append(box, typ...), data...)
+}
+
+func mp4Header(timescale, duration uint32, width, height uint16) []byte {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], timescale)
+	binary.BigEndian.PutUint32(mvhd[16:], duration)
+	tkhd := make([]byte, 84)
+	binary.BigEndian.PutUint32(tkhd[76:], uint32(width)<<16)
+	binary.BigEndian.PutUint32(tkhd[80:], uint32(height)<<16)
+	audio := make([]byte, 84)
+	return append(mp4Box("ftyp", []byte("isom\x00\x00\x02\x00")),
+		append(mp4Box("mdat", make([]byte, 64)),
+			mp4Box("moov", mp4Box("mvhd", mvhd), mp4Box("trak", mp4Box("tkhd", tkhd)), mp4Box("trak", mp4Box("tkhd", audio)))...)...)
+}
+
+// ebml encodes an element whose ID is given with its length marker.
+func ebml(id uint32, body ...[]byte) []byte {
+	var data []byte
+	for _, b := range body {
+		data = append(data, b...)
+	}
+	var out []byte
+	for shift := 24; shift >= 0; shift -= 8 {
+		if b := byte(id >> shift); b != 0 || len(out) > 0 {
+			out = append(out, b)
+		}
+	}
+	size := binary.BigEndian.AppendUint64(nil, uint64(len(data))|1<<56)
+	return append(append(out, size...), data...)
+}
+
+func mkvHeader(seconds float64, width, height uint16) []byte {
+	duration := binary.BigEndian.AppendUint64(nil, math.Float64bits(seconds*1000))
+	video := ebml(mkvVideo, ebml(mkvPixelWidth, binary.BigEndian.AppendUint16(nil, width)), ebml(mkvPixelHeight, binary.BigEndian.AppendUint16(nil, height)))
+	return append(ebml(0x1A45DFA3, []byte{0x42, 0x82, 0x84, 'w', 'e', 'b', 'm'}),
+		ebml(mkvSegment,
+			ebml(mkvInfo, ebml(mkvDuration, duration)),
+			ebml(mkvTracks, ebml(mkvTrackEntry, video)),
+			ebml(mkvCluster, make([]byte, 16)))...)
+}
+
+func TestProbeVideo(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		data []byte
+		want videoInfo
+	}{
+		{name: "clip.mp4", data: mp4Header(600, 6000, 1920, 1080), want: videoInfo{duration: 10 * time.Second, width: 1920, height: 1080}},
+		{name: "film.mkv", data: mkvHeader(5400, 3840, 2160), want: videoInfo{duration: 90 * time.Minute, width: 3840, height: 2160}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := probeVideo(path)
+			if err != nil {
+				t.Fatalf("probe: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, []byte("not a video at all"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := probeVideo(path); err == nil {
+		t.Fatal("expected an error for a file that is not a video")
+	}
+}
+
+func TestProbeVideo_MalformedMatroska(t *testing.T) {
+	dir := t.TempDir()
+	header := []byte{0x1A, 0x45, 0xDF, 0xA3, 0x80}
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{name: "truncated element ID", data: append(header, 0x18, 0x53)},
+		{name: "truncated element size", data: append(header, 0x18, 0x53, 0x80, 0x67, 0x40)},
+		{name: "header straddling its parent", data: append(header, 0x18, 0x53, 0x80, 0x67, 0x81, 0xB0, 0x81, 0x05)},
+		{name: "data straddling its parent", data: append(header, 0x18, 0x53, 0x80, 0x67, 0x82, 0xB0, 0x84, 0x05)},
+		{name: "empty duration", data: append(header, 0x18, 0x53, 0x80, 0x67, 0x85, 0x15, 0x49, 0xA9, 0x66, 0x82, 0x44, 0x89, 0x80)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "clip.mkv")
+			if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := probeVideo(path); err == nil {
+				t.Fatal("expected an error for a malformed file")
+			}
+		})
+	}
+}
+
+func TestPlanner_VideoBuckets(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"clip.mp4": mp4Header(600, 6000, 1920, 1080),
+		"film.mkv": mkvHeader(5400, 3840, 2160),
+		"talk.mp4": mp4Header(600, 600*60*40, 1280, 720),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg, err := loadEmbeddedConfig()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	p := &planner{resolver: newCategoryResolver(cfg), dates: dateResolver{}, cp: &checkpoint{}, events: discardEvents{},
+		buckets: map[string][]VideoBucket{"movies": {
+			{Category: "clips", MaxDuration: 30 * time.Second},
+			{Category: "4k", MinWidth: 3840},
+		}}}
+	plan, err := p.build(t.Context(), dir)
+	if err != nil {
+		t.Fatalf("build plan: %v", err)
+	}
+	want := map[string]string{"clip.mp4": "clips", "film.mkv": "4k", "talk.mp4": "movies"}
+	for _, f := range plan {
+		if f.category != want[f.name] {
+			t.Errorf("%s: got category %q, want %q", f.name, f.category, want[f.name])
+		}
+	}
+	if len(plan) != len(want) {
+		t.Fatalf("expected %d planned files, got %d", len(want), len(plan))
+	}
+}
+
+func TestPlanner_MinDuration(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"blip.mp4":  mp4Header(600, 600, 1920, 1080),
+		"clip.mp4":  mp4Header(600, 6000, 1920, 1080),
+		"other.avi": []byte("no header to read"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg, err := loadEmbeddedConfig()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	p := &planner{resolver: newCategoryResolver(cfg), dates: dateResolver{}, cp: &checkpoint{}, events: discardEvents{},
+		minDurations: map[string]time.Duration{"movies": 2 * time.Second},
+		buckets:      map[string][]VideoBucket{"movies": {{Category: "clips", MaxDuration: 30 * time.Second}}}}
+	plan, err := p.build(t.Context(), dir)
+	if err != nil {
+		t.Fatalf("build plan: %v", err)
+	}
+	want := map[string]string{"clip.mp4": "clips", "other.avi": "movies"}
+	for _, f := range plan {
+		if f.category != want[f.name] {
+			t.Errorf("%s: got category %q, want %q", f.name, f.category, want[f.name])
+		}
+	}
+	if len(plan) != len(want) {
+		t.Fatalf("expected %d planned files, got %d", len(want), len(plan))
+	}
+	if len(p.filtered) != 1 || p.filtered[0].srcPath != filepath.Join(dir, "blip.mp4") || p.filtered[0].reason != "shorter than the 2s of min_duration" {
+		t.Fatalf("unexpected filtered files: %+v", p.filtered)
+	}
+}
@@ -0,0 +1,8 @@
+package engine
+
+// The extended attributes that record where a copy came from, for tools
+// such as getfattr or xattr.
+const (
+	XattrSource = "user.classifier.source"
+	XattrRunID  = "user.classifier.run_id"
+)
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd
+
+package engine
+
+// hasXattrs reports true: without extended attributes on this platform
+// there is nothing to warn about.
+func hasXattrs(string) bool {
+	return true
+}
+
+// setProvenance does nothing; extended attributes are not supported here.
+func setProvenance(string, string, string) error {
+	return nil
+}
@@ -0,0 +1,36 @@
+//go:build linux || darwin || freebsd
+
+package engine
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// hasXattrs reports whether the filesystem of the file at path takes
+// extended attributes.
+func hasXattrs(path string) bool {
+	err := unix.Setxattr(path, XattrRunID, []byte("probe"), 0)
+	return !errors.Is(err, unix.ENOTSUP) && !errors.Is(err, unix.EOPNOTSUPP)
+}
+
+// setProvenance stores the source path and run id of the copy at path in
+// extended attributes. Filesystems without them, and copies that are
+// read-only, are left alone.
+func setProvenance(path, src, runID string) error {
+	for _, attr := range []struct{ name, value string }{
+		{XattrSource, src},
+		{XattrRunID, runID},
+	} {
+		err := unix.Setxattr(path, attr.name, []byte(attr.value), 0)
+		if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.EACCES) || errors.Is(err, unix.EPERM) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("set %s: %w", attr.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+//go:build linux || darwin || freebsd
+
+package engine
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestSetProvenance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(path, []byte("photo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := setProvenance(path, "/media/card/DCIM/photo.jpg", "run-1"); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{XattrSource: "/media/card/DCIM/photo.jpg", XattrRunID: "run-1"} {
+		buf := make([]byte, 256)
+		n, err := unix.Getxattr(path, name, buf)
+		if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP) {
+			t.Skip("no extended attributes on this filesystem")
+		}
+		if err != nil {
+			t.Fatalf("get %s: %v", name, err)
+		}
+		if got := string(buf[:n]); got != want {
+			t.Fatalf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"context"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// zeroCopy copies src from its offset to dst at its offset inside the
+// kernel, with copy_file_range, or sendfile where that is not supported
+// between the two filesystems, so the data never passes through user
+// space. done is false when neither works or either fails part way, or
+// ctx is cancelled; the offsets of both files are then past what was
+// copied, so a plain copy can pick up where it stopped.
+func zeroCopy(ctx context.Context, dst, src *os.File) (done bool) {
+	in, out := int(src.Fd()), int(dst.Fd())
+	rangeOK := true
+	copied := false
+	for ctx.Err() == nil {
+		var n int
+		var err error
+		if rangeOK {
+			n, err = unix.CopyFileRange(in, nil, out, nil, zeroCopyChunk, 0)
+			if err != nil && err != unix.EINTR {
+				// EXDEV across filesystems on older kernels, EINVAL or
+				// EOPNOTSUPP where the filesystem has no support.
+				rangeOK = false
+				continue
+			}
+		} else {
+			n, err = unix.Sendfile(out, in, nil, zeroCopyChunk)
+		}
+		switch {
+		case err == unix.EINTR:
+		case err != nil:
+			return false
+		case n == 0:
+			// Files of procfs and some FUSE mounts read as empty here;
+			// leave those, and empty files, to the plain copy.
+			return copied
+		default:
+			copied = true
+		}
+	}
+	return false
+}
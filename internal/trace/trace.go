@@ -0,0 +1,250 @@
+// Package trace records OpenTelemetry spans of a run and exports them to an
+// OTLP/HTTP collector without depending on the OpenTelemetry SDK.
+package trace
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracer collects OpenTelemetry spans for one run and exports them to an
+// OTLP/HTTP endpoint, JSON encoded, when the run ends. A nil Tracer records
+// nothing.
+type Tracer struct {
+	endpoint string
+	headers  map[string]string
+	service  string
+	version  string
+	traceID  [16]byte
+	// parent is the span id from TRACEPARENT, linking the run into the
+	// caller's trace.
+	parent [8]byte
+
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// Span is one timed operation of a run. A nil Span records nothing.
+type Span struct {
+	t      *Tracer
+	id     [8]byte
+	parent [8]byte
+	name   string
+	start  time.Time
+	end    time.Time
+	attrs  map[string]any
+	err    error
+}
+
+type spanKey struct{}
+type tracerKey struct{}
+
+// Endpoint resolves the traces URL from -otlp-endpoint or the standard
+// OTEL_EXPORTER_OTLP_* variables; empty means tracing is off.
+func Endpoint(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); v != "" {
+		return v
+	}
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); v != "" {
+		return strings.TrimSuffix(v, "/") + "/v1/traces"
+	}
+	return ""
+}
+
+// New returns a Tracer exporting to endpoint. It honours OTEL_SERVICE_NAME,
+// OTEL_EXPORTER_OTLP_HEADERS and, to join the caller's trace, TRACEPARENT.
+func New(endpoint, version string) *Tracer {
+	t := &Tracer{endpoint: endpoint, headers: make(map[string]string), service: "classifier", version: version}
+	if v := os.Getenv("OTEL_SERVICE_NAME"); v != "" {
+		t.service = v
+	}
+	for _, h := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		if k, v, ok := strings.Cut(h, "="); ok {
+			t.headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	// TRACEPARENT is version-traceid-spanid-flags, as in W3C Trace Context.
+	if parts := strings.Split(os.Getenv("TRACEPARENT"), "-"); len(parts) == 4 {
+		tid, err1 := hex.DecodeString(parts[1])
+		sid, err2 := hex.DecodeString(parts[2])
+		if err1 == nil && err2 == nil && len(tid) == 16 && len(sid) == 8 {
+			copy(t.traceID[:], tid)
+			copy(t.parent[:], sid)
+			return t
+		}
+	}
+	rand.Read(t.traceID[:])
+	return t
+}
+
+// WithTracer returns a context in which Start records spans to t.
+func WithTracer(ctx context.Context, t *Tracer) context.Context {
+	if t == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// Start begins a span as a child of the span in ctx, if any.
+func Start(ctx context.Context, name string, attrs map[string]any) (context.Context, *Span) {
+	t, _ := ctx.Value(tracerKey{}).(*Tracer)
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{t: t, name: name, start: time.Now(), attrs: attrs, parent: t.parent}
+	if p, ok := ctx.Value(spanKey{}).(*Span); ok {
+		s.parent = p.id
+	}
+	rand.Read(s.id[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Set adds an attribute to the span.
+func (s *Span) Set(key string, value any) {
+	if s == nil {
+		return
+	}
+	if s.attrs == nil {
+		s.attrs = make(map[string]any)
+	}
+	s.attrs[key] = value
+}
+
+// Finish ends the span, marking it failed when err is not nil.
+func (s *Span) Finish(err error) {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	s.err = err
+	s.t.mu.Lock()
+	s.t.spans = append(s.t.spans, s)
+	s.t.mu.Unlock()
+}
+
+// maxSpansPerRequest keeps export requests to a reasonable size.
+const maxSpansPerRequest = 1000
+
+// Export sends every finished span to the collector.
+func (t *Tracer) Export(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+
+	for len(spans) > 0 {
+		n := min(len(spans), maxSpansPerRequest)
+		if err := t.post(ctx, spans[:n]); err != nil {
+			return err
+		}
+		spans = spans[n:]
+	}
+	return nil
+}
+
+func (t *Tracer) post(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(t.payload(spans))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("export traces: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("export traces: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("export traces: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// payload builds an ExportTraceServiceRequest in OTLP's JSON mapping.
+func (t *Tracer) payload(spans []*Span) map[string]any {
+	out := make([]map[string]any, len(spans))
+	for i, s := range spans {
+		js := map[string]any{
+			"traceId":           hex.EncodeToString(t.traceID[:]),
+			"spanId":            hex.EncodeToString(s.id[:]),
+			"name":              s.name,
+			"kind":              1, // SPAN_KIND_INTERNAL
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attrs),
+		}
+		if s.parent != ([8]byte{}) {
+			js["parentSpanId"] = hex.EncodeToString(s.parent[:])
+		}
+		if s.err != nil {
+			js["status"] = map[string]any{"code": 2, "message": s.err.Error()} // STATUS_CODE_ERROR
+		}
+		out[i] = js
+	}
+	return map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": otlpAttributes(map[string]any{
+				"service.name":    t.service,
+				"service.version": t.version,
+			})},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "github.com/sky0621/classifier"},
+				"spans": out,
+			}},
+		}},
+	}
+}
+
+func otlpAttributes(attrs map[string]any) []map[string]any {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]map[string]any, 0, len(attrs))
+	for _, k := range keys {
+		v := attrs[k]
+		var value map[string]any
+		switch v := v.(type) {
+		case string:
+			value = map[string]any{"stringValue": v}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, map[string]any{"key": k, "value": value})
+	}
+	return out
+}
@@ -0,0 +1,147 @@
+// Package classify sorts files into category and date folders with the same
+// rules, deduplication and reports as the classifier command, for programs
+// that embed it.
+//
+//	c, err := classify.NewClassifier(classify.WithWorkers(4))
+//	if err != nil {
+//		return err
+//	}
+//	res, err := c.Run(ctx, "/media/card", "/archive")
+package classify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+// Classifier copies files from a source directory into a destination
+// according to its options. A Classifier may be reused for several runs.
+type Classifier struct {
+	cfg       Config
+	hasConfig bool
+	workers   int
+	hash      HashAlgorithm
+	dryRun    bool
+
+	categories CategoryResolver
+	dates      DateResolver
+	progress   func(Event)
+}
+
+// NewClassifier returns a Classifier configured by opts.
+func NewClassifier(opts ...Option) (*Classifier, error) {
+	c := &Classifier{workers: 1, hash: SHA256}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if !c.hasConfig {
+		cfg, err := LoadConfig("")
+		if err != nil {
+			return nil, err
+		}
+		c.cfg = cfg
+	}
+	if c.workers < 1 {
+		return nil, fmt.Errorf("%w: workers must be at least 1, got %d", ErrInvalidOption, c.workers)
+	}
+	if c.hash != SHA256 && c.hash != SHA512 && c.hash != SHA256Tree {
+		return nil, fmt.Errorf("%w: unknown hash algorithm %q", ErrInvalidOption, c.hash)
+	}
+	return c, nil
+}
+
+// Result sums up a run. Files placed in several destinations are counted
+// once per destination.
+type Result struct {
+	// RunID names the run in the destination's audit log; it is empty
+	// for dry runs.
+	RunID string
+
+	Copied      int
+	BytesCopied int64
+	// Duplicates had the same content as a file copied earlier in the
+	// run; Present were already in the destination.
+	Duplicates int
+	Present    int
+	// Small counts images skipped for being too small.
+	Small  int
+	Failed int
+
+	// Failures lists the files that could not be placed.
+	Failures []*CopyError
+}
+
+// Run classifies every file below src into dest. Both must be absolute
+// paths, or Run returns ErrNotAbsolute. Files that cannot be copied are
+// listed in the Result as CopyErrors rather than ending the run; the error
+// reports problems that stopped it, such as ErrSourceNotDir. The Result
+// covers what was done up to that point either way.
+func (c *Classifier) Run(ctx context.Context, src, dest string) (Result, error) {
+	var events engine.Sink
+	if c.progress != nil {
+		events = progressSink(c.progress)
+	}
+	res, err := c.run(ctx, src, dest, c.dryRun, events)
+	out := newResult(res)
+	if c.progress != nil {
+		c.progress(Event{Type: RunFinished, Err: err, Result: &out})
+	}
+	return out, err
+}
+
+func (c *Classifier) run(ctx context.Context, src, dest string, dryRun bool, events engine.Sink) (engine.Result, error) {
+	if !filepath.IsAbs(src) || !filepath.IsAbs(dest) {
+		return engine.Result{}, ErrNotAbsolute
+	}
+	cfg := c.cfg.engineConfig()
+	hash, err := configHash(cfg)
+	if err != nil {
+		return engine.Result{}, err
+	}
+	return engine.Run(ctx, engine.Options{
+		Config:     cfg,
+		Categories: c.categories,
+		Dates:      c.dates,
+		Events:     events,
+		Source:     src,
+		Dest:       dest,
+		DryRun:     dryRun,
+		Workers:    c.workers,
+		Hash:       string(c.hash),
+		ConfigHash: hash,
+	})
+}
+
+func newResult(res engine.Result) Result {
+	out := Result{
+		RunID:       res.RunID,
+		Copied:      res.Copied,
+		BytesCopied: res.BytesCopied,
+		Duplicates:  res.Duplicates,
+		Present:     res.Present,
+		Small:       res.Small,
+		Failed:      res.Failed,
+	}
+	for _, f := range res.Failures {
+		out.Failures = append(out.Failures, &CopyError{Src: f.Src, Dest: f.Dest, Err: f.Err})
+	}
+	return out
+}
+
+// configHash identifies the config in the audit log, like the hash of the
+// config file does for the command.
+func configHash(cfg engine.Config) (string, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("encode config: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,223 @@
+package classify
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClassifierRun(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "doc")
+	writeFile(t, src, "bravo.txt", "doc")
+	writeFile(t, src, "clip.mov", "movie")
+	writeFile(t, src, "notes.bin", "other")
+
+	cfg := Config{
+		Categories: []Category{
+			{Name: "documents", Extensions: []string{"txt"}},
+			{Name: "movies", Extensions: []string{"mov"}},
+		},
+		DefaultCategory: "misc",
+	}
+	c, err := NewClassifier(WithConfig(cfg), WithWorkers(3), WithHashAlgorithm(SHA512))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.Run(t.Context(), src, dest)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Copied != 3 || res.Duplicates != 1 || res.BytesCopied != 13 || res.Failed != 0 || res.RunID == "" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	for _, p := range []string{"documents/alpha.txt", "movies/clip.mov", "misc/notes.bin"} {
+		if _, err := os.Stat(filepath.Join(dest, p)); err != nil {
+			t.Fatalf("expected %s in destination: %v", p, err)
+		}
+	}
+
+	res, err = c.Run(t.Context(), src, dest)
+	if err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if res.Copied != 0 || res.Present != 3 {
+		t.Fatalf("second run must find everything present: %+v", res)
+	}
+}
+
+func TestClassifierDryRunWritesNothing(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "doc")
+
+	c, err := NewClassifier(WithDryRun(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.Run(t.Context(), src, dest)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Copied != 1 || res.RunID != "" {
+		t.Fatalf("unexpected dry-run result: %+v", res)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatalf("dry run must not create the destination, got %v", err)
+	}
+}
+
+func TestClassifierRun_CustomResolvers(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "invoice-7.txt", "invoice")
+	writeFile(t, src, "notes.txt", "notes")
+	writeFile(t, src, "clip.mov", "movie")
+	writeFile(t, src, "escape.dat", "escape")
+
+	cfg := Config{
+		Categories: []Category{
+			{Name: "documents", Extensions: []string{"txt"}},
+			{Name: "movies", Extensions: []string{"mov"}},
+		},
+		DefaultCategory: "misc",
+	}
+	known := CategoryResolverFunc(func(path string) (string, bool) {
+		switch {
+		case strings.HasPrefix(filepath.Base(path), "invoice-"):
+			return "invoices", true
+		case filepath.Ext(path) == ".dat":
+			return "../outside", true
+		}
+		return "", false
+	})
+	dates := DateResolverFunc(func(string) (int, time.Month, bool) {
+		return 2023, time.March, true
+	})
+	c, err := NewClassifier(
+		WithConfig(cfg),
+		WithCategoryResolver(ChainCategories(known, ExtensionCategories(cfg))),
+		WithDateResolver(dates),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.Run(t.Context(), src, dest)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Copied != 3 || res.Failed != 1 || len(res.Failures) != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if got := res.Failures[0]; got.Src != filepath.Join(src, "escape.dat") || got.Err == nil {
+		t.Fatalf("expected the invalid category to fail escape.dat, got %v", got)
+	}
+	for _, p := range []string{"invoices/invoice-7.txt", "documents/notes.txt", "movies/2023/202303/clip.mov"} {
+		if _, err := os.Stat(filepath.Join(dest, p)); err != nil {
+			t.Fatalf("expected %s in destination: %v", p, err)
+		}
+	}
+}
+
+func TestClassifierRun_ReportsProgress(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "doc")
+	writeFile(t, src, "bravo.txt", "doc")
+
+	var events []Event
+	c, err := NewClassifier(WithProgress(func(ev Event) { events = append(events, ev) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Run(t.Context(), src, dest); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	counts := map[EventType]int{}
+	for _, ev := range events {
+		counts[ev.Type]++
+		if ev.Type == FileSkipped && ev.Reason != ReasonDuplicate {
+			t.Fatalf("unexpected skip reason: %+v", ev)
+		}
+	}
+	if counts[FileStarted] != 2 || counts[FileClassified] != 2 || counts[FileCopied] != 1 || counts[FileSkipped] != 1 {
+		t.Fatalf("unexpected events: %v", counts)
+	}
+	last := events[len(events)-1]
+	if last.Type != RunFinished || last.Err != nil || last.Result == nil || last.Result.Copied != 1 {
+		t.Fatalf("expected the run summary last, got %+v", last)
+	}
+}
+
+func TestNewClassifier_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  Option
+	}{
+		{"zero workers", WithWorkers(0)},
+		{"unknown hash", WithHashAlgorithm("md4")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewClassifier(tt.opt); !errors.Is(err, ErrInvalidOption) {
+				t.Fatalf("expected ErrInvalidOption, got %v", err)
+			}
+		})
+	}
+}
+
+func TestClassifierRun_RejectsRelativePaths(t *testing.T) {
+	var events []Event
+	c, err := NewClassifier(WithProgress(func(ev Event) { events = append(events, ev) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Run(t.Context(), "src", t.TempDir()); !errors.Is(err, ErrNotAbsolute) {
+		t.Fatalf("expected ErrNotAbsolute, got %v", err)
+	}
+	if len(events) != 1 || events[0].Type != RunFinished || !errors.Is(events[0].Err, ErrNotAbsolute) || events[0].Result == nil {
+		t.Fatalf("expected only a RunFinished event carrying the error, got %+v", events)
+	}
+}
+
+func TestClassifierRun_SourceNotDir(t *testing.T) {
+	workspace := t.TempDir()
+	writeFile(t, workspace, "file.txt", "doc")
+
+	c, err := NewClassifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Run(t.Context(), filepath.Join(workspace, "file.txt"), filepath.Join(workspace, "dest"))
+	if !errors.Is(err, ErrSourceNotDir) {
+		t.Fatalf("expected ErrSourceNotDir, got %v", err)
+	}
+}
+
+func mustMkdir(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		t.Fatalf("failed to create directory %s: %v", path, err)
+	}
+}
+
+func writeFile(t *testing.T, dir, name, contents string) {
+	t.Helper()
+	fullPath := filepath.Join(dir, name)
+	if err := os.WriteFile(fullPath, []byte(contents), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", fullPath, err)
+	}
+}
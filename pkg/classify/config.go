@@ -0,0 +1,104 @@
+package classify
+
+import (
+	"time"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+// Config maps file extensions to categories and lists the patterns that
+// date images and movies by their file name. It has the same shape as the
+// YAML config of the classifier command.
+type Config struct {
+	Categories []Category
+	// DefaultCategory receives files whose extension is not listed;
+	// empty means "others".
+	DefaultCategory string
+	// DatePatterns are regular expressions with year and month groups,
+	// tried in order against the file name.
+	DatePatterns []string
+	// DateLayout is the Go time layout of the date folders, "/"
+	// separating nested folders; empty means "2006/200601".
+	DateLayout string
+	// Aliases maps former category names to current ones, e.g. "photos"
+	// to "images", so files already filed under a former name are not
+	// copied again.
+	Aliases map[string]string
+	// Exclude holds name patterns, as for filepath.Match, of the source
+	// files and directories to leave out, e.g. "*.tmp".
+	Exclude []string
+}
+
+// Category is a destination folder and the extensions that go to it.
+type Category struct {
+	Name       string
+	Extensions []string
+	// Root, when set, is the absolute directory the category's folder is
+	// created in instead of the destination passed to Run.
+	Root string
+	// PostCommand is run on every file copied into the category, with
+	// {dest}, {src} and {category} replaced in each argument.
+	// OnPostFailure is "warn" (the default), "fail" or "stop".
+	PostCommand   []string
+	OnPostFailure string
+	// Layout "alphabetical" files undated files in a folder per first
+	// letter of their name.
+	Layout string
+	// VideoBuckets send the category's videos that match one of them, the
+	// first that does, to the bucket's category instead.
+	VideoBuckets []VideoBucket
+	// MinBytes skips the category's files smaller than it, and
+	// MinBytesByExtension the files of single extensions instead.
+	MinBytes            int64
+	MinBytesByExtension map[string]int64
+	// MinDuration skips the category's videos shorter than it.
+	MinDuration time.Duration
+}
+
+// VideoBucket matches videos by the duration and frame size in their MP4,
+// MOV or Matroska header; zero limits are not checked.
+type VideoBucket struct {
+	Category    string
+	MinDuration time.Duration
+	// MaxDuration matches videos shorter than it.
+	MaxDuration time.Duration
+	MinWidth    int
+	MinHeight   int
+}
+
+// LoadConfig reads and validates a YAML config file, or fetches it when
+// path is an http(s) URL; a "#sha256=<hex>" fragment pins the content the
+// URL must serve. An empty path returns the built-in default config.
+func LoadConfig(path string) (Config, error) {
+	cfg, err := engine.LoadConfig(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return fromEngineConfig(cfg), nil
+}
+
+func fromEngineConfig(cfg engine.Config) Config {
+	out := Config{DefaultCategory: cfg.DefaultCategory, DatePatterns: cfg.DatePatterns, DateLayout: cfg.DateLayout, Aliases: cfg.Aliases, Exclude: cfg.Exclude}
+	for _, c := range cfg.Categories {
+		cat := Category{Name: c.Name, Extensions: c.Extensions, Root: c.Root, PostCommand: c.PostCommand, OnPostFailure: c.OnPostFailure, Layout: c.Layout,
+			MinBytes: c.MinBytes, MinBytesByExtension: c.MinBytesByExtension, MinDuration: c.MinDuration}
+		for _, b := range c.VideoBuckets {
+			cat.VideoBuckets = append(cat.VideoBuckets, VideoBucket(b))
+		}
+		out.Categories = append(out.Categories, cat)
+	}
+	return out
+}
+
+func (c Config) engineConfig() engine.Config {
+	out := engine.Config{DefaultCategory: c.DefaultCategory, DatePatterns: c.DatePatterns, DateLayout: c.DateLayout, Aliases: c.Aliases, Exclude: c.Exclude}
+	for _, cat := range c.Categories {
+		ec := engine.Category{Name: cat.Name, Extensions: cat.Extensions, Root: cat.Root, PostCommand: cat.PostCommand, OnPostFailure: cat.OnPostFailure, Layout: cat.Layout,
+			MinBytes: cat.MinBytes, MinBytesByExtension: cat.MinBytesByExtension, MinDuration: cat.MinDuration}
+		for _, b := range cat.VideoBuckets {
+			ec.VideoBuckets = append(ec.VideoBuckets, engine.VideoBucket(b))
+		}
+		out.Categories = append(out.Categories, ec)
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+package classify
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+var (
+	// ErrNotAbsolute is returned by Run for a relative source or
+	// destination path.
+	ErrNotAbsolute = errors.New("source and destination must be absolute paths")
+	// ErrSourceNotDir is returned by Run when the source is not a
+	// directory.
+	ErrSourceNotDir = engine.ErrSourceNotDir
+	// ErrInvalidOption is returned by NewClassifier for an option value
+	// it cannot use.
+	ErrInvalidOption = errors.New("invalid option")
+)
+
+// CopyError is a file that could not be placed in the destination. Dest is
+// empty when the file failed before a destination path was chosen.
+type CopyError struct {
+	Src  string
+	Dest string
+	Err  error
+}
+
+func (e *CopyError) Error() string {
+	if e.Dest == "" {
+		return fmt.Sprintf("%s: %v", e.Src, e.Err)
+	}
+	return fmt.Sprintf("copy %s to %s: %v", e.Src, e.Dest, e.Err)
+}
+
+func (e *CopyError) Unwrap() error { return e.Err }
@@ -0,0 +1,76 @@
+package classify
+
+// Option configures a Classifier.
+type Option func(*Classifier)
+
+// HashAlgorithm is the content hash duplicates are detected by.
+type HashAlgorithm string
+
+// Supported hash algorithms.
+const (
+	SHA256 HashAlgorithm = "sha256"
+	SHA512 HashAlgorithm = "sha512"
+	// SHA256Tree hashes files in 4 MiB leaves, so files of 64 MiB and
+	// more are hashed on every core. Its digests differ from SHA256.
+	SHA256Tree HashAlgorithm = "sha256-tree"
+)
+
+// WithConfig sets the category and date rules. Without it the built-in
+// default config is used.
+func WithConfig(cfg Config) Option {
+	return func(c *Classifier) {
+		c.cfg = cfg
+		c.hasConfig = true
+	}
+}
+
+// WithWorkers hashes up to n source files at once. The default of 1 hashes
+// them one after another; the order files are copied in does not change.
+func WithWorkers(n int) Option {
+	return func(c *Classifier) {
+		c.workers = n
+	}
+}
+
+// WithHashAlgorithm selects the content hash, SHA256 by default. Files
+// already in the destination are compared with the same algorithm.
+func WithHashAlgorithm(alg HashAlgorithm) Option {
+	return func(c *Classifier) {
+		c.hash = alg
+	}
+}
+
+// WithDryRun makes Run decide everything without writing to the
+// destination; the Result reports what would have happened.
+func WithDryRun(dryRun bool) Option {
+	return func(c *Classifier) {
+		c.dryRun = dryRun
+	}
+}
+
+// WithCategoryResolver decides categories with r instead of the extensions
+// in the config. Use ChainCategories with ExtensionCategories to fall back
+// to them for the files r has no answer for.
+func WithCategoryResolver(r CategoryResolver) Option {
+	return func(c *Classifier) {
+		c.categories = r
+	}
+}
+
+// WithDateResolver dates images and movies with r instead of the date
+// patterns in the config. Use ChainDates with FilenameDates to fall back
+// to them.
+func WithDateResolver(r DateResolver) Option {
+	return func(c *Classifier) {
+		c.dates = r
+	}
+}
+
+// WithProgress calls fn for every file the run deals with and, last, with
+// a RunFinished event. Calls are made one at a time from the goroutine
+// running Run, so fn should return quickly.
+func WithProgress(fn func(Event)) Option {
+	return func(c *Classifier) {
+		c.progress = fn
+	}
+}
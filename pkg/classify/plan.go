@@ -0,0 +1,60 @@
+package classify
+
+import "context"
+
+// ActionKind says what a run would do with a file.
+type ActionKind string
+
+// Action kinds.
+const (
+	Copy          ActionKind = "copy"
+	SkipDuplicate ActionKind = "skip-duplicate"
+	SkipPresent   ActionKind = "skip-present"
+	SkipSmall     ActionKind = "skip-small"
+	SkipFiltered  ActionKind = "skip-filtered"
+	Error         ActionKind = "error"
+)
+
+// Action is the decision a run would take on one source file.
+type Action struct {
+	Kind ActionKind
+	Src  string
+	// Dest is where the file would be copied to for Copy, and the file
+	// it matches for SkipDuplicate and SkipPresent.
+	Dest     string
+	Category string
+	Size     int64
+	// Err is set for Error.
+	Err error
+}
+
+// Plan decides what Run would do with every file below src without
+// writing anything, and returns the actions in the order Run would take
+// them. The error reports problems that would stop the run.
+func (c *Classifier) Plan(ctx context.Context, src, dest string) ([]Action, error) {
+	var actions []Action
+	collect := progressSink(func(ev Event) {
+		a := Action{Src: ev.Src, Dest: ev.Dest, Category: ev.Category, Size: ev.Size, Err: ev.Err}
+		switch {
+		case ev.Type == FileCopied:
+			a.Kind = Copy
+		case ev.Type == FileFailed:
+			a.Kind = Error
+		case ev.Reason == ReasonDuplicate:
+			a.Kind = SkipDuplicate
+		case ev.Reason == ReasonPresent:
+			a.Kind = SkipPresent
+		case ev.Reason == ReasonSmall:
+			a.Kind = SkipSmall
+		case ev.Reason == ReasonFiltered:
+			a.Kind = SkipFiltered
+		default:
+			return
+		}
+		actions = append(actions, a)
+	})
+	if _, err := c.run(ctx, src, dest, true, collect); err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
@@ -0,0 +1,48 @@
+package classify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClassifierPlan(t *testing.T) {
+	workspace := t.TempDir()
+	src := filepath.Join(workspace, "src")
+	dest := filepath.Join(workspace, "dest")
+	mustMkdir(t, src)
+	writeFile(t, src, "alpha.txt", "doc")
+	writeFile(t, src, "bravo.txt", "doc")
+	writeFile(t, src, "tiny.jpg", "img")
+
+	c, err := NewClassifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions, err := c.Plan(t.Context(), src, dest)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+
+	got := map[string]Action{}
+	for _, a := range actions {
+		got[filepath.Base(a.Src)] = a
+	}
+	alpha := filepath.Join(dest, "documents", "alpha.txt")
+	want := map[string]Action{
+		"alpha.txt": {Kind: Copy, Src: filepath.Join(src, "alpha.txt"), Dest: alpha, Category: "documents", Size: 3},
+		"bravo.txt": {Kind: SkipDuplicate, Src: filepath.Join(src, "bravo.txt"), Dest: alpha, Category: "documents", Size: 3},
+		"tiny.jpg":  {Kind: SkipSmall, Src: filepath.Join(src, "tiny.jpg"), Category: "images", Size: 3},
+	}
+	if len(actions) != len(want) {
+		t.Fatalf("expected %d actions, got %+v", len(want), actions)
+	}
+	for name, w := range want {
+		if got[name] != w {
+			t.Fatalf("unexpected action for %s:\ngot  %+v\nwant %+v", name, got[name], w)
+		}
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatalf("plan must not create the destination, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package classify
+
+import "github.com/sky0621/classifier/internal/engine"
+
+// EventType says what an Event reports.
+type EventType string
+
+// Event types, in the order a file goes through them.
+const (
+	// FileStarted is sent when a source file is found.
+	FileStarted EventType = "started"
+	// FileClassified is sent once a file is hashed and will be placed;
+	// counting them gives the total for a progress bar.
+	FileClassified EventType = "classified"
+	FileCopied     EventType = "copied"
+	FileSkipped    EventType = "skipped"
+	FileFailed     EventType = "failed"
+	// RunFinished is the last event of a run and carries its Result.
+	RunFinished EventType = "finished"
+)
+
+// Reasons a file is skipped, found in Event.Reason.
+const (
+	// ReasonDuplicate is a file with the same content as one placed
+	// earlier in the run.
+	ReasonDuplicate = "duplicate"
+	// ReasonPresent is a file already in the destination.
+	ReasonPresent = "present"
+	// ReasonSmall is an image too small to keep.
+	ReasonSmall = "small"
+	// ReasonFiltered is a file left out by a filter.
+	ReasonFiltered = "filtered"
+)
+
+// Event reports progress on one file, or the end of the run.
+type Event struct {
+	Type     EventType
+	Src      string
+	Dest     string
+	Category string
+	Size     int64
+	// Reason says why a FileSkipped file was skipped.
+	Reason string
+	// Err is a *CopyError for FileFailed, or for RunFinished what
+	// stopped the run.
+	Err error
+	// Result is set for RunFinished.
+	Result *Result
+}
+
+// progressSink hands the engine's events to a WithProgress callback.
+type progressSink func(Event)
+
+func (fn progressSink) Emit(ev engine.Event) {
+	out := Event{Src: ev.Src, Dest: ev.Dest, Category: ev.Category, Size: ev.Size}
+	switch ev.Type {
+	case engine.EventDiscovered:
+		out.Type = FileStarted
+	case engine.EventClassified:
+		out.Type = FileClassified
+	case engine.EventCopied:
+		out.Type = FileCopied
+	case engine.EventSkippedDuplicate:
+		out.Type, out.Reason = FileSkipped, ReasonDuplicate
+	case engine.EventSkippedPresent:
+		out.Type, out.Reason = FileSkipped, ReasonPresent
+	case engine.EventSkippedSmall:
+		out.Type, out.Reason = FileSkipped, ReasonSmall
+	case engine.EventSkippedFiltered:
+		out.Type, out.Reason = FileSkipped, ReasonFiltered
+	case engine.EventError:
+		out.Type, out.Err = FileFailed, &CopyError{Src: ev.Src, Dest: ev.Dest, Err: ev.Err}
+	default:
+		return
+	}
+	fn(out)
+}
+
+func (progressSink) Finish() {}
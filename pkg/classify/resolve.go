@@ -0,0 +1,71 @@
+package classify
+
+import (
+	"time"
+
+	"github.com/sky0621/classifier/internal/engine"
+)
+
+// CategoryResolver decides which category a source file belongs to. ok is
+// false when it has no answer, leaving the file to the next resolver of a
+// chain or, failing all of them, to the default category of the config.
+// The category becomes a folder below the destination, so it must be a
+// single folder name.
+type CategoryResolver interface {
+	ResolveCategory(path string) (category string, ok bool)
+}
+
+// DateResolver dates the files of the images and movies categories so they
+// are filed below year/yyyymm. ok is false when it cannot tell, which
+// leaves the file directly in its category folder.
+type DateResolver interface {
+	ResolveDate(path string) (year int, month time.Month, ok bool)
+}
+
+// CategoryResolverFunc adapts a function to CategoryResolver.
+type CategoryResolverFunc func(path string) (string, bool)
+
+// ResolveCategory calls f(path).
+func (f CategoryResolverFunc) ResolveCategory(path string) (string, bool) { return f(path) }
+
+// DateResolverFunc adapts a function to DateResolver.
+type DateResolverFunc func(path string) (int, time.Month, bool)
+
+// ResolveDate calls f(path).
+func (f DateResolverFunc) ResolveDate(path string) (int, time.Month, bool) { return f(path) }
+
+// ChainCategories asks each resolver in turn and returns the first answer.
+func ChainCategories(resolvers ...CategoryResolver) CategoryResolver {
+	return CategoryResolverFunc(func(path string) (string, bool) {
+		for _, r := range resolvers {
+			if cat, ok := r.ResolveCategory(path); ok {
+				return cat, true
+			}
+		}
+		return "", false
+	})
+}
+
+// ChainDates asks each resolver in turn and returns the first answer.
+func ChainDates(resolvers ...DateResolver) DateResolver {
+	return DateResolverFunc(func(path string) (int, time.Month, bool) {
+		for _, r := range resolvers {
+			if year, month, ok := r.ResolveDate(path); ok {
+				return year, month, true
+			}
+		}
+		return 0, 0, false
+	})
+}
+
+// ExtensionCategories is the built-in CategoryResolver, which looks the
+// file extension up in cfg.Categories.
+func ExtensionCategories(cfg Config) CategoryResolver {
+	return engine.ExtensionCategories(cfg.engineConfig())
+}
+
+// FilenameDates is the built-in DateResolver, which matches file names
+// against patterns like Config.DatePatterns.
+func FilenameDates(patterns []string) (DateResolver, error) {
+	return engine.FilenameDates(patterns)
+}